@@ -121,12 +121,15 @@ func mainRun() exitCode {
 	cmd.AddCommand(commands.NewQuestionCommand(c))
 	cmd.AddCommand(commands.NewChatCommand(c))
 	cmd.AddCommand(commands.NewHistoryCommand(c))
+	cmd.AddCommand(commands.NewReplayCommand(c))
 	cmd.AddCommand(commands.NewConfigCommand(c))
 	cmd.AddCommand(commands.NewToolCommand(c))
 	cmd.AddCommand(commands.NewTaskCommand(c))
 	cmd.AddCommand(commands.NewRoutineCommand(c))
 	cmd.AddCommand(commands.NewDaemonCommand(c))
 	cmd.AddCommand(commands.NewMemoryCommand())
+	cmd.AddCommand(commands.NewIndexCommand(c))
+	cmd.AddCommand(commands.NewContextCommand(c))
 	cmd.AddCommand(commands.NewAuthCommand())
 	cmd.AddCommand(commands.NewPluginCommand())
 
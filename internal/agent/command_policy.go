@@ -0,0 +1,283 @@
+package agent
+
+import (
+	"maps"
+	"strings"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+)
+
+// commandRegistry is the declarative command-policy registry consulted for
+// programs outside the hard-coded read-only allowlist. A nil registry uses the
+// built-in policies.
+type commandRegistry map[string]config.CommandPolicy
+
+// builtinCommandPolicies classify common developer tools whose read-only
+// subcommands would otherwise always prompt. Anything not listed falls back to
+// the program default, which is write unless stated.
+var builtinCommandPolicies = map[string]config.CommandPolicy{
+	"git": {
+		Flags: map[string]string{
+			// -c can set hooks, pagers and other programs git will execute.
+			"-c":          config.CommandAccessWrite,
+			"--exec-path": config.CommandAccessWrite,
+			"--output":    config.CommandAccessWrite,
+			"--ext-diff":  config.CommandAccessWrite,
+		},
+		Subcommands: map[string]config.CommandSubcommand{
+			"blame":    {Access: config.CommandAccessRead},
+			"cat-file": {Access: config.CommandAccessRead},
+			"describe": {Access: config.CommandAccessRead},
+			"diff":     {Access: config.CommandAccessRead},
+			// -O opens the matching files in a pager, which can be any program.
+			"grep": {Access: config.CommandAccessRead, Flags: map[string]string{
+				"-O":                    config.CommandAccessWrite,
+				"--open-files-in-pager": config.CommandAccessWrite,
+			}},
+			"log":           {Access: config.CommandAccessRead},
+			"ls-files":      {Access: config.CommandAccessRead},
+			"ls-tree":       {Access: config.CommandAccessRead},
+			"reflog":        {Access: config.CommandAccessRead},
+			"reflog delete": {Access: config.CommandAccessWrite},
+			"reflog expire": {Access: config.CommandAccessWrite},
+			// Only listing remotes and reading their URLs is read; every other
+			// remote subcommand is listed so none falls back to the listing.
+			"remote":              {Access: config.CommandAccessRead},
+			"remote get-url":      {Access: config.CommandAccessRead},
+			"remote show":         {Access: config.CommandAccessRead},
+			"remote add":          {Access: config.CommandAccessWrite},
+			"remote prune":        {Access: config.CommandAccessNetwork},
+			"remote remove":       {Access: config.CommandAccessWrite},
+			"remote rename":       {Access: config.CommandAccessWrite},
+			"remote rm":           {Access: config.CommandAccessWrite},
+			"remote set-branches": {Access: config.CommandAccessWrite},
+			"remote set-head":     {Access: config.CommandAccessWrite},
+			"remote set-url":      {Access: config.CommandAccessWrite},
+			"remote update":       {Access: config.CommandAccessNetwork},
+			"rev-parse":           {Access: config.CommandAccessRead},
+			"shortlog":            {Access: config.CommandAccessRead},
+			"show":                {Access: config.CommandAccessRead},
+			"show-ref":            {Access: config.CommandAccessRead},
+			"stash list":          {Access: config.CommandAccessRead},
+			"stash show":          {Access: config.CommandAccessRead},
+			"status":              {Access: config.CommandAccessRead},
+			"worktree list":       {Access: config.CommandAccessRead},
+			"fetch":               {Access: config.CommandAccessNetwork},
+			"ls-remote":           {Access: config.CommandAccessNetwork},
+			"push":                {Access: config.CommandAccessNetwork},
+		},
+	},
+	"go": {
+		Subcommands: map[string]config.CommandSubcommand{
+			"doc": {Access: config.CommandAccessRead},
+			"env": {Access: config.CommandAccessRead, Flags: map[string]string{"-w": config.CommandAccessWrite, "-u": config.CommandAccessWrite}},
+			// -toolexec and -exec run programs, -export and -compiled build,
+			// and -mod, -modfile and -overlay can rewrite go.mod or read
+			// files from outside the module. Flags not listed here are
+			// write.
+			"list": {Access: config.CommandAccessRead, UnlistedFlags: config.CommandAccessWrite, Flags: map[string]string{
+				"-deps":      config.CommandAccessRead,
+				"-e":         config.CommandAccessRead,
+				"-f":         config.CommandAccessRead,
+				"-find":      config.CommandAccessRead,
+				"-json":      config.CommandAccessRead,
+				"-m":         config.CommandAccessRead,
+				"-retracted": config.CommandAccessRead,
+				"-tags":      config.CommandAccessRead,
+				"-test":      config.CommandAccessRead,
+				"-versions":  config.CommandAccessRead,
+				"-u":         config.CommandAccessNetwork,
+				"-compiled":  config.CommandAccessWrite,
+				"-exec":      config.CommandAccessWrite,
+				"-export":    config.CommandAccessWrite,
+				"-mod":       config.CommandAccessWrite,
+				"-modfile":   config.CommandAccessWrite,
+				"-overlay":   config.CommandAccessWrite,
+				"-toolexec":  config.CommandAccessWrite,
+			}},
+			"mod graph": {Access: config.CommandAccessRead},
+			"mod why":   {Access: config.CommandAccessRead},
+			"version":   {Access: config.CommandAccessRead},
+		},
+	},
+	"kubectl": {
+		Subcommands: map[string]config.CommandSubcommand{
+			"api-resources":          {Access: config.CommandAccessRead},
+			"api-versions":           {Access: config.CommandAccessRead},
+			"auth can-i":             {Access: config.CommandAccessRead},
+			"cluster-info":           {Access: config.CommandAccessRead},
+			"config current-context": {Access: config.CommandAccessRead},
+			"config get-contexts":    {Access: config.CommandAccessRead},
+			"config view":            {Access: config.CommandAccessRead},
+			"describe":               {Access: config.CommandAccessRead},
+			"events":                 {Access: config.CommandAccessRead},
+			"explain":                {Access: config.CommandAccessRead},
+			"get":                    {Access: config.CommandAccessRead},
+			"logs":                   {Access: config.CommandAccessRead},
+			"top":                    {Access: config.CommandAccessRead},
+			"version":                {Access: config.CommandAccessRead},
+		},
+	},
+	"docker": {
+		Subcommands: map[string]config.CommandSubcommand{
+			"container ls": {Access: config.CommandAccessRead},
+			"diff":         {Access: config.CommandAccessRead},
+			"history":      {Access: config.CommandAccessRead},
+			"image ls":     {Access: config.CommandAccessRead},
+			"images":       {Access: config.CommandAccessRead},
+			"info":         {Access: config.CommandAccessRead},
+			"inspect":      {Access: config.CommandAccessRead},
+			"logs":         {Access: config.CommandAccessRead},
+			"network ls":   {Access: config.CommandAccessRead},
+			"port":         {Access: config.CommandAccessRead},
+			"ps":           {Access: config.CommandAccessRead},
+			"stats":        {Access: config.CommandAccessRead},
+			"top":          {Access: config.CommandAccessRead},
+			"version":      {Access: config.CommandAccessRead},
+			"volume ls":    {Access: config.CommandAccessRead},
+			"login":        {Access: config.CommandAccessNetwork},
+			"pull":         {Access: config.CommandAccessNetwork},
+			"push":         {Access: config.CommandAccessNetwork},
+			"search":       {Access: config.CommandAccessNetwork},
+		},
+	},
+	"helm": {
+		Subcommands: map[string]config.CommandSubcommand{
+			"get manifest": {Access: config.CommandAccessRead},
+			"get values":   {Access: config.CommandAccessRead},
+			"history":      {Access: config.CommandAccessRead},
+			"list":         {Access: config.CommandAccessRead},
+			"repo list":    {Access: config.CommandAccessRead},
+			"status":       {Access: config.CommandAccessRead},
+			"version":      {Access: config.CommandAccessRead},
+			"repo update":  {Access: config.CommandAccessNetwork},
+		},
+	},
+	"systemctl": {
+		Subcommands: map[string]config.CommandSubcommand{
+			"cat":             {Access: config.CommandAccessRead},
+			"is-active":       {Access: config.CommandAccessRead},
+			"is-enabled":      {Access: config.CommandAccessRead},
+			"is-failed":       {Access: config.CommandAccessRead},
+			"list-timers":     {Access: config.CommandAccessRead},
+			"list-unit-files": {Access: config.CommandAccessRead},
+			"list-units":      {Access: config.CommandAccessRead},
+			"show":            {Access: config.CommandAccessRead},
+			"status":          {Access: config.CommandAccessRead},
+		},
+	},
+}
+
+var commandAccessRank = map[string]int{
+	config.CommandAccessRead:    0,
+	config.CommandAccessNetwork: 1,
+	config.CommandAccessWrite:   2,
+}
+
+// newCommandRegistry layers user-configured policies over the built-ins; a
+// configured program replaces the built-in entry as a whole.
+func newCommandRegistry(overrides map[string]config.CommandPolicy) commandRegistry {
+	registry := make(commandRegistry, len(builtinCommandPolicies)+len(overrides))
+	maps.Copy(registry, builtinCommandPolicies)
+	maps.Copy(registry, overrides)
+	return registry
+}
+
+func (r commandRegistry) lookup(program string) (config.CommandPolicy, bool) {
+	if r == nil {
+		policy, ok := builtinCommandPolicies[program]
+		return policy, ok
+	}
+	policy, ok := r[program]
+	return policy, ok
+}
+
+// classify returns the access class of running program with args, and false
+// when the program is not in the registry. The subcommand is the longest
+// listed key formed by the leading positional arguments; flags anywhere on the
+// line can only widen the class.
+func (r commandRegistry) classify(program string, args []string) (string, bool) {
+	policy, ok := r.lookup(program)
+	if !ok {
+		return "", false
+	}
+
+	access := policy.Default
+	if access == "" {
+		access = config.CommandAccessWrite
+	}
+	var subFlags map[string]string
+	var unlisted string
+	positional := commandPositionalArgs(args)
+	for n := len(positional); n > 0; n-- {
+		if sub, ok := policy.Subcommands[strings.Join(positional[:n], " ")]; ok {
+			access = sub.Access
+			subFlags = sub.Flags
+			unlisted = sub.UnlistedFlags
+			break
+		}
+	}
+
+	for _, arg := range args {
+		listed := false
+		for _, flags := range []map[string]string{policy.Flags, subFlags} {
+			for flag, flagAccess := range flags {
+				if commandFlagMatches(flag, arg) {
+					access = widerCommandAccess(access, flagAccess)
+				}
+				listed = listed || commandFlagNamed(flag, arg)
+			}
+		}
+		if unlisted != "" && strings.HasPrefix(arg, "-") && !listed {
+			access = widerCommandAccess(access, unlisted)
+		}
+	}
+	return access, true
+}
+
+// commandPositionalArgs returns the positional arguments a subcommand is looked
+// up by. Options before the first one leave it unnamed; options after it are
+// skipped, so that "git remote -v add" is read as "remote add".
+func commandPositionalArgs(args []string) []string {
+	var positional []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			if len(positional) == 0 {
+				break
+			}
+			continue
+		}
+		positional = append(positional, arg)
+	}
+	return positional
+}
+
+// commandFlagMatches reports whether arg passes flag: by name, or as a short
+// flag inside a cluster such as -fd.
+func commandFlagMatches(flag, arg string) bool {
+	if commandFlagNamed(flag, arg) {
+		return true
+	}
+	if len(flag) == 2 && flag[0] == '-' && len(arg) > 2 && arg[0] == '-' && arg[1] != '-' {
+		return strings.ContainsRune(arg[1:], rune(flag[1]))
+	}
+	return false
+}
+
+// commandFlagNamed reports whether arg is flag itself or flag=value. Go-style
+// single-dash flags such as -toolexec=prog take values the same way.
+func commandFlagNamed(flag, arg string) bool {
+	return arg == flag || (len(flag) > 2 && strings.HasPrefix(arg, flag+"="))
+}
+
+func widerCommandAccess(a, b string) string {
+	rankA, okA := commandAccessRank[a]
+	rankB, okB := commandAccessRank[b]
+	if !okA || !okB {
+		return config.CommandAccessWrite
+	}
+	if rankB > rankA {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandRegistryClassify(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{"git", []string{"log", "--oneline", "-n", "5"}, config.CommandAccessRead},
+		{"git", []string{"remote", "-v"}, config.CommandAccessRead},
+		{"git", []string{"remote", "add", "origin", "url"}, config.CommandAccessWrite},
+		{"git", []string{"diff", "--output=patch.diff"}, config.CommandAccessWrite},
+		{"git", []string{"-c", "core.pager=sh", "log"}, config.CommandAccessWrite},
+		{"git", []string{"commit", "-m", "x"}, config.CommandAccessWrite},
+		{"git", []string{"fetch", "origin"}, config.CommandAccessNetwork},
+		{"git", []string{"grep", "-Ovim", "TODO"}, config.CommandAccessWrite},
+		{"git", []string{"grep", "--open-files-in-pager=sh", "TODO"}, config.CommandAccessWrite},
+		{"git", []string{"grep", "-n", "TODO"}, config.CommandAccessRead},
+		{"git", []string{"log", "--output", "out.txt"}, config.CommandAccessWrite},
+		{"git", []string{"show", "--output=out.txt", "HEAD"}, config.CommandAccessWrite},
+		{"git", []string{"remote", "show", "origin"}, config.CommandAccessRead},
+		{"git", []string{"remote", "get-url", "origin"}, config.CommandAccessRead},
+		{"git", []string{"remote", "set-head", "origin", "main"}, config.CommandAccessWrite},
+		{"git", []string{"remote", "set-branches", "origin", "main"}, config.CommandAccessWrite},
+		{"git", []string{"remote", "set-url", "origin", "url"}, config.CommandAccessWrite},
+		{"git", []string{"remote", "remove", "origin"}, config.CommandAccessWrite},
+		{"git", []string{"remote", "-v", "add", "origin", "url"}, config.CommandAccessWrite},
+		{"go", []string{"list", "./..."}, config.CommandAccessRead},
+		{"go", []string{"list", "-m", "-u", "all"}, config.CommandAccessNetwork},
+		{"go", []string{"list", "-f", "{{.Dir}}", "-deps", "-json=Dir", "./..."}, config.CommandAccessRead},
+		{"go", []string{"list", "-export", "-toolexec=rm", "."}, config.CommandAccessWrite},
+		{"go", []string{"list", "-toolexec", "/tmp/x.sh", "."}, config.CommandAccessWrite},
+		{"go", []string{"list", "--toolexec=rm", "."}, config.CommandAccessWrite},
+		{"go", []string{"list", "-exec=sh", "."}, config.CommandAccessWrite},
+		{"go", []string{"list", "-export", "."}, config.CommandAccessWrite},
+		{"go", []string{"list", "-compiled", "."}, config.CommandAccessWrite},
+		{"go", []string{"list", "-deps", "-export", "."}, config.CommandAccessWrite},
+		{"go", []string{"list", "-mod=mod", "-m", "all"}, config.CommandAccessWrite},
+		{"go", []string{"list", "-modfile=other.mod", "."}, config.CommandAccessWrite},
+		{"go", []string{"list", "-overlay", "overlay.json", "."}, config.CommandAccessWrite},
+		{"go", []string{"list", "-pgo=off", "."}, config.CommandAccessWrite},
+		{"go", []string{"env", "-w", "GOFLAGS=-mod=mod"}, config.CommandAccessWrite},
+		{"kubectl", []string{"get", "pods", "-A"}, config.CommandAccessRead},
+		{"kubectl", []string{"config", "view"}, config.CommandAccessRead},
+		{"kubectl", []string{"config", "use-context", "prod"}, config.CommandAccessWrite},
+		{"docker", []string{"ps", "-a"}, config.CommandAccessRead},
+		{"docker", []string{"pull", "alpine"}, config.CommandAccessNetwork},
+		{"docker", []string{"rm", "abc"}, config.CommandAccessWrite},
+	}
+	var registry commandRegistry
+	for _, tt := range tests {
+		access, known := registry.classify(tt.command, tt.args)
+		assert.True(t, known, tt.command)
+		assert.Equal(t, tt.want, access, "%s %v", tt.command, tt.args)
+	}
+
+	_, known := registry.classify("terraform", []string{"plan"})
+	assert.False(t, known)
+}
+
+func TestCommandRegistryOverridesReplaceBuiltins(t *testing.T) {
+	registry := newCommandRegistry(map[string]config.CommandPolicy{
+		"git": {Subcommands: map[string]config.CommandSubcommand{"status": {Access: config.CommandAccessRead}}},
+		"terraform": {
+			Default:     config.CommandAccessWrite,
+			Flags:       map[string]string{"-auto-approve": config.CommandAccessWrite},
+			Subcommands: map[string]config.CommandSubcommand{"plan": {Access: config.CommandAccessRead}},
+		},
+	})
+
+	access, _ := registry.classify("git", []string{"log"})
+	assert.Equal(t, config.CommandAccessWrite, access)
+	access, _ = registry.classify("terraform", []string{"plan"})
+	assert.Equal(t, config.CommandAccessRead, access)
+	access, _ = registry.classify("docker", []string{"ps"})
+	assert.Equal(t, config.CommandAccessRead, access)
+
+	assert.True(t, isReadOnlyUnixCommand("terraform plan | head", TaskDirs{}, registry))
+	assert.False(t, isReadOnlyUnixCommand("git log", TaskDirs{}, registry))
+}
+
+func TestCommandFlagMatches(t *testing.T) {
+	assert.True(t, commandFlagMatches("-u", "-u"))
+	assert.True(t, commandFlagMatches("-f", "-fd"))
+	assert.True(t, commandFlagMatches("--output", "--output=x"))
+	assert.False(t, commandFlagMatches("--output", "--output-indicator-new=x"))
+	assert.False(t, commandFlagMatches("-f", "--force"))
+}
+
+func TestRegisteredCallPathsStayInRoot(t *testing.T) {
+	rootDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(rootDir, "src"), 0o755))
+	require.NoError(t, os.Symlink("/etc", filepath.Join(rootDir, "etc-link")))
+	dirs := TaskDirs{RootDir: rootDir, CurrentDir: rootDir}
+
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"git log -- src/main.go", true},
+		{"git show HEAD:src/main.go", true},
+		{"git diff --no-index ./src/a ./src/b", true},
+		{"git diff --no-index /etc/shadow /dev/null", false},
+		{"git show HEAD:../..", false},
+		{"git log ../other", false},
+		{"git show etc-link/passwd", false},
+		{"git log --git-dir=/tmp/repo", false},
+		{"git blame ~/notes.txt", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isReadOnlyUnixCommandInDirs(tt.command, dirs), tt.command)
+	}
+}
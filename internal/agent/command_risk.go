@@ -0,0 +1,301 @@
+package agent
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Risk levels attached to unix tool calls and shown in confirmation prompts.
+// High-risk commands prompt even under auto-approve unless a rule explicitly
+// allows them.
+const (
+	CommandRiskLow    = "low"
+	CommandRiskMedium = "medium"
+	CommandRiskHigh   = "high"
+)
+
+// CommandRisk is the risk classification of one unix command.
+type CommandRisk struct {
+	Level   string
+	Reasons []string
+}
+
+var shellInterpreters = []string{"sh", "bash", "zsh", "dash", "ksh", "fish", "python", "python3", "perl", "ruby", "node"}
+
+var downloadPrograms = []string{"curl", "wget"}
+
+// criticalPaths are targets where a recursive permission change or delete
+// damages the system or the user's home rather than a project.
+var criticalPaths = []string{"/", "/*", "~", "~/", "$HOME", "/bin", "/boot", "/dev", "/etc", "/home", "/lib", "/opt", "/proc", "/root", "/sbin", "/sys", "/usr", "/var"}
+
+var privilegePrograms = []string{"sudo", "doas"}
+
+// sudoValueFlags take a separate value argument, which must be skipped to find
+// the wrapped command.
+var sudoValueFlags = []string{"-C", "-D", "-g", "-h", "-p", "-r", "-t", "-U", "-u"}
+
+var diskPrograms = []string{"fdisk", "parted", "sfdisk", "shred", "wipefs"}
+
+var powerPrograms = []string{"halt", "poweroff", "reboot", "shutdown"}
+
+// assessUnixCommandRisk classifies a unix command. Recognised destructive
+// patterns are high risk; otherwise read-only commands are low risk and
+// everything else medium.
+func assessUnixCommandRisk(command string, readOnly bool) CommandRisk {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return CommandRisk{Level: CommandRiskMedium, Reasons: []string{"command could not be parsed"}}
+	}
+
+	var reasons []string
+	add := func(reason string) {
+		if !slices.Contains(reasons, reason) {
+			reasons = append(reasons, reason)
+		}
+	}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.CallExpr:
+			for _, reason := range callRiskReasons(n) {
+				add(reason)
+			}
+		case *syntax.BinaryCmd:
+			if n.Op == syntax.Pipe && pipesDownloadIntoInterpreter(n) {
+				add("pipes downloaded content into an interpreter")
+			}
+		case *syntax.Redirect:
+			if target, ok := staticShellWordValue(n.Word); ok && n.Op != syntax.RdrIn && isBlockDevice(target) {
+				add("writes directly to a block device")
+			}
+		}
+		return true
+	})
+
+	switch {
+	case len(reasons) > 0:
+		return CommandRisk{Level: CommandRiskHigh, Reasons: reasons}
+	case readOnly:
+		return CommandRisk{Level: CommandRiskLow}
+	default:
+		return CommandRisk{Level: CommandRiskMedium}
+	}
+}
+
+func callRiskReasons(call *syntax.CallExpr) []string {
+	args := riskCallArgs(call)
+	if len(args) == 0 {
+		return nil
+	}
+
+	var reasons []string
+	name := filepath.Base(args[0])
+	if slices.Contains(privilegePrograms, name) {
+		reasons = append(reasons, "runs with elevated privileges")
+		args = unwrapPrivilegeCommand(args[1:])
+		if len(args) == 0 {
+			return reasons
+		}
+		name = filepath.Base(args[0])
+	}
+	args = args[1:]
+
+	switch {
+	case name == "rm":
+		recursive := hasShortOrLongFlag(args, 'r', "--recursive") || hasShortOrLongFlag(args, 'R', "--recursive")
+		if recursive && hasShortOrLongFlag(args, 'f', "--force") {
+			reasons = append(reasons, "recursive forced delete")
+		}
+		if recursive && touchesCriticalPath(args) {
+			reasons = append(reasons, "deletes a system or home directory")
+		}
+	case name == "chmod" || name == "chown" || name == "chgrp":
+		if hasShortOrLongFlag(args, 'R', "--recursive") && touchesCriticalPath(args) {
+			reasons = append(reasons, "recursive "+name+" on a system or home directory")
+		}
+	case name == "dd":
+		for _, arg := range args {
+			if target, ok := strings.CutPrefix(arg, "of="); ok && isBlockDevice(target) {
+				reasons = append(reasons, "writes directly to a block device")
+			}
+		}
+	case strings.HasPrefix(name, "mkfs") || slices.Contains(diskPrograms, name):
+		reasons = append(reasons, "formats or wipes a disk")
+	case slices.Contains(powerPrograms, name):
+		reasons = append(reasons, "shuts down or restarts the machine")
+	case name == "git":
+		reasons = append(reasons, gitRiskReasons(args)...)
+	case slices.Contains(shellInterpreters, name):
+		if callRunsDownload(call) {
+			reasons = append(reasons, "runs downloaded content in an interpreter")
+		}
+	}
+	return reasons
+}
+
+// riskCallArgs returns the call's words, keeping dynamic words as their raw
+// source text so the program and flags remain visible to the checks.
+func riskCallArgs(call *syntax.CallExpr) []string {
+	args := make([]string, 0, len(call.Args))
+	for _, word := range call.Args {
+		if value, ok := staticShellWordValue(word); ok {
+			args = append(args, value)
+			continue
+		}
+		var source strings.Builder
+		if err := syntax.NewPrinter().Print(&source, word); err == nil {
+			args = append(args, source.String())
+		}
+	}
+	return args
+}
+
+func unwrapPrivilegeCommand(args []string) []string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return args[i:]
+		}
+		if arg == "--" {
+			return args[i+1:]
+		}
+		if slices.Contains(sudoValueFlags, arg) {
+			i++
+		}
+	}
+	return nil
+}
+
+func gitRiskReasons(args []string) []string {
+	positional := commandPositionalArgs(args)
+	if len(positional) == 0 {
+		return nil
+	}
+	switch positional[0] {
+	case "push":
+		if hasShortOrLongFlag(args, 'f', "--force") || hasFlagPrefix(args, "--force-with-lease") || slices.ContainsFunc(args, func(arg string) bool { return strings.HasPrefix(arg, "+") }) {
+			return []string{"force-pushes and can overwrite remote history"}
+		}
+	case "reset":
+		if slices.Contains(args, "--hard") {
+			return []string{"discards uncommitted changes"}
+		}
+	case "clean":
+		if hasShortOrLongFlag(args, 'f', "--force") {
+			return []string{"deletes untracked files"}
+		}
+	}
+	return nil
+}
+
+// hasShortOrLongFlag reports whether args pass the short flag (alone or in a
+// cluster such as -rf) or the long flag.
+func hasShortOrLongFlag(args []string, short byte, long string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if arg == long {
+			return true
+		}
+		if len(arg) > 1 && arg[0] == '-' && arg[1] != '-' && strings.IndexByte(arg[1:], short) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFlagPrefix(args []string, prefix string) bool {
+	return slices.ContainsFunc(args, func(arg string) bool { return strings.HasPrefix(arg, prefix) })
+}
+
+func touchesCriticalPath(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		trimmed := arg
+		if len(trimmed) > 1 {
+			trimmed = strings.TrimSuffix(trimmed, "/")
+		}
+		if slices.Contains(criticalPaths, arg) || slices.Contains(criticalPaths, trimmed) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlockDevice(path string) bool {
+	for _, prefix := range []string{"/dev/sd", "/dev/nvme", "/dev/hd", "/dev/vd", "/dev/xvd", "/dev/mmcblk", "/dev/disk"} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func pipesDownloadIntoInterpreter(pipe *syntax.BinaryCmd) bool {
+	stmts := pipelineStmts(pipe)
+	downloaded := false
+	for _, stmt := range stmts {
+		name := stmtProgramName(stmt)
+		if slices.Contains(downloadPrograms, name) {
+			downloaded = true
+			continue
+		}
+		if downloaded && slices.Contains(shellInterpreters, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func pipelineStmts(cmd *syntax.BinaryCmd) []*syntax.Stmt {
+	var stmts []*syntax.Stmt
+	for _, side := range []*syntax.Stmt{cmd.X, cmd.Y} {
+		if nested, ok := side.Cmd.(*syntax.BinaryCmd); ok && nested.Op == syntax.Pipe {
+			stmts = append(stmts, pipelineStmts(nested)...)
+			continue
+		}
+		stmts = append(stmts, side)
+	}
+	return stmts
+}
+
+func stmtProgramName(stmt *syntax.Stmt) string {
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok {
+		return ""
+	}
+	args := riskCallArgs(call)
+	if len(args) == 0 {
+		return ""
+	}
+	name := filepath.Base(args[0])
+	if slices.Contains(privilegePrograms, name) {
+		if wrapped := unwrapPrivilegeCommand(args[1:]); len(wrapped) > 0 {
+			name = filepath.Base(wrapped[0])
+		}
+	}
+	return name
+}
+
+// callRunsDownload reports whether an interpreter call receives a curl or wget
+// through command or process substitution, e.g. bash <(curl ...).
+func callRunsDownload(call *syntax.CallExpr) bool {
+	found := false
+	for _, word := range call.Args[1:] {
+		syntax.Walk(word, func(node syntax.Node) bool {
+			if nested, ok := node.(*syntax.CallExpr); ok {
+				args := riskCallArgs(nested)
+				if len(args) > 0 && slices.Contains(downloadPrograms, filepath.Base(args[0])) {
+					found = true
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
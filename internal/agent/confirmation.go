@@ -21,11 +21,21 @@ type ConfirmationManager struct {
 	confirmWithUser UserConfirmationFunc
 	rememberFunc    RememberDecisionFunc
 	maxPriority     int
+	policy          *PolicyEngine
 }
 
 type RememberDecisionFunc func(actions []string, allow bool) error
 
-type UserConfirmationFunc func(action string) (confirmationDecision, error)
+type UserConfirmationFunc func(request TaskConfirmationRequest) (confirmationDecision, error)
+
+// ConfirmationResult is the outcome of resolving one tool call. Reason carries
+// the explanation of the expression policy rule that decided or flagged the
+// call; PolicyBlocked is set when a policy deny rejected it without prompting.
+type ConfirmationResult struct {
+	Allowed       bool
+	Reason        string
+	PolicyBlocked bool
+}
 
 type ruleType int
 
@@ -63,6 +73,11 @@ func NewConfirmationManager(allow []string, ruleSets []config.PermissionRuleSet,
 
 	maxPriority := 0
 	for _, set := range ruleSets {
+		if set.Managed {
+			manager.appendPatterns(set.Permissions.Deny, ruleDeny, config.ManagedPermissionPriority)
+			manager.appendPatterns(set.Permissions.Ask, ruleAsk, config.ManagedPermissionPriority)
+			continue
+		}
 		if set.Priority > maxPriority {
 			maxPriority = set.Priority
 		}
@@ -77,6 +92,12 @@ func NewConfirmationManager(allow []string, ruleSets []config.PermissionRuleSet,
 	return manager
 }
 
+// SetPolicy installs an expression policy engine consulted before the glob
+// permission rules.
+func (cm *ConfirmationManager) SetPolicy(policy *PolicyEngine) {
+	cm.policy = policy
+}
+
 func (cm *ConfirmationManager) Confirm(action string) (bool, error) {
 	return cm.ConfirmWithDefault(action, false)
 }
@@ -92,37 +113,94 @@ func (cm *ConfirmationManager) ConfirmWithDefault(action string, autoAllow bool)
 // policy. autoApprove is an explicit user request to approve prompts, so it
 // bypasses ask rules but still respects allow/deny resolution.
 func (cm *ConfirmationManager) ConfirmWithPolicy(action string, autoAllow bool, autoApprove bool) (bool, error) {
+	result, err := cm.ConfirmCall(PolicyContext{Action: action}, autoAllow, autoApprove)
+	return result.Allowed, err
+}
+
+// ConfirmCall resolves a tool call against the expression policy, the
+// permission rules and caller policy. A policy deny is a hard block that even
+// autoApprove and cached decisions cannot lift; a policy ask prompts with the
+// rule's reason (autoApprove bypasses it, as with ask rules); a policy allow
+// skips the prompt but still yields to matching deny rules. Deny and ask rules
+// from the organisation-managed policy behave like policy deny and an ask that
+// autoApprove cannot bypass. High-risk commands also prompt under autoApprove
+// unless an allow rule or policy allow matches them. A policy rule that cannot
+// be evaluated prompts, and blocks under autoApprove since nobody is asked.
+func (cm *ConfirmationManager) ConfirmCall(call PolicyContext, autoAllow bool, autoApprove bool) (ConfirmationResult, error) {
+	action := call.Action
 	if action == "" {
-		return true, nil
+		return ConfirmationResult{Allowed: true}, nil
+	}
+	if call.Tool == "" {
+		call.Tool, _ = ParseToolAndCommand(action)
+	}
+
+	policy, policyMatched := cm.policy.Evaluate(call)
+	if policyMatched && policy.Decision == config.PolicyDecisionDeny {
+		return ConfirmationResult{Reason: policy.Reason, PolicyBlocked: true}, nil
+	}
+	if autoApprove && policyMatched && policy.Failed {
+		return ConfirmationResult{Reason: policy.Reason, PolicyBlocked: true}, nil
+	}
+	if cm.matchesManaged(action, cm.denyPatterns) {
+		return ConfirmationResult{Reason: managedPolicyReason, PolicyBlocked: true}, nil
 	}
 
-	if decision, ok := cm.decisions[action]; ok {
-		return decision, nil
+	key := cm.decisionKey(call)
+	if decision, ok := cm.decisions[key]; ok {
+		return ConfirmationResult{Allowed: decision}, nil
+	}
+
+	if cm.matchesManaged(action, cm.askPatterns) {
+		allowed, err := cm.confirmAndRemember(call, managedPolicyReason)
+		return ConfirmationResult{Allowed: allowed, Reason: managedPolicyReason}, err
 	}
 
 	if autoApprove {
 		if allowed, matched := cm.resolveAllowDeny(action); matched {
-			cm.decisions[action] = allowed
-			return allowed, nil
+			cm.decisions[key] = allowed
+			return ConfirmationResult{Allowed: allowed}, nil
+		}
+		explicitlyAllowed := policyMatched && policy.Decision == config.PolicyDecisionAllow
+		if call.Risk.Level != CommandRiskHigh || explicitlyAllowed {
+			cm.decisions[key] = true
+			return ConfirmationResult{Allowed: true}, nil
+		}
+		allowed, err := cm.confirmAndRemember(call, "")
+		return ConfirmationResult{Allowed: allowed}, err
+	}
+
+	if policyMatched {
+		switch policy.Decision {
+		case config.PolicyDecisionAsk:
+			allowed, err := cm.confirmAndRemember(call, policy.Reason)
+			return ConfirmationResult{Allowed: allowed, Reason: policy.Reason}, err
+		case config.PolicyDecisionAllow:
+			allowed, matched := cm.resolveAllowDeny(action)
+			if !matched {
+				allowed = true
+			}
+			cm.decisions[key] = allowed
+			return ConfirmationResult{Allowed: allowed, Reason: policy.Reason}, nil
 		}
-		cm.decisions[action] = true
-		return true, nil
 	}
 
 	if cm.shouldAsk(action) {
-		return cm.confirmAndRemember(action)
+		allowed, err := cm.confirmAndRemember(call, "")
+		return ConfirmationResult{Allowed: allowed}, err
 	}
 
 	if allowed, matched := cm.resolveAllowDeny(action); matched {
-		cm.decisions[action] = allowed
-		return allowed, nil
+		cm.decisions[key] = allowed
+		return ConfirmationResult{Allowed: allowed}, nil
 	}
 
 	if autoAllow {
-		return true, nil
+		return ConfirmationResult{Allowed: true}, nil
 	}
 
-	return cm.confirmAndRemember(action)
+	allowed, err := cm.confirmAndRemember(call, "")
+	return ConfirmationResult{Allowed: allowed}, err
 }
 
 func (cm *ConfirmationManager) appendPatterns(values []string, rule ruleType, priority int) {
@@ -147,6 +225,14 @@ func (cm *ConfirmationManager) appendPatterns(values []string, rule ruleType, pr
 	}
 }
 
+// managedPolicyReason is reported when a managed permission rule decides a call.
+const managedPolicyReason = "required by the organisation policy"
+
+func (cm *ConfirmationManager) matchesManaged(action string, patterns []rulePattern) bool {
+	matched, priority := cm.matchWithPriority(action, patterns)
+	return matched && priority >= config.ManagedPermissionPriority
+}
+
 func (cm *ConfirmationManager) shouldAsk(action string) bool {
 	return cm.matchesPatterns(action, cm.askPatterns)
 }
@@ -170,17 +256,35 @@ func (cm *ConfirmationManager) resolveAllowDeny(action string) (bool, bool) {
 	return true, true
 }
 
-func (cm *ConfirmationManager) confirmAndRemember(action string) (bool, error) {
+// decisionKey is what a decision is cached under for the rest of the run.
+// Expression policies can read the directory and git branch, so with policy
+// rules a decision only carries over to calls made in the same ones: approving
+// a command on a feature branch does not approve it after a switch to main.
+func (cm *ConfirmationManager) decisionKey(call PolicyContext) string {
+	if cm.policy == nil || len(cm.policy.rules) == 0 {
+		return call.Action
+	}
+	cwd := call.cwd()
+	return call.Action + "\x00" + cwd + "\x00" + GitBranch(cwd)
+}
+
+func (cm *ConfirmationManager) confirmAndRemember(call PolicyContext, reason string) (bool, error) {
 	if cm.confirmWithUser == nil {
 		return false, ErrTaskInteractionRequired
 	}
 
-	decision, err := cm.confirmWithUser(action)
+	action := call.Action
+	decision, err := cm.confirmWithUser(TaskConfirmationRequest{
+		Action:      action,
+		Reason:      reason,
+		Risk:        call.Risk.Level,
+		RiskReasons: call.Risk.Reasons,
+	})
 	if err != nil {
 		return false, err
 	}
 
-	cm.decisions[action] = decision.allowed
+	cm.decisions[cm.decisionKey(call)] = decision.allowed
 	if decision.remember && cm.rememberFunc != nil {
 		actions := decision.patterns
 		if len(actions) == 0 {
@@ -443,6 +547,13 @@ func parseActionCall(input string) (actionCall, error) {
 	}, nil
 }
 
+// ValidatePattern reports whether input is a well-formed allow/deny rule, such
+// as `unix("rm *")`.
+func ValidatePattern(input string) error {
+	_, err := parseAllowPattern(input)
+	return err
+}
+
 // ParseToolAndCommand extracts the tool name and positional command string
 // from an action expression like `unix("find . -type f")`. Returns empty
 // strings if the expression cannot be parsed.
@@ -252,7 +252,7 @@ func TestMultiPatternRemember(t *testing.T) {
 	var rememberedAllow bool
 
 	manager := NewConfirmationManager(nil, nil,
-		func(action string) (confirmationDecision, error) {
+		func(TaskConfirmationRequest) (confirmationDecision, error) {
 			return confirmationDecision{
 				allowed:  true,
 				remember: true,
@@ -285,7 +285,7 @@ func TestRememberedPatternMatchesWithinSameSession(t *testing.T) {
 	promptCount := 0
 
 	manager := NewConfirmationManager(nil, nil,
-		func(action string) (confirmationDecision, error) {
+		func(TaskConfirmationRequest) (confirmationDecision, error) {
 			promptCount++
 			return confirmationDecision{
 				allowed:  true,
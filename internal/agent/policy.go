@@ -0,0 +1,274 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/tools"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// PolicyContext describes one tool call as seen by expression policy rules.
+type PolicyContext struct {
+	Action string
+	Tool   string
+	Input  map[string]any
+	Paths  []string
+	// PathsUnknown is set when a unix call has arguments only known when it
+	// runs, such as $HOME or a command substitution; rules reading paths then
+	// fail to evaluate rather than miss a path.
+	PathsUnknown bool
+	ReadOnly     bool
+	RunKind      string
+	RoutineID    string
+	RootDir      string
+	CurrentDir   string
+	// Risk is set for unix calls; its level is empty for other tools.
+	Risk CommandRisk
+}
+
+// cwd is the directory the call runs in, as policies see it.
+func (call PolicyContext) cwd() string {
+	if call.CurrentDir == "" {
+		return call.RootDir
+	}
+	return call.CurrentDir
+}
+
+// PolicyDecision is the outcome of the first matching policy rule.
+type PolicyDecision struct {
+	Decision string
+	Reason   string
+	Rule     string
+	// Failed marks the ask decision of a rule that could not be evaluated.
+	Failed bool
+}
+
+// PolicyEngine evaluates compiled expression rules in file order; the first rule
+// whose condition holds decides the call.
+type PolicyEngine struct {
+	rules []compiledPolicyRule
+}
+
+type compiledPolicyRule struct {
+	rule      config.PolicyRule
+	condition policyExpr
+	label     string
+}
+
+// NewPolicyEngine compiles the rules of the given policy files, failing on the
+// first condition that does not parse so a broken policy never silently allows.
+func NewPolicyEngine(files ...config.PolicyFile) (*PolicyEngine, error) {
+	engine := &PolicyEngine{}
+	for _, file := range files {
+		for i, rule := range file.Rules {
+			label := rule.Name
+			if label == "" {
+				label = fmt.Sprintf("rule %d", i+1)
+			}
+			condition, err := compilePolicyExpr(rule.When)
+			if err != nil {
+				return nil, fmt.Errorf("policy %s: %s: %w", file.SourcePath, label, err)
+			}
+			engine.rules = append(engine.rules, compiledPolicyRule{rule: rule, condition: condition, label: label})
+		}
+	}
+	return engine, nil
+}
+
+// Evaluate returns the decision of the first matching rule. A rule that fails
+// to evaluate fails closed: the later rules are still evaluated so that a
+// matching deny blocks the call, and otherwise the call gets a Failed ask
+// decision so the user sees the problem.
+func (e *PolicyEngine) Evaluate(call PolicyContext) (PolicyDecision, bool) {
+	if e == nil || len(e.rules) == 0 {
+		return PolicyDecision{}, false
+	}
+	env := policyEnvironment(call)
+	var failed *PolicyDecision
+	for _, compiled := range e.rules {
+		matched, err := evalPolicyCondition(compiled.condition, env)
+		if err != nil {
+			if failed == nil {
+				failed = &PolicyDecision{
+					Decision: config.PolicyDecisionAsk,
+					Reason:   fmt.Sprintf("policy %s could not be evaluated: %v", compiled.label, err),
+					Rule:     compiled.label,
+					Failed:   true,
+				}
+			}
+			continue
+		}
+		if !matched {
+			continue
+		}
+		// Past a failed rule only a deny can decide: the failed rule might
+		// have matched before any allow or ask.
+		if failed != nil && compiled.rule.Decision != config.PolicyDecisionDeny {
+			continue
+		}
+		reason := compiled.rule.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("matched policy %s", compiled.label)
+		}
+		return PolicyDecision{Decision: compiled.rule.Decision, Reason: reason, Rule: compiled.label}, true
+	}
+	if failed != nil {
+		return *failed, true
+	}
+	return PolicyDecision{}, false
+}
+
+func policyEnvironment(call PolicyContext) map[string]any {
+	input := make(map[string]any, len(call.Input))
+	for key, value := range call.Input {
+		input[key] = normalizePolicyValue(value)
+	}
+	command, _ := call.Input["command"].(string)
+	paths := make([]any, 0, len(call.Paths))
+	for _, path := range call.Paths {
+		paths = append(paths, path)
+	}
+	riskReasons := make([]any, 0, len(call.Risk.Reasons))
+	for _, reason := range call.Risk.Reasons {
+		riskReasons = append(riskReasons, reason)
+	}
+	cwd := call.cwd()
+	var pathsValue any = paths
+	if call.PathsUnknown {
+		pathsValue = policyUnknown{reason: "the command's paths depend on values only known when it runs"}
+	}
+	return map[string]any{
+		"action":       call.Action,
+		"tool":         call.Tool,
+		"input":        input,
+		"command":      command,
+		"paths":        pathsValue,
+		"read_only":    call.ReadOnly,
+		"risk":         call.Risk.Level,
+		"risk_reasons": riskReasons,
+		"run_kind":     call.RunKind,
+		"routine_id":   call.RoutineID,
+		"root_dir":     call.RootDir,
+		"cwd":          cwd,
+		"home":         os.Getenv("HOME"),
+		"branch":       GitBranch(cwd),
+	}
+}
+
+// policyPathsForCall resolves the filesystem paths a tool call touches: path-like
+// input fields for file tools, and path-like static arguments for unix commands.
+// It reports false when a unix command has arguments or redirections whose value
+// is only known when it runs, so its paths cannot all be listed.
+func policyPathsForCall(toolName string, input map[string]any, dirs TaskDirs) ([]string, bool) {
+	var paths []string
+	for _, key := range []string{"path", "root"} {
+		if value, ok := input[key].(string); ok {
+			if resolved := resolvePolicyPath(value, dirs); resolved != "" {
+				paths = append(paths, resolved)
+			}
+		}
+	}
+	if toolName == tools.ToolNameUnix {
+		command, _ := input["command"].(string)
+		commandPaths, known := unixCommandPaths(command, dirs)
+		return append(paths, commandPaths...), known
+	}
+	return paths, true
+}
+
+func unixCommandPaths(command string, dirs TaskDirs) ([]string, bool) {
+	file, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, false
+	}
+	var paths []string
+	known := true
+	addPath := func(word *syntax.Word) {
+		arg, ok := staticShellWordValue(word)
+		if !ok {
+			known = false
+			return
+		}
+		if looksLikePath(arg) {
+			if resolved := resolvePolicyPath(arg, dirs); resolved != "" {
+				paths = append(paths, resolved)
+			}
+		}
+	}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.CallExpr:
+			for i, word := range n.Args {
+				if i > 0 {
+					addPath(word)
+				}
+			}
+		case *syntax.Redirect:
+			addPath(n.Word)
+		}
+		return true
+	})
+	return paths, known
+}
+
+func looksLikePath(arg string) bool {
+	if arg == "" || strings.HasPrefix(arg, "-") {
+		return false
+	}
+	return arg == "." || arg == "~" || strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, "~/") ||
+		strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../") || arg == parentRelPath || strings.Contains(arg, "/")
+}
+
+func resolvePolicyPath(path string, dirs TaskDirs) string {
+	trimmed := strings.TrimSpace(path)
+	if trimmed == "~" || strings.HasPrefix(trimmed, "~/") {
+		trimmed = filepath.Join(os.Getenv("HOME"), strings.TrimPrefix(trimmed, "~"))
+	}
+	return resolveTaskPathForScope(trimmed, dirs)
+}
+
+// GitBranch returns the checked-out branch of the repository containing dir by
+// reading .git/HEAD, or an empty string outside a repository or on a detached
+// HEAD.
+func GitBranch(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	current := filepath.Clean(dir)
+	for {
+		gitPath := filepath.Join(current, ".git")
+		if info, err := os.Stat(gitPath); err == nil {
+			headPath := filepath.Join(gitPath, "HEAD")
+			if !info.IsDir() {
+				// Worktrees and submodules use a .git file pointing at the real dir.
+				content, err := os.ReadFile(gitPath)
+				if err != nil {
+					return ""
+				}
+				gitDir := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(content)), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(current, gitDir)
+				}
+				headPath = filepath.Join(gitDir, "HEAD")
+			}
+			head, err := os.ReadFile(headPath)
+			if err != nil {
+				return ""
+			}
+			ref := strings.TrimSpace(string(head))
+			if !strings.HasPrefix(ref, "ref: ") {
+				return ""
+			}
+			return strings.TrimPrefix(strings.TrimPrefix(ref, "ref: "), "refs/heads/")
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return ""
+		}
+		current = parent
+	}
+}
@@ -0,0 +1,791 @@
+package agent
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The policy expression language is a deliberately small, CEL-flavoured subset:
+// literals (strings, numbers, booleans, lists), variables, member access
+// (input.path), indexing (input["path"]), the operators ! && || == != < <= > >=
+// in +, the size() function, string methods (startsWith, endsWith, contains,
+// matches, glob) and the list macros exists/all. Expressions are compiled once
+// when the policy file is loaded and evaluated per tool call. We keep it in-tree
+// rather than adopting cel-go so the permission path stays dependency-free.
+
+type policyExpr interface {
+	eval(env map[string]any) (any, error)
+}
+
+// compilePolicyExpr parses an expression into an evaluable tree.
+func compilePolicyExpr(source string) (policyExpr, error) {
+	tokens, err := lexPolicyExpr(source)
+	if err != nil {
+		return nil, err
+	}
+	parser := &policyParser{tokens: tokens}
+	expr, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := parser.peek(); tok.kind != policyTokenEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+	return expr, nil
+}
+
+// evalPolicyCondition evaluates a compiled expression and requires a boolean
+// result.
+func evalPolicyCondition(expr policyExpr, env map[string]any) (bool, error) {
+	value, err := expr.eval(env)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition evaluated to %s, not a boolean", policyTypeName(value))
+	}
+	return result, nil
+}
+
+type policyTokenKind int
+
+const (
+	policyTokenEOF policyTokenKind = iota
+	policyTokenIdent
+	policyTokenString
+	policyTokenNumber
+	policyTokenOp
+)
+
+type policyToken struct {
+	kind policyTokenKind
+	text string
+	pos  int
+}
+
+var policyOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "!", "<", ">", "+", "(", ")", "[", "]", ",", "."}
+
+func lexPolicyExpr(source string) ([]policyToken, error) {
+	var tokens []policyToken
+	for i := 0; i < len(source); {
+		ch := rune(source[i])
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch == '\'' || ch == '"':
+			value, next, err := lexPolicyString(source, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, policyToken{kind: policyTokenString, text: value, pos: i})
+			i = next
+		case ch >= '0' && ch <= '9':
+			start := i
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, policyToken{kind: policyTokenNumber, text: source[start:i], pos: start})
+		case ch == '_' || unicode.IsLetter(ch):
+			start := i
+			for i < len(source) && (source[i] == '_' || unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i]))) {
+				i++
+			}
+			tokens = append(tokens, policyToken{kind: policyTokenIdent, text: source[start:i], pos: start})
+		default:
+			matched := false
+			for _, op := range policyOperators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, policyToken{kind: policyTokenOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", ch, i)
+			}
+		}
+	}
+	return append(tokens, policyToken{kind: policyTokenEOF, pos: len(source)}), nil
+}
+
+func lexPolicyString(source string, start int) (string, int, error) {
+	quote := source[start]
+	var value strings.Builder
+	for i := start + 1; i < len(source); i++ {
+		ch := source[i]
+		switch {
+		case ch == '\\' && i+1 < len(source):
+			i++
+			switch source[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			default:
+				value.WriteByte(source[i])
+			}
+		case ch == quote:
+			return value.String(), i + 1, nil
+		default:
+			value.WriteByte(ch)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+type policyParser struct {
+	tokens []policyToken
+	pos    int
+}
+
+func (p *policyParser) peek() policyToken {
+	return p.tokens[p.pos]
+}
+
+func (p *policyParser) next() policyToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != policyTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *policyParser) acceptOp(op string) bool {
+	if tok := p.peek(); tok.kind == policyTokenOp && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *policyParser) expectOp(op string) error {
+	if p.acceptOp(op) {
+		return nil
+	}
+	tok := p.peek()
+	return fmt.Errorf("expected %q at offset %d, got %q", op, tok.pos, tok.text)
+}
+
+func (p *policyParser) parseOr() (policyExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = policyLogical{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *policyParser) parseAnd() (policyExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = policyLogical{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *policyParser) parseUnary() (policyExpr, error) {
+	if p.acceptOp("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return policyNot{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *policyParser) parseComparison() (policyExpr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	op := ""
+	switch {
+	case tok.kind == policyTokenOp && (tok.text == "==" || tok.text == "!=" || tok.text == "<" || tok.text == "<=" || tok.text == ">" || tok.text == ">="):
+		op = tok.text
+	case tok.kind == policyTokenIdent && tok.text == "in":
+		op = "in"
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return policyCompare{op: op, left: left, right: right}, nil
+}
+
+func (p *policyParser) parseAdditive() (policyExpr, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("+") {
+		right, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		left = policyConcat{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *policyParser) parsePostfix() (policyExpr, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.acceptOp("."):
+			name := p.next()
+			if name.kind != policyTokenIdent {
+				return nil, fmt.Errorf("expected field or method name at offset %d", name.pos)
+			}
+			if !p.acceptOp("(") {
+				expr = policyIndex{target: expr, key: policyLiteral{value: name.text}}
+				continue
+			}
+			if name.text == "exists" || name.text == "all" {
+				expr, err = p.parseMacro(expr, name.text)
+			} else {
+				var args []policyExpr
+				args, err = p.parseArgs()
+				expr = policyMethod{target: expr, name: name.text, args: args}
+			}
+			if err != nil {
+				return nil, err
+			}
+		case p.acceptOp("["):
+			key, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp("]"); err != nil {
+				return nil, err
+			}
+			expr = policyIndex{target: expr, key: key}
+		default:
+			return expr, nil
+		}
+	}
+}
+
+// parseMacro handles list.exists(v, predicate) and list.all(v, predicate),
+// binding v to each element while the predicate is evaluated.
+func (p *policyParser) parseMacro(target policyExpr, name string) (policyExpr, error) {
+	variable := p.next()
+	if variable.kind != policyTokenIdent {
+		return nil, fmt.Errorf("%s() expects a variable name at offset %d", name, variable.pos)
+	}
+	if err := p.expectOp(","); err != nil {
+		return nil, err
+	}
+	predicate, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	return policyMacro{target: target, name: name, variable: variable.text, predicate: predicate}, nil
+}
+
+func (p *policyParser) parseArgs() ([]policyExpr, error) {
+	var args []policyExpr
+	if p.acceptOp(")") {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.acceptOp(")") {
+			return args, nil
+		}
+		if err := p.expectOp(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *policyParser) parsePrimary() (policyExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case policyTokenString:
+		return policyLiteral{value: tok.text}, nil
+	case policyTokenNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return policyLiteral{value: value}, nil
+	case policyTokenIdent:
+		switch tok.text {
+		case "true":
+			return policyLiteral{value: true}, nil
+		case "false":
+			return policyLiteral{value: false}, nil
+		case "null":
+			return policyLiteral{value: nil}, nil
+		}
+		if p.acceptOp("(") {
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			return policyCall{name: tok.text, args: args}, nil
+		}
+		return policyVariable{name: tok.text}, nil
+	case policyTokenOp:
+		switch tok.text {
+		case "(":
+			expr, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return expr, nil
+		case "[":
+			var items []policyExpr
+			if p.acceptOp("]") {
+				return policyList{items: items}, nil
+			}
+			for {
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if p.acceptOp("]") {
+					return policyList{items: items}, nil
+				}
+				if err := p.expectOp(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case policyTokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+}
+
+type policyLiteral struct{ value any }
+
+func (e policyLiteral) eval(map[string]any) (any, error) { return e.value, nil }
+
+type policyVariable struct{ name string }
+
+// policyUnknown stands in for a variable whose value cannot be worked out for
+// the call; reading it fails the condition.
+type policyUnknown struct{ reason string }
+
+func (e policyVariable) eval(env map[string]any) (any, error) {
+	value, ok := env[e.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %q", e.name)
+	}
+	if unknown, ok := value.(policyUnknown); ok {
+		return nil, fmt.Errorf("%s is unknown: %s", e.name, unknown.reason)
+	}
+	return value, nil
+}
+
+type policyList struct{ items []policyExpr }
+
+func (e policyList) eval(env map[string]any) (any, error) {
+	values := make([]any, 0, len(e.items))
+	for _, item := range e.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+type policyNot struct{ operand policyExpr }
+
+func (e policyNot) eval(env map[string]any) (any, error) {
+	value, err := e.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("! expects a boolean, got %s", policyTypeName(value))
+	}
+	return !b, nil
+}
+
+type policyLogical struct {
+	op          string
+	left, right policyExpr
+}
+
+func (e policyLogical) eval(env map[string]any) (any, error) {
+	left, err := evalPolicyCondition(e.left, env)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "&&" && !left {
+		return false, nil
+	}
+	if e.op == "||" && left {
+		return true, nil
+	}
+	return evalPolicyCondition(e.right, env)
+}
+
+type policyCompare struct {
+	op          string
+	left, right policyExpr
+}
+
+func (e policyCompare) eval(env map[string]any) (any, error) {
+	left, err := e.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "==":
+		return policyEqual(left, right), nil
+	case "!=":
+		return !policyEqual(left, right), nil
+	case "in":
+		return policyContains(right, left)
+	}
+
+	if l, ok := left.(float64); ok {
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number with %s", policyTypeName(right))
+		}
+		return policyOrdered(e.op, compareFloat(l, r)), nil
+	}
+	if l, ok := left.(string); ok {
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %s", policyTypeName(right))
+		}
+		return policyOrdered(e.op, strings.Compare(l, r)), nil
+	}
+	return nil, fmt.Errorf("operator %s does not support %s", e.op, policyTypeName(left))
+}
+
+func compareFloat(l, r float64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func policyOrdered(op string, cmp int) bool {
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+type policyConcat struct{ left, right policyExpr }
+
+func (e policyConcat) eval(env map[string]any) (any, error) {
+	left, err := e.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch l := left.(type) {
+	case string:
+		if r, ok := right.(string); ok {
+			return l + r, nil
+		}
+	case float64:
+		if r, ok := right.(float64); ok {
+			return l + r, nil
+		}
+	case []any:
+		if r, ok := right.([]any); ok {
+			return append(append([]any{}, l...), r...), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot add %s and %s", policyTypeName(left), policyTypeName(right))
+}
+
+type policyIndex struct {
+	target policyExpr
+	key    policyExpr
+}
+
+// eval returns null for a missing map key so rules can test optional tool
+// input fields (input.path == null) without erroring.
+func (e policyIndex) eval(env map[string]any) (any, error) {
+	target, err := e.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	key, err := e.key.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch t := target.(type) {
+	case map[string]any:
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("map key must be a string, got %s", policyTypeName(key))
+		}
+		return normalizePolicyValue(t[name]), nil
+	case []any:
+		index, ok := key.(float64)
+		if !ok || index < 0 || int(index) >= len(t) {
+			return nil, fmt.Errorf("list index %v out of range", key)
+		}
+		return t[int(index)], nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("cannot index %s", policyTypeName(target))
+}
+
+type policyCall struct {
+	name string
+	args []policyExpr
+}
+
+func (e policyCall) eval(env map[string]any) (any, error) {
+	if e.name != "size" || len(e.args) != 1 {
+		return nil, fmt.Errorf("unknown function %s/%d", e.name, len(e.args))
+	}
+	value, err := e.args[0].eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case string:
+		return float64(len(v)), nil
+	case []any:
+		return float64(len(v)), nil
+	case map[string]any:
+		return float64(len(v)), nil
+	case nil:
+		return float64(0), nil
+	}
+	return nil, fmt.Errorf("size() does not support %s", policyTypeName(value))
+}
+
+type policyMethod struct {
+	target policyExpr
+	name   string
+	args   []policyExpr
+}
+
+func (e policyMethod) eval(env map[string]any) (any, error) {
+	target, err := e.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]any, 0, len(e.args))
+	for _, arg := range e.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+
+	if list, ok := target.([]any); ok && e.name == "contains" && len(args) == 1 {
+		return policyContains(list, args[0])
+	}
+	if target == nil {
+		// Methods on a missing value never match, mirroring the null result of a
+		// missing map key.
+		return false, nil
+	}
+	s, ok := target.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s() is not defined on %s", e.name, policyTypeName(target))
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s() expects one argument", e.name)
+	}
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s() expects a string argument", e.name)
+	}
+	switch e.name {
+	case "startsWith":
+		return strings.HasPrefix(s, arg), nil
+	case "endsWith":
+		return strings.HasSuffix(s, arg), nil
+	case "contains":
+		return strings.Contains(s, arg), nil
+	case "matches":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	case "glob":
+		re, err := compileGlob(arg)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}
+	return nil, fmt.Errorf("unknown method %s()", e.name)
+}
+
+type policyMacro struct {
+	target    policyExpr
+	name      string
+	variable  string
+	predicate policyExpr
+}
+
+func (e policyMacro) eval(env map[string]any) (any, error) {
+	target, err := e.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	var items []any
+	switch t := target.(type) {
+	case []any:
+		items = t
+	case nil:
+	default:
+		return nil, fmt.Errorf("%s() expects a list, got %s", e.name, policyTypeName(target))
+	}
+
+	scope := make(map[string]any, len(env)+1)
+	for key, value := range env {
+		scope[key] = value
+	}
+	for _, item := range items {
+		scope[e.variable] = item
+		matched, err := evalPolicyCondition(e.predicate, scope)
+		if err != nil {
+			return nil, err
+		}
+		if e.name == "exists" && matched {
+			return true, nil
+		}
+		if e.name == "all" && !matched {
+			return false, nil
+		}
+	}
+	return e.name == "all", nil
+}
+
+func policyEqual(left, right any) bool {
+	return reflect.DeepEqual(normalizePolicyValue(left), normalizePolicyValue(right))
+}
+
+func policyContains(container any, item any) (bool, error) {
+	switch c := container.(type) {
+	case []any:
+		for _, candidate := range c {
+			if policyEqual(candidate, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]any:
+		key, ok := item.(string)
+		if !ok {
+			return false, nil
+		}
+		_, found := c[key]
+		return found, nil
+	case string:
+		s, ok := item.(string)
+		if !ok {
+			return false, fmt.Errorf("in on a string expects a string operand")
+		}
+		return strings.Contains(c, s), nil
+	case nil:
+		return false, nil
+	}
+	return false, fmt.Errorf("in is not defined on %s", policyTypeName(container))
+}
+
+// normalizePolicyValue maps Go values coming from tool input (ints, string
+// slices) onto the expression language's value types.
+func normalizePolicyValue(value any) any {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case []string:
+		items := make([]any, 0, len(v))
+		for _, item := range v {
+			items = append(items, item)
+		}
+		return items
+	default:
+		return value
+	}
+}
+
+func policyTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "map"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
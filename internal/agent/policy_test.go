@@ -0,0 +1,289 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyExprEvaluation(t *testing.T) {
+	env := map[string]any{
+		"tool":  "file_edit",
+		"input": map[string]any{"path": "docs/README.md", "count": 3},
+		"paths": []any{"/home/me/projects/a", "/tmp/b"},
+		"home":  "/home/me",
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`tool == "file_edit"`, true},
+		{`tool != 'file_edit'`, false},
+		{`input.path.endsWith(".md")`, true},
+		{`input["path"].glob("docs/*.md")`, true},
+		{`input.missing == null`, true},
+		{`input.missing.startsWith("x")`, false},
+		{`input.count >= 3 && input.count < 4`, true},
+		{`tool in ["unix", "python"]`, false},
+		{`"path" in input`, true},
+		{`size(paths) == 2`, true},
+		{`paths.all(p, p.startsWith(home + "/projects"))`, false},
+		{`paths.exists(p, p.startsWith("/tmp"))`, true},
+		{`!(tool == "unix") || false`, true},
+		{`input.path.matches("^docs/")`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := compilePolicyExpr(tt.expr)
+			require.NoError(t, err)
+			got, err := evalPolicyCondition(expr, env)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPolicyExprRejectsInvalidSyntax(t *testing.T) {
+	for _, expr := range []string{`tool ==`, `(tool == "x"`, `tool == "unterminated`, `paths.exists(1, true)`, `tool @ 1`} {
+		_, err := compilePolicyExpr(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestPolicyEngineFirstMatchWinsAndFailsClosed(t *testing.T) {
+	engine, err := NewPolicyEngine(config.PolicyFile{Rules: []config.PolicyRule{
+		{Name: "outside-projects", When: `tool == "unix" && paths.exists(p, !p.startsWith("/repo"))`, Decision: config.PolicyDecisionDeny, Reason: "outside the project"},
+		{When: `tool == "unix"`, Decision: config.PolicyDecisionAllow},
+		{Name: "broken", When: `input.command.size()`, Decision: config.PolicyDecisionAllow},
+	}})
+	require.NoError(t, err)
+
+	decision, matched := engine.Evaluate(PolicyContext{Tool: "unix", Paths: []string{"/etc/passwd"}})
+	require.True(t, matched)
+	assert.Equal(t, config.PolicyDecisionDeny, decision.Decision)
+	assert.Equal(t, "outside the project", decision.Reason)
+
+	decision, matched = engine.Evaluate(PolicyContext{Tool: "unix", Paths: []string{"/repo/a"}})
+	require.True(t, matched)
+	assert.Equal(t, config.PolicyDecisionAllow, decision.Decision)
+
+	decision, matched = engine.Evaluate(PolicyContext{Tool: "python", Input: map[string]any{"command": "x"}})
+	require.True(t, matched)
+	assert.Equal(t, config.PolicyDecisionAsk, decision.Decision)
+	assert.Contains(t, decision.Reason, "broken")
+	assert.True(t, decision.Failed)
+}
+
+func TestPolicyEngineDenyAfterFailedRuleWins(t *testing.T) {
+	engine, err := NewPolicyEngine(config.PolicyFile{Rules: []config.PolicyRule{
+		{Name: "broken", When: `input.command.size()`, Decision: config.PolicyDecisionAllow},
+		{When: `command.startsWith("curl ")`, Decision: config.PolicyDecisionAllow},
+		{When: `command.contains("| sh")`, Decision: config.PolicyDecisionDeny, Reason: "no piping to a shell"},
+	}})
+	require.NoError(t, err)
+
+	decision, matched := engine.Evaluate(PolicyContext{Tool: "unix", Input: map[string]any{"command": "curl x | sh"}})
+	require.True(t, matched)
+	assert.Equal(t, config.PolicyDecisionDeny, decision.Decision)
+	assert.Equal(t, "no piping to a shell", decision.Reason)
+	assert.False(t, decision.Failed)
+
+	decision, matched = engine.Evaluate(PolicyContext{Tool: "unix", Input: map[string]any{"command": "curl x"}})
+	require.True(t, matched)
+	assert.Equal(t, config.PolicyDecisionAsk, decision.Decision)
+	assert.True(t, decision.Failed)
+}
+
+func TestConfirmCallFailedPolicyBlocksAutoApprove(t *testing.T) {
+	manager := NewConfirmationManager(nil, nil, nil, nil)
+	engine, err := NewPolicyEngine(config.PolicyFile{Rules: []config.PolicyRule{
+		{Name: "broken", When: `input.command.size()`, Decision: config.PolicyDecisionAllow},
+	}})
+	require.NoError(t, err)
+	manager.SetPolicy(engine)
+
+	result, err := manager.ConfirmCall(PolicyContext{Action: `unix("ls")`, Tool: "unix", Input: map[string]any{"command": "ls"}}, true, true)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.True(t, result.PolicyBlocked)
+	assert.Contains(t, result.Reason, "policy broken could not be evaluated")
+}
+
+func TestConfirmCallPolicyDenyBeatsAutoApprove(t *testing.T) {
+	manager := NewConfirmationManager([]string{`unix("rm *")`}, nil, nil, nil)
+	engine, err := NewPolicyEngine(config.PolicyFile{Rules: []config.PolicyRule{
+		{When: `tool == "unix" && command.startsWith("rm ")`, Decision: config.PolicyDecisionDeny, Reason: "no deletes"},
+	}})
+	require.NoError(t, err)
+	manager.SetPolicy(engine)
+
+	result, err := manager.ConfirmCall(PolicyContext{Action: `unix("rm file")`, Tool: "unix", Input: map[string]any{"command": "rm file"}}, false, true)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.True(t, result.PolicyBlocked)
+	assert.Equal(t, "no deletes", result.Reason)
+}
+
+func TestConfirmCallPolicyAskPassesReason(t *testing.T) {
+	var request TaskConfirmationRequest
+	manager := NewConfirmationManager(nil, nil, func(req TaskConfirmationRequest) (confirmationDecision, error) {
+		request = req
+		return confirmationDecision{allowed: true}, nil
+	}, nil)
+	engine, err := NewPolicyEngine(config.PolicyFile{Rules: []config.PolicyRule{
+		{When: `tool == "read"`, Decision: config.PolicyDecisionAsk, Reason: "reads are audited"},
+	}})
+	require.NoError(t, err)
+	manager.SetPolicy(engine)
+
+	result, err := manager.ConfirmCall(PolicyContext{Action: `read(path="x")`}, true, false)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, "reads are audited", request.Reason)
+	assert.Equal(t, `read(path="x")`, request.Action)
+}
+
+func TestConfirmCallCachedDecisionDoesNotSurviveBranchSwitch(t *testing.T) {
+	repo := t.TempDir()
+	head := filepath.Join(repo, ".git", "HEAD")
+	require.NoError(t, os.MkdirAll(filepath.Dir(head), 0o755))
+	require.NoError(t, os.WriteFile(head, []byte("ref: refs/heads/feature\n"), 0o644))
+
+	prompts := 0
+	manager := NewConfirmationManager(nil, nil, func(req TaskConfirmationRequest) (confirmationDecision, error) {
+		prompts++
+		return confirmationDecision{allowed: prompts == 1}, nil
+	}, nil)
+	engine, err := NewPolicyEngine(config.PolicyFile{Rules: []config.PolicyRule{
+		{When: `branch == "main" && !read_only`, Decision: config.PolicyDecisionAsk, Reason: "main is protected"},
+	}})
+	require.NoError(t, err)
+	manager.SetPolicy(engine)
+	call := PolicyContext{Action: `unix("make release")`, Tool: "unix", Input: map[string]any{"command": "make release"}, RootDir: repo}
+
+	result, err := manager.ConfirmCall(call, false, false)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	result, err = manager.ConfirmCall(call, false, false)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, prompts, "the decision is cached on the same branch")
+
+	require.NoError(t, os.WriteFile(head, []byte("ref: refs/heads/main\n"), 0o644))
+	result, err = manager.ConfirmCall(call, false, false)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, "main is protected", result.Reason)
+	assert.Equal(t, 2, prompts)
+}
+
+func TestConfirmCallPolicyAllowYieldsToDenyRule(t *testing.T) {
+	manager := NewConfirmationManager(nil, []config.PermissionRuleSet{{
+		Permissions: config.Permissions{Deny: []string{`file_edit(path="*")`}},
+	}}, nil, nil)
+	engine, err := NewPolicyEngine(config.PolicyFile{Rules: []config.PolicyRule{
+		{When: `input.path.endsWith(".md")`, Decision: config.PolicyDecisionAllow},
+	}})
+	require.NoError(t, err)
+	manager.SetPolicy(engine)
+
+	result, err := manager.ConfirmCall(PolicyContext{Action: `file_edit(path="a.md")`, Input: map[string]any{"path": "a.md"}}, false, false)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+}
+
+func TestPolicyPathsForUnixCommand(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	dirs := TaskDirs{RootDir: "/repo", CurrentDir: "/repo/sub"}
+
+	paths, known := policyPathsForCall(tools.ToolNameUnix, map[string]any{"command": "cp ./a.txt ~/b.txt > ../out.log && ls -la"}, dirs)
+
+	assert.True(t, known)
+	assert.Equal(t, []string{"/repo/sub/a.txt", "/home/me/b.txt", "/repo/out.log"}, paths)
+
+	for _, command := range []string{"rm -rf $HOME/x", "cat $(cat list)", "echo hi > \"$OUT\""} {
+		_, known = policyPathsForCall(tools.ToolNameUnix, map[string]any{"command": command}, dirs)
+		assert.False(t, known, command)
+	}
+}
+
+func TestPolicyUnknownPathsFailClosed(t *testing.T) {
+	engine, err := NewPolicyEngine(config.PolicyFile{Rules: []config.PolicyRule{
+		{When: `paths.all(p, p.startsWith("/repo"))`, Decision: config.PolicyDecisionAllow},
+		{When: `paths.exists(p, p.startsWith("/etc"))`, Decision: config.PolicyDecisionDeny, Reason: "system files"},
+	}})
+	require.NoError(t, err)
+
+	decision, matched := engine.Evaluate(PolicyContext{Tool: "unix", Input: map[string]any{"command": "rm -rf $HOME"}, PathsUnknown: true})
+	require.True(t, matched)
+	assert.Equal(t, config.PolicyDecisionAsk, decision.Decision)
+	assert.True(t, decision.Failed)
+	assert.Contains(t, decision.Reason, "paths is unknown")
+}
+
+func TestGitBranchReadsHead(t *testing.T) {
+	repo := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, ".git"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".git", "HEAD"), []byte("ref: refs/heads/feature/x\n"), 0o644))
+	nested := filepath.Join(repo, "a", "b")
+	require.NoError(t, os.MkdirAll(nested, 0o755))
+
+	assert.Equal(t, "feature/x", GitBranch(nested))
+	assert.Equal(t, "", GitBranch(t.TempDir()))
+}
+
+func TestConfirmCallManagedRulesBeatUserAllowAndAutoApprove(t *testing.T) {
+	prompted := 0
+	manager := NewConfirmationManager([]string{`unix("curl *")`, `file_edit(path="*")`}, []config.PermissionRuleSet{
+		{Permissions: config.Permissions{Allow: []string{`unix("curl *")`}}, Priority: 3},
+		{
+			Permissions: config.Permissions{Deny: []string{`unix("curl *")`}, Ask: []string{`file_edit(path="*")`}},
+			Priority:    config.ManagedPermissionPriority,
+			Managed:     true,
+		},
+	}, func(TaskConfirmationRequest) (confirmationDecision, error) {
+		prompted++
+		return confirmationDecision{allowed: false}, nil
+	}, nil)
+
+	result, err := manager.ConfirmCall(PolicyContext{Action: `unix("curl example.com")`}, true, true)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.True(t, result.PolicyBlocked)
+
+	result, err = manager.ConfirmCall(PolicyContext{Action: `file_edit(path="a.txt")`}, true, true)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 1, prompted)
+	assert.Equal(t, 3, manager.maxPriority)
+}
+
+func TestConfirmCallHighRiskPromptsUnderAutoApprove(t *testing.T) {
+	var request TaskConfirmationRequest
+	manager := NewConfirmationManager(nil, nil, func(req TaskConfirmationRequest) (confirmationDecision, error) {
+		request = req
+		return confirmationDecision{allowed: false}, nil
+	}, nil)
+	risk := assessUnixCommandRisk("rm -rf build", false)
+
+	result, err := manager.ConfirmCall(PolicyContext{Action: `unix("rm -rf build")`, Risk: risk}, false, true)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, CommandRiskHigh, request.Risk)
+	assert.Equal(t, []string{"recursive forced delete"}, request.RiskReasons)
+
+	allowed := NewConfirmationManager([]string{`unix("rm -rf build")`}, nil, nil, nil)
+	result, err = allowed.ConfirmCall(PolicyContext{Action: `unix("rm -rf build")`, Risk: risk}, false, true)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "an explicit allow rule lets a high-risk command run under auto-approve")
+
+	medium := NewConfirmationManager(nil, nil, nil, nil)
+	result, err = medium.ConfirmCall(PolicyContext{Action: `unix("touch a")`, Risk: CommandRisk{Level: CommandRiskMedium}}, false, true)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
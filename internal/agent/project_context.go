@@ -0,0 +1,317 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// projectContextOverrideFileName, in a directory, is used instead of its
+// regular context file and replaces the context inherited from the
+// directories above.
+const projectContextOverrideFileName = "AGENTS.override.md"
+
+// maxProjectContextIncludeDepth bounds nested @include directives.
+const maxProjectContextIncludeDepth = 5
+
+// includeDirective matches an "@include path" line.
+var includeDirective = regexp.MustCompile(`^\s*@include\s+(\S.*?)\s*$`)
+
+// ProjectContextSource is a file that contributed to the project context.
+type ProjectContextSource struct {
+	Path string
+	// IncludedFrom is the file whose @include pulled this one in; empty for
+	// files found in a directory.
+	IncludedFrom string
+	// Override is set for an AGENTS.override.md file.
+	Override bool
+}
+
+// ProjectContextSection is the context of one directory level, with its
+// @include directives expanded.
+type ProjectContextSection struct {
+	Dir     string
+	Content string
+	// Sources are the level's context file followed by the files it includes.
+	Sources []ProjectContextSource
+}
+
+// Override reports whether the section replaces the context above it.
+func (s ProjectContextSection) Override() bool {
+	return len(s.Sources) > 0 && s.Sources[0].Override
+}
+
+// ProjectContext is the context merged from the repository root down to a
+// working directory.
+type ProjectContext struct {
+	// Root is the repository root, or the working directory outside a
+	// repository.
+	Root     string
+	Sections []ProjectContextSection
+}
+
+// Sources lists every file that contributed, outermost first.
+func (pc ProjectContext) Sources() []ProjectContextSource {
+	var sources []ProjectContextSource
+	for _, section := range pc.Sections {
+		sources = append(sources, section.Sources...)
+	}
+	return sources
+}
+
+// Content is the merged context. With more than one level, each section is
+// headed by a comment naming the file it comes from.
+func (pc ProjectContext) Content() string {
+	return formatProjectContextSections(pc.Root, pc.Sections)
+}
+
+// Prompt is the merged context wrapped in <project_context> tags for the
+// system prompt, or "" when there is none.
+func (pc ProjectContext) Prompt() string {
+	content := pc.Content()
+	if content == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n<project_context>\n%s\n</project_context>\n", content)
+}
+
+func formatProjectContextSections(root string, sections []ProjectContextSection) string {
+	if len(sections) == 1 {
+		return sections[0].Content
+	}
+	parts := make([]string, 0, len(sections))
+	for _, section := range sections {
+		name := section.Sources[0].Path
+		if rel, err := filepath.Rel(root, name); err == nil && !strings.HasPrefix(rel, "..") {
+			name = filepath.ToSlash(rel)
+		}
+		parts = append(parts, fmt.Sprintf("<!-- %s -->\n%s", name, section.Content))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// LoadProjectContext reads the context files from the repository root down to
+// workingDir: at each level AGENTS.override.md, else the first of AGENTS.md,
+// CLAUDE.md or .agentrules. Deeper levels come later so they take precedence,
+// and an override file drops the levels above it. Outside a git repository
+// only workingDir is read.
+func LoadProjectContext(workingDir string) (ProjectContext, error) {
+	if workingDir == "" {
+		return ProjectContext{}, nil
+	}
+	workingDir, err := filepath.Abs(workingDir)
+	if err != nil {
+		return ProjectContext{}, err
+	}
+	if info, err := os.Stat(workingDir); err != nil || !info.IsDir() {
+		return ProjectContext{}, nil
+	}
+	root := findRepositoryRoot(workingDir)
+	pc := ProjectContext{Root: root}
+
+	for _, dir := range projectContextLevels(root, workingDir) {
+		path, override := projectContextFileAt(dir)
+		if path == "" {
+			continue
+		}
+		section := ProjectContextSection{Dir: dir}
+		source := ProjectContextSource{Path: path, Override: override}
+		content, err := readProjectContextFile(root, source, &section, nil)
+		if err != nil {
+			return ProjectContext{}, err
+		}
+		if override {
+			pc.Sections = nil
+		}
+		if content == "" {
+			continue
+		}
+		section.Content = content
+		pc.Sections = append(pc.Sections, section)
+	}
+	return pc, nil
+}
+
+// ReadProjectContext reads the project context for the working directory.
+// Returns the merged content wrapped in <project_context> tags, or an empty
+// string if no context file exists.
+func ReadProjectContext(workingDir string) (string, error) {
+	pc, err := LoadProjectContext(workingDir)
+	if err != nil {
+		return "", err
+	}
+	return pc.Prompt(), nil
+}
+
+// findRepositoryRoot returns the nearest directory at or above dir holding a
+// .git entry, or dir itself when there is none.
+func findRepositoryRoot(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// projectContextLevels lists root and each directory below it down to dir.
+func projectContextLevels(root, dir string) []string {
+	levels := []string{dir}
+	for dir != root {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+		levels = append(levels, dir)
+	}
+	for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+		levels[i], levels[j] = levels[j], levels[i]
+	}
+	return levels
+}
+
+// projectContextFileAt returns the context file of one directory, and whether
+// it is an override.
+func projectContextFileAt(dir string) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), projectContextOverrideFileName) && !entry.IsDir() {
+			return filepath.Join(dir, entry.Name()), true
+		}
+	}
+	return discoverProjectContextFile(dir), false
+}
+
+// readProjectContextFile reads a context file, replacing each @include line
+// with the included file's content. Relative include paths are resolved
+// against the including file's directory. stack holds the files being
+// included, to reject cycles.
+//
+// Every file must resolve, symlinks followed, to a path inside root: a cloned
+// repository's context files must not pull files such as credentials from
+// elsewhere on the machine into the prompt.
+func readProjectContextFile(root string, source ProjectContextSource, section *ProjectContextSection, stack []string) (string, error) {
+	if err := checkProjectContextPath(root, source); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(source.Path)
+	if err != nil {
+		if source.IncludedFrom != "" {
+			return "", fmt.Errorf("failed to include %s from %s: %w", source.Path, source.IncludedFrom, err)
+		}
+		return "", fmt.Errorf("failed to read project context file %s: %w", source.Path, err)
+	}
+	section.Sources = append(section.Sources, source)
+	stack = append(stack, source.Path)
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		match := includeDirective.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		path := expandIncludePath(match[1], filepath.Dir(source.Path))
+		for _, open := range stack {
+			if open == path {
+				return "", fmt.Errorf("project context file %s includes itself through %s", path, source.Path)
+			}
+		}
+		if len(stack) > maxProjectContextIncludeDepth {
+			return "", fmt.Errorf("project context includes nest deeper than %d levels at %s", maxProjectContextIncludeDepth, source.Path)
+		}
+		included, err := readProjectContextFile(root, ProjectContextSource{Path: path, IncludedFrom: source.Path}, section, stack)
+		if err != nil {
+			return "", err
+		}
+		lines[i] = included
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+func expandIncludePath(path, baseDir string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// checkProjectContextPath rejects a context file that resolves outside root.
+// A file that does not exist is left for the read to report.
+func checkProjectContextPath(root string, source ProjectContextSource) error {
+	resolved, err := filepath.EvalSymlinks(source.Path)
+	if err != nil {
+		return nil
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		resolvedRoot = root
+	}
+	if rel, err := filepath.Rel(resolvedRoot, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	if source.IncludedFrom != "" {
+		return fmt.Errorf("project context file %s includes %s, which is outside the repository %s", source.IncludedFrom, source.Path, root)
+	}
+	return fmt.Errorf("project context file %s resolves to %s, which is outside the repository %s", source.Path, resolved, root)
+}
+
+// projectContextTracker follows the context files a task run has seen, so
+// that moving into a subdirectory with change_directory adds the context
+// files found there.
+type projectContextTracker struct {
+	seen map[string]bool
+}
+
+// newProjectContextTracker records the context files already in the system
+// prompt, the ones of rootDir.
+func newProjectContextTracker(rootDir string) *projectContextTracker {
+	tracker := &projectContextTracker{seen: map[string]bool{}}
+	if pc, err := LoadProjectContext(rootDir); err == nil {
+		for _, section := range pc.Sections {
+			tracker.seen[section.Dir] = true
+		}
+	}
+	return tracker
+}
+
+// enter returns the context of dir not given to the run yet, wrapped in
+// <project_context> tags, or "" when there is none.
+func (t *projectContextTracker) enter(dir string) (string, error) {
+	pc, err := LoadProjectContext(dir)
+	if err != nil {
+		return "", err
+	}
+	var fresh []ProjectContextSection
+	override := false
+	for _, section := range pc.Sections {
+		if t.seen[section.Dir] {
+			continue
+		}
+		t.seen[section.Dir] = true
+		override = override || section.Override()
+		fresh = append(fresh, section)
+	}
+	if len(fresh) == 0 {
+		return "", nil
+	}
+	intro := "Project context for this directory, in addition to the one given earlier:"
+	if override {
+		intro = "Project context for this directory; it replaces the project context given earlier:"
+	}
+	// Name the files even for a single section, since the run has seen others.
+	parts := make([]string, 0, len(fresh))
+	for _, section := range fresh {
+		parts = append(parts, fmt.Sprintf("<!-- %s -->\n%s", section.Sources[0].Path, section.Content))
+	}
+	return fmt.Sprintf("%s\n<project_context>\n%s\n</project_context>", intro, strings.Join(parts, "\n\n")), nil
+}
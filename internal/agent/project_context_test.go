@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// projectTree writes files under a new temporary repository root.
+func projectTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, ".git"), 0o755))
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		requireWriteFile(t, path, content)
+	}
+	return root
+}
+
+func sourcePaths(pc ProjectContext) []string {
+	var paths []string
+	for _, source := range pc.Sources() {
+		paths = append(paths, source.Path)
+	}
+	return paths
+}
+
+func TestLoadProjectContextMergesLevels(t *testing.T) {
+	root := projectTree(t, map[string]string{
+		"AGENTS.md":              "root rules",
+		"services/CLAUDE.md":     "services rules",
+		"services/api/.keep":     "",
+		"services/api/README.md": "not context",
+	})
+
+	pc, err := LoadProjectContext(filepath.Join(root, "services", "api"))
+	require.NoError(t, err)
+	assert.Equal(t, root, pc.Root)
+	assert.Equal(t, []string{filepath.Join(root, "AGENTS.md"), filepath.Join(root, "services", "CLAUDE.md")}, sourcePaths(pc))
+	assert.Equal(t, "<!-- AGENTS.md -->\nroot rules\n\n<!-- services/CLAUDE.md -->\nservices rules", pc.Content())
+
+	content, err := ReadProjectContext(root)
+	require.NoError(t, err)
+	assert.Equal(t, "\n<project_context>\nroot rules\n</project_context>\n", content)
+}
+
+func TestLoadProjectContextOutsideRepositoryReadsOnlyWorkingDir(t *testing.T) {
+	parent := t.TempDir()
+	requireWriteFile(t, filepath.Join(parent, "AGENTS.md"), "parent rules")
+	dir := filepath.Join(parent, "child")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	requireWriteFile(t, filepath.Join(dir, "AGENTS.md"), "child rules")
+
+	pc, err := LoadProjectContext(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "child rules", pc.Content())
+}
+
+func TestLoadProjectContextOverride(t *testing.T) {
+	root := projectTree(t, map[string]string{
+		"AGENTS.md":                 "root rules",
+		"vendor/AGENTS.md":          "ignored",
+		"vendor/AGENTS.override.md": "vendored code, do not edit",
+		"vendor/lib/AGENTS.md":      "lib rules",
+	})
+
+	pc, err := LoadProjectContext(filepath.Join(root, "vendor", "lib"))
+	require.NoError(t, err)
+	require.Len(t, pc.Sections, 2)
+	assert.True(t, pc.Sections[0].Override())
+	assert.Equal(t, "<!-- vendor/AGENTS.override.md -->\nvendored code, do not edit\n\n<!-- vendor/lib/AGENTS.md -->\nlib rules", pc.Content())
+}
+
+func TestLoadProjectContextIncludes(t *testing.T) {
+	root := projectTree(t, map[string]string{
+		"AGENTS.md":         "# Rules\n@include docs/style.md\nend",
+		"docs/style.md":     "style rules\n  @include ../shared/naming.md",
+		"shared/naming.md":  "naming rules",
+		"loop/AGENTS.md":    "@include self.md",
+		"loop/self.md":      "@include AGENTS.md",
+		"missing/AGENTS.md": "@include nowhere.md",
+	})
+
+	pc, err := LoadProjectContext(root)
+	require.NoError(t, err)
+	assert.Equal(t, "# Rules\nstyle rules\nnaming rules\nend", pc.Content())
+	sources := pc.Sources()
+	require.Len(t, sources, 3)
+	assert.Equal(t, filepath.Join(root, "docs", "style.md"), sources[1].Path)
+	assert.Equal(t, filepath.Join(root, "AGENTS.md"), sources[1].IncludedFrom)
+	assert.Equal(t, filepath.Join(root, "docs", "style.md"), sources[2].IncludedFrom)
+
+	_, err = LoadProjectContext(filepath.Join(root, "loop"))
+	assert.ErrorContains(t, err, "includes itself")
+
+	_, err = LoadProjectContext(filepath.Join(root, "missing"))
+	assert.ErrorContains(t, err, "failed to include "+filepath.Join(root, "missing", "nowhere.md"))
+}
+
+func TestLoadProjectContextRejectsFilesOutsideRepository(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "credentials")
+	requireWriteFile(t, outside, "aws_secret_access_key = abc")
+	root := projectTree(t, map[string]string{
+		"absolute/AGENTS.md": "@include " + outside,
+		"parent/AGENTS.md":   "@include ../../" + filepath.Base(filepath.Dir(outside)) + "/credentials",
+		"linked/AGENTS.md":   "@include notes.md",
+	})
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "linked", "notes.md")))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "self"), 0o755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "self", "AGENTS.md")))
+
+	for _, dir := range []string{"absolute", "parent", "linked"} {
+		_, err := LoadProjectContext(filepath.Join(root, dir))
+		assert.ErrorContains(t, err, "which is outside the repository "+root, dir)
+	}
+	_, err := LoadProjectContext(filepath.Join(root, "self"))
+	assert.ErrorContains(t, err, "resolves to "+outside+", which is outside the repository")
+}
+
+func TestProjectContextTrackerAddsNestedContext(t *testing.T) {
+	root := projectTree(t, map[string]string{
+		"AGENTS.md":          "root rules",
+		"services/AGENTS.md": "services rules",
+		"docs/.keep":         "",
+	})
+
+	tracker := newProjectContextTracker(root)
+
+	nested, err := tracker.enter(filepath.Join(root, "docs"))
+	require.NoError(t, err)
+	assert.Empty(t, nested)
+
+	nested, err = tracker.enter(filepath.Join(root, "services"))
+	require.NoError(t, err)
+	assert.Contains(t, nested, "in addition to the one given earlier")
+	assert.Contains(t, nested, "<project_context>\n<!-- "+filepath.Join(root, "services", "AGENTS.md")+" -->\nservices rules\n</project_context>")
+	assert.NotContains(t, nested, "root rules")
+
+	nested, err = tracker.enter(filepath.Join(root, "services"))
+	require.NoError(t, err)
+	assert.Empty(t, nested, "context is only added once")
+}
@@ -14,15 +14,17 @@ import (
 
 // SystemInfo contains information about the current system environment
 type SystemInfo struct {
-	Hostname           string
-	Username           string
-	CurrentTime        string
-	WorkingDir         string
-	OS                 string
-	OSVersion          string
-	Architecture       string
-	GoVersion          string
-	ProjectContextPath string
+	Hostname     string
+	Username     string
+	CurrentTime  string
+	WorkingDir   string
+	OS           string
+	OSVersion    string
+	Architecture string
+	GoVersion    string
+	// ProjectContextPaths are the context files merged into the project
+	// context, outermost first.
+	ProjectContextPaths []string
 }
 
 // GetSystemInfo collects information about the current system.
@@ -60,8 +62,12 @@ func GetSystemInfo(workingDir string) SystemInfo {
 	// Get OS version
 	info.OSVersion = getOSVersion()
 
-	// Discover project context file path
-	info.ProjectContextPath = discoverProjectContextFile(info.WorkingDir)
+	// Discover project context files
+	if pc, err := LoadProjectContext(info.WorkingDir); err == nil {
+		for _, section := range pc.Sections {
+			info.ProjectContextPaths = append(info.ProjectContextPaths, section.Sources[0].Path)
+		}
+	}
 
 	return info
 }
@@ -87,8 +93,8 @@ Current system context:
 - Operating System: %s
 `, info.Hostname, info.Username, info.CurrentTime, info.WorkingDir, osLine)
 
-	if info.ProjectContextPath != "" {
-		header += fmt.Sprintf("- Project Context: %s\n", info.ProjectContextPath)
+	if len(info.ProjectContextPaths) > 0 {
+		header += fmt.Sprintf("- Project Context: %s\n", strings.Join(info.ProjectContextPaths, ", "))
 	}
 
 	return header
@@ -123,28 +129,6 @@ func discoverProjectContextFile(workingDir string) string {
 	return ""
 }
 
-// ReadProjectContext reads the project context file from the working directory.
-// Returns the content wrapped in <project_context> tags, or an empty string if no
-// context file exists.
-func ReadProjectContext(workingDir string) (string, error) {
-	path := discoverProjectContextFile(workingDir)
-	if path == "" {
-		return "", nil
-	}
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return "", fmt.Errorf("failed to read project context file %s: %w", path, err)
-	}
-
-	content := strings.TrimSpace(string(data))
-	if content == "" {
-		return "", nil
-	}
-
-	return fmt.Sprintf("\n<project_context>\n%s\n</project_context>\n", content), nil
-}
-
 // getOSVersion returns a human-readable OS name and version.
 func getOSVersion() string {
 	switch runtime.GOOS {
@@ -228,6 +212,7 @@ Prefer native tools for editing and searching files (file_edit, file_search). Us
 For output-oriented tools such as unix, python, and file_search: Use final=true ONLY when the raw output is definitely the complete final user-facing answer: concise, clean, readable, and requiring no interpretation. Never use final=true for exploratory commands, listings, searches, validation checks, or any step before a requested create/edit/delete/install/initialize/configure action is complete. If the output needs interpretation, filtering, grouping, cleanup, explanation, validation, or follow-up action, do not set final=true; let the agent inspect the result and continue.
 For process tools such as unix and python: use timeout for bounded observation or commands that may not terminate, and use max_bytes for noisy commands. Omit them for safe defaults; set either value to 0 only when the user explicitly wants unlimited runtime or capture.
 When creating a new file, use file_edit with operation "write" and the target path. file_edit and file_search are confined to the current allowed scope; attempts outside that scope require user permission before the tool can run. Read approval does not grant write approval.
+Use recall to check long-term memory for conventions of the project you work in. When you discover a durable fact that later runs would need (e.g. how the project builds or tests), save it with remember; the user confirms each entry.
 If you are not sure about anything pertaining to the user's request, use your tools to read files and gather the relevant information: do NOT guess or make up an answer.
 
 You MUST plan extensively before each function call, and reflect extensively on the outcomes of the previous function calls. DO NOT do this entire process by making function calls only, as this can impair your ability to solve the problem and think insightfully.
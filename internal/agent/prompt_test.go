@@ -214,6 +214,24 @@ func TestSystemPromptHeaderIncludesProjectContextPath(t *testing.T) {
 		}
 	})
 
+	t.Run("lists every merged level", func(t *testing.T) {
+		root := t.TempDir()
+		if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+			t.Fatalf("failed to create .git: %v", err)
+		}
+		sub := filepath.Join(root, "sub")
+		if err := os.Mkdir(sub, 0o755); err != nil {
+			t.Fatalf("failed to create sub: %v", err)
+		}
+		requireWriteFile(t, filepath.Join(root, "AGENTS.md"), "root")
+		requireWriteFile(t, filepath.Join(sub, "CLAUDE.md"), "sub")
+		header := SystemPromptHeader(sub)
+		want := "- Project Context: " + filepath.Join(root, "AGENTS.md") + ", " + filepath.Join(sub, "CLAUDE.md") + "\n"
+		if !strings.Contains(header, want) {
+			t.Errorf("expected header to contain %q, got:\n%s", want, header)
+		}
+	})
+
 	t.Run("omits context line when no file exists", func(t *testing.T) {
 		dir := t.TempDir()
 		header := SystemPromptHeader(dir)
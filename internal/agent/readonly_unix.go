@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/laszukdawid/terminal-agent/internal/config"
 	"mvdan.cc/sh/v3/syntax"
 )
 
@@ -99,9 +100,16 @@ type unixSafetyContext struct {
 	rootDir    string
 	currentDir string
 	loopVars   map[string]struct{}
+	commands   commandRegistry
 }
 
 func isReadOnlyUnixCommandInDirs(command string, dirs TaskDirs) bool {
+	return isReadOnlyUnixCommand(command, dirs, nil)
+}
+
+// isReadOnlyUnixCommand is isReadOnlyUnixCommandInDirs with programs outside the
+// built-in allowlist classified by the given command-policy registry.
+func isReadOnlyUnixCommand(command string, dirs TaskDirs, commands commandRegistry) bool {
 	command = strings.TrimSpace(command)
 	if command == "" {
 		return false
@@ -113,7 +121,7 @@ func isReadOnlyUnixCommandInDirs(command string, dirs TaskDirs) bool {
 		return false
 	}
 
-	ctx := unixSafetyContext{rootDir: dirs.RootDir, currentDir: dirs.CurrentDir, loopVars: map[string]struct{}{}}
+	ctx := unixSafetyContext{rootDir: dirs.RootDir, currentDir: dirs.CurrentDir, loopVars: map[string]struct{}{}, commands: commands}
 	for _, stmt := range file.Stmts {
 		if !isReadOnlyStmt(stmt, &ctx) {
 			return false
@@ -199,7 +207,7 @@ func isReadOnlyCall(call *syntax.CallExpr, ctx *unixSafetyContext, allowDirector
 
 	policy, ok := readOnlyUnixCommands[name]
 	if !ok {
-		return false
+		return isReadOnlyRegisteredCall(name, call.Args[1:], ctx)
 	}
 	args := make([]string, 0, len(call.Args))
 	for i, word := range call.Args {
@@ -223,6 +231,81 @@ func isReadOnlyCall(call *syntax.CallExpr, ctx *unixSafetyContext, allowDirector
 	return readOnlyCommandAllowsArgs(policy, args[1:])
 }
 
+// isReadOnlyRegisteredCall approves a program outside the allowlist when the
+// command-policy registry classifies its static arguments as read and every
+// path they name stays inside the task root.
+func isReadOnlyRegisteredCall(name string, words []*syntax.Word, ctx *unixSafetyContext) bool {
+	for _, word := range words {
+		if !isStaticShellWord(word) {
+			return false
+		}
+	}
+	args, ok := staticShellWordLits(words)
+	if !ok {
+		return false
+	}
+	access, known := ctx.commands.classify(name, args)
+	return known && access == config.CommandAccessRead && registeredCallPathsInRoot(args, ctx)
+}
+
+// registeredCallPathsInRoot reports whether the path-like arguments, option
+// values such as --git-dir=PATH and the path of a REV:PATH argument included,
+// resolve inside the task root, as a cd in a read-only command must.
+func registeredCallPathsInRoot(args []string, ctx *unixSafetyContext) bool {
+	for _, arg := range args {
+		value := arg
+		if strings.HasPrefix(value, "-") {
+			_, optionValue, ok := strings.Cut(value, "=")
+			if !ok {
+				continue
+			}
+			value = optionValue
+		}
+		if rev, path, ok := strings.Cut(value, ":"); ok && !strings.Contains(rev, "/") {
+			value = path
+		}
+		if looksLikePath(value) && !readOnlyPathInRoot(value, ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+// readOnlyPathInRoot reports whether path, resolved against the current
+// directory with symlinks followed as far as it exists, is inside the root.
+// Without a root only relative paths that do not climb out are accepted.
+func readOnlyPathInRoot(path string, ctx *unixSafetyContext) bool {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return false
+	}
+	if ctx == nil || ctx.rootDir == "" {
+		return filepath.IsLocal(path)
+	}
+	if !filepath.IsAbs(path) {
+		base := ctx.currentDir
+		if base == "" {
+			base = ctx.rootDir
+		}
+		path = filepath.Join(base, path)
+	}
+	rootDir, err := filepath.Abs(ctx.rootDir)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(rootDir); err == nil {
+		rootDir = resolved
+	}
+	target, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		target = resolved
+	}
+	rel, err := filepath.Rel(rootDir, target)
+	return err == nil && rel != parentRelPath && !strings.HasPrefix(rel, parentRelPath+string(os.PathSeparator))
+}
+
 func isDirectoryChangeCommand(name string) bool {
 	return name == unixCommandCD
 }
@@ -42,6 +42,15 @@ func TestIsReadOnlyUnixCommandInDirsWithoutPathContext(t *testing.T) {
 		{name: "sed extended expression", command: `printf '%s\n' hello | sed -E 's/(h)ello/\1i/'`, want: true},
 		{name: "sed multiple expressions", command: `printf '%s\n' hello | sed -e 's/h/H/' -e 's/o/O/'`, want: true},
 		{name: "semicolon safe commands", command: "pwd; ls", want: true},
+		{name: "registry git log", command: "git log --oneline -n 5 | head", want: true},
+		{name: "registry kubectl get", command: "kubectl get pods -n default", want: true},
+		{name: "registry docker ps", command: "docker ps -a", want: true},
+		{name: "registry go list", command: "go list ./...", want: true},
+		{name: "registry go list toolexec", command: "go list -export -toolexec=rm .", want: false},
+		{name: "registry git commit", command: "git commit -m wip", want: false},
+		{name: "registry git output flag", command: "git diff --output=out.patch", want: false},
+		{name: "registry git fetch", command: "git fetch origin", want: false},
+		{name: "registry dynamic argument", command: "git log $(rm file)", want: false},
 		{name: "find delete", command: "find . -delete", want: false},
 		{name: "find exec", command: `find . -exec rm {} \;`, want: false},
 		{name: "redirection", command: "ls > out.txt", want: false},
@@ -71,8 +71,9 @@ func TestTaskWithOptionsResultRespectsConfiguredStepBudget(t *testing.T) {
 func TestBuildTaskToolsExternalFacingPolicy(t *testing.T) {
 	agentInstance := &Agent{
 		Tools: map[string]tools.Tool{
-			"local": &schemaOutputTool{name: "local"},
-			"ext":   &externalSchemaTool{schemaOutputTool: &schemaOutputTool{name: "ext"}},
+			"local":    &schemaOutputTool{name: "local"},
+			"ext":      &externalSchemaTool{schemaOutputTool: &schemaOutputTool{name: "ext"}},
+			"remember": &schemaOutputTool{name: tools.ToolNameRemember},
 		},
 	}
 	interaction := &fakeTaskInteraction{}
@@ -81,6 +82,7 @@ func TestBuildTaskToolsExternalFacingPolicy(t *testing.T) {
 		got := agentInstance.buildTaskTools(interaction, nil, true)
 		assert.Contains(t, got, "local")
 		assert.NotContains(t, got, "ext")
+		assert.NotContains(t, got, tools.ToolNameRemember)
 		// Task-only tools are always present.
 		assert.Contains(t, got, ToolNameFinalAnswer)
 		assert.Contains(t, got, UserClarificationToolName)
@@ -90,6 +92,7 @@ func TestBuildTaskToolsExternalFacingPolicy(t *testing.T) {
 		got := agentInstance.buildTaskTools(interaction, nil, false)
 		assert.Contains(t, got, "local")
 		assert.Contains(t, got, "ext", "normal task runs must not lose external tools")
+		assert.Contains(t, got, tools.ToolNameRemember)
 	})
 
 	t.Run("explicit allow-list opts external tool back in", func(t *testing.T) {
@@ -162,4 +165,16 @@ func TestUnattendedInteraction(t *testing.T) {
 		require.NoError(t, err)
 		assert.True(t, decision.Allowed)
 	})
+
+	t.Run("confirm declines high-risk commands", func(t *testing.T) {
+		decision, err := UnattendedInteraction{}.Confirm(TaskConfirmationRequest{Action: `unix("rm -rf /")`, Risk: CommandRiskHigh})
+		require.NoError(t, err)
+		assert.False(t, decision.Allowed)
+	})
+
+	t.Run("confirm declines when required", func(t *testing.T) {
+		decision, err := UnattendedInteraction{DeclineConfirmations: true}.Confirm(TaskConfirmationRequest{Action: `unix("ls")`})
+		require.NoError(t, err)
+		assert.False(t, decision.Allowed)
+	})
 }
@@ -8,6 +8,8 @@ import (
 
 	"github.com/laszukdawid/terminal-agent/internal/config"
 	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/laszukdawid/terminal-agent/internal/egress"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
 	"github.com/laszukdawid/terminal-agent/internal/tools"
 	log "github.com/laszukdawid/terminal-agent/internal/utils"
 	"go.uber.org/zap"
@@ -50,9 +52,29 @@ type TaskOptions struct {
 	// here).
 	EnabledTools []string
 	// DisableExternalTools, when true and EnabledTools is nil, drops external-facing
-	// tools (web search, MCP) from the run. Routines set this; interactive task runs
-	// leave it false to preserve access to all available tools.
+	// tools (web search, MCP) and remember from the run. Routines set this;
+	// interactive task runs leave it false to preserve access to all available
+	// tools.
 	DisableExternalTools bool
+	// RunKind ("task" or "routine") and RoutineID describe the run to expression
+	// policy rules.
+	RunKind   string
+	RoutineID string
+	// RunID identifies the run's session log. Tools that persist state, such
+	// as remember, record it.
+	RunID string
+	// Redactor masks secrets in the task query, tool outputs and errors before
+	// they reach the model or the recorded steps. Nil disables redaction.
+	Redactor *redact.Redactor
+	// OnEgress receives every host checked against the egress policy: hosts
+	// declared by external-facing tools and requests through the egress proxy.
+	OnEgress func(egress.Request)
+	// DryRun simulates every call that could change something (writes, unix
+	// commands that are not read-only, code execution and other gated tools):
+	// once the policy allows it, the call is recorded as a simulated step and
+	// the model is told it succeeded, instead of running it. Read-only calls
+	// still run so the model sees real state.
+	DryRun bool
 }
 
 type TaskToolOutputEvent struct {
@@ -145,6 +167,20 @@ type taskExecutionState struct {
 	onProgress        func(TaskProgressEvent)
 	onToolOutput      func(TaskToolOutputEvent) error
 	autoApprove       bool
+	runKind           string
+	routineID         string
+	runID             string
+	commands          commandRegistry
+	redactor          *redact.Redactor
+	egress            *egress.Policy
+	egressProxy       bool
+	onEgress          func(egress.Request)
+	dryRun            bool
+	// toolEnv is added to the environment of processes started by tools.
+	toolEnv []string
+	// projectContext adds the context files of directories the run moves
+	// into; nil when project context is disabled.
+	projectContext *projectContextTracker
 }
 
 func (r *taskExecutionState) appendStep(step TaskStep) {
@@ -158,12 +194,12 @@ type taskUserConfirmationRequester struct {
 	interaction TaskInteraction
 }
 
-func (r taskUserConfirmationRequester) RequestUserConfirmation(action string) (confirmationDecision, error) {
+func (r taskUserConfirmationRequester) RequestUserConfirmation(request TaskConfirmationRequest) (confirmationDecision, error) {
 	if r.interaction == nil {
 		return confirmationDecision{}, ErrTaskInteractionRequired
 	}
 
-	decision, err := r.interaction.Confirm(TaskConfirmationRequest{Action: action})
+	decision, err := r.interaction.Confirm(request)
 	if err != nil {
 		return confirmationDecision{}, err
 	}
@@ -230,6 +266,14 @@ func (a *Agent) runTaskLoop(ctx context.Context, s string, options TaskOptions)
 		return TaskRunResult{}, err
 	}
 	defer func() { result.TokensUsed = run.state.TokensUsed }()
+	if run.egressProxy {
+		proxy, err := egress.StartProxy(run.egress, run.onEgress)
+		if err != nil {
+			return TaskRunResult{}, fmt.Errorf("failed to start egress proxy: %w", err)
+		}
+		defer proxy.Close()
+		run.toolEnv = proxy.Env()
+	}
 
 	for run.state.Phase == TaskPhaseRunning && run.state.Iterations < run.state.MaxTurns && run.state.ToolCalls < run.state.MaxIterations {
 		if err := ctx.Err(); err != nil {
@@ -266,6 +310,11 @@ func (a *Agent) newTaskExecutionState(query string, options TaskOptions) (*taskE
 	if err != nil {
 		return nil, err
 	}
+	if options.AutoApprove {
+		if err := config.CheckAutoApproveAllowed(); err != nil {
+			return nil, err
+		}
+	}
 
 	ruleSets, store, err := config.LoadPermissionRuleSets(taskDirs.RootDir)
 	if err != nil {
@@ -291,10 +340,29 @@ func (a *Agent) newTaskExecutionState(query string, options TaskOptions) (*taskE
 	if len(options.Deny) > 0 {
 		confirmations.appendPatterns(options.Deny, ruleDeny, confirmations.maxPriority+2)
 	}
+	policyFiles, err := config.LoadPolicyFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy: %w", err)
+	}
+	if len(policyFiles) > 0 {
+		policy, err := NewPolicyEngine(policyFiles...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load policy: %w", err)
+		}
+		confirmations.SetPolicy(policy)
+	}
+	commandPolicies, err := config.LoadCommandPolicies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load command policies: %w", err)
+	}
+	egressConfig, err := config.LoadEgressConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load egress policy: %w", err)
+	}
 
 	return &taskExecutionState{
 		state: &TaskState{
-			OriginalQuery: query,
+			OriginalQuery: options.Redactor.String(query),
 			MaxIterations: maxToolCalls,
 			MaxTurns:      maxTurns,
 			TokenBudget:   options.TokenBudget,
@@ -310,9 +378,28 @@ func (a *Agent) newTaskExecutionState(query string, options TaskOptions) (*taskE
 		onProgress:        options.OnProgress,
 		onToolOutput:      options.OnToolOutput,
 		autoApprove:       options.AutoApprove,
+		runKind:           options.RunKind,
+		routineID:         options.RoutineID,
+		runID:             options.RunID,
+		commands:          newCommandRegistry(commandPolicies),
+		redactor:          options.Redactor,
+		egress:            egress.NewPolicy(egressConfig),
+		egressProxy:       egressConfig.Proxy,
+		onEgress:          options.OnEgress,
+		dryRun:            options.DryRun,
+		projectContext:    a.newProjectContextTracker(taskDirs.RootDir),
 	}, nil
 }
 
+// newProjectContextTracker returns nil when project context is disabled, as
+// the system prompt then has none either.
+func (a *Agent) newProjectContextTracker(rootDir string) *projectContextTracker {
+	if a.config == nil || !a.config.GetProjectContext() || rootDir == "" {
+		return nil
+	}
+	return newProjectContextTracker(rootDir)
+}
+
 func (a *Agent) runTaskIteration(ctx context.Context, logger *zap.SugaredLogger, run *taskExecutionState) (TaskRunResult, bool, error) {
 	run.emitStatus(TaskStatusThinking, "Thinking", "", nil)
 	response, err := a.queryTaskResponse(ctx, run)
@@ -402,23 +489,61 @@ func (a *Agent) handleTaskToolResponse(ctx context.Context, logger *zap.SugaredL
 		run.state.ToolCalls++
 		return TaskRunResult{}, false, nil
 	}
-	allowed, err := run.confirmTool(tool, response)
+	if blocked, ok := run.checkEgress(ctx, tool, response); !ok {
+		run.recordDeclined(response, blocked)
+		return TaskRunResult{}, false, nil
+	}
+	confirmation, err := run.confirmToolCall(tool, response)
 	if err != nil {
 		logger.Errorw("Tool confirmation failed", "tool", response.ToolName, "error", err)
 		return TaskRunResult{}, false, fmt.Errorf("tool confirmation failed: %w", err)
 	}
-	if !allowed {
-		run.recordDeclined(response)
+	if !confirmation.Allowed {
+		run.recordDeclined(response, confirmation)
 		return TaskRunResult{}, false, nil
 	}
 	run.expandAllowedScopeForApprovedTool(tool, response)
 	if err := ctx.Err(); err != nil {
 		return TaskRunResult{}, false, err
 	}
+	if run.dryRun && !run.safeInDryRun(tool, response.ToolInput) {
+		run.recordSimulated(response)
+		run.state.ToolCalls++
+		return TaskRunResult{}, false, nil
+	}
 
 	return a.executeTaskTool(ctx, logger, run, tool, response)
 }
 
+// safeInDryRun reports whether a call runs for real in a dry run: read-only
+// calls and the task-only tools that only talk to the user or end the run.
+func (r *taskExecutionState) safeInDryRun(tool tools.Tool, input map[string]any) bool {
+	switch tool.Name() {
+	case ToolNameFinalAnswer, UserClarificationToolName:
+		return true
+	case tools.ToolNameUnix:
+		command, _ := input["command"].(string)
+		return isReadOnlyUnixCommand(command, r.state.Dirs, r.commands)
+	}
+	return permissionCategoryFor(tool) == tools.PermissionRead
+}
+
+// checkEgress checks the hosts a tool declares against the egress policy, CIDR
+// rules matched against the addresses they resolve to, and reports each to
+// onEgress. A denied host blocks the call like a policy rule.
+func (r *taskExecutionState) checkEgress(ctx context.Context, tool tools.Tool, response connector.LlmResponseWithTools) (ConfirmationResult, bool) {
+	for _, host := range tools.EgressHosts(tool, response.ToolInput) {
+		err := r.egress.CheckResolved(ctx, host)
+		if r.onEgress != nil {
+			r.onEgress(egress.Request{Tool: response.ToolName, Host: host, Allowed: err == nil})
+		}
+		if err != nil {
+			return ConfirmationResult{PolicyBlocked: true, Reason: err.Error()}, false
+		}
+	}
+	return ConfirmationResult{Allowed: true}, true
+}
+
 func (r *taskExecutionState) expandAllowedScopeForApprovedTool(tool tools.Tool, response connector.LlmResponseWithTools) {
 	scope := r.requestedAdditionalScope(tool, response.ToolInput)
 	if scope == "" {
@@ -453,7 +578,13 @@ func (a *Agent) executeTaskTool(ctx context.Context, logger *zap.SugaredLogger,
 		return TaskRunResult{}, false, err
 	}
 	run.emitStatus(TaskStatusRunningTool, formatRunningToolStatus(tool, response.ToolInput), response.ToolName, response.ToolInput)
-	toolResult, err := runTaskTool(ctx, tool, response.ToolInput, run.state.Dirs, newTaskToolOutputWriter(ctx, response.ToolName, run.onToolOutput), run.progressReporter(response.ToolName))
+	toolResult, err := runTaskTool(ctx, tool, response.ToolInput, run.state.Dirs, tools.ToolExecutionContext{
+		Output:    newTaskToolOutputWriter(ctx, response.ToolName, run.onToolOutput),
+		Progress:  run.progressReporter(response.ToolName),
+		Env:       run.toolEnv,
+		RunID:     run.runID,
+		RoutineID: run.routineID,
+	})
 	if err != nil {
 		if ctxErr := ctx.Err(); ctxErr != nil {
 			return TaskRunResult{}, false, ctxErr
@@ -466,6 +597,7 @@ func (a *Agent) executeTaskTool(ctx context.Context, logger *zap.SugaredLogger,
 	if err := ctx.Err(); err != nil {
 		return TaskRunResult{}, false, err
 	}
+	toolResult = run.redactor.String(toolResult)
 
 	run.state.ToolCalls++
 	run.recordSuccess(response, toolResult)
@@ -512,11 +644,43 @@ func (a *Agent) finalizeTaskRun(ctx context.Context, run *taskExecutionState) (T
 }
 
 func (r *taskExecutionState) confirmTool(tool tools.Tool, response connector.LlmResponseWithTools) (bool, error) {
+	result, err := r.confirmToolCall(tool, response)
+	return result.Allowed, err
+}
+
+func (r *taskExecutionState) confirmToolCall(tool tools.Tool, response connector.LlmResponseWithTools) (ConfirmationResult, error) {
 	autoAllow := r.autoAllowsTool(tool, response.ToolInput)
 	if !autoAllow && !r.autoApprove {
 		r.emitStatus(TaskStatusAwaitingConfirmation, fmt.Sprintf("Awaiting confirmation for %s...", response.ToolName), response.ToolName, response.ToolInput)
 	}
-	return r.confirmations.ConfirmWithPolicy(BuildActionString(response.ToolName, response.ToolInput), autoAllow, r.autoApprove)
+	return r.confirmations.ConfirmCall(r.policyContext(tool, response, autoAllow), autoAllow, r.autoApprove)
+}
+
+// policyContext gathers what expression policy rules may inspect about a call.
+// For unix the read-only analysis doubles as the auto-allow decision; for other
+// tools read-only means the tool's permission category is read.
+func (r *taskExecutionState) policyContext(tool tools.Tool, response connector.LlmResponseWithTools, autoAllow bool) PolicyContext {
+	readOnly := permissionCategoryFor(tool) == tools.PermissionRead
+	var risk CommandRisk
+	if tool.Name() == tools.ToolNameUnix {
+		readOnly = autoAllow
+		command, _ := response.ToolInput["command"].(string)
+		risk = assessUnixCommandRisk(command, readOnly)
+	}
+	paths, pathsKnown := policyPathsForCall(response.ToolName, response.ToolInput, r.state.Dirs)
+	return PolicyContext{
+		Action:       BuildActionString(response.ToolName, response.ToolInput),
+		Tool:         response.ToolName,
+		Input:        response.ToolInput,
+		Paths:        paths,
+		PathsUnknown: !pathsKnown,
+		ReadOnly:     readOnly,
+		RunKind:      r.runKind,
+		RoutineID:    r.routineID,
+		RootDir:      r.state.Dirs.RootDir,
+		CurrentDir:   r.state.Dirs.CurrentDir,
+		Risk:         risk,
+	}
 }
 
 func (r *taskExecutionState) emitStatus(phase TaskStatusPhase, message string, toolName string, toolInput map[string]any) {
@@ -553,7 +717,7 @@ func (r *taskExecutionState) progressReporter(toolName string) func(string) {
 func (r *taskExecutionState) autoAllowsTool(tool tools.Tool, input map[string]any) bool {
 	if tool.Name() == tools.ToolNameUnix {
 		command, _ := input["command"].(string)
-		return isReadOnlyUnixCommandInDirs(command, r.state.Dirs)
+		return isReadOnlyUnixCommand(command, r.state.Dirs, r.commands)
 	}
 
 	switch permissionCategoryFor(tool) {
@@ -564,6 +728,8 @@ func (r *taskExecutionState) autoAllowsTool(tool tools.Tool, input map[string]an
 		}
 		return true
 	case tools.PermissionWrite:
+		// A write that names no path, like remember, is never in the
+		// workspace, so it is confirmed.
 		path, _ := input["path"].(string)
 		return tools.PathAllowedInContext(path, tools.ToolExecutionContext{
 			RootDir:         r.state.Dirs.RootDir,
@@ -589,17 +755,35 @@ func (r *taskExecutionState) recordFailure(response connector.LlmResponseWithToo
 		Thought:   response.Response,
 		ToolName:  response.ToolName,
 		ToolInput: response.ToolInput,
-		Error:     fmt.Sprintf("Failed to execute %s: %v", response.ToolName, err),
+		Error:     r.redactor.String(fmt.Sprintf("Failed to execute %s: %v", response.ToolName, err)),
 	})
 }
 
-func (r *taskExecutionState) recordDeclined(response connector.LlmResponseWithTools) {
+func (r *taskExecutionState) recordDeclined(response connector.LlmResponseWithTools, confirmation ConfirmationResult) {
+	message := "user declined execution"
+	if confirmation.PolicyBlocked {
+		message = "blocked by policy: " + confirmation.Reason
+	}
 	r.appendStep(TaskStep{
 		Status:    TaskStepStatusDeclined,
 		Thought:   response.Response,
 		ToolName:  response.ToolName,
 		ToolInput: response.ToolInput,
-		Message:   "user declined execution",
+		Message:   message,
+	})
+}
+
+// simulatedToolOutput is what the model sees for a call a dry run intercepted.
+const simulatedToolOutput = "Dry run: this call was recorded but not executed. Assume it succeeded and continue."
+
+func (r *taskExecutionState) recordSimulated(response connector.LlmResponseWithTools) {
+	r.appendStep(TaskStep{
+		Status:     TaskStepStatusSimulated,
+		Thought:    response.Response,
+		ToolName:   response.ToolName,
+		ToolInput:  response.ToolInput,
+		ToolOutput: simulatedToolOutput,
+		Message:    "simulated: " + BuildActionString(response.ToolName, response.ToolInput),
 	})
 }
 
@@ -626,6 +810,14 @@ func (r *taskExecutionState) handleDirectoryChange(response connector.LlmRespons
 		r.recordFailure(response, err)
 		return
 	}
+	if r.projectContext != nil {
+		nested, err := r.projectContext.enter(r.state.Dirs.CurrentDir)
+		if err != nil {
+			logger.Warnw("Reading project context failed", "dir", r.state.Dirs.CurrentDir, "error", err)
+		} else if nested != "" {
+			changeMessage += "\n\n" + r.redactor.String(nested)
+		}
+	}
 	r.recordSuccess(response, changeMessage)
 }
 
@@ -636,7 +828,8 @@ func (r *taskExecutionState) finalAnswerResult(answer string) TaskRunResult {
 // buildTaskTools assembles the tools available for a run. enabledTools selects
 // which of the agent's tools are exposed: a nil slice exposes every available
 // tool, except that when disableExternal is set, external-facing tools (web
-// search and MCP tools) are dropped (the routine default policy). A non-nil
+// search and MCP tools) and remember are dropped (the routine default policy):
+// an unattended run must not add to the memory of later runs unasked. A non-nil
 // enabledTools is an explicit allow-list of tool names, and a named
 // external-facing tool is included (explicit opt-in). The task-only tools
 // (user_clarification, final_answer, change_directory) are always appended.
@@ -644,7 +837,7 @@ func (a *Agent) buildTaskTools(interaction TaskInteraction, enabledTools []strin
 	taskTools := make(map[string]tools.Tool, len(a.Tools))
 	if enabledTools == nil {
 		for name, tool := range a.Tools {
-			if disableExternal && tools.IsExternalFacing(tool) {
+			if disableExternal && (tools.IsExternalFacing(tool) || name == tools.ToolNameRemember) {
 				continue
 			}
 			taskTools[name] = tool
@@ -3,7 +3,6 @@ package agent
 import (
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -28,16 +27,15 @@ func selectTaskRawOutput(outputs []taskToolOutput) taskToolOutput {
 	return taskToolOutput{}
 }
 
-func runTaskTool(ctx context.Context, tool tools.Tool, input map[string]any, dirs TaskDirs, output io.Writer, progress func(string)) (string, error) {
+// runTaskTool runs a tool call within the run's directory scope. execCtx
+// carries the rest of the call's context (output sinks, environment, run);
+// its directory fields are set from dirs.
+func runTaskTool(ctx context.Context, tool tools.Tool, input map[string]any, dirs TaskDirs, execCtx tools.ToolExecutionContext) (string, error) {
 	if err := ctx.Err(); err != nil {
 		return "", err
 	}
-	execCtx := tools.ToolExecutionContext{
-		RootDir:    dirs.RootDir,
-		CurrentDir: dirs.CurrentDir,
-		Output:     output,
-		Progress:   progress,
-	}
+	execCtx.RootDir = dirs.RootDir
+	execCtx.CurrentDir = dirs.CurrentDir
 	if permissionCategoryFor(tool) == tools.PermissionWrite {
 		execCtx.AllowedRootDirs = []string{dirs.RootDir}
 		execCtx.AllowedPaths = dirs.WriteAllowedPaths
@@ -15,6 +15,8 @@ const (
 	TaskStepStatusFailed      TaskStepStatus = "failed"
 	TaskStepStatusDeclined    TaskStepStatus = "declined"
 	TaskStepStatusFinalAnswer TaskStepStatus = "final_answer"
+	// TaskStepStatusSimulated is a call a dry run recorded instead of running.
+	TaskStepStatusSimulated TaskStepStatus = "simulated"
 )
 
 type TaskStep struct {
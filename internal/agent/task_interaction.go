@@ -11,6 +11,12 @@ type TaskInteraction interface {
 
 type TaskConfirmationRequest struct {
 	Action string
+	// Reason explains why an expression policy rule asked for confirmation; it
+	// is empty for ordinary prompts.
+	Reason string
+	// Risk and RiskReasons classify unix commands; Risk is empty for other tools.
+	Risk        string
+	RiskReasons []string
 }
 
 type TaskConfirmationDecision struct {
@@ -31,16 +37,20 @@ const DefaultUnattendedClarification = "No interactive user is available (automa
 
 // UnattendedInteraction is a TaskInteraction for headless runs (e.g. routines).
 // Confirmations are approved (the run also sets AutoApprove, which already
-// honors deny rules), and clarifications return a fixed "proceed" response so
-// the agent never waits for input that will never come.
+// honors deny rules) except for high-risk commands, and clarifications return
+// a fixed "proceed" response so the agent never waits for input that will
+// never come.
 type UnattendedInteraction struct {
 	// ClarificationResponse overrides the default canned clarification answer
 	// when non-empty.
 	ClarificationResponse string
+	// DeclineConfirmations declines every confirmation instead, for runs where
+	// the organisation policy requires a human to approve.
+	DeclineConfirmations bool
 }
 
-func (u UnattendedInteraction) Confirm(TaskConfirmationRequest) (TaskConfirmationDecision, error) {
-	return TaskConfirmationDecision{Allowed: true}, nil
+func (u UnattendedInteraction) Confirm(request TaskConfirmationRequest) (TaskConfirmationDecision, error) {
+	return TaskConfirmationDecision{Allowed: !u.DeclineConfirmations && request.Risk != CommandRiskHigh}, nil
 }
 
 func (u UnattendedInteraction) Clarify(TaskClarificationRequest) (string, error) {
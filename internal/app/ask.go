@@ -7,6 +7,7 @@ import (
 
 	internalagent "github.com/laszukdawid/terminal-agent/internal/agent"
 	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
 	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
 )
 
@@ -20,10 +21,14 @@ type AskRequest struct {
 	WorkingDir           string
 	ContextFiles         []string
 	TerminalContextCount int
-	Stream               bool
-	UseWebSearch         bool
-	Device               string
-	Config               config.Config
+	// RAG adds the RAGTopK chunks of the working directory's semantic index
+	// closest to Message as context.
+	RAG          bool
+	RAGTopK      int
+	Stream       bool
+	UseWebSearch bool
+	Device       string
+	Config       config.Config
 }
 
 type AskResult struct {
@@ -54,16 +59,23 @@ func (s *service) Ask(ctx context.Context, req AskRequest) (AskResult, error) {
 }
 
 func (s *service) AskEvents(ctx context.Context, req AskRequest) (<-chan Event, error) {
-	runtime, prompts, userQuestion, err := prepareAsk(req)
+	redactor, err := redact.Load()
 	if err != nil {
 		return nil, err
 	}
+	runtime, prompts, userQuestion, err := prepareAsk(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	// Context files and captured terminal output are the likeliest place for
+	// secrets to reach the model.
+	userQuestion = redactor.String(userQuestion)
 
 	agentInstance := runtime.NewAgent(prompts)
 	agentInstance.SetDevice(req.Device)
 	events := make(chan Event)
 
-	recorder := sessionlog.New(SessionDir(), buildMeta("ask", req.Provider, req.Model, req.WorkingDir, req.Message))
+	recorder := sessionlog.NewWithRedactor(SessionDir(), buildMeta("ask", req.Provider, req.Model, req.WorkingDir, req.Message), redactor)
 
 	go func() {
 		defer close(events)
@@ -120,14 +132,15 @@ func (s *service) AskEvents(ctx context.Context, req AskRequest) (<-chan Event,
 		completed := newEvent(RunKindAsk, EventCompleted)
 		completed.Status = userQuestion
 		completed.FinalOutput = response
-		recorder.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Kind: string(RunKindAsk), Text: response})
+		completed.Redactions = redactor.Counts()
+		recorder.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Kind: string(RunKindAsk), Text: response, Redactions: completed.Redactions})
 		_ = emitEvent(ctx, events, completed)
 	}()
 
 	return events, nil
 }
 
-func prepareAsk(req AskRequest) (*Runtime, PromptSet, string, error) {
+func prepareAsk(ctx context.Context, req AskRequest) (*Runtime, PromptSet, string, error) {
 	if strings.TrimSpace(req.Message) == "" {
 		return nil, PromptSet{}, "", internalagent.ErrEmptyQuery
 	}
@@ -146,6 +159,7 @@ func prepareAsk(req AskRequest) (*Runtime, PromptSet, string, error) {
 		AskOverride: req.PromptOverride,
 		UseMemory:   req.UseMemory,
 		MemoryPath:  req.MemoryPath,
+		Message:     req.Message,
 	})
 	if err != nil {
 		return nil, PromptSet{}, "", err
@@ -164,6 +178,20 @@ func prepareAsk(req AskRequest) (*Runtime, PromptSet, string, error) {
 		}
 	}
 
+	if req.RAG {
+		dir := runtime.WorkingDir
+		if dir == "" {
+			dir = "."
+		}
+		indexContext, err := BuildContextFromIndex(ctx, dir, runtime.Config, req.Message, req.RAGTopK)
+		if err != nil {
+			return nil, PromptSet{}, "", fmt.Errorf("failed to search index: %w", err)
+		}
+		if indexContext != "" {
+			contextParts = append(contextParts, indexContext)
+		}
+	}
+
 	if req.TerminalContextCount > 0 {
 		terminalContext, err := BuildContextFromTerminal(req.TerminalContextCount)
 		if err != nil {
@@ -29,7 +29,7 @@ func TestBuildAskPrompt(t *testing.T) {
 		m := memory.NewMemory(memoryPath)
 		m.Add("some memory entry")
 
-		result, err := BuildAskPrompt(basePrompt, false, memoryPath)
+		result, err := BuildAskPrompt(basePrompt, false, memoryPath, memory.Query{})
 		assert.NoError(t, err)
 		assert.Equal(t, basePrompt, result)
 		assert.NotContains(t, result, "<memory>")
@@ -43,7 +43,7 @@ func TestBuildAskPrompt(t *testing.T) {
 		m.Add("viewing images is with catimg")
 		m.Add("use kubectl for kubernetes")
 
-		result, err := BuildAskPrompt(basePrompt, true, memoryPath)
+		result, err := BuildAskPrompt(basePrompt, true, memoryPath, memory.Query{})
 		assert.NoError(t, err)
 		assert.True(t, strings.HasPrefix(result, "<memory>"))
 		assert.Contains(t, result, "viewing images is with catimg")
@@ -56,7 +56,7 @@ func TestBuildAskPrompt(t *testing.T) {
 		memoryPath, cleanup := setupTempMemory(t)
 		defer cleanup()
 
-		result, err := BuildAskPrompt(basePrompt, true, memoryPath)
+		result, err := BuildAskPrompt(basePrompt, true, memoryPath, memory.Query{})
 		assert.NoError(t, err)
 		assert.Equal(t, basePrompt, result)
 		assert.NotContains(t, result, "<memory>")
@@ -66,7 +66,7 @@ func TestBuildAskPrompt(t *testing.T) {
 		tempDir := t.TempDir()
 		nonExistentPath := filepath.Join(tempDir, "nonexistent", "memory.jsonl")
 
-		result, err := BuildAskPrompt(basePrompt, true, nonExistentPath)
+		result, err := BuildAskPrompt(basePrompt, true, nonExistentPath, memory.Query{})
 		assert.NoError(t, err)
 		assert.Equal(t, basePrompt, result)
 	})
@@ -78,7 +78,7 @@ func TestBuildAskPrompt(t *testing.T) {
 		m := memory.NewMemory(memoryPath)
 		m.Add("test memory entry")
 
-		result, err := BuildAskPrompt(basePrompt, true, memoryPath)
+		result, err := BuildAskPrompt(basePrompt, true, memoryPath, memory.Query{})
 		assert.NoError(t, err)
 		expected := "<memory>\ntest memory entry\n</memory>\n\n" + basePrompt
 		assert.Equal(t, expected, result)
@@ -148,7 +148,7 @@ func TestConfigMemoryIntegration(t *testing.T) {
 		m.Add("config-enabled memory entry")
 
 		basePrompt := "Base prompt"
-		result, err := BuildAskPrompt(basePrompt, true, memoryPath)
+		result, err := BuildAskPrompt(basePrompt, true, memoryPath, memory.Query{})
 		assert.NoError(t, err)
 		assert.Contains(t, result, "config-enabled memory entry")
 		assert.Contains(t, result, "<memory>")
@@ -162,7 +162,7 @@ func TestConfigMemoryIntegration(t *testing.T) {
 		m.Add("flag-enabled memory entry")
 
 		basePrompt := "Base prompt"
-		result, err := BuildAskPrompt(basePrompt, true, memoryPath)
+		result, err := BuildAskPrompt(basePrompt, true, memoryPath, memory.Query{})
 		assert.NoError(t, err)
 		assert.Contains(t, result, "flag-enabled memory entry")
 		assert.Contains(t, result, "<memory>")
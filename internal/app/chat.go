@@ -9,7 +9,9 @@ import (
 	"github.com/laszukdawid/terminal-agent/internal/chat"
 	"github.com/laszukdawid/terminal-agent/internal/config"
 	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
 	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	log "github.com/laszukdawid/terminal-agent/internal/utils"
 )
 
 type ChatRequest struct {
@@ -25,9 +27,24 @@ type ChatRequest struct {
 	Device         string
 	NewSession     bool
 	ChatDBPath     string
-	Config         config.Config
+	// HistoryLimit is the most tokens of history sent with a message; older
+	// turns are folded into the session summary. 0 uses the model's default
+	// budget (chat.HistoryBudget).
+	HistoryLimit int
+	// EditMessageID replaces that earlier user message with Message: the
+	// edit starts a branch next to the original, which is kept (see
+	// chat.SessionStore.Checkout).
+	EditMessageID int64
+	// Regenerate asks again for the reply on the checked-out branch, on a new
+	// branch next to it; Message is not used. When the branch ends with an
+	// unanswered message, that message is answered.
+	Regenerate bool
+	Config     config.Config
 }
 
+// chatReplyCancelled marks a reply the user cancelled in the chat history.
+const chatReplyCancelled = "[reply cancelled]"
+
 type ChatResult struct {
 	Message  string
 	Response string
@@ -56,7 +73,11 @@ func (s *service) Chat(ctx context.Context, req ChatRequest) (ChatResult, error)
 }
 
 func (s *service) ChatEvents(ctx context.Context, req ChatRequest) (<-chan Event, error) {
-	runtime, prompts, connectorMessages, userMessage, sessionStore, err := prepareChat(req)
+	redactor, err := redact.Load()
+	if err != nil {
+		return nil, err
+	}
+	runtime, prompts, connectorMessages, userMessage, sessionStore, err := prepareChat(ctx, req, redactor)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +86,7 @@ func (s *service) ChatEvents(ctx context.Context, req ChatRequest) (<-chan Event
 	agentInstance.SetDevice(req.Device)
 	events := make(chan Event)
 
-	recorder := sessionlog.New(SessionDir(), buildMeta("chat", req.Provider, req.Model, req.WorkingDir, req.Message))
+	recorder := sessionlog.NewWithRedactor(SessionDir(), buildMeta("chat", req.Provider, req.Model, req.WorkingDir, req.Message), redactor)
 
 	go func() {
 		defer close(events)
@@ -86,8 +107,10 @@ func (s *service) ChatEvents(ctx context.Context, req ChatRequest) (<-chan Event
 			Device:     req.Device,
 		}
 
+		var streamed strings.Builder
 		if req.Stream {
 			qParams.OnStream = func(chunk string) error {
+				streamed.WriteString(chunk)
 				event := newEvent(RunKindChat, EventOutputDelta)
 				event.Text = chunk
 				return emitEvent(ctx, events, event)
@@ -96,6 +119,14 @@ func (s *service) ChatEvents(ctx context.Context, req ChatRequest) (<-chan Event
 
 		response, err := agentInstance.Connector.Query(ctx, &qParams)
 		if err != nil {
+			if ctx.Err() != nil {
+				// Keep what was said before the reply was cancelled, so the
+				// history still alternates between user and assistant.
+				cancelled := strings.TrimSpace(streamed.String() + "\n\n" + chatReplyCancelled)
+				if saveErr := sessionStore.AddMessage("assistant", cancelled); saveErr != nil {
+					log.Warnw("Failed to save cancelled chat reply", "error", saveErr)
+				}
+			}
 			failed := newEvent(RunKindChat, EventFailed)
 			failed.Err = err
 			recorder.Write(sessionlog.Record{Type: sessionlog.RecordFailed, Kind: string(RunKindChat), Error: err.Error()})
@@ -114,15 +145,16 @@ func (s *service) ChatEvents(ctx context.Context, req ChatRequest) (<-chan Event
 		completed := newEvent(RunKindChat, EventCompleted)
 		completed.Status = strings.TrimSpace(userMessage)
 		completed.FinalOutput = response
-		recorder.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Kind: string(RunKindChat), Text: response})
+		completed.Redactions = redactor.Counts()
+		recorder.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Kind: string(RunKindChat), Text: response, Redactions: completed.Redactions})
 		_ = emitEvent(ctx, events, completed)
 	}()
 
 	return events, nil
 }
 
-func prepareChat(req ChatRequest) (*Runtime, PromptSet, []connector.Message, string, *chat.SessionStore, error) {
-	if strings.TrimSpace(req.Message) == "" {
+func prepareChat(ctx context.Context, req ChatRequest, redactor *redact.Redactor) (*Runtime, PromptSet, []connector.Message, string, *chat.SessionStore, error) {
+	if strings.TrimSpace(req.Message) == "" && !req.Regenerate {
 		return nil, PromptSet{}, nil, "", nil, internalagent.ErrEmptyQuery
 	}
 
@@ -139,39 +171,67 @@ func prepareChat(req ChatRequest) (*Runtime, PromptSet, []connector.Message, str
 		}
 	}
 
-	_, err = sessionStore.GetOrCreateSession()
+	sessionID, err := sessionStore.GetOrCreateSession()
 	if err != nil {
 		sessionStore.Close()
 		return nil, PromptSet{}, nil, "", nil, fmt.Errorf("failed to get session: %w", err)
 	}
-
-	chatHistory, err := sessionStore.GetMessages()
+	// question is the stored message being answered again, if any.
+	question, err := checkoutChatBranch(sessionStore, sessionID, req)
 	if err != nil {
 		sessionStore.Close()
-		return nil, PromptSet{}, nil, "", nil, fmt.Errorf("failed to load chat history: %w", err)
+		return nil, PromptSet{}, nil, "", nil, err
+	}
+	sessionID = sessionStore.CurrentSessionID()
+	session, err := sessionStore.GetSession(sessionID)
+	if err != nil {
+		sessionStore.Close()
+		return nil, PromptSet{}, nil, "", nil, fmt.Errorf("failed to get session: %w", err)
 	}
 
-	connectorMessages := make([]connector.Message, 0, len(chatHistory))
-	for _, msg := range chatHistory {
-		connectorMessages = append(connectorMessages, connector.Message{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
+	// Messages covered by the summary are not sent again.
+	chatHistory, err := sessionStore.SessionMessagesAfter(sessionID, session.SummaryThrough)
+	if err != nil {
+		sessionStore.Close()
+		return nil, PromptSet{}, nil, "", nil, fmt.Errorf("failed to load chat history: %w", err)
 	}
 
-	userMessage := req.Message
-	if len(req.ContextFiles) > 0 {
+	// The message is stored in the chat session and replayed as history on
+	// every later turn, so it is masked once here.
+	message := redactor.String(req.Message)
+	userMessage := message
+	if question != nil {
+		// The question is the branch's last message; it is sent as the
+		// prompt rather than as history, and is already stored.
+		if n := len(chatHistory); n > 0 && chatHistory[n-1].ID == question.ID {
+			chatHistory = chatHistory[:n-1]
+		}
+		userMessage = question.Content
+	} else if len(req.ContextFiles) > 0 {
 		contextContent, err := BuildContextFromFiles(req.ContextFiles)
 		if err != nil {
 			sessionStore.Close()
 			return nil, PromptSet{}, nil, "", nil, fmt.Errorf("failed to read context files: %w", err)
 		}
-		userMessage = contextContent + "\n\n" + userMessage
+		userMessage = redactor.String(contextContent) + "\n\n" + message
 	}
 
-	if err := sessionStore.AddMessage("user", userMessage); err != nil {
+	if question == nil {
+		if err := sessionStore.AddMessage("user", userMessage); err != nil {
+			sessionStore.Close()
+			return nil, PromptSet{}, nil, "", nil, fmt.Errorf("failed to save user message: %w", err)
+		}
+	}
+	// Titles come from the first exchange, without any attached files.
+	if session.MessageCount == 0 {
+		if err := sessionStore.SetTitleIfEmpty(chat.TitleFromMessage(message)); err != nil {
+			sessionStore.Close()
+			return nil, PromptSet{}, nil, "", nil, err
+		}
+	}
+	if err := sessionStore.SetSessionModel(req.Provider, req.Model); err != nil {
 		sessionStore.Close()
-		return nil, PromptSet{}, nil, "", nil, fmt.Errorf("failed to save user message: %w", err)
+		return nil, PromptSet{}, nil, "", nil, err
 	}
 
 	runtime, err := NewRuntime(RuntimeRequest{
@@ -189,11 +249,67 @@ func prepareChat(req ChatRequest) (*Runtime, PromptSet, []connector.Message, str
 		AskOverride: req.PromptOverride,
 		UseMemory:   req.UseMemory,
 		MemoryPath:  req.MemoryPath,
+		Message:     message,
 	})
 	if err != nil {
 		sessionStore.Close()
 		return nil, PromptSet{}, nil, "", nil, err
 	}
 
+	summary, recent := fitChatHistory(ctx, runtime.Connector, sessionStore, chatHistoryBudget(req), req.Config.GetMaxTokens(), session.Summary, chatHistory)
+	if summary != "" {
+		prompts.Ask = withChatSummary(prompts.Ask, summary)
+	}
+	connectorMessages := make([]connector.Message, 0, len(recent))
+	for _, msg := range recent {
+		connectorMessages = append(connectorMessages, connector.Message{
+			Role:    msg.Role,
+			Content: msg.Content,
+		})
+	}
+
 	return runtime, prompts, connectorMessages, userMessage, sessionStore, nil
 }
+
+// checkoutChatBranch moves the session's head for an edit or a regeneration.
+// An edit checks out the edited message's parent, so the new message becomes
+// its sibling. A regeneration checks out the question the reply answered and
+// returns it, so the new reply becomes the old one's sibling.
+func checkoutChatBranch(store *chat.SessionStore, sessionID int64, req ChatRequest) (*chat.Message, error) {
+	switch {
+	case req.EditMessageID != 0:
+		edited, err := store.GetMessage(req.EditMessageID)
+		if err != nil {
+			return nil, err
+		}
+		if edited.Role != "user" {
+			return nil, fmt.Errorf("message #%d is an %s message; only user messages can be edited", edited.ID, edited.Role)
+		}
+		return nil, store.Checkout(edited.SessionID, edited.ParentID)
+	case req.Regenerate:
+		session, err := store.GetSession(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if session.HeadID == 0 {
+			return nil, fmt.Errorf("session #%d has no reply to regenerate", sessionID)
+		}
+		question, err := store.GetMessage(session.HeadID)
+		if err != nil {
+			return nil, err
+		}
+		if question.Role == "assistant" {
+			if question.ParentID == 0 {
+				return nil, fmt.Errorf("reply #%d answers no message", question.ID)
+			}
+			if question, err = store.GetMessage(question.ParentID); err != nil {
+				return nil, err
+			}
+		}
+		if err := store.Checkout(sessionID, question.ID); err != nil {
+			return nil, err
+		}
+		return &question, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/laszukdawid/terminal-agent/internal/chat"
+	"github.com/laszukdawid/terminal-agent/internal/connector"
+	log "github.com/laszukdawid/terminal-agent/internal/utils"
+)
+
+const chatSummaryPrompt = `You condense chat transcripts. Summarize the conversation you are given so the summary can stand in for its messages in later turns. Keep facts, decisions, commands, file names, numbers and open questions; drop greetings and repetition. If a summary of earlier messages is included, merge it into yours. Reply with the summary only.`
+
+// minSummaryChunkTokens keeps summary requests from degrading into one
+// message each when the history budget is tiny.
+const minSummaryChunkTokens = 2000
+
+// ChatCompactResult reports what CompactChat folded into the summary.
+type ChatCompactResult struct {
+	SessionID     int64
+	Folded        int // messages folded into the summary
+	SummaryTokens int // estimated size of the new summary
+}
+
+func chatHistoryBudget(req ChatRequest) int {
+	if req.HistoryLimit > 0 {
+		return req.HistoryLimit
+	}
+	return chat.HistoryBudget(req.Model)
+}
+
+// fitChatHistory keeps the most recent messages that fit in budget tokens
+// next to the summary, and folds the older ones into the stored summary. If
+// summarising fails the older messages are left out of this turn only; they
+// stay unsummarised and are folded on a later turn.
+func fitChatHistory(ctx context.Context, conn connector.LLMConnector, store *chat.SessionStore, budget, maxTokens int, summary string, history []chat.Message) (string, []chat.Message) {
+	older, recent := chat.SplitHistory(history, budget-chat.EstimateTokens(summary))
+	if len(older) == 0 {
+		return summary, recent
+	}
+
+	folded, err := summarizeChat(ctx, conn, max(budget, minSummaryChunkTokens), maxTokens, summary, older)
+	if err != nil {
+		log.Warnw("Failed to summarise chat history; sending recent messages only", "folded", len(older), "error", err)
+		return summary, recent
+	}
+	if err := store.SetSummary(folded, older[len(older)-1].ID); err != nil {
+		log.Warnw("Failed to save chat summary", "error", err)
+	}
+	return folded, recent
+}
+
+// summarizeChat folds messages into the running summary, a chunk of at most
+// chunkTokens at a time so each request fits in the model's window.
+func summarizeChat(ctx context.Context, conn connector.LLMConnector, chunkTokens, maxTokens int, summary string, messages []chat.Message) (string, error) {
+	sysPrompt := chatSummaryPrompt
+	for len(messages) > 0 {
+		var transcript strings.Builder
+		if summary != "" {
+			fmt.Fprintf(&transcript, "Summary of the earlier conversation:\n%s\n\nMessages since:\n\n", summary)
+		}
+		used := chat.EstimateTokens(summary)
+		n := 0
+		for n < len(messages) && (n == 0 || used+chat.MessageTokens(messages[n]) <= chunkTokens) {
+			used += chat.MessageTokens(messages[n])
+			n++
+		}
+		transcript.WriteString(formatChatTranscript(messages[:n]))
+		messages = messages[n:]
+
+		prompt := transcript.String()
+		response, err := conn.Query(ctx, &connector.QueryParams{
+			UserPrompt: &prompt,
+			SysPrompt:  &sysPrompt,
+			MaxTokens:  maxTokens,
+		})
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(response) == "" {
+			return "", fmt.Errorf("model returned an empty summary")
+		}
+		summary = strings.TrimSpace(response)
+	}
+	return summary, nil
+}
+
+func formatChatTranscript(messages []chat.Message) string {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&transcript, "[%s]\n%s\n\n", msg.Role, strings.TrimSpace(msg.Content))
+	}
+	return transcript.String()
+}
+
+// withChatSummary appends the session summary to the system prompt.
+func withChatSummary(prompt, summary string) string {
+	return strings.TrimSpace(prompt) + "\n\nSummary of the earlier conversation, whose messages are not repeated below:\n" + summary
+}
+
+// CompactChat folds every message of the current chat session not yet in the
+// summary into it, so the next turn starts from the summary alone.
+func (s *service) CompactChat(ctx context.Context, req ChatRequest) (ChatCompactResult, error) {
+	store, err := chat.NewSessionStore(req.ChatDBPath)
+	if err != nil {
+		return ChatCompactResult{}, fmt.Errorf("failed to initialize chat session: %w", err)
+	}
+	defer store.Close()
+
+	if store.CurrentSessionID() == 0 {
+		return ChatCompactResult{}, fmt.Errorf("no current chat session")
+	}
+	session, err := store.GetSession(store.CurrentSessionID())
+	if err != nil {
+		return ChatCompactResult{}, err
+	}
+	result := ChatCompactResult{SessionID: session.ID, SummaryTokens: chat.EstimateTokens(session.Summary)}
+	pending, err := store.SessionMessagesAfter(session.ID, session.SummaryThrough)
+	if err != nil {
+		return ChatCompactResult{}, fmt.Errorf("failed to load chat history: %w", err)
+	}
+	if len(pending) == 0 {
+		return result, nil
+	}
+
+	runtime, err := NewRuntime(RuntimeRequest{
+		Provider:   req.Provider,
+		Model:      req.Model,
+		WorkingDir: req.WorkingDir,
+		Config:     req.Config,
+	})
+	if err != nil {
+		return ChatCompactResult{}, err
+	}
+	summary, err := summarizeChat(ctx, runtime.Connector, max(chatHistoryBudget(req), minSummaryChunkTokens), req.Config.GetMaxTokens(), session.Summary, pending)
+	if err != nil {
+		return ChatCompactResult{}, fmt.Errorf("failed to summarise chat history: %w", err)
+	}
+	if err := store.SetSummary(summary, pending[len(pending)-1].ID); err != nil {
+		return ChatCompactResult{}, err
+	}
+	result.Folded = len(pending)
+	result.SummaryTokens = chat.EstimateTokens(summary)
+	return result, nil
+}
+
+// ChatTaskBackground renders the current chat session as background for a
+// task handed off from it: the summary and the recent messages that fit the
+// request's history budget. It is empty when there is no current session.
+func ChatTaskBackground(req ChatRequest) (string, error) {
+	store, err := chat.NewSessionStore(req.ChatDBPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize chat session: %w", err)
+	}
+	defer store.Close()
+	if store.CurrentSessionID() == 0 {
+		return "", nil
+	}
+
+	session, err := store.GetSession(store.CurrentSessionID())
+	if err != nil {
+		return "", err
+	}
+	pending, err := store.SessionMessagesAfter(session.ID, session.SummaryThrough)
+	if err != nil {
+		return "", fmt.Errorf("failed to load chat history: %w", err)
+	}
+	_, recent := chat.SplitHistory(pending, chatHistoryBudget(req)-chat.EstimateTokens(session.Summary))
+
+	var background strings.Builder
+	if session.Summary != "" {
+		fmt.Fprintf(&background, "Summary of the earlier conversation:\n%s\n\n", session.Summary)
+	}
+	background.WriteString(formatChatTranscript(recent))
+	return strings.TrimSpace(background.String()), nil
+}
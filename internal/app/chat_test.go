@@ -2,11 +2,14 @@ package app
 
 import (
 	"context"
+	"errors"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/laszukdawid/terminal-agent/internal/chat"
 	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/connector"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -37,3 +40,129 @@ func TestChatPersistsConversationHistory(t *testing.T) {
 	assert.Equal(t, "user", messages[0].Role)
 	assert.Equal(t, "first", messages[0].Content)
 }
+
+// summaryConnector answers every query with a summary naming the call, and
+// keeps the prompts it was sent.
+type summaryConnector struct {
+	prompts []string
+	err     error
+}
+
+func (c *summaryConnector) Query(_ context.Context, params *connector.QueryParams) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	c.prompts = append(c.prompts, *params.UserPrompt)
+	return "summary " + string(rune('0'+len(c.prompts))), nil
+}
+
+func TestFitChatHistoryFoldsOlderTurnsIntoSummary(t *testing.T) {
+	store, err := chat.NewSessionStore(filepath.Join(t.TempDir(), "chat.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	_, err = store.NewSession()
+	require.NoError(t, err)
+
+	long := strings.Repeat("word ", 80) // ~100 tokens
+	for _, msg := range []chat.Message{
+		{Role: "user", Content: "first " + long},
+		{Role: "assistant", Content: "answer " + long},
+		{Role: "user", Content: "second " + long},
+		{Role: "assistant", Content: "answer " + long},
+		{Role: "user", Content: "latest"},
+		{Role: "assistant", Content: "reply"},
+	} {
+		require.NoError(t, store.AddMessage(msg.Role, msg.Content))
+	}
+	history, err := store.GetMessages()
+	require.NoError(t, err)
+
+	conn := &summaryConnector{}
+	summary, recent := fitChatHistory(context.Background(), conn, store, 50, 600, "", history)
+	assert.Equal(t, "summary 1", summary)
+	require.Len(t, recent, 2)
+	assert.Equal(t, "latest", recent[0].Content)
+	require.Len(t, conn.prompts, 1)
+	assert.Contains(t, conn.prompts[0], "[user]\nfirst")
+	assert.Contains(t, conn.prompts[0], "[assistant]\nanswer")
+
+	session, err := store.GetSession(store.CurrentSessionID())
+	require.NoError(t, err)
+	assert.Equal(t, "summary 1", session.Summary)
+	assert.Equal(t, history[3].ID, session.SummaryThrough)
+
+	// A later turn folds only what is past the summary, and merges the
+	// existing summary into the new one.
+	_, recent = fitChatHistory(context.Background(), conn, store, 50, 600, session.Summary, history[4:])
+	assert.Len(t, recent, 2)
+	assert.Len(t, conn.prompts, 1)
+
+	// Without a summary the older turns are only left out of this turn.
+	failing := &summaryConnector{err: errors.New("offline")}
+	summary, recent = fitChatHistory(context.Background(), failing, store, 50, 600, "", history)
+	assert.Equal(t, "", summary)
+	assert.Len(t, recent, 2)
+	session, err = store.GetSession(store.CurrentSessionID())
+	require.NoError(t, err)
+	assert.Equal(t, "summary 1", session.Summary)
+}
+
+func TestSummarizeChatChunksLongHistory(t *testing.T) {
+	long := strings.Repeat("word ", 400) // ~500 tokens
+	var messages []chat.Message
+	for range 6 {
+		messages = append(messages, chat.Message{Role: "user", Content: long})
+	}
+
+	conn := &summaryConnector{}
+	summary, err := summarizeChat(context.Background(), conn, 1200, 600, "earlier", messages)
+	require.NoError(t, err)
+	assert.Equal(t, "summary 3", summary)
+	require.Len(t, conn.prompts, 3)
+	assert.Contains(t, conn.prompts[0], "Summary of the earlier conversation:\nearlier")
+	assert.Contains(t, conn.prompts[1], "Summary of the earlier conversation:\nsummary 1")
+}
+
+func TestCheckoutChatBranchForEditsAndRegeneration(t *testing.T) {
+	store, err := chat.NewSessionStore(filepath.Join(t.TempDir(), "chat.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	id, err := store.NewSession()
+	require.NoError(t, err)
+	require.NoError(t, store.AddMessage("user", "q1"))
+	require.NoError(t, store.AddMessage("assistant", "a1"))
+	require.NoError(t, store.AddMessage("user", "q2"))
+	require.NoError(t, store.AddMessage("assistant", "a2"))
+	messages, err := store.GetMessages()
+	require.NoError(t, err)
+
+	// Regenerating answers the last question again
+	question, err := checkoutChatBranch(store, id, ChatRequest{Regenerate: true})
+	require.NoError(t, err)
+	require.NotNil(t, question)
+	assert.Equal(t, messages[2].ID, question.ID)
+	session, err := store.GetSession(id)
+	require.NoError(t, err)
+	assert.Equal(t, messages[2].ID, session.HeadID)
+
+	// ...and so does regenerating an unanswered question
+	question, err = checkoutChatBranch(store, id, ChatRequest{Regenerate: true})
+	require.NoError(t, err)
+	assert.Equal(t, messages[2].ID, question.ID)
+
+	// Editing branches off before the edited message
+	question, err = checkoutChatBranch(store, id, ChatRequest{EditMessageID: messages[2].ID})
+	require.NoError(t, err)
+	assert.Nil(t, question)
+	session, err = store.GetSession(id)
+	require.NoError(t, err)
+	assert.Equal(t, messages[1].ID, session.HeadID)
+
+	_, err = checkoutChatBranch(store, id, ChatRequest{EditMessageID: messages[1].ID})
+	assert.ErrorContains(t, err, "only user messages can be edited")
+
+	empty, err := store.NewSession()
+	require.NoError(t, err)
+	_, err = checkoutChatBranch(store, empty, ChatRequest{Regenerate: true})
+	assert.ErrorContains(t, err, "no reply to regenerate")
+}
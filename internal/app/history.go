@@ -0,0 +1,369 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/chat"
+	"github.com/laszukdawid/terminal-agent/internal/history"
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	log "github.com/laszukdawid/terminal-agent/internal/utils"
+)
+
+// HistorySources are the places runs are recorded: the --log interaction log,
+// the per-run session logs, the routines' run logs and the chat database.
+type HistorySources struct {
+	LogPath    string
+	SessionDir string
+	// RoutinesDir holds a <routine id>/logs directory per routine.
+	RoutinesDir string
+	ChatDB      string
+}
+
+func DefaultHistorySources() HistorySources {
+	return HistorySources{
+		LogPath:     LogPath(),
+		SessionDir:  SessionDir(),
+		RoutinesDir: routines.DataDir(),
+		ChatDB:      ChatDBPath(),
+	}
+}
+
+// Source key prefixes of the chat sessions and the interaction log; session
+// logs are keyed by their path.
+const (
+	chatSourcePrefix = "chat:"
+	logSourcePrefix  = "log:"
+)
+
+// logDedupWindow is how close a --log entry must be to the end of a session
+// logged run with the same request to be taken for the same run.
+const logDedupWindow = time.Minute
+
+// OpenHistory opens the unified history database and brings it up to date
+// with the sources.
+func OpenHistory(sources HistorySources) (*history.Store, error) {
+	store, err := history.OpenStore(HistoryDBPath())
+	if err != nil {
+		return nil, err
+	}
+	if err := SyncHistory(store, sources); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// SyncHistory reads the sources added or changed since the last sync into the
+// store, and drops the runs of sources that are gone. Session logs of chat
+// turns are skipped, since their session is read whole from the chat
+// database, and --log entries of runs that also have a session log are
+// skipped too.
+func SyncHistory(store *history.Store, sources HistorySources) error {
+	states, err := store.SourceStates()
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+
+	for _, path := range sessionLogPaths(sources) {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		seen[path] = true
+		state := fileState(info)
+		if states[path] == state {
+			continue
+		}
+		runs, err := sessionLogRuns(path)
+		if err != nil {
+			log.Warnw("Skipping session log in history", "path", path, "error", err)
+			continue
+		}
+		if err := store.ReplaceSource(path, state, runs); err != nil {
+			return err
+		}
+	}
+
+	if err := syncChatHistory(store, sources.ChatDB, states, seen); err != nil {
+		return err
+	}
+	if err := syncLogHistory(store, sources.LogPath, states, seen); err != nil {
+		return err
+	}
+
+	for key := range states {
+		if !seen[key] {
+			if err := store.RemoveSource(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func fileState(info os.FileInfo) string {
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+}
+
+// sessionLogPaths lists the session logs of ad-hoc runs and of routines.
+func sessionLogPaths(sources HistorySources) []string {
+	var paths []string
+	if sources.SessionDir != "" {
+		matches, _ := filepath.Glob(filepath.Join(sources.SessionDir, "*.jsonl"))
+		paths = append(paths, matches...)
+	}
+	if sources.RoutinesDir != "" {
+		matches, _ := filepath.Glob(filepath.Join(sources.RoutinesDir, "*", "logs", "*.jsonl"))
+		paths = append(paths, matches...)
+	}
+	return paths
+}
+
+// sessionLogRuns reads the run recorded in a session log; none for chat turns.
+func sessionLogRuns(path string) ([]history.Run, error) {
+	summary, records, err := sessionlog.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if summary.RunID == "" || summary.Kind == "" || summary.Kind == string(RunKindChat) {
+		return nil, nil
+	}
+
+	run := history.Run{
+		ID:          summary.RunID,
+		Kind:        summary.Kind,
+		Source:      history.SourceSession,
+		Status:      history.StatusIncomplete,
+		Provider:    summary.Provider,
+		Model:       summary.Model,
+		Cwd:         summary.Cwd,
+		RoutineID:   summary.RoutineID,
+		Request:     summary.Request,
+		Response:    summary.Response,
+		Error:       summary.Error,
+		CreatedAt:   summary.CreatedAt,
+		CompletedAt: summary.CompletedAt,
+		Path:        path,
+	}
+	if run.Request == "" {
+		run.Request = summary.Command
+	}
+	for _, rec := range records {
+		switch rec.Type {
+		case sessionlog.RecordCompleted:
+			run.Status = history.StatusCompleted
+		case sessionlog.RecordFailed:
+			run.Status = history.StatusFailed
+		}
+		if entry, ok := sessionRecordEntry(rec); ok {
+			run.Entries = append(run.Entries, entry)
+		}
+	}
+	return []history.Run{run}, nil
+}
+
+// sessionRecordEntry turns a session log record into a transcript entry.
+// Progress updates and egress records are left out.
+func sessionRecordEntry(rec sessionlog.Record) (history.Entry, bool) {
+	entry := history.Entry{Type: string(rec.Type), Tool: rec.ToolName, Time: rec.Timestamp}
+	var parts []string
+	switch rec.Type {
+	case sessionlog.RecordMeta, sessionlog.RecordProgress, sessionlog.RecordEgress:
+		return history.Entry{}, false
+	case sessionlog.RecordRequest:
+		entry.Type = "user"
+	case sessionlog.RecordCompleted:
+		entry.Type = "assistant"
+	case sessionlog.RecordFailed:
+		entry.Type = "error"
+	case sessionlog.RecordConfirmation:
+		parts = append(parts, rec.Confirmation)
+		if rec.Allowed != nil {
+			parts = append(parts, "allowed: "+strconv.FormatBool(*rec.Allowed))
+		}
+	case sessionlog.RecordStep:
+		if rec.StepID != "" {
+			parts = append(parts, "step "+rec.StepID+": "+rec.Status)
+		}
+	}
+	if len(rec.ToolInput) > 0 {
+		if input, err := json.Marshal(rec.ToolInput); err == nil {
+			parts = append(parts, string(input))
+		}
+	}
+	parts = append(parts, rec.Text, rec.ToolResult, rec.Error)
+	var kept []string
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			kept = append(kept, strings.TrimSpace(part))
+		}
+	}
+	entry.Text = strings.Join(kept, "\n")
+	return entry, true
+}
+
+// syncChatHistory reads each chat session as one run holding the messages of
+// its checked-out branch.
+func syncChatHistory(store *history.Store, dbPath string, states map[string]string, seen map[string]bool) error {
+	if dbPath == "" {
+		return nil
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil
+	}
+	chats, err := chat.NewSessionStore(dbPath)
+	if err != nil {
+		log.Warnw("Skipping chat sessions in history", "path", dbPath, "error", err)
+		keepSources(states, seen, chatSourcePrefix)
+		return nil
+	}
+	defer chats.Close()
+	sessions, err := chats.ListSessions()
+	if err != nil {
+		log.Warnw("Skipping chat sessions in history", "path", dbPath, "error", err)
+		keepSources(states, seen, chatSourcePrefix)
+		return nil
+	}
+
+	for _, session := range sessions {
+		key := chatSourcePrefix + strconv.FormatInt(session.ID, 10)
+		seen[key] = true
+		state := fmt.Sprintf("%d:%d:%d:%s/%s", session.UpdatedAt.UnixNano(), session.MessageCount, session.HeadID, session.Provider, session.Model)
+		if states[key] == state {
+			continue
+		}
+		messages, err := chats.SessionMessages(session.ID)
+		if err != nil {
+			return err
+		}
+		if err := store.ReplaceSource(key, state, []history.Run{chatSessionRun(session, messages, dbPath)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepSources marks the sources with a key prefix as seen, keeping the runs
+// read earlier from a source that cannot be read now.
+func keepSources(states map[string]string, seen map[string]bool, prefix string) {
+	for key := range states {
+		if strings.HasPrefix(key, prefix) {
+			seen[key] = true
+		}
+	}
+}
+
+func chatSessionRun(session chat.Session, messages []chat.Message, dbPath string) history.Run {
+	run := history.Run{
+		ID:          "chat-" + strconv.FormatInt(session.ID, 10),
+		Kind:        string(RunKindChat),
+		Source:      history.SourceChat,
+		Status:      history.StatusIncomplete,
+		Provider:    session.Provider,
+		Model:       session.Model,
+		Request:     session.Title,
+		CreatedAt:   session.CreatedAt,
+		CompletedAt: session.UpdatedAt,
+		Path:        dbPath,
+	}
+	for _, msg := range messages {
+		run.Entries = append(run.Entries, history.Entry{Type: msg.Role, Text: msg.Content})
+		switch msg.Role {
+		case "user":
+			if run.Request == "" {
+				run.Request = msg.Content
+			}
+			run.Status = history.StatusIncomplete
+		case "assistant":
+			run.Response = msg.Content
+			run.Status = history.StatusCompleted
+		}
+	}
+	return run
+}
+
+// syncLogHistory reads the --log interaction log, which only grows, whole
+// whenever it changed.
+func syncLogHistory(store *history.Store, path string, states map[string]string, seen map[string]bool) error {
+	if path == "" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	key := logSourcePrefix + path
+	seen[key] = true
+	state := fileState(info)
+	if states[key] == state {
+		return nil
+	}
+	logs, err := history.NewHistory(path).Query(history.HistoryQuery{})
+	if err != nil {
+		log.Warnw("Skipping interaction log in history", "path", path, "error", err)
+		keepSources(states, seen, key)
+		return nil
+	}
+
+	var runs []history.Run
+	for _, entry := range logs {
+		at, err := time.Parse(time.RFC3339, entry.Timestamp)
+		if err != nil {
+			continue
+		}
+		logged, err := hasSessionRun(store, entry.Method, entry.Query, at)
+		if err != nil {
+			return err
+		}
+		if logged {
+			continue
+		}
+		sum := sha256.Sum256([]byte(entry.Timestamp + "\x00" + entry.Method + "\x00" + entry.Query))
+		runs = append(runs, history.Run{
+			ID:          "log-" + hex.EncodeToString(sum[:4]),
+			Kind:        entry.Method,
+			Source:      history.SourceLog,
+			Status:      history.StatusCompleted,
+			Request:     entry.Query,
+			Response:    entry.Answer,
+			CreatedAt:   at,
+			CompletedAt: at,
+			Path:        path,
+			Entries: []history.Entry{
+				{Type: "user", Text: entry.Query, Time: at},
+				{Type: "assistant", Text: entry.Answer, Time: at},
+			},
+		})
+	}
+	return store.ReplaceSource(key, state, runs)
+}
+
+// hasSessionRun reports whether a session log records the run a --log entry
+// written at `at` describes: one of the same kind, finishing around then,
+// whose request ends the logged query (ask logs the question with its
+// context prepended).
+func hasSessionRun(store *history.Store, kind, query string, at time.Time) (bool, error) {
+	candidates, err := store.Search(history.SearchQuery{Kind: kind, Until: at.Add(logDedupWindow), Limit: 50})
+	if err != nil {
+		return false, err
+	}
+	for _, candidate := range candidates {
+		if candidate.Source != history.SourceSession || candidate.Request == "" || candidate.CompletedAt.IsZero() {
+			continue
+		}
+		if candidate.CompletedAt.Sub(at).Abs() <= logDedupWindow && strings.HasSuffix(query, candidate.Request) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
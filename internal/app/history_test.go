@@ -0,0 +1,106 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/chat"
+	"github.com/laszukdawid/terminal-agent/internal/history"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncHistoryIngestsEverySource(t *testing.T) {
+	root := t.TempDir()
+	sources := HistorySources{
+		LogPath:     filepath.Join(root, "query_log.jsonl"),
+		SessionDir:  filepath.Join(root, "sessions"),
+		RoutinesDir: filepath.Join(root, "routines"),
+		ChatDB:      filepath.Join(root, "chat.db"),
+	}
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	task := sessionlog.New(sources.SessionDir, sessionlog.Meta{Kind: "task", Provider: "bedrock", Model: "m", Cwd: "/src", Command: "bring up the stack", CreatedAt: start})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: "bring up the stack"})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordToolCall, ToolName: "unix", ToolInput: map[string]any{"command": "docker compose up"}})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordFailed, Error: "port in use", Timestamp: start.Add(time.Second)})
+
+	chatTurn := sessionlog.New(sources.SessionDir, sessionlog.Meta{Kind: "chat", CreatedAt: start})
+	chatTurn.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: "hello"})
+
+	routine := sessionlog.New(filepath.Join(sources.RoutinesDir, "nightly", "logs"), sessionlog.Meta{Kind: "routine", RoutineID: "nightly", Command: "report", CreatedAt: start.Add(time.Minute)})
+	routine.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: "report"})
+	routine.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Text: "all good", Timestamp: start.Add(2 * time.Minute)})
+
+	chats, err := chat.NewSessionStore(sources.ChatDB)
+	require.NoError(t, err)
+	_, err = chats.NewSession()
+	require.NoError(t, err)
+	require.NoError(t, chats.AddMessage("user", "how do I list containers?"))
+	require.NoError(t, chats.AddMessage("assistant", "docker ps"))
+	require.NoError(t, chats.Close())
+
+	// The task was also logged with --log; that entry is the same run.
+	logs := history.NewHistory(sources.LogPath)
+	require.NoError(t, logs.Log("ask", "what is 2+2?", "4"))
+	writeLogEntry(t, sources.LogPath, "task", "bring up the stack", start.Add(time.Second))
+
+	store, err := history.OpenStore(filepath.Join(root, "history.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, SyncHistory(store, sources))
+
+	results, err := store.Search(history.SearchQuery{Limit: 10})
+	require.NoError(t, err)
+	kinds := map[string]history.SearchResult{}
+	for _, result := range results {
+		kinds[result.Kind] = result
+	}
+	assert.Len(t, results, 4)
+	assert.Equal(t, history.StatusFailed, kinds["task"].Status)
+	assert.Equal(t, history.SourceSession, kinds["task"].Source)
+	assert.Equal(t, "nightly", kinds["routine"].RoutineID)
+	assert.Equal(t, history.StatusCompleted, kinds["routine"].Status)
+	assert.Equal(t, history.SourceChat, kinds["chat"].Source)
+	assert.Equal(t, "docker ps", kinds["chat"].Response)
+	assert.Equal(t, history.SourceLog, kinds["ask"].Source)
+
+	results, err = store.Search(history.SearchQuery{Text: "docker compose", Kind: "task", Provider: "bedrock", Status: "failed"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	run, err := store.Get(results[0].ShortID())
+	require.NoError(t, err)
+	assert.Equal(t, task.RunID(), run.ID)
+	assert.Equal(t, []history.Entry{
+		{Type: "user", Text: "bring up the stack"},
+		{Type: "tool_call", Tool: "unix", Text: `{"command":"docker compose up"}`},
+		{Type: "error", Text: "port in use"},
+	}, withoutTimes(run.Entries))
+
+	require.NoError(t, os.Remove(routine.Path()))
+	require.NoError(t, SyncHistory(store, sources))
+	results, err = store.Search(history.SearchQuery{Kind: "routine"})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func writeLogEntry(t *testing.T, path, method, query string, at time.Time) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(`{"method":"` + method + `","timestamp":"` + at.Format(time.RFC3339) + `","query":"` + query + `","answer":""}` + "\n")
+	require.NoError(t, err)
+}
+
+func withoutTimes(entries []history.Entry) []history.Entry {
+	out := make([]history.Entry, len(entries))
+	for i, entry := range entries {
+		entry.Time = time.Time{}
+		out[i] = entry
+	}
+	return out
+}
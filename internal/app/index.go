@@ -0,0 +1,301 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/laszukdawid/terminal-agent/internal/index"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
+	log "github.com/laszukdawid/terminal-agent/internal/utils"
+)
+
+// DefaultRAGTopK is how many indexed chunks `ask --rag` adds by default.
+const DefaultRAGTopK = 5
+
+// ErrNoIndex is returned when no directory at or above the one given has a
+// semantic search index.
+var ErrNoIndex = errors.New("no semantic index found; build one with `agent index build`")
+
+type IndexBuildRequest struct {
+	// Dir is the directory to index; "" is the current one.
+	Dir string
+	// Provider and Model select the embedding model. When both are empty, the
+	// model the index was built with is reused, or else the default
+	// provider's default embedding model.
+	Provider string
+	Model    string
+	Config   config.Config
+	// Progress, if set, is called after each embedded file.
+	Progress func(done, total int)
+}
+
+type IndexBuildResult struct {
+	Root  string
+	Model index.Model
+	Stats index.Stats
+}
+
+// BuildIndex creates or incrementally updates the semantic index of a
+// directory.
+func BuildIndex(ctx context.Context, req IndexBuildRequest) (IndexBuildResult, error) {
+	root, err := indexDir(req.Dir)
+	if err != nil {
+		return IndexBuildResult{}, err
+	}
+	// Chunks leave the machine for the embedding model and are kept in the
+	// index, so secrets are masked first.
+	redactor, err := redact.Load()
+	if err != nil {
+		return IndexBuildResult{}, err
+	}
+	ix, err := index.OpenWithRedactor(IndexPath(root), root, redactor)
+	if err != nil {
+		return IndexBuildResult{}, err
+	}
+	defer ix.Close()
+
+	info, err := ix.Info()
+	if err != nil {
+		return IndexBuildResult{}, err
+	}
+	model, err := resolveIndexModel(info.Model, req.Provider, req.Model, req.Config)
+	if err != nil {
+		return IndexBuildResult{}, err
+	}
+	embedder, err := newIndexEmbedder(model, req.Config)
+	if err != nil {
+		return IndexBuildResult{}, err
+	}
+
+	stats, err := ix.Update(ctx, model, embedder, req.Progress)
+	return IndexBuildResult{Root: root, Model: model, Stats: stats}, err
+}
+
+// resolveIndexModel picks the embedding model for a build: the requested
+// provider and model, the model the index already uses, or the default
+// provider's default embedding model.
+func resolveIndexModel(current index.Model, provider, model string, cfg config.Config) (index.Model, error) {
+	if provider == "" {
+		provider = current.Provider
+	}
+	if provider == "" && cfg != nil {
+		provider = cfg.GetDefaultProvider()
+	}
+	if provider == "" {
+		return index.Model{}, fmt.Errorf("no embedding provider; pass --provider")
+	}
+	if !connector.SupportsEmbeddings(provider) {
+		return index.Model{}, fmt.Errorf("provider %s does not support embeddings; pass --provider %s, %s or %s", provider, connector.OpenaiProvider, connector.OllamaProvider, connector.LlamaProvider)
+	}
+	if model == "" && provider == current.Provider {
+		model = current.Name
+	}
+	if model == "" {
+		model = connector.DefaultEmbeddingModel(provider)
+	}
+	if model == "" {
+		return index.Model{}, fmt.Errorf("provider %s has no default embedding model; pass --model", provider)
+	}
+	return index.Model{Provider: provider, Name: model}, nil
+}
+
+func newIndexEmbedder(model index.Model, cfg config.Config) (connector.Embedder, error) {
+	if err := config.CheckProviderAllowed(model.Provider); err != nil {
+		return nil, err
+	}
+	return connector.NewEmbedder(model.Provider, model.Name, cfg)
+}
+
+func indexDir(dir string) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid directory %q: %w", dir, err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", root)
+	}
+	return root, nil
+}
+
+// FindIndexRoot returns the nearest directory at or above dir that has a
+// semantic index.
+func FindIndexRoot(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if _, err := os.Stat(IndexPath(dir)); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+type IndexStatus struct {
+	Path    string
+	Info    index.Info
+	Changes index.Changes
+}
+
+// GetIndexStatus describes the index covering dir and the files changed since
+// it was last updated.
+func GetIndexStatus(dir string) (IndexStatus, error) {
+	root, err := indexDir(dir)
+	if err != nil {
+		return IndexStatus{}, err
+	}
+	root, ok := FindIndexRoot(root)
+	if !ok {
+		return IndexStatus{}, ErrNoIndex
+	}
+	status := IndexStatus{Path: IndexPath(root)}
+	ix, err := index.Open(status.Path, root)
+	if err != nil {
+		return IndexStatus{}, err
+	}
+	defer ix.Close()
+
+	if status.Info, err = ix.Info(); err != nil {
+		return IndexStatus{}, err
+	}
+	if status.Changes, err = ix.Changes(); err != nil {
+		return IndexStatus{}, err
+	}
+	return status, nil
+}
+
+// ProjectIndex is an open semantic index with the embedding model it was
+// built with, ready to search.
+type ProjectIndex struct {
+	*index.Index
+	embedder connector.Embedder
+}
+
+// OpenProjectIndex opens the index covering dir. It returns ErrNoIndex when
+// there is none.
+func OpenProjectIndex(dir string, cfg config.Config) (*ProjectIndex, error) {
+	root, ok := FindIndexRoot(dir)
+	if !ok {
+		return nil, ErrNoIndex
+	}
+	ix, err := index.Open(IndexPath(root), root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := ix.Info()
+	if err == nil && info.Chunks == 0 {
+		err = ErrNoIndex
+	}
+	var embedder connector.Embedder
+	if err == nil {
+		embedder, err = newIndexEmbedder(info.Model, cfg)
+	}
+	if err != nil {
+		ix.Close()
+		return nil, err
+	}
+	return &ProjectIndex{Index: ix, embedder: embedder}, nil
+}
+
+// Search returns the k indexed chunks closest to query.
+func (p *ProjectIndex) Search(ctx context.Context, query string, k int) ([]index.Result, error) {
+	return p.Index.Search(ctx, p.embedder, query, k)
+}
+
+// BuildContextFromIndex searches the index covering dir for the chunks
+// closest to query and formats them as <context> blocks. k <= 0 uses
+// DefaultRAGTopK.
+func BuildContextFromIndex(ctx context.Context, dir string, cfg config.Config, query string, k int) (string, error) {
+	if k <= 0 {
+		k = DefaultRAGTopK
+	}
+	projectIndex, err := OpenProjectIndex(dir, cfg)
+	if err != nil {
+		return "", err
+	}
+	defer projectIndex.Close()
+
+	results, err := projectIndex.Search(ctx, query, k)
+	if err != nil {
+		return "", err
+	}
+	return index.FormatResults(results), nil
+}
+
+// indexWatchDelay is how long WatchIndex waits for changes to settle before
+// updating the index, so a checkout or a formatter run triggers one update.
+const indexWatchDelay = 2 * time.Second
+
+// WatchIndex keeps the index of req.Dir up to date until ctx is done: once
+// files under it change and settle, it runs an incremental build and reports
+// the result to onBuild.
+func WatchIndex(ctx context.Context, req IndexBuildRequest, onBuild func(IndexBuildResult, error)) error {
+	root, err := indexDir(req.Dir)
+	if err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+	defer watcher.Close()
+
+	dirs, err := index.Dirs(root)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			log.Warnw("Watching directory failed", "dir", dir, "error", err)
+		}
+	}
+
+	timer := time.NewTimer(indexWatchDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					_ = watcher.Add(event.Name)
+				}
+			}
+			timer.Reset(indexWatchDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warnw("Index watcher error", "error", err)
+		case <-timer.C:
+			onBuild(BuildIndex(ctx, req))
+		}
+	}
+}
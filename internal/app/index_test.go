@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/laszukdawid/terminal-agent/internal/index"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOllamaEmbeddings serves /api/embed, embedding each text by which of a
+// few keywords it contains.
+func fakeOllamaEmbeddings(t *testing.T) {
+	keywords := []string{"login", "invoice", "readme"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		embeddings := make([][]float32, len(req.Input))
+		for i, text := range req.Input {
+			vector := make([]float32, len(keywords)+1)
+			vector[len(keywords)] = 0.1
+			for j, keyword := range keywords {
+				vector[j] = float32(strings.Count(strings.ToLower(text), keyword))
+			}
+			embeddings[i] = vector
+		}
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": embeddings})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("OLLAMA_HOST", server.URL)
+}
+
+func TestBuildIndexAndSearch(t *testing.T) {
+	fakeOllamaEmbeddings(t)
+	t.Setenv(IndexDirEnv, t.TempDir())
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "auth"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "auth", "login.go"), []byte("package auth\n\nfunc Login() {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "invoice.go"), []byte("package main\n\nfunc Invoice() {}\n"), 0644))
+
+	_, err := OpenProjectIndex(root, nil)
+	assert.ErrorIs(t, err, ErrNoIndex)
+
+	result, err := BuildIndex(context.Background(), IndexBuildRequest{Dir: root, Provider: connector.OllamaProvider})
+	require.NoError(t, err)
+	assert.Equal(t, root, result.Root)
+	assert.Equal(t, index.Model{Provider: connector.OllamaProvider, Name: connector.DefaultOllamaEmbeddingModel}, result.Model)
+	assert.Equal(t, 2, result.Stats.Indexed)
+
+	found, ok := FindIndexRoot(filepath.Join(root, "auth"))
+	assert.True(t, ok)
+	assert.Equal(t, root, found)
+
+	indexContext, err := BuildContextFromIndex(context.Background(), filepath.Join(root, "auth"), nil, "how does login work", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "<context source=\"auth/login.go:1-3\">\npackage auth\n\nfunc Login() {}\n</context>", indexContext)
+
+	// A rebuild without flags keeps the model and only embeds changes.
+	require.NoError(t, os.WriteFile(filepath.Join(root, "README.md"), []byte("# Readme\n"), 0644))
+	status, err := GetIndexStatus(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"README.md"}, status.Changes.Added)
+
+	result, err = BuildIndex(context.Background(), IndexBuildRequest{Dir: root})
+	require.NoError(t, err)
+	assert.Equal(t, connector.DefaultOllamaEmbeddingModel, result.Model.Name)
+	assert.Equal(t, index.Stats{Indexed: 1, Chunks: 1, Unchanged: 2}, result.Stats)
+}
+
+func TestResolveIndexModel(t *testing.T) {
+	current := index.Model{Provider: connector.OllamaProvider, Name: "mxbai-embed-large"}
+
+	model, err := resolveIndexModel(current, "", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, current, model)
+
+	model, err = resolveIndexModel(current, connector.OpenaiProvider, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, index.Model{Provider: connector.OpenaiProvider, Name: connector.DefaultOpenAIEmbeddingModel}, model)
+
+	model, err = resolveIndexModel(current, "", "all-minilm", nil)
+	require.NoError(t, err)
+	assert.Equal(t, index.Model{Provider: connector.OllamaProvider, Name: "all-minilm"}, model)
+
+	_, err = resolveIndexModel(index.Model{}, "", "", nil)
+	assert.ErrorContains(t, err, "no embedding provider")
+
+	_, err = resolveIndexModel(index.Model{}, connector.AnthropicProvider, "", nil)
+	assert.ErrorContains(t, err, "provider anthropic does not support embeddings")
+
+	_, err = resolveIndexModel(index.Model{}, connector.LlamaProvider, "", nil)
+	assert.ErrorContains(t, err, "provider llama has no default embedding model")
+}
@@ -1,27 +1,36 @@
 package app
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/laszukdawid/terminal-agent/internal/memory"
 )
 
 var (
-	memoryDir  = filepath.Join(os.Getenv("HOME"), ".local", "share", "terminal-agent")
-	memoryFile = "memory.jsonl"
 	logDir     = filepath.Join(os.Getenv("HOME"), ".local", "share", "terminal-agent")
 	logFile    = "query_log.jsonl"
 	sessionDir = filepath.Join(os.Getenv("HOME"), ".local", "share", "terminal-agent", "sessions")
+	chatDir    = filepath.Join(os.Getenv("HOME"), ".local", "share", "terminal-agent")
+	chatFile   = "chat.db"
 )
 
 func MemoryPath() string {
-	return filepath.Join(memoryDir, memoryFile)
+	return memory.DefaultPath()
 }
 
 func LogPath() string {
 	return filepath.Join(logDir, logFile)
 }
 
+// ChatDBPath is the database holding the chat sessions.
+func ChatDBPath() string {
+	return filepath.Join(chatDir, chatFile)
+}
+
 // SessionDirEnv overrides the directory where per-run execution logs are written.
 // Primarily used by tests to avoid writing into the real user data directory.
 const SessionDirEnv = "TERMINAL_AGENT_SESSIONS_DIR"
@@ -33,3 +42,29 @@ func SessionDir() string {
 	}
 	return sessionDir
 }
+
+// IndexDirEnv overrides the directory holding the semantic search indexes.
+const IndexDirEnv = "TERMINAL_AGENT_INDEX_DIR"
+
+// IndexPath is the database of the semantic search index for the directory
+// root, named after a hash of its path.
+func IndexPath(root string) string {
+	dir := filepath.Join(logDir, "index")
+	if override := strings.TrimSpace(os.Getenv(IndexDirEnv)); override != "" {
+		dir = override
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".db")
+}
+
+// HistoryDBEnv overrides the path of the unified history database.
+const HistoryDBEnv = "TERMINAL_AGENT_HISTORY_DB"
+
+// HistoryDBPath is the database indexing every ask, chat, task and routine
+// run for `agent history`.
+func HistoryDBPath() string {
+	if override := strings.TrimSpace(os.Getenv(HistoryDBEnv)); override != "" {
+		return override
+	}
+	return filepath.Join(logDir, "history.db")
+}
@@ -2,17 +2,24 @@ package app
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/laszukdawid/terminal-agent/internal/memory"
 )
 
-func BuildAskPrompt(basePrompt string, includeMemory bool, memoryPath string) (string, error) {
+// BuildAskPrompt prepends the memory entries relevant to query to basePrompt
+// when includeMemory is set.
+func BuildAskPrompt(basePrompt string, includeMemory bool, memoryPath string, query memory.Query) (string, error) {
 	if !includeMemory {
 		return basePrompt, nil
 	}
 
+	if query.Dir == "" {
+		// Project entries match the directory the run works in.
+		query.Dir, _ = os.Getwd()
+	}
 	mClient := memory.NewMemory(memoryPath)
-	memoryPrompt, err := mClient.FormatAsPrompt()
+	memoryPrompt, err := mClient.FormatAsPrompt(query)
 	if err != nil {
 		return "", fmt.Errorf("failed to format memory: %w", err)
 	}
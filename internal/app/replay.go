@@ -0,0 +1,108 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/laszukdawid/terminal-agent/internal/history"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+)
+
+// RecordedRun is a run read back from its session log.
+type RecordedRun struct {
+	sessionlog.Summary
+	// Meta is the log's provenance header; zero when the log has none.
+	Meta    sessionlog.Meta
+	Records []sessionlog.Record
+}
+
+// LoadRecordedRun reads the run recorded in a session log, given either the
+// log's path or a run id, or a unique prefix of one, from the history.
+func LoadRecordedRun(ref string, sources HistorySources) (RecordedRun, error) {
+	path := ref
+	if info, err := os.Stat(ref); err != nil || info.IsDir() {
+		store, err := OpenHistory(sources)
+		if err != nil {
+			return RecordedRun{}, fmt.Errorf("failed to open history: %w", err)
+		}
+		run, err := store.Get(ref)
+		store.Close()
+		if err != nil {
+			return RecordedRun{}, err
+		}
+		if run.Source != history.SourceSession {
+			return RecordedRun{}, fmt.Errorf("run %s has no session log to replay: it was read from %s", run.ShortID(), run.Path)
+		}
+		path = run.Path
+	}
+
+	summary, records, err := sessionlog.Load(path)
+	if err != nil {
+		return RecordedRun{}, err
+	}
+	recorded := RecordedRun{Summary: summary, Records: records}
+	for _, rec := range records {
+		if rec.Type == sessionlog.RecordMeta && rec.Meta != nil {
+			recorded.Meta = *rec.Meta
+			break
+		}
+	}
+	return recorded, nil
+}
+
+// ModelResponses rebuilds the model's responses from the step records, in the
+// order the run received them. Each model response of a task run is recorded
+// as exactly one of them, whatever became of its tool call.
+func (r RecordedRun) ModelResponses() []connector.LlmResponseWithTools {
+	var responses []connector.LlmResponseWithTools
+	for _, rec := range r.Records {
+		switch rec.Type {
+		case sessionlog.RecordThought:
+			responses = append(responses, connector.LlmResponseWithTools{Response: rec.Text})
+		case sessionlog.RecordToolResult, sessionlog.RecordToolCall, sessionlog.RecordDeclined, sessionlog.RecordSimulated:
+			if rec.ToolName == "" {
+				continue
+			}
+			response := connector.LlmResponseWithTools{
+				ToolUse:   true,
+				ToolName:  rec.ToolName,
+				ToolInput: rec.ToolInput,
+			}
+			// A simulated record's text is the simulation notice, not the
+			// model's thought.
+			if rec.Type != sessionlog.RecordSimulated {
+				response.Response = rec.Text
+			}
+			responses = append(responses, response)
+		}
+	}
+	return responses
+}
+
+// ReplayRequest returns a task request running the recorded run again with
+// its recorded model responses in place of the provider, so that its tool
+// calls are made again without calling the model. Only task runs and the runs
+// of prompt routines can be run again this way.
+func (r RecordedRun) ReplayRequest() (TaskRequest, error) {
+	if r.Kind != string(RunKindTask) && r.Kind != string(RunKindRoutine) {
+		return TaskRequest{}, fmt.Errorf("cannot re-execute a %q run: only task and routine runs make tool calls", r.Kind)
+	}
+	if r.Request == "" {
+		return TaskRequest{}, errors.New("the recorded run has no request")
+	}
+	responses := r.ModelResponses()
+	if len(responses) == 0 {
+		return TaskRequest{}, errors.New("the recorded run has no model responses to replay")
+	}
+	return TaskRequest{
+		Message:    r.Request,
+		Provider:   r.Provider,
+		Model:      r.Model,
+		WorkingDir: r.Cwd,
+		RoutineID:  r.RoutineID,
+		DryRun:     r.Meta.DryRun,
+		Connector:  connector.NewReplayConnector(responses, r.Response),
+	}, nil
+}
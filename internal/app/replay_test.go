@@ -0,0 +1,61 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRecordedRunRebuildsModelResponses(t *testing.T) {
+	root := t.TempDir()
+	sources := HistorySources{SessionDir: filepath.Join(root, "sessions")}
+	t.Setenv(HistoryDBEnv, filepath.Join(root, "history.db"))
+	start := time.Now().Add(-time.Hour)
+
+	recorder := sessionlog.New(sources.SessionDir, sessionlog.Meta{Kind: "task", Provider: "bedrock", Model: "m", Cwd: "/src", Command: "clean up", CreatedAt: start})
+	recorder.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: "clean up"})
+	recorder.Write(sessionlog.Record{Type: sessionlog.RecordProgress, Text: "Thinking"})
+	recorder.Write(sessionlog.Record{Type: sessionlog.RecordToolResult, Text: "List first.", ToolName: "unix", ToolInput: map[string]any{"command": "ls"}, ToolResult: "a.tmp"})
+	recorder.Write(sessionlog.Record{Type: sessionlog.RecordToolCall, Text: "Remove it.", ToolName: "unix", ToolInput: map[string]any{"command": "rm a.tmp"}, Error: "permission denied"})
+	recorder.Write(sessionlog.Record{Type: sessionlog.RecordSimulated, Text: "simulated: rm -f a.tmp", ToolName: "unix", ToolInput: map[string]any{"command": "rm -f a.tmp"}})
+	recorder.Write(sessionlog.Record{Type: sessionlog.RecordToolResult, Text: "Done.", ToolName: "final_answer", ToolInput: map[string]any{"answer": "Removed."}, ToolResult: "Removed."})
+	recorder.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Text: "Removed."})
+
+	byPath, err := LoadRecordedRun(recorder.Path(), sources)
+	require.NoError(t, err)
+	byID, err := LoadRecordedRun(recorder.RunID()[:8], sources)
+	require.NoError(t, err)
+	assert.Equal(t, byPath, byID)
+	assert.Equal(t, "/src", byID.Meta.Cwd)
+
+	assert.Equal(t, []connector.LlmResponseWithTools{
+		{Response: "List first.", ToolUse: true, ToolName: "unix", ToolInput: map[string]any{"command": "ls"}},
+		{Response: "Remove it.", ToolUse: true, ToolName: "unix", ToolInput: map[string]any{"command": "rm a.tmp"}},
+		{ToolUse: true, ToolName: "unix", ToolInput: map[string]any{"command": "rm -f a.tmp"}},
+		{Response: "Done.", ToolUse: true, ToolName: "final_answer", ToolInput: map[string]any{"answer": "Removed."}},
+	}, byID.ModelResponses())
+
+	req, err := byID.ReplayRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "clean up", req.Message)
+	assert.Equal(t, "bedrock", req.Provider)
+	assert.Equal(t, "/src", req.WorkingDir)
+	replay, ok := req.Connector.(*connector.ReplayConnector)
+	require.True(t, ok)
+	assert.Equal(t, 4, replay.Remaining())
+}
+
+func TestRecordedRunReplayRequestRejectsRunsWithoutToolCalls(t *testing.T) {
+	ask := RecordedRun{Summary: sessionlog.Summary{Kind: "ask", Request: "what is 2+2?"}}
+	_, err := ask.ReplayRequest()
+	assert.ErrorContains(t, err, `cannot re-execute a "ask" run`)
+
+	empty := RecordedRun{Summary: sessionlog.Summary{Kind: "task", Request: "do it"}}
+	_, err = empty.ReplayRequest()
+	assert.EqualError(t, err, "the recorded run has no model responses to replay")
+}
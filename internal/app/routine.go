@@ -2,10 +2,13 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -13,13 +16,18 @@ import (
 	internalagent "github.com/laszukdawid/terminal-agent/internal/agent"
 	"github.com/laszukdawid/terminal-agent/internal/config"
 	"github.com/laszukdawid/terminal-agent/internal/daemon"
+	"github.com/laszukdawid/terminal-agent/internal/notify"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
 	"github.com/laszukdawid/terminal-agent/internal/routines"
-	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	log "github.com/laszukdawid/terminal-agent/internal/utils"
 )
 
 // maxResultSummaries bounds how many run artifacts are retained per routine.
 const maxResultSummaries = 50
 
+// routineOutputExt is the extension of a run's stored, normalised output.
+const routineOutputExt = ".output"
+
 // RoutineService is the app-layer facade for managing and running routines. It
 // is shared by the CLI, the GUI, and the scheduling daemon so every surface uses
 // one execution and persistence path.
@@ -38,6 +46,10 @@ type RoutineService interface {
 	// ReadLog returns the content of a stored run log, keeping filesystem access
 	// behind the facade rather than in callers (e.g. the GUI).
 	ReadLog(ctx context.Context, ref RoutineLogRef) (string, error)
+	// Diff compares the stored outputs of two runs, named by run id or summary
+	// name. With no runs it compares the last two successful runs; with only
+	// from, it compares from with the latest run.
+	Diff(ctx context.Context, idOrName, from, to string) (RoutineDiff, error)
 	// LaunchNotice returns a one-line summary of routine runs that completed
 	// since the user last saw routine activity, then advances the seen marker.
 	// It returns an empty string when there is nothing to report.
@@ -45,6 +57,18 @@ type RoutineService interface {
 	// DaemonRunning reports whether the scheduler daemon is currently running.
 	// Scheduled routines only fire while it is; surfaces should warn when it isn't.
 	DaemonRunning() bool
+	// DaemonStatus asks the running daemon for its schedule, queue and running
+	// runs. The error wraps daemon.ErrNotRunning when no daemon is listening.
+	DaemonStatus(ctx context.Context) (daemon.ControlStatus, error)
+	// PauseDaemon holds (true) or releases (false) the daemon's run queue.
+	PauseDaemon(ctx context.Context, paused bool) error
+	// Enqueue asks the running daemon to run a routine, subject to its queue
+	// and concurrency caps, instead of running it in this process.
+	Enqueue(ctx context.Context, req RoutineRunRequest) (routines.QueuedRun, error)
+	// Export packs the named routines into a portable bundle.
+	Export(ctx context.Context, idsOrNames []string) (routines.Bundle, error)
+	// Import validates and sandboxes a bundle's routines, then stores them.
+	Import(ctx context.Context, bundle routines.Bundle, opts RoutineImportOptions) ([]RoutineImportResult, error)
 }
 
 // RoutineView merges a routine definition with its latest run status for display.
@@ -60,12 +84,25 @@ type RoutineView struct {
 	// model instead of a bare "(default)" that hides what will execute.
 	ResolvedProvider string
 	ResolvedModel    string
+	// Settings holds everything a run would resolve, for dry runs and
+	// detailed views.
+	Settings RoutineSettings
 }
 
 // RoutineRunRequest asks the service to run a routine now.
 type RoutineRunRequest struct {
 	IDOrName string
-	Trigger  string // routines.TriggerManual (default) or routines.TriggerScheduled
+	Trigger  string // routines.Trigger*; defaults to routines.TriggerManual, or Event.Type
+	// Event is the payload of the trigger that fired the run, exposed to the
+	// prompt template as {{.Trigger}}.
+	Event *routines.TriggerEvent
+	// Params sets declared prompt parameters; the rest use their defaults.
+	Params map[string]string
+	// DryRun runs the task loop with mutating tool calls simulated (see
+	// RoutineRunResult.Simulated). Nothing is recorded in the run state, no
+	// summary is written and no notifications are sent; the session log is
+	// kept, marked as a dry run.
+	DryRun bool
 }
 
 // RoutineRunResult is the outcome of a single routine run.
@@ -78,6 +115,31 @@ type RoutineRunResult struct {
 	TokensUsed int
 	SessionLog string
 	ResultPath string
+	// Redactions counts secrets masked during the run, per detector.
+	Redactions map[string]int
+	// Steps holds the per-step results of a workflow routine, in definition
+	// order.
+	Steps []StepResult
+	// Attempts lists every try of a run made under a retry policy.
+	Attempts []routines.Attempt
+	// Changed reports whether a successful run's output differs from the
+	// previous successful run's, and Diff shows how.
+	Changed bool
+	Diff    string
+	// AutoDisabled is set when the run's failure reached the routine's
+	// DisableAfter limit and the routine was disabled.
+	AutoDisabled bool
+	// DryRun is set for a dry run, and Simulated lists the calls it
+	// intercepted, in order (per step, in definition order, for workflows).
+	DryRun    bool
+	Simulated []SimulatedCall
+}
+
+// RoutineDiff is the change between two runs' outputs.
+type RoutineDiff struct {
+	From string // run names, as in the summary file names
+	To   string
+	Diff string // unified diff; empty when the outputs match
 }
 
 // RoutineLogRef points at a stored run artifact (transcript or summary).
@@ -92,6 +154,8 @@ type routineService struct {
 	cfg   config.Config
 	store *routines.Store
 	state *routines.StateStore
+	// wait sleeps between retry attempts and reports false if ctx ended first.
+	wait func(ctx context.Context, d time.Duration) bool
 }
 
 // NewRoutineService builds a RoutineService backed by the standard routine
@@ -101,14 +165,47 @@ func NewRoutineService(cfg config.Config) RoutineService {
 		cfg:   cfg,
 		store: routines.DefaultStore(),
 		state: routines.DefaultStateStore(),
+		wait:  waitContext,
+	}
+}
+
+func waitContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
 func (s *routineService) DaemonRunning() bool {
+	if _, err := s.DaemonStatus(context.Background()); err == nil {
+		return true
+	}
+	// A daemon without the control API still holds the single-instance lock.
 	status, err := daemon.CurrentStatus()
 	return err == nil && status.Running
 }
 
+func (s *routineService) DaemonStatus(ctx context.Context) (daemon.ControlStatus, error) {
+	return daemon.NewClient().Status(ctx)
+}
+
+func (s *routineService) PauseDaemon(ctx context.Context, paused bool) error {
+	_, err := daemon.NewClient().SetPaused(ctx, paused)
+	return err
+}
+
+func (s *routineService) Enqueue(ctx context.Context, req RoutineRunRequest) (routines.QueuedRun, error) {
+	r, err := s.store.Get(req.IDOrName)
+	if err != nil {
+		return routines.QueuedRun{}, err
+	}
+	return daemon.NewClient().Run(ctx, r.ID, req.Params)
+}
+
 func (s *routineService) List(ctx context.Context) ([]RoutineView, error) {
 	defs, err := s.store.List()
 	if err != nil {
@@ -127,9 +224,10 @@ func (s *routineService) List(ctx context.Context) ([]RoutineView, error) {
 			Status:           routines.DisplayStatus(r.Enabled, rec, has),
 			Run:              rec,
 			HasRun:           has,
-			Frequency:        describeSchedule(r.Schedule),
+			Frequency:        describeFrequency(r),
 			ResolvedProvider: eff.Provider,
 			ResolvedModel:    eff.Model,
+			Settings:         eff,
 		})
 	}
 	sort.Slice(views, func(i, j int) bool { return views[i].Routine.ID < views[j].Routine.ID })
@@ -151,9 +249,10 @@ func (s *routineService) Get(ctx context.Context, idOrName string) (RoutineView,
 		Status:           routines.DisplayStatus(r.Enabled, rec, has),
 		Run:              rec,
 		HasRun:           has,
-		Frequency:        describeSchedule(r.Schedule),
+		Frequency:        describeFrequency(r),
 		ResolvedProvider: eff.Provider,
 		ResolvedModel:    eff.Model,
+		Settings:         eff,
 	}, nil
 }
 
@@ -177,6 +276,36 @@ func (s *routineService) Save(ctx context.Context, r routines.Routine) (routines
 	return s.store.Get(r.ID)
 }
 
+// buildPromptData collects the built-in template variables of a run.
+func buildPromptData(r routines.Routine, eff RoutineSettings, prior routines.RunRecord, params map[string]string, start time.Time) *routines.PromptData {
+	data := &routines.PromptData{
+		RoutineID:  r.ID,
+		Date:       start.Local().Format(time.DateOnly),
+		Now:        start.Local().Format(time.RFC3339),
+		LastOutput: routines.ReadResultOutput(prior.LastResultPath),
+		Params:     params,
+	}
+	if !prior.LastRunAt.IsZero() {
+		data.LastRunAt = prior.LastRunAt.Local().Format(time.RFC3339)
+	}
+	if dir, err := resolveTaskRootDir(TaskRequest{WorkingDir: eff.WorkingDir}); err == nil {
+		data.WorkingDir = dir
+		data.GitBranch = internalagent.GitBranch(dir)
+	}
+	return data
+}
+
+// disable turns off a routine, re-reading it so edits made during the run are
+// kept.
+func (s *routineService) disable(id string) error {
+	r, err := s.store.Get(id)
+	if err != nil {
+		return err
+	}
+	r.Enabled = false
+	return s.store.Upsert(r)
+}
+
 func (s *routineService) Delete(ctx context.Context, idOrName string, purge bool) error {
 	r, err := s.store.Get(idOrName)
 	if err != nil {
@@ -232,6 +361,83 @@ func (s *routineService) Logs(ctx context.Context, idOrName string) ([]RoutineLo
 	return refs, nil
 }
 
+func (s *routineService) Diff(ctx context.Context, idOrName, from, to string) (RoutineDiff, error) {
+	r, err := s.store.Get(idOrName)
+	if err != nil {
+		return RoutineDiff{}, err
+	}
+	dir := routines.LogDir(r.ID)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return RoutineDiff{}, err
+	}
+	// Run names start with their timestamp, so name order is run order.
+	var runs, successes []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), routineOutputExt); ok && !entry.IsDir() {
+			runs = append(runs, name)
+			if strings.Contains(name, "_"+routines.OutcomeSuccess+"_") {
+				successes = append(successes, name)
+			}
+		}
+	}
+	sort.Strings(runs)
+	sort.Strings(successes)
+
+	switch {
+	case from == "" && to == "":
+		if len(successes) < 2 {
+			return RoutineDiff{}, fmt.Errorf("routine %q has fewer than two successful runs to compare", r.ID)
+		}
+		from, to = successes[len(successes)-2], successes[len(successes)-1]
+	case to == "":
+		if from, err = matchRun(runs, from); err != nil {
+			return RoutineDiff{}, err
+		}
+		to = runs[len(runs)-1]
+	default:
+		if from, err = matchRun(runs, from); err != nil {
+			return RoutineDiff{}, err
+		}
+		if to, err = matchRun(runs, to); err != nil {
+			return RoutineDiff{}, err
+		}
+	}
+
+	before, err := os.ReadFile(filepath.Join(dir, from+routineOutputExt))
+	if err != nil {
+		return RoutineDiff{}, err
+	}
+	after, err := os.ReadFile(filepath.Join(dir, to+routineOutputExt))
+	if err != nil {
+		return RoutineDiff{}, err
+	}
+	return RoutineDiff{From: from, To: to, Diff: routines.Diff(string(before), string(after), from, to)}, nil
+}
+
+// matchRun resolves a run reference (a full run name, its run id, or any other
+// unambiguous part of the name) against the stored runs.
+func matchRun(runs []string, ref string) (string, error) {
+	ref = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(ref), ".md"), routineOutputExt)
+	var matches []string
+	for _, run := range runs {
+		if run == ref {
+			return run, nil
+		}
+		if strings.Contains(run, ref) {
+			matches = append(matches, run)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no stored run output matches %q", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("run %q is ambiguous: %s", ref, strings.Join(matches, ", "))
+	}
+}
+
 func (s *routineService) ReadLog(ctx context.Context, ref RoutineLogRef) (string, error) {
 	data, err := os.ReadFile(ref.Path)
 	if err != nil {
@@ -245,11 +451,27 @@ func (s *routineService) Run(ctx context.Context, req RoutineRunRequest) (Routin
 	if err != nil {
 		return RoutineRunResult{}, err
 	}
+	given := req.Params
+	if given == nil && req.Event != nil {
+		given = req.Event.Params
+	}
+	params, err := r.ResolveParams(given)
+	if err != nil {
+		return RoutineRunResult{}, err
+	}
 	trigger := req.Trigger
+	if trigger == "" && req.Event != nil {
+		trigger = req.Event.Type
+	}
 	if trigger == "" {
 		trigger = routines.TriggerManual
 	}
 
+	redactor, err := redact.Load()
+	if err != nil {
+		return RoutineRunResult{}, err
+	}
+
 	// A cross-process run lock prevents overlapping runs of the same routine
 	// (manual + scheduled, or a run that outlived a daemon restart). If another
 	// run holds it, skip cleanly without recording a new run.
@@ -263,58 +485,127 @@ func (s *routineService) Run(ctx context.Context, req RoutineRunRequest) (Routin
 	defer release()
 
 	eff := s.resolve(r)
-	logDir := routines.LogDir(r.ID)
-
-	meta := buildMeta(string(RunKindRoutine), eff.Provider, eff.Model, eff.WorkingDir, r.Prompt)
-	meta.RoutineID = r.ID
-	meta.TaskTimeout = formatTaskTimeout(eff.Timeout)
-	recorder := sessionlog.New(logDir, meta)
-	recorder.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: r.Prompt})
-
-	taskReq := TaskRequest{
-		Message:      r.Prompt,
-		Provider:     eff.Provider,
-		Model:        eff.Model,
-		WorkingDir:   eff.WorkingDir,
-		Deny:         r.Deny,
-		AutoApprove:  true,
-		Timeout:      eff.Timeout,
-		TokenBudget:  eff.TokenBudget,
-		MaxTurns:     eff.MaxTurns,
-		MaxToolCalls: eff.MaxToolCalls,
-		EnabledTools: r.Tools,
-		// With no explicit tool list, a routine disables external-facing tools by
-		// default; naming tools (r.Tools != nil) is an explicit allow-list instead.
-		DisableExternalTools: r.Tools == nil,
-		Config:               s.cfg,
-	}
-
-	onStep := func(step internalagent.TaskStep) { recorder.Write(taskStepToRecord(step)) }
-	onStatus := func(status internalagent.TaskStatusEvent) { recorder.Write(taskStatusToRecord(status)) }
-	onProgress := func(progress internalagent.TaskProgressEvent) { recorder.Write(taskProgressToRecord(progress)) }
 
-	start := time.Now().UTC()
-	taskResult, runErr := executeTask(ctx, taskReq, internalagent.UnattendedInteraction{}, onStep, onStatus, onProgress, nil)
-	duration := time.Since(start)
-	outcome := classifyOutcome(runErr)
+	// Under a managed policy nobody is present to answer its ask rules, so they
+	// are declined; when it forbids auto-approve every prompt is declined.
+	unattended := unattendedPolicy{autoApprove: true, dryRun: req.DryRun}
+	if managed, err := config.LoadManagedPolicy(); err == nil && managed.SourcePath != "" {
+		unattended.interaction.DeclineConfirmations = true
+		unattended.autoApprove = managed.AutoApproveAllowed()
+	}
 
-	if runErr != nil {
-		recorder.Write(sessionlog.Record{Type: sessionlog.RecordFailed, Error: runErr.Error()})
-	} else {
-		recorder.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Text: taskResult.Response})
+	prior, _, err := s.state.Get(r.ID)
+	if err != nil {
+		return RoutineRunResult{}, err
 	}
+	start := time.Now().UTC()
+	data := buildPromptData(r, eff, prior, params, start)
+	if req.Event != nil {
+		data.Trigger = *req.Event
+	}
+
+	// Unattended runs are retried per the routine's policy; a manual run is
+	// tried once so its result comes straight back to the caller.
+	maxAttempts := 1
+	if trigger != routines.TriggerManual && !req.DryRun {
+		maxAttempts = r.Retry.Attempts()
+	}
+	var result RoutineRunResult
+	var errText, runID string
+	var attempts []routines.Attempt
+	tokensUsed := 0
+	for number := 1; ; number++ {
+		attemptStart := time.Now().UTC()
+		if r.IsWorkflow() {
+			result, errText, runID = s.runWorkflow(ctx, r, eff, data, redactor, unattended)
+		} else {
+			run := s.runPrompt(ctx, promptRun{
+				owner:      r,
+				routine:    r,
+				eff:        eff,
+				data:       data,
+				redactor:   redactor,
+				unattended: unattended,
+			})
+			result = RoutineRunResult{
+				Routine:    r,
+				Output:     run.output,
+				Outcome:    run.outcome,
+				Err:        run.err,
+				Duration:   run.duration,
+				TokensUsed: run.tokensUsed,
+				SessionLog: run.sessionLog,
+				Simulated:  run.simulated,
+			}
+			errText, runID = run.errText, run.runID
+		}
+		tokensUsed += result.TokensUsed
+		if maxAttempts == 1 {
+			break
+		}
 
-	result := RoutineRunResult{
-		Routine:    r,
-		Output:     taskResult.Response,
-		Outcome:    outcome,
-		Err:        runErr,
-		Duration:   duration,
-		TokensUsed: taskResult.TokensUsed,
-		SessionLog: recorder.Path(),
+		attempt := routines.Attempt{
+			Number:     number,
+			StartedAt:  attemptStart,
+			Outcome:    result.Outcome,
+			Duration:   result.Duration.Round(time.Millisecond).String(),
+			Error:      errText,
+			SessionLog: result.SessionLog,
+		}
+		retry := number < maxAttempts && r.Retry.Retryable(result.Outcome) && ctx.Err() == nil
+		delay := r.Retry.Delay(number)
+		if retry {
+			attempt.NextRetryAt = time.Now().UTC().Add(delay)
+		}
+		attempts = append(attempts, attempt)
+		if err := s.state.RecordAttempt(r.ID, attempt); err != nil {
+			log.Warnw("routine: recording attempt failed", "routine", r.ID, "attempt", number, "error", err)
+		}
+		if !retry || !s.wait(ctx, delay) {
+			break
+		}
+		log.Infow("routine: retrying", "routine", r.ID, "attempt", number+1, "outcome", result.Outcome)
+	}
+	result.Attempts = attempts
+	if len(attempts) > 1 {
+		result.Duration = time.Since(start)
+		result.TokensUsed = tokensUsed
+	}
+	result.Redactions = redactor.Counts()
+	if req.DryRun {
+		result.DryRun = true
+		return result, nil
+	}
+	duration, outcome, output, runErr := result.Duration, result.Outcome, result.Output, result.Err
+
+	// A routine that keeps failing is disabled rather than left to fire (and
+	// alert) forever; the summary and its notifications say so.
+	failures := 0
+	if outcome != routines.OutcomeSuccess {
+		failures = prior.ConsecutiveFailures + 1
+	}
+	result.AutoDisabled = r.Enabled && r.DisableAfter > 0 && failures >= r.DisableAfter
+
+	// Each run's output is stored normalised next to its summary. A successful
+	// run is compared with the previous successful one; the diff goes into the
+	// summary and notifications.
+	artifact := runArtifactName(start, outcome, runID)
+	normalized := routines.NormalizeOutput(output)
+	outputHash := ""
+	if runErr == nil {
+		outputHash = hashOutput(normalized)
+		result.Changed = prior.OutputHash != "" && outputHash != prior.OutputHash
+		if previous, err := os.ReadFile(prior.OutputPath); result.Changed && err == nil {
+			result.Diff = routines.Diff(string(previous), normalized, runNameFromPath(prior.OutputPath), artifact)
+		}
+	}
+	outputPath, err := writeRunOutput(r.ID, artifact, normalized)
+	if err != nil {
+		log.Warnw("routine: storing run output failed", "routine", r.ID, "error", err)
 	}
 
-	resultPath, summaryErr := s.writeSummary(r, eff, result, trigger, start, recorder.RunID())
+	summary := renderSummary(r, eff, result, errText, trigger, start)
+	resultPath, summaryErr := writeSummary(r.ID, summary, artifact)
 	if summaryErr == nil {
 		result.ResultPath = resultPath
 	}
@@ -324,19 +615,76 @@ func (s *routineService) Run(ctx context.Context, req RoutineRunRequest) (Routin
 		LastStatus:     outcome,
 		LastDuration:   duration.Round(time.Millisecond).String(),
 		LastTrigger:    trigger,
-		LastSessionLog: recorder.Path(),
+		LastSessionLog: result.SessionLog,
 		LastResultPath: result.ResultPath,
 		TokensUsed:     result.TokensUsed,
+		OutputHash:     outputHash,
+		Attempts:       attempts,
+	}
+	if runErr == nil {
+		record.OutputPath = outputPath
 	}
 	if runErr != nil {
-		record.LastError = runErr.Error()
+		record.LastError = errText
 	}
 	if err := s.state.Record(r.ID, record); err != nil {
 		return result, err
 	}
+	if result.AutoDisabled {
+		if err := s.disable(r.ID); err != nil {
+			log.Warnw("routine: auto-disable failed", "routine", r.ID, "error", err)
+		} else {
+			log.Warnw("routine: disabled after consecutive failures", "routine", r.ID, "failures", failures)
+		}
+	}
+
+	// In only-notify-on-change mode an unchanged success is not worth a
+	// notification; the first success (nothing to compare with) still is.
+	if r.OnlyNotifyOnChange && runErr == nil && prior.OutputHash != "" && !result.Changed {
+		return result, nil
+	}
+	s.notify(ctx, r, notify.Message{
+		RoutineID:   r.ID,
+		RoutineName: r.Name,
+		Outcome:     outcome,
+		Trigger:     trigger,
+		Changed:     result.Changed,
+		Diff:        result.Diff,
+		StartedAt:   start,
+		Duration:    duration,
+		TokensUsed:  result.TokensUsed,
+		Error:       errText,
+		Output:      output,
+		Summary:     summary,
+		ResultPath:  result.ResultPath,
+	})
 	return result, nil
 }
 
+// notify sends the run result to the routine's and the global notifications.
+// Delivery problems are logged rather than failing a run that already finished.
+func (s *routineService) notify(ctx context.Context, r routines.Routine, msg notify.Message) {
+	global, err := config.LoadRoutinesConfig()
+	if err != nil {
+		log.Warnw("Failed to load routine notifications", "routine", r.ID, "error", err)
+	}
+	notifications := append(slices.Clone(global.Notifications), r.Notify...)
+	if len(notifications) == 0 {
+		return
+	}
+	// A cancelled run (e.g. Ctrl-C) is still worth reporting.
+	ctx = context.WithoutCancel(ctx)
+	if err := notify.New(global.SMTP).Dispatch(ctx, notifications, msg); err != nil {
+		log.Warnw("Failed to deliver routine notification", "routine", r.ID, "error", err)
+	}
+}
+
+// hashOutput fingerprints a run's normalised output for change detection.
+func hashOutput(output string) string {
+	sum := sha256.Sum256([]byte(output))
+	return hex.EncodeToString(sum[:])
+}
+
 func (s *routineService) LaunchNotice(ctx context.Context) (string, error) {
 	notices, err := s.state.ClaimPending()
 	if err != nil {
@@ -364,8 +712,9 @@ func (s *routineService) LaunchNotice(ctx context.Context) (string, error) {
 	return msg + ".", nil
 }
 
-// effectiveSettings are the resolved per-run parameters for a routine.
-type effectiveSettings struct {
+// RoutineSettings are the resolved per-run parameters for a routine: its own
+// values, then the routine defaults and finally the global config.
+type RoutineSettings struct {
 	Provider     string
 	Model        string
 	WorkingDir   string
@@ -373,9 +722,13 @@ type effectiveSettings struct {
 	TokenBudget  int
 	MaxTurns     int
 	MaxToolCalls int
+	// Tools is the routine's tool allow-list; nil = every tool except the
+	// external-facing ones and remember.
+	Tools []string
+	Deny  []string
 }
 
-func (s *routineService) resolve(r routines.Routine) effectiveSettings {
+func (s *routineService) resolve(r routines.Routine) RoutineSettings {
 	defaults := s.cfg.GetRoutineDefaults()
 	// Provider/model fall back to the global config defaults so a routine created
 	// with only a prompt still runs; an empty provider would otherwise be rejected
@@ -386,7 +739,7 @@ func (s *routineService) resolve(r routines.Routine) effectiveSettings {
 	if model == "" {
 		model = s.cfg.GetModelIdForProvider(provider)
 	}
-	return effectiveSettings{
+	return RoutineSettings{
 		Provider:     provider,
 		Model:        model,
 		WorkingDir:   firstNonEmpty(r.WorkingDir, defaults.WorkingDir),
@@ -394,10 +747,14 @@ func (s *routineService) resolve(r routines.Routine) effectiveSettings {
 		TokenBudget:  resolveIntPtr(r.TokenBudget, defaults.TokenBudget),
 		MaxTurns:     resolveIntPtr(r.MaxTurns, defaults.MaxTurns),
 		MaxToolCalls: resolveIntPtr(r.MaxToolCalls, defaults.MaxToolCalls),
+		Tools:        r.Tools,
+		Deny:         r.Deny,
 	}
 }
 
-func (s *routineService) writeSummary(r routines.Routine, eff effectiveSettings, result RoutineRunResult, trigger string, start time.Time, runID string) (string, error) {
+// renderSummary renders the Markdown result summary stored for a run and sent
+// to its notifications.
+func renderSummary(r routines.Routine, eff RoutineSettings, result RoutineRunResult, errText, trigger string, start time.Time) string {
 	end := start.Add(result.Duration)
 	var b strings.Builder
 	title := r.Name
@@ -414,21 +771,78 @@ func (s *routineService) writeSummary(r routines.Routine, eff effectiveSettings,
 	fmt.Fprintf(&b, "- Duration: %s\n", result.Duration.Round(time.Millisecond))
 	fmt.Fprintf(&b, "- Status: %s\n", result.Outcome)
 	fmt.Fprintf(&b, "- Tokens (est.): %d / %s\n", result.TokensUsed, budgetLabel(eff.TokenBudget))
+	if summary := redact.Summary(result.Redactions); summary != "" {
+		fmt.Fprintf(&b, "- Redacted secrets: %s\n", summary)
+	}
+	if result.AutoDisabled {
+		fmt.Fprintf(&b, "- Auto-disabled: after %d consecutive failures\n", r.DisableAfter)
+	}
+	if len(result.Attempts) > 1 {
+		b.WriteString("\n## Attempts\n\n| Attempt | Started | Status | Duration | Error |\n|---------|---------|--------|----------|-------|\n")
+		for _, attempt := range result.Attempts {
+			fmt.Fprintf(&b, "| %d | %s | %s | %s | %s |\n", attempt.Number, attempt.StartedAt.Format(time.RFC3339), attempt.Outcome, attempt.Duration, firstLine(attempt.Error))
+		}
+	}
+	if len(result.Steps) > 0 {
+		b.WriteString("\n## Steps\n\n| Step | Status | Duration | Tokens | Log |\n|------|--------|----------|--------|-----|\n")
+		for _, step := range result.Steps {
+			name := step.ID
+			if step.Routine != "" {
+				name += " (" + step.Routine + ")"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %d | %s |\n", name, step.Outcome, step.Duration.Round(time.Millisecond), step.TokensUsed, filepath.Base(step.SessionLog))
+		}
+	}
 	if result.Err != nil {
-		fmt.Fprintf(&b, "\n## Error\n\n%s\n", result.Err.Error())
+		fmt.Fprintf(&b, "\n## Error\n\n%s\n", errText)
+	}
+	if result.Diff != "" {
+		fmt.Fprintf(&b, "\n## Changes\n\n```diff\n%s\n```\n", result.Diff)
 	}
 	fmt.Fprintf(&b, "\n## Output\n\n%s\n", strings.TrimSpace(result.Output))
+	return b.String()
+}
+
+// firstLine returns the first line of s, for single-line table cells.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return strings.ReplaceAll(line, "|", "\\|")
+}
+
+// runArtifactName is the base name shared by a run's summary and stored
+// output; it also names the run in diffs.
+func runArtifactName(start time.Time, outcome, runID string) string {
+	return fmt.Sprintf("%s_%s_%s", start.Format("2006-01-02T15-04-05"), outcome, shortRunID(runID))
+}
 
-	name := fmt.Sprintf("%s_%s_%s.md", start.Format("2006-01-02T15-04-05"), result.Outcome, shortRunID(runID))
-	path := filepath.Join(routines.LogDir(r.ID), name)
+// runNameFromPath returns the run name of a summary or output path.
+func runNameFromPath(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+func writeSummary(routineID, summary, artifact string) (string, error) {
+	path := filepath.Join(routines.LogDir(routineID), artifact+".md")
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return "", err
 	}
-	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+	if err := os.WriteFile(path, []byte(summary), 0o644); err != nil {
 		return "", err
 	}
-	pruneOldArtifacts(routines.LogDir(r.ID), ".md", maxResultSummaries)
-	pruneOldArtifacts(routines.LogDir(r.ID), ".jsonl", maxResultSummaries)
+	pruneOldArtifacts(routines.LogDir(routineID), ".md", maxResultSummaries)
+	pruneOldArtifacts(routines.LogDir(routineID), ".jsonl", maxResultSummaries)
+	return path, nil
+}
+
+// writeRunOutput stores a run's normalised output for later diffs.
+func writeRunOutput(routineID, artifact, output string) (string, error) {
+	path := filepath.Join(routines.LogDir(routineID), artifact+routineOutputExt)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+		return "", err
+	}
+	pruneOldArtifacts(routines.LogDir(routineID), routineOutputExt, maxResultSummaries)
 	return path, nil
 }
 
@@ -534,6 +948,22 @@ func budgetLabel(budget int) string {
 	return fmt.Sprintf("%d", budget)
 }
 
+// describeFrequency renders what fires a routine: its schedule and event
+// triggers. A routine with only triggers is not shown as manual.
+func describeFrequency(r routines.Routine) string {
+	if len(r.Triggers) == 0 {
+		return describeSchedule(r.Schedule)
+	}
+	parts := make([]string, 0, len(r.Triggers)+1)
+	if strings.TrimSpace(r.Schedule) != "" {
+		parts = append(parts, describeSchedule(r.Schedule))
+	}
+	for _, t := range r.Triggers {
+		parts = append(parts, t.Describe())
+	}
+	return strings.Join(parts, "; ")
+}
+
 // describeSchedule renders a cron expression for display. Phase 1 keeps this
 // minimal (manual vs. the raw expression); the daemon phase replaces it with a
 // proper cron humanizer.
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	internalagent "github.com/laszukdawid/terminal-agent/internal/agent"
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	"github.com/laszukdawid/terminal-agent/internal/tools"
+)
+
+// RoutineImportOptions controls how a bundle is imported.
+type RoutineImportOptions struct {
+	// OnConflict is a routines.Conflict* policy for ids that are already taken;
+	// empty = skip.
+	OnConflict string
+	// Disabled imports every routine disabled, to be reviewed before it runs.
+	Disabled bool
+	// AllowExternalTools keeps external-facing and unknown (e.g. MCP) tools that
+	// a bundled routine enables by name; by default they are dropped.
+	AllowExternalTools bool
+	// KeepNotifications keeps the notification targets of bundled routines; by
+	// default they are dropped, since they send run results off the machine.
+	KeepNotifications bool
+}
+
+// RoutineImportResult is the outcome of importing one bundled routine. Notes
+// list what the sandbox changed and what the routine keeps that reaches
+// beyond its prompt: triggers, notifications and its working directory.
+type RoutineImportResult struct {
+	routines.ImportResult
+	Notes []string
+}
+
+func (s *routineService) Export(ctx context.Context, idsOrNames []string) (routines.Bundle, error) {
+	var list []routines.Routine
+	for _, ref := range idsOrNames {
+		r, err := s.store.Get(ref)
+		if err != nil {
+			return routines.Bundle{}, err
+		}
+		if !slices.ContainsFunc(list, func(have routines.Routine) bool { return have.ID == r.ID }) {
+			list = append(list, r)
+		}
+	}
+	return routines.NewBundle(list, time.Now()), nil
+}
+
+func (s *routineService) Import(ctx context.Context, bundle routines.Bundle, opts RoutineImportOptions) ([]RoutineImportResult, error) {
+	builtin := tools.GetAllBuiltinTools(s.cfg)
+	bundled := make(map[string]bool, len(bundle.Routines))
+	for _, r := range bundle.Routines {
+		bundled[r.ID] = true
+	}
+	sandboxed := make([]routines.Routine, 0, len(bundle.Routines))
+	notes := make([][]string, 0, len(bundle.Routines))
+	for _, r := range bundle.Routines {
+		r, routineNotes, err := sandboxRoutine(r, builtin, bundled, opts)
+		if err != nil {
+			return nil, fmt.Errorf("routine %q: %w", r.ID, err)
+		}
+		if opts.Disabled {
+			r.Enabled = false
+		}
+		sandboxed = append(sandboxed, r)
+		notes = append(notes, routineNotes)
+	}
+
+	imported, err := s.store.Import(sandboxed, opts.OnConflict)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]RoutineImportResult, len(imported))
+	for i, result := range imported {
+		results[i] = RoutineImportResult{ImportResult: result}
+		if result.Action != routines.ImportSkipped {
+			results[i].Notes = notes[i]
+		}
+	}
+	return results, nil
+}
+
+// sandboxRoutine restricts what a routine from a bundle may do on this machine.
+// Deny rules only ever narrow a run, so they are kept once they parse. A tool
+// list is an explicit allow-list that bypasses the default external-facing
+// policy, so it keeps only the local built-in tools unless opts allows external
+// ones. A list that loses every tool stays empty rather than nil, which would
+// re-enable the default set. Workflow steps may only run routines of the
+// bundle, whose ids are in bundled, rather than whatever this machine has under
+// the same id, and notifications are dropped unless opts keeps them.
+func sandboxRoutine(r routines.Routine, builtin map[string]tools.Tool, bundled map[string]bool, opts RoutineImportOptions) (routines.Routine, []string, error) {
+	var notes []string
+	for _, step := range r.Steps {
+		if step.Routine != "" && !bundled[step.Routine] {
+			return r, nil, fmt.Errorf("step %q runs routine %q, which is not in the bundle", step.ID, step.Routine)
+		}
+	}
+
+	var deny []string
+	for _, rule := range r.Deny {
+		rule = strings.TrimSpace(rule)
+		if rule == "" || slices.Contains(deny, rule) {
+			continue
+		}
+		if err := internalagent.ValidatePattern(rule); err != nil {
+			return r, nil, fmt.Errorf("invalid deny rule %q: %w", rule, err)
+		}
+		deny = append(deny, rule)
+	}
+	r.Deny = deny
+
+	if r.Tools != nil {
+		kept := []string{}
+		for _, name := range r.Tools {
+			name = strings.TrimSpace(name)
+			if name == "" || slices.Contains(kept, name) {
+				continue
+			}
+			tool, known := builtin[name]
+			switch {
+			case opts.AllowExternalTools:
+			case !known:
+				notes = append(notes, fmt.Sprintf("dropped tool %s: not a built-in tool", name))
+				continue
+			case tools.IsExternalFacing(tool):
+				notes = append(notes, fmt.Sprintf("dropped tool %s: external-facing", name))
+				continue
+			}
+			kept = append(kept, name)
+		}
+		r.Tools = kept
+	}
+
+	if !opts.KeepNotifications {
+		for _, n := range r.Notify {
+			notes = append(notes, fmt.Sprintf("dropped notification %s", n.Describe()))
+		}
+		r.Notify = nil
+	}
+	for _, t := range r.Triggers {
+		notes = append(notes, "keeps trigger: "+t.Describe())
+	}
+	for _, n := range r.Notify {
+		notes = append(notes, "keeps notification: "+n.Describe())
+	}
+	if r.WorkingDir != "" {
+		notes = append(notes, "keeps working directory: "+r.WorkingDir)
+	}
+	return r, notes, nil
+}
@@ -2,6 +2,12 @@ package app
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -115,6 +121,230 @@ func TestRunRoutineRecordsFailureAndWritesArtifacts(t *testing.T) {
 	assert.True(t, hasSummary, "a result summary should be written for the failed run")
 }
 
+func TestRunRoutineNotifiesMatchingSinks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(routines.DataDirEnv, t.TempDir())
+	t.Setenv(routines.DefinitionsFileEnv, t.TempDir()+"/routines.json")
+
+	received := make(chan map[string]any, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		body["path"] = r.URL.Path
+		received <- body
+	}))
+	defer server.Close()
+
+	store := routines.NewStore(routines.DefinitionsPath())
+	require.NoError(t, store.Upsert(routines.Routine{
+		ID:       "broken",
+		Prompt:   "do something",
+		Provider: "nonexistent-provider",
+		Enabled:  true,
+		Notify: []routines.Notification{
+			{Type: routines.NotifyWebhook, URL: server.URL + "/failures"},
+			{Type: routines.NotifyWebhook, URL: server.URL + "/successes", On: []string{routines.OutcomeSuccess}},
+		},
+	}))
+
+	result, err := NewRoutineService(config.NewDefaultConfig()).Run(context.Background(), RoutineRunRequest{IDOrName: "broken"})
+	require.NoError(t, err)
+	require.Len(t, received, 1, "only the notification matching the failed outcome fires")
+
+	body := <-received
+	assert.Equal(t, "/failures", body["path"])
+	assert.Equal(t, routines.OutcomeFailed, body["outcome"])
+	assert.Equal(t, result.ResultPath, body["result_path"])
+	summary, err := os.ReadFile(result.ResultPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(summary), body["summary"], "the stored result summary is delivered")
+}
+
+func TestRunRoutineRecordsTriggerAndPromptTemplateErrors(t *testing.T) {
+	t.Setenv(routines.DataDirEnv, t.TempDir())
+	t.Setenv(routines.DefinitionsFileEnv, t.TempDir()+"/routines.json")
+	store := routines.NewStore(routines.DefinitionsPath())
+	require.NoError(t, store.Upsert(routines.Routine{ID: "review", Prompt: "Review {{.Trigger.Missing}}", Enabled: true}))
+
+	result, err := NewRoutineService(config.NewDefaultConfig()).Run(context.Background(), RoutineRunRequest{
+		IDOrName: "review",
+		Event:    &routines.TriggerEvent{Type: routines.TriggerFile, Paths: []string{"a.go"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, routines.OutcomeFailed, result.Outcome)
+	assert.ErrorContains(t, result.Err, "rendering routine prompt")
+
+	rec, _, err := routines.NewStateStore(routines.StatePath()).Get("review")
+	require.NoError(t, err)
+	assert.Equal(t, routines.TriggerFile, rec.LastTrigger, "the run records the trigger type that fired it")
+}
+
+func TestRunWorkflowAppliesConditionsAndPassesOutputs(t *testing.T) {
+	t.Setenv(routines.DataDirEnv, t.TempDir())
+	t.Setenv(routines.DefinitionsFileEnv, t.TempDir()+"/routines.json")
+	store := routines.NewStore(routines.DefinitionsPath())
+	require.NoError(t, store.Upsert(routines.Routine{ID: "lint", Prompt: "lint the repo", Provider: "nonexistent-provider", Enabled: true}))
+	require.NoError(t, store.Upsert(routines.Routine{
+		ID:       "release",
+		Provider: "nonexistent-provider",
+		Enabled:  true,
+		Steps: []routines.Step{
+			{ID: "build", Prompt: "build it"},
+			{ID: "lint", Routine: "lint"},
+			{ID: "deploy", Prompt: "deploy", Needs: []string{"build", "lint"}},
+			{ID: "report", Prompt: "Build {{.Steps.build.Outcome}}, deploy {{.Steps.deploy.Outcome}}", Needs: []string{"build", "deploy"}, If: routines.IfFailed},
+		},
+	}))
+
+	result, err := NewRoutineService(config.NewDefaultConfig()).Run(context.Background(), RoutineRunRequest{IDOrName: "release"})
+	require.NoError(t, err)
+	assert.Equal(t, routines.OutcomeFailed, result.Outcome)
+	require.Len(t, result.Steps, 4)
+	outcomes := map[string]string{}
+	for _, step := range result.Steps {
+		outcomes[step.ID] = step.Outcome
+	}
+	assert.Equal(t, map[string]string{
+		"build":  routines.OutcomeFailed,
+		"lint":   routines.OutcomeFailed,
+		"deploy": routines.OutcomeSkipped,
+		"report": routines.OutcomeFailed,
+	}, outcomes)
+	assert.ErrorContains(t, result.Err, "step build:")
+	assert.ErrorContains(t, result.Err, "step report:")
+
+	report := result.Steps[3]
+	assert.Equal(t, routines.LogDir("release"), filepath.Dir(report.SessionLog), "step logs live under the workflow's log dir")
+	assert.Contains(t, filepath.Base(report.SessionLog), "-report")
+	data, err := os.ReadFile(report.SessionLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Build failed, deploy skipped", "finished step outcomes are passed to downstream prompts")
+
+	summary, err := os.ReadFile(result.ResultPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(summary), "## Steps")
+	assert.Contains(t, string(summary), "| lint (lint) | failed |")
+}
+
+func TestRunRoutineRetriesAndAutoDisables(t *testing.T) {
+	t.Setenv(routines.DataDirEnv, t.TempDir())
+	t.Setenv(routines.DefinitionsFileEnv, t.TempDir()+"/routines.json")
+	store := routines.NewStore(routines.DefinitionsPath())
+	require.NoError(t, store.Upsert(routines.Routine{
+		ID:           "flaky",
+		Prompt:       "do something",
+		Provider:     "nonexistent-provider",
+		Retry:        &routines.RetryPolicy{MaxAttempts: 3, Backoff: "1m"},
+		DisableAfter: 1,
+		Enabled:      true,
+	}))
+
+	svc := NewRoutineService(config.NewDefaultConfig()).(*routineService)
+	var waits []time.Duration
+	svc.wait = func(_ context.Context, d time.Duration) bool {
+		waits = append(waits, d)
+		return true
+	}
+
+	result, err := svc.Run(context.Background(), RoutineRunRequest{IDOrName: "flaky", Trigger: routines.TriggerScheduled})
+	require.NoError(t, err)
+	assert.Equal(t, routines.OutcomeFailed, result.Outcome)
+	assert.Equal(t, []time.Duration{time.Minute, 2 * time.Minute}, waits)
+	require.Len(t, result.Attempts, 3)
+	assert.True(t, result.AutoDisabled)
+
+	rec, _, err := svc.state.Get("flaky")
+	require.NoError(t, err)
+	require.Len(t, rec.Attempts, 3)
+	assert.False(t, rec.Attempts[1].NextRetryAt.IsZero())
+	assert.True(t, rec.Attempts[2].NextRetryAt.IsZero(), "the last attempt schedules no retry")
+	assert.Equal(t, 1, rec.ConsecutiveFailures, "a retried run counts as one failure")
+
+	r, err := store.Get("flaky")
+	require.NoError(t, err)
+	assert.False(t, r.Enabled, "the routine is disabled once it reaches disable_after")
+	summary, err := os.ReadFile(result.ResultPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(summary), "## Attempts")
+	assert.Contains(t, string(summary), "- Auto-disabled: after 1 consecutive failures")
+
+	waits = nil
+	result, err = svc.Run(context.Background(), RoutineRunRequest{IDOrName: "flaky"})
+	require.NoError(t, err)
+	assert.Empty(t, waits, "manual runs are not retried")
+	assert.Empty(t, result.Attempts)
+}
+
+func TestRunRoutineRendersParamsAndBuiltins(t *testing.T) {
+	t.Setenv(routines.DataDirEnv, t.TempDir())
+	t.Setenv(routines.DefinitionsFileEnv, t.TempDir()+"/routines.json")
+	workDir := t.TempDir()
+	store := routines.NewStore(routines.DefinitionsPath())
+	require.NoError(t, store.Upsert(routines.Routine{
+		ID:         "changes",
+		Prompt:     `Summarise {{.Params.repo}} in {{.WorkingDir}} since {{.LastRunAt | default "the start"}} ({{.Date}})`,
+		Params:     []routines.Param{{Name: "repo", Default: "api"}},
+		Provider:   "nonexistent-provider",
+		WorkingDir: workDir,
+		Enabled:    true,
+	}))
+	svc := NewRoutineService(config.NewDefaultConfig())
+
+	_, err := svc.Run(context.Background(), RoutineRunRequest{IDOrName: "changes", Params: map[string]string{"branch": "main"}})
+	assert.ErrorContains(t, err, "unknown parameter")
+
+	result, err := svc.Run(context.Background(), RoutineRunRequest{IDOrName: "changes", Params: map[string]string{"repo": "web"}})
+	require.NoError(t, err)
+	transcript, err := os.ReadFile(result.SessionLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(transcript), "Summarise web in "+workDir+" since the start ("+time.Now().Format(time.DateOnly)+")")
+
+	result, err = svc.Run(context.Background(), RoutineRunRequest{IDOrName: "changes", Trigger: routines.TriggerScheduled})
+	require.NoError(t, err)
+	transcript, err = os.ReadFile(result.SessionLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(transcript), "Summarise api in", "scheduled runs use the defaults")
+	assert.NotContains(t, string(transcript), "since the start", "the previous run's start is filled in")
+}
+
+func TestRoutineDiffComparesStoredOutputs(t *testing.T) {
+	t.Setenv(routines.DataDirEnv, t.TempDir())
+	t.Setenv(routines.DefinitionsFileEnv, t.TempDir()+"/routines.json")
+	store := routines.NewStore(routines.DefinitionsPath())
+	require.NoError(t, store.Upsert(routines.Routine{ID: "advisories", Prompt: "check", Provider: "nonexistent-provider", Enabled: true}))
+	svc := NewRoutineService(config.NewDefaultConfig())
+
+	// Every run stores its normalised output next to its summary.
+	result, err := svc.Run(context.Background(), RoutineRunRequest{IDOrName: "advisories"})
+	require.NoError(t, err)
+	_, err = os.Stat(strings.TrimSuffix(result.ResultPath, ".md") + routineOutputExt)
+	require.NoError(t, err)
+
+	_, err = svc.Diff(context.Background(), "advisories", "", "")
+	assert.ErrorContains(t, err, "fewer than two successful runs")
+
+	dir := routines.LogDir("advisories")
+	for name, output := range map[string]string{
+		"2026-01-01T09-00-00_success_aaaa1111": "lodash: none\nleft-pad: none",
+		"2026-01-02T09-00-00_success_bbbb2222": "lodash: CVE-1\nleft-pad: none",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+routineOutputExt), []byte(output), 0o644))
+	}
+
+	diff, err := svc.Diff(context.Background(), "advisories", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "2026-01-01T09-00-00_success_aaaa1111", diff.From)
+	assert.Equal(t, "2026-01-02T09-00-00_success_bbbb2222", diff.To)
+	assert.Contains(t, diff.Diff, "-lodash: none\n+lodash: CVE-1")
+
+	diff, err = svc.Diff(context.Background(), "advisories", "bbbb2222", "aaaa1111")
+	require.NoError(t, err)
+	assert.Contains(t, diff.Diff, "-lodash: CVE-1\n+lodash: none", "runs can be named by run id, in either order")
+
+	_, err = svc.Diff(context.Background(), "advisories", "success", "aaaa1111")
+	assert.ErrorContains(t, err, "ambiguous")
+}
+
 func TestRunRoutineSkipsWhenAlreadyRunning(t *testing.T) {
 	t.Setenv(routines.DataDirEnv, t.TempDir())
 	t.Setenv(routines.DefinitionsFileEnv, t.TempDir()+"/routines.json")
@@ -154,3 +384,123 @@ func TestLaunchNoticeReportsFailuresOnce(t *testing.T) {
 	require.NoError(t, err)
 	assert.Empty(t, notice)
 }
+
+func TestImportRoutinesSandboxesToolsAndDeny(t *testing.T) {
+	t.Setenv(routines.DataDirEnv, t.TempDir())
+	t.Setenv(routines.DefinitionsFileEnv, t.TempDir()+"/routines.json")
+	svc := NewRoutineService(config.NewDefaultConfig())
+
+	bundle := routines.NewBundle([]routines.Routine{{
+		ID:      "sync",
+		Prompt:  "sync the repo",
+		Tools:   []string{"unix", "websearch", "mcp_deploy", "unix"},
+		Deny:    []string{`unix("rm *")`, " "},
+		Enabled: true,
+	}, {
+		ID:     "local",
+		Prompt: "tidy up",
+		Tools:  []string{"websearch"},
+	}}, time.Now())
+
+	results, err := svc.Import(context.Background(), bundle, RoutineImportOptions{Disabled: true})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Len(t, results[0].Notes, 2)
+
+	sync, err := svc.Get(context.Background(), "sync")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"unix"}, sync.Routine.Tools)
+	assert.Equal(t, []string{`unix("rm *")`}, sync.Routine.Deny)
+	assert.False(t, sync.Routine.Enabled)
+	local, err := svc.Get(context.Background(), "local")
+	require.NoError(t, err)
+	assert.NotNil(t, local.Routine.Tools, "a list that loses every tool must not fall back to the defaults")
+	assert.Empty(t, local.Routine.Tools)
+
+	results, err = svc.Import(context.Background(), bundle, RoutineImportOptions{OnConflict: routines.ConflictOverwrite, AllowExternalTools: true})
+	require.NoError(t, err)
+	assert.Empty(t, results[0].Notes)
+	sync, err = svc.Get(context.Background(), "sync")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"unix", "websearch", "mcp_deploy"}, sync.Routine.Tools)
+
+	bad := routines.NewBundle([]routines.Routine{{ID: "bad", Prompt: "p", Deny: []string{"unix"}}}, time.Now())
+	_, err = svc.Import(context.Background(), bad, RoutineImportOptions{})
+	assert.ErrorContains(t, err, "invalid deny rule")
+}
+
+func TestImportRoutinesSandboxesStepsAndNotifications(t *testing.T) {
+	t.Setenv(routines.DataDirEnv, t.TempDir())
+	t.Setenv(routines.DefinitionsFileEnv, t.TempDir()+"/routines.json")
+	svc := NewRoutineService(config.NewDefaultConfig())
+
+	notify := []routines.Notification{{Type: routines.NotifyWebhook, URL: "https://hooks.example.com/secret", On: []string{"success"}}}
+	bundle := routines.NewBundle([]routines.Routine{{
+		ID:         "report",
+		Prompt:     "summarise the build",
+		WorkingDir: "/srv/app",
+		Triggers:   []routines.Trigger{{Type: routines.TriggerFile, Paths: []string{"/srv/app/build"}}},
+		Notify:     notify,
+	}, {
+		ID:    "pipeline",
+		Steps: []routines.Step{{ID: "report", Routine: "report"}},
+	}}, time.Now())
+
+	results, err := svc.Import(context.Background(), bundle, RoutineImportOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, []string{
+		"dropped notification webhook hooks.example.com on success",
+		"keeps trigger: file /srv/app/build",
+		"keeps working directory: /srv/app",
+	}, results[0].Notes)
+	report, err := svc.Get(context.Background(), "report")
+	require.NoError(t, err)
+	assert.Empty(t, report.Routine.Notify)
+
+	results, err = svc.Import(context.Background(), bundle, RoutineImportOptions{OnConflict: routines.ConflictOverwrite, KeepNotifications: true})
+	require.NoError(t, err)
+	assert.Contains(t, results[0].Notes, "keeps notification: webhook hooks.example.com on success")
+	report, err = svc.Get(context.Background(), "report")
+	require.NoError(t, err)
+	assert.Equal(t, notify, report.Routine.Notify)
+
+	outside := routines.NewBundle([]routines.Routine{{ID: "deploy", Steps: []routines.Step{{ID: "prod", Routine: "release-prod"}}}}, time.Now())
+	_, err = svc.Import(context.Background(), outside, RoutineImportOptions{})
+	assert.EqualError(t, err, `routine "deploy": step "prod" runs routine "release-prod", which is not in the bundle`)
+}
+
+func TestRunRoutineDryRunRecordsNothing(t *testing.T) {
+	t.Setenv(routines.DataDirEnv, t.TempDir())
+	t.Setenv(routines.DefinitionsFileEnv, t.TempDir()+"/routines.json")
+	store := routines.NewStore(routines.DefinitionsPath())
+	require.NoError(t, store.Upsert(routines.Routine{
+		ID:       "cleanup",
+		Prompt:   "delete stale branches",
+		Provider: "nonexistent-provider",
+		Tools:    []string{"unix"},
+		Deny:     []string{`unix("git push *")`},
+		Retry:    &routines.RetryPolicy{MaxAttempts: 3},
+		Enabled:  true,
+	}))
+	svc := NewRoutineService(config.NewDefaultConfig())
+
+	view, err := svc.Get(context.Background(), "cleanup")
+	require.NoError(t, err)
+	assert.Equal(t, "nonexistent-provider", view.Settings.Provider)
+	assert.Equal(t, []string{"unix"}, view.Settings.Tools)
+	assert.Equal(t, []string{`unix("git push *")`}, view.Settings.Deny)
+
+	result, err := svc.Run(context.Background(), RoutineRunRequest{IDOrName: "cleanup", Trigger: routines.TriggerScheduled, DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Empty(t, result.Attempts, "dry runs are not retried")
+	assert.Empty(t, result.ResultPath, "dry runs write no summary")
+	_, has, err := routines.DefaultStateStore().Get("cleanup")
+	require.NoError(t, err)
+	assert.False(t, has, "dry runs are not recorded in the run state")
+
+	data, err := os.ReadFile(result.SessionLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"dry_run":true`)
+}
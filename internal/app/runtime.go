@@ -6,6 +6,8 @@ import (
 	internalagent "github.com/laszukdawid/terminal-agent/internal/agent"
 	"github.com/laszukdawid/terminal-agent/internal/config"
 	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/laszukdawid/terminal-agent/internal/memory"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
 	"github.com/laszukdawid/terminal-agent/internal/tools"
 )
 
@@ -14,6 +16,11 @@ type RuntimeRequest struct {
 	Model      string
 	WorkingDir string
 	Config     config.Config
+	// Connector, when set, is used instead of one for Provider and Model.
+	Connector connector.LLMConnector
+	// Redactor masks secrets in the project context added to the task prompt;
+	// nil leaves it as read.
+	Redactor *redact.Redactor
 }
 
 type Runtime struct {
@@ -21,6 +28,7 @@ type Runtime struct {
 	Connector    connector.LLMConnector
 	ToolProvider tools.ToolProvider
 	WorkingDir   string
+	Redactor     *redact.Redactor
 }
 
 type PromptSet struct {
@@ -33,6 +41,8 @@ type PromptOptions struct {
 	TaskOverride string
 	UseMemory    bool
 	MemoryPath   string
+	// Message is the request memory entries are ranked against.
+	Message string
 }
 
 func NewRuntime(req RuntimeRequest) (*Runtime, error) {
@@ -46,9 +56,15 @@ func NewRuntime(req RuntimeRequest) (*Runtime, error) {
 		runtimeConfig = config.WithWorkingDir(runtimeConfig, workingDir)
 	}
 
-	conn, err := connector.NewConnector(req.Provider, req.Model, runtimeConfig)
-	if err != nil {
-		return nil, err
+	conn := req.Connector
+	if conn == nil {
+		if err := config.CheckProviderAllowed(req.Provider); err != nil {
+			return nil, err
+		}
+		var err error
+		if conn, err = connector.NewConnector(req.Provider, req.Model, runtimeConfig); err != nil {
+			return nil, err
+		}
 	}
 
 	return &Runtime{
@@ -56,6 +72,7 @@ func NewRuntime(req RuntimeRequest) (*Runtime, error) {
 		Connector:    conn,
 		ToolProvider: tools.NewToolProvider(runtimeConfig),
 		WorkingDir:   workingDir,
+		Redactor:     req.Redactor,
 	}, nil
 }
 
@@ -79,7 +96,7 @@ func (r *Runtime) ResolveAskPrompt(opts PromptOptions) (string, error) {
 		return "", fmt.Errorf("failed to resolve ask prompt: %w", err)
 	}
 
-	askPrompt, err = BuildAskPrompt(askPrompt, opts.UseMemory, opts.MemoryPath)
+	askPrompt, err = BuildAskPrompt(askPrompt, opts.UseMemory, opts.MemoryPath, memory.Query{Dir: r.WorkingDir, Text: opts.Message})
 	if err != nil {
 		return "", err
 	}
@@ -99,7 +116,7 @@ func (r *Runtime) ResolveTaskPrompt(taskOverride string) (string, error) {
 			return "", fmt.Errorf("failed to read project context: %w", readErr)
 		}
 		if contextContent != "" {
-			taskPrompt += contextContent
+			taskPrompt += r.Redactor.String(contextContent)
 		}
 	}
 
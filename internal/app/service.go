@@ -10,6 +10,7 @@ type Service interface {
 	AskEvents(ctx context.Context, req AskRequest) (<-chan Event, error)
 	Chat(ctx context.Context, req ChatRequest) (ChatResult, error)
 	ChatEvents(ctx context.Context, req ChatRequest) (<-chan Event, error)
+	CompactChat(ctx context.Context, req ChatRequest) (ChatCompactResult, error)
 	TaskEvents(ctx context.Context, req TaskRequest) (<-chan Event, error)
 }
 
@@ -44,7 +45,13 @@ type TaskConfirmationResponse struct {
 
 type TaskConfirmationEvent struct {
 	Action string
-	Reply  func(TaskConfirmationResponse) error
+	// Reason explains why a policy rule requires confirmation, when one did.
+	Reason string
+	// Risk is the risk level of a unix command (low, medium or high) and
+	// RiskReasons the patterns behind a high rating; both are empty otherwise.
+	Risk        string
+	RiskReasons []string
+	Reply       func(TaskConfirmationResponse) error
 }
 
 type TaskClarificationEvent struct {
@@ -69,6 +76,9 @@ type Event struct {
 	DirectRawOutput bool
 	Confirmation    *TaskConfirmationEvent
 	Clarification   *TaskClarificationEvent
+	// Redactions counts secrets masked during the run; set on completed task
+	// events.
+	Redactions map[string]int
 }
 
 type service struct{}
@@ -12,7 +12,12 @@ import (
 
 	internalagent "github.com/laszukdawid/terminal-agent/internal/agent"
 	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/laszukdawid/terminal-agent/internal/egress"
+	"github.com/laszukdawid/terminal-agent/internal/memory"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
 	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	"github.com/laszukdawid/terminal-agent/internal/tools"
 	log "github.com/laszukdawid/terminal-agent/internal/utils"
 )
 
@@ -36,10 +41,30 @@ type TaskRequest struct {
 	// EnabledTools selects which tools the run may use; nil exposes all available
 	// tools (subject to DisableExternalTools).
 	EnabledTools []string
-	// DisableExternalTools drops external-facing tools (web search, MCP) when
-	// EnabledTools is nil. Routines set this; interactive runs leave it false.
+	// DisableExternalTools drops external-facing tools (web search, MCP) and
+	// remember when EnabledTools is nil. Routines set this; interactive runs leave it false.
 	DisableExternalTools bool
-	Config               config.Config
+	// RoutineID identifies the routine a run belongs to; empty for interactive
+	// task runs. Policy rules see it together with the run kind.
+	RoutineID string
+	// RunID is the run's session-log ID, recorded on memory the agent writes.
+	RunID string
+	// DryRun simulates the run's mutating tool calls instead of running them.
+	DryRun bool
+	// Background is context the task starts from, such as the chat it was
+	// handed off from. The agent sees it ahead of Message.
+	Background string
+	// UseMemory prepends the memory entries relevant to the run (its
+	// directory, routine and message) to the task prompt.
+	UseMemory  bool
+	MemoryPath string
+	Config     config.Config
+	// Connector, when set, answers in place of the provider; replays use it
+	// to feed recorded model responses back.
+	Connector connector.LLMConnector
+	// Redactor masks secrets sent to the model and written to the session log.
+	// TaskEvents loads it from the config; nil disables redaction.
+	Redactor *redact.Redactor
 }
 
 // formatTaskTimeout renders a task timeout for the session log meta header.
@@ -58,6 +83,8 @@ type TaskResult struct {
 	RawOutputTool   string
 	DirectRawOutput bool
 	TokensUsed      int
+	// Redactions counts secrets masked during the run, per detector.
+	Redactions map[string]int
 }
 
 func (s *service) TaskEvents(ctx context.Context, req TaskRequest) (<-chan Event, error) {
@@ -65,10 +92,17 @@ func (s *service) TaskEvents(ctx context.Context, req TaskRequest) (<-chan Event
 		return nil, internalagent.ErrEmptyQuery
 	}
 
+	redactor, err := redact.Load()
+	if err != nil {
+		return nil, err
+	}
+	req.Redactor = redactor
+
 	events := make(chan Event)
 	meta := buildMeta("task", req.Provider, req.Model, req.WorkingDir, req.Message)
 	meta.TaskTimeout = formatTaskTimeout(req.Timeout)
-	recorder := sessionlog.New(SessionDir(), meta)
+	recorder := sessionlog.NewWithRedactor(SessionDir(), meta, redactor)
+	req.RunID = recorder.RunID()
 	interaction := &taskEventInteraction{ctx: ctx, events: events}
 
 	go s.runTaskEvents(ctx, req, interaction, recorder, events)
@@ -126,12 +160,14 @@ func (s *service) runTaskEvents(ctx context.Context, req TaskRequest, interactio
 		return emitEvent(ctx, events, event)
 	}
 
-	result, err := executeTask(ctx, req, interaction, onStep, onStatus, onProgress, onToolOutput)
+	onEgress := func(request egress.Request) { recorder.Write(egressToRecord(request)) }
+
+	result, err := executeTask(ctx, req, interaction, onStep, onStatus, onProgress, onToolOutput, onEgress)
 	if err != nil {
 		onStatus(internalagent.TaskStatusEvent{Phase: internalagent.TaskStatusFailed, Message: "Task failed.", Timestamp: time.Now().UTC()})
 		failed := newEvent(RunKindTask, EventFailed)
 		failed.Err = err
-		recorder.Write(sessionlog.Record{Type: sessionlog.RecordFailed, Kind: string(RunKindTask), Error: err.Error()})
+		recorder.Write(sessionlog.Record{Type: sessionlog.RecordFailed, Kind: string(RunKindTask), Error: err.Error(), Redactions: req.Redactor.Counts()})
 		_ = emitEvent(ctx, events, failed)
 		return
 	}
@@ -142,11 +178,12 @@ func (s *service) runTaskEvents(ctx context.Context, req TaskRequest, interactio
 	completed.RawOutput = result.RawOutput
 	completed.RawOutputTool = result.RawOutputTool
 	completed.DirectRawOutput = result.DirectRawOutput
-	recorder.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Kind: string(RunKindTask), Text: result.Response, ToolName: result.RawOutputTool})
+	completed.Redactions = result.Redactions
+	recorder.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Kind: string(RunKindTask), Text: result.Response, ToolName: result.RawOutputTool, Redactions: result.Redactions})
 	_ = emitEvent(ctx, events, completed)
 }
 
-func executeTask(ctx context.Context, req TaskRequest, interaction internalagent.TaskInteraction, onStep func(internalagent.TaskStep), onStatus func(internalagent.TaskStatusEvent), onProgress func(internalagent.TaskProgressEvent), onToolOutput func(internalagent.TaskToolOutputEvent) error) (TaskResult, error) {
+func executeTask(ctx context.Context, req TaskRequest, interaction internalagent.TaskInteraction, onStep func(internalagent.TaskStep), onStatus func(internalagent.TaskStatusEvent), onProgress func(internalagent.TaskProgressEvent), onToolOutput func(internalagent.TaskToolOutputEvent) error, onEgress func(egress.Request)) (TaskResult, error) {
 	if strings.TrimSpace(req.Message) == "" {
 		return TaskResult{}, internalagent.ErrEmptyQuery
 	}
@@ -166,6 +203,8 @@ func executeTask(ctx context.Context, req TaskRequest, interaction internalagent
 		Model:      req.Model,
 		WorkingDir: taskRootDir,
 		Config:     runtimeConfig,
+		Connector:  req.Connector,
+		Redactor:   req.Redactor,
 	})
 	if err != nil {
 		return TaskResult{}, err
@@ -175,10 +214,25 @@ func executeTask(ctx context.Context, req TaskRequest, interaction internalagent
 	if err != nil {
 		return TaskResult{}, err
 	}
+	taskPrompt, err = BuildAskPrompt(taskPrompt, req.UseMemory, req.MemoryPath, memory.Query{Dir: taskRootDir, Routine: req.RoutineID, Text: req.Message})
+	if err != nil {
+		return TaskResult{}, err
+	}
 
 	agentInstance := runtime.NewAgent(PromptSet{Task: taskPrompt})
 	agentInstance.SetDevice(req.Device)
-	response, err := agentInstance.TaskWithOptionsResult(ctx, req.Message, internalagent.TaskOptions{
+	// An indexed project gets semantic search over its files.
+	if projectIndex, err := OpenProjectIndex(taskRootDir, runtime.Config); err == nil {
+		defer projectIndex.Close()
+		agentInstance.Tools[tools.ToolNameSemanticSearch] = tools.NewSemanticSearchTool(projectIndex)
+	} else if !errors.Is(err, ErrNoIndex) {
+		log.Warnw("Semantic index unavailable", "dir", taskRootDir, "error", err)
+	}
+	runKind := RunKindTask
+	if req.RoutineID != "" {
+		runKind = RunKindRoutine
+	}
+	response, err := agentInstance.TaskWithOptionsResult(ctx, taskQuery(req), internalagent.TaskOptions{
 		Allow:                req.Allow,
 		Deny:                 req.Deny,
 		AutoApprove:          req.AutoApprove,
@@ -187,12 +241,18 @@ func executeTask(ctx context.Context, req TaskRequest, interaction internalagent
 		OnStatus:             onStatus,
 		OnProgress:           onProgress,
 		OnToolOutput:         onToolOutput,
+		OnEgress:             onEgress,
 		Timeout:              req.Timeout,
 		TokenBudget:          req.TokenBudget,
 		MaxTurns:             req.MaxTurns,
 		MaxToolCalls:         req.MaxToolCalls,
 		EnabledTools:         req.EnabledTools,
 		DisableExternalTools: req.DisableExternalTools,
+		RunKind:              string(runKind),
+		RoutineID:            req.RoutineID,
+		RunID:                req.RunID,
+		Redactor:             req.Redactor,
+		DryRun:               req.DryRun,
 		Dirs: internalagent.TaskDirs{
 			RootDir:    taskRootDir,
 			CurrentDir: taskRootDir,
@@ -209,6 +269,7 @@ func executeTask(ctx context.Context, req TaskRequest, interaction internalagent
 		RawOutputTool:   response.RawOutputTool,
 		DirectRawOutput: response.DirectRawOutput,
 		TokensUsed:      response.TokensUsed,
+		Redactions:      req.Redactor.Counts(),
 	}, nil
 }
 
@@ -225,6 +286,19 @@ func taskStatusToRecord(status internalagent.TaskStatusEvent) sessionlog.Record
 	}
 }
 
+// egressToRecord logs one host checked against the egress policy so the
+// session log shows everything a run contacted or tried to contact.
+func egressToRecord(request egress.Request) sessionlog.Record {
+	allowed := request.Allowed
+	return sessionlog.Record{
+		Type:     sessionlog.RecordEgress,
+		Text:     request.Host,
+		Status:   request.Method,
+		ToolName: request.Tool,
+		Allowed:  &allowed,
+	}
+}
+
 func taskProgressToRecord(progress internalagent.TaskProgressEvent) sessionlog.Record {
 	return sessionlog.Record{
 		Type:      sessionlog.RecordProgress,
@@ -268,6 +342,9 @@ func taskStepToRecord(step internalagent.TaskStep) sessionlog.Record {
 		rec.Type = sessionlog.RecordToolCall
 	case internalagent.TaskStepStatusDeclined:
 		rec.Type = sessionlog.RecordDeclined
+	case internalagent.TaskStepStatusSimulated:
+		rec.Type = sessionlog.RecordSimulated
+		rec.Text = step.Message
 	case internalagent.TaskStepStatusFinalAnswer:
 		// The run's authoritative completion is the app-level completed event; record the
 		// final-answer step as the final_answer tool's result to avoid a duplicate line.
@@ -286,7 +363,10 @@ func (i *taskEventInteraction) Confirm(req internalagent.TaskConfirmationRequest
 
 	event := newEvent(RunKindTask, EventConfirmationNeeded)
 	event.Confirmation = &TaskConfirmationEvent{
-		Action: req.Action,
+		Action:      req.Action,
+		Reason:      req.Reason,
+		Risk:        req.Risk,
+		RiskReasons: req.RiskReasons,
 		Reply: func(response TaskConfirmationResponse) error {
 			var err error = errTaskEventAlreadyReplied
 			once.Do(func() {
@@ -364,3 +444,13 @@ func resolveTaskRootDir(req TaskRequest) (string, error) {
 
 	return filepath.Abs(workingDir)
 }
+
+// taskQuery is the request given to the task agent, preceded by its
+// background when there is one.
+func taskQuery(req TaskRequest) string {
+	background := strings.TrimSpace(req.Background)
+	if background == "" {
+		return req.Message
+	}
+	return "Background from the conversation this task was handed off from:\n\n" + background + "\n\nTask: " + req.Message
+}
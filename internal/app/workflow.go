@@ -0,0 +1,327 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	internalagent "github.com/laszukdawid/terminal-agent/internal/agent"
+	"github.com/laszukdawid/terminal-agent/internal/egress"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+)
+
+// maxParallelSteps bounds how many workflow steps run at once.
+const maxParallelSteps = 4
+
+// StepResult is the outcome of one workflow step.
+type StepResult struct {
+	ID string
+	// Routine is the routine the step ran; empty for inline prompts.
+	Routine    string
+	Outcome    string // routines.Outcome* or routines.OutcomeSkipped
+	Output     string
+	Err        error
+	Duration   time.Duration
+	TokensUsed int
+	SessionLog string
+	// Simulated lists the calls a dry run intercepted.
+	Simulated []SimulatedCall
+}
+
+// SimulatedCall is a tool call a dry run recorded instead of running.
+type SimulatedCall struct {
+	Step   string // workflow step; empty for single-prompt routines
+	Tool   string
+	Action string // the call as a policy action, e.g. `file_edit(path="a.txt")`
+}
+
+// unattendedPolicy is how a routine run answers confirmations nobody is
+// present for, and whether it only simulates its mutating calls.
+type unattendedPolicy struct {
+	interaction internalagent.UnattendedInteraction
+	autoApprove bool
+	dryRun      bool
+}
+
+// promptRun is one agent task executed for a routine: the whole of a
+// single-prompt routine, or one workflow step.
+type promptRun struct {
+	// owner is the routine being run, whose log directory receives the session
+	// log; routine supplies the prompt, and eff the resolved settings with the
+	// tools and deny rules. They differ for a step that runs another routine.
+	owner   routines.Routine
+	routine routines.Routine
+	eff     RoutineSettings
+	// prompt overrides routine.Prompt for inline workflow steps.
+	prompt     string
+	stepID     string
+	data       *routines.PromptData
+	redactor   *redact.Redactor
+	unattended unattendedPolicy
+}
+
+type promptRunResult struct {
+	output     string
+	outcome    string
+	err        error
+	errText    string
+	duration   time.Duration
+	tokensUsed int
+	sessionLog string
+	runID      string
+	simulated  []SimulatedCall
+}
+
+// runPrompt renders the prompt, runs it as an unattended task and records the
+// session log. A prompt template that does not render fails the run like any
+// other error, so it is recorded and reported rather than silently skipped.
+func (s *routineService) runPrompt(ctx context.Context, run promptRun) promptRunResult {
+	template := run.prompt
+	if template == "" {
+		template = run.routine.Prompt
+	}
+	prompt, renderErr := routines.RenderPrompt(template, run.data)
+	if renderErr != nil {
+		prompt = template
+	}
+	eff := run.eff
+
+	meta := buildMeta(string(RunKindRoutine), eff.Provider, eff.Model, eff.WorkingDir, prompt)
+	meta.RoutineID = run.owner.ID
+	meta.StepID = run.stepID
+	meta.DryRun = run.unattended.dryRun
+	meta.TaskTimeout = formatTaskTimeout(eff.Timeout)
+	recorder := sessionlog.NewWithRedactor(routines.LogDir(run.owner.ID), meta, run.redactor)
+	recorder.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: prompt})
+
+	taskReq := TaskRequest{
+		Message:      prompt,
+		Provider:     eff.Provider,
+		Model:        eff.Model,
+		WorkingDir:   eff.WorkingDir,
+		Deny:         eff.Deny,
+		AutoApprove:  run.unattended.autoApprove,
+		Timeout:      eff.Timeout,
+		TokenBudget:  eff.TokenBudget,
+		MaxTurns:     eff.MaxTurns,
+		MaxToolCalls: eff.MaxToolCalls,
+		EnabledTools: eff.Tools,
+		// With no explicit tool list, a routine disables external-facing tools by
+		// default; naming tools (eff.Tools != nil) is an explicit allow-list instead.
+		DisableExternalTools: eff.Tools == nil,
+		RoutineID:            run.owner.ID,
+		RunID:                recorder.RunID(),
+		DryRun:               run.unattended.dryRun,
+		UseMemory:            s.cfg != nil && s.cfg.GetMemory(),
+		MemoryPath:           MemoryPath(),
+		Config:               s.cfg,
+		Redactor:             run.redactor,
+	}
+
+	var simulated []SimulatedCall
+	onStep := func(step internalagent.TaskStep) {
+		recorder.Write(taskStepToRecord(step))
+		if step.Status == internalagent.TaskStepStatusSimulated {
+			action := run.redactor.String(internalagent.BuildActionString(step.ToolName, step.ToolInput))
+			simulated = append(simulated, SimulatedCall{Step: run.stepID, Tool: step.ToolName, Action: action})
+		}
+	}
+	onStatus := func(status internalagent.TaskStatusEvent) { recorder.Write(taskStatusToRecord(status)) }
+	onProgress := func(progress internalagent.TaskProgressEvent) { recorder.Write(taskProgressToRecord(progress)) }
+	onEgress := func(request egress.Request) { recorder.Write(egressToRecord(request)) }
+
+	start := time.Now()
+	taskResult, runErr := TaskResult{}, renderErr
+	if renderErr == nil {
+		taskResult, runErr = executeTask(ctx, taskReq, run.unattended.interaction, onStep, onStatus, onProgress, nil, onEgress)
+	}
+
+	// The output and error are persisted in the summary and run state, so they
+	// are masked before the counts are taken.
+	result := promptRunResult{
+		output:     run.redactor.String(taskResult.Response),
+		outcome:    classifyOutcome(runErr),
+		err:        runErr,
+		duration:   time.Since(start),
+		tokensUsed: taskResult.TokensUsed,
+		sessionLog: recorder.Path(),
+		runID:      recorder.RunID(),
+		simulated:  simulated,
+	}
+	redactions := run.redactor.Counts()
+	if runErr != nil {
+		result.errText = run.redactor.String(runErr.Error())
+		recorder.Write(sessionlog.Record{Type: sessionlog.RecordFailed, Error: result.errText, Redactions: redactions})
+	} else {
+		recorder.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Text: result.output, Redactions: redactions})
+	}
+	return result
+}
+
+// runWorkflow runs a workflow routine's steps as a DAG: each step starts once
+// the steps it needs have finished, runs when its condition holds and is
+// skipped otherwise, and sees their outputs in its prompt. Every step gets its
+// own session log; a workflow-level log records each step's outcome.
+func (s *routineService) runWorkflow(ctx context.Context, r routines.Routine, eff RoutineSettings, data *routines.PromptData, redactor *redact.Redactor, unattended unattendedPolicy) (RoutineRunResult, string, string) {
+	start := time.Now()
+	meta := buildMeta(string(RunKindRoutine), eff.Provider, eff.Model, eff.WorkingDir, describeSteps(r.Steps))
+	meta.RoutineID = r.ID
+	meta.DryRun = unattended.dryRun
+	recorder := sessionlog.NewWithRedactor(routines.LogDir(r.ID), meta, redactor)
+	recorder.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: describeSteps(r.Steps)})
+
+	result := RoutineRunResult{Routine: r, SessionLog: recorder.Path()}
+	order, err := r.StepOrder()
+	if err != nil {
+		result.Outcome, result.Err = routines.OutcomeFailed, err
+		recorder.Write(sessionlog.Record{Type: sessionlog.RecordFailed, Error: err.Error()})
+		return result, err.Error(), recorder.RunID()
+	}
+
+	var (
+		mu      sync.Mutex
+		results = map[string]StepResult{}
+		outputs = map[string]routines.StepOutput{}
+		done    = map[string]chan struct{}{}
+		slots   = make(chan struct{}, maxParallelSteps)
+		wg      sync.WaitGroup
+	)
+	for _, step := range order {
+		done[step.ID] = make(chan struct{})
+	}
+	for _, step := range order {
+		wg.Add(1)
+		go func(step routines.Step) {
+			defer wg.Done()
+			defer close(done[step.ID])
+			for _, need := range step.Needs {
+				<-done[need]
+			}
+
+			mu.Lock()
+			needed := make([]string, 0, len(step.Needs))
+			for _, need := range step.Needs {
+				needed = append(needed, results[need].Outcome)
+			}
+			stepData := &routines.PromptData{}
+			if data != nil {
+				*stepData = *data
+			}
+			stepData.Steps = maps.Clone(outputs)
+			mu.Unlock()
+
+			stepResult := StepResult{ID: step.ID, Routine: step.Routine, Outcome: routines.OutcomeSkipped}
+			if step.ShouldRun(needed) {
+				slots <- struct{}{}
+				stepResult = s.runStep(ctx, r, eff, step, stepData, redactor, unattended)
+				<-slots
+			}
+
+			errText := ""
+			if stepResult.Err != nil {
+				errText = redactor.String(stepResult.Err.Error())
+			}
+			recorder.Write(sessionlog.Record{Type: sessionlog.RecordStep, StepID: step.ID, Status: stepResult.Outcome, Text: stepResult.SessionLog, Error: errText})
+
+			mu.Lock()
+			results[step.ID] = stepResult
+			outputs[step.ID] = routines.StepOutput{Outcome: stepResult.Outcome, Output: stepResult.Output}
+			mu.Unlock()
+		}(step)
+	}
+	wg.Wait()
+
+	// The workflow's outcome is that of its first step (in definition order)
+	// that ran and did not succeed; its output is that of its final steps.
+	result.Outcome = routines.OutcomeSuccess
+	var errs []error
+	dependents := map[string]bool{}
+	for _, step := range r.Steps {
+		for _, need := range step.Needs {
+			dependents[need] = true
+		}
+	}
+	var finals []StepResult
+	for _, step := range r.Steps {
+		stepResult := results[step.ID]
+		result.Steps = append(result.Steps, stepResult)
+		result.Simulated = append(result.Simulated, stepResult.Simulated...)
+		result.TokensUsed += stepResult.TokensUsed
+		if stepResult.Err != nil {
+			if result.Outcome == routines.OutcomeSuccess {
+				result.Outcome = stepResult.Outcome
+			}
+			errs = append(errs, fmt.Errorf("step %s: %w", step.ID, stepResult.Err))
+		}
+		if !dependents[step.ID] && stepResult.Outcome != routines.OutcomeSkipped {
+			finals = append(finals, stepResult)
+		}
+	}
+	result.Err = errors.Join(errs...)
+	result.Output = joinStepOutputs(finals)
+	result.Duration = time.Since(start)
+
+	errText := ""
+	if result.Err != nil {
+		errText = redactor.String(result.Err.Error())
+		recorder.Write(sessionlog.Record{Type: sessionlog.RecordFailed, Error: errText, Redactions: redactor.Counts()})
+	} else {
+		recorder.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Text: result.Output, Redactions: redactor.Counts()})
+	}
+	return result, errText, recorder.RunID()
+}
+
+// runStep runs one workflow step: an inline prompt with the workflow's
+// settings, or another routine with its own.
+func (s *routineService) runStep(ctx context.Context, owner routines.Routine, eff RoutineSettings, step routines.Step, data *routines.PromptData, redactor *redact.Redactor, unattended unattendedPolicy) StepResult {
+	run := promptRun{owner: owner, routine: owner, eff: eff, prompt: step.Prompt, stepID: step.ID, data: data, redactor: redactor, unattended: unattended}
+	if step.Routine != "" {
+		target, err := s.store.Get(step.Routine)
+		if err == nil && target.IsWorkflow() {
+			err = fmt.Errorf("routine %q is itself a workflow; workflows cannot be nested", target.ID)
+		}
+		if err != nil {
+			return StepResult{ID: step.ID, Routine: step.Routine, Outcome: routines.OutcomeFailed, Err: err}
+		}
+		run.routine, run.eff = target, s.resolve(target)
+	}
+	result := s.runPrompt(ctx, run)
+	return StepResult{
+		ID:         step.ID,
+		Routine:    step.Routine,
+		Outcome:    result.outcome,
+		Output:     result.output,
+		Err:        result.err,
+		Duration:   result.duration,
+		TokensUsed: result.tokensUsed,
+		SessionLog: result.sessionLog,
+		Simulated:  result.simulated,
+	}
+}
+
+func joinStepOutputs(steps []StepResult) string {
+	if len(steps) == 1 {
+		return steps[0].Output
+	}
+	var b strings.Builder
+	for _, step := range steps {
+		fmt.Fprintf(&b, "### %s\n\n%s\n\n", step.ID, strings.TrimSpace(step.Output))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// describeSteps renders a workflow's steps for its session log request line.
+func describeSteps(steps []routines.Step) string {
+	lines := make([]string, 0, len(steps)+1)
+	lines = append(lines, "Workflow:")
+	for _, step := range steps {
+		lines = append(lines, "- "+step.Describe())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,142 @@
+package chat
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// A session's messages form a tree: every message records the message it
+// follows, and the session records the last message of the checked-out
+// branch. New messages follow that head, so checking out an earlier message
+// and sending another one starts a branch next to the existing ones instead
+// of overwriting them.
+
+// ErrMessageNotFound is returned for a message id that does not exist.
+var ErrMessageNotFound = errors.New("chat message not found")
+
+// Branch is one path through a session's message tree, from its first
+// message to a message nothing follows yet.
+type Branch struct {
+	// Head is the branch's last message.
+	Head Message
+	// ForkID is the last message the branch shares with another one, or 0
+	// when it shares none.
+	ForkID int64
+	// Length counts the branch's messages, shared ones included.
+	Length int
+	// Current reports whether Head is the session's checked-out message.
+	Current bool
+}
+
+// GetMessage returns a message by id.
+func (s *SessionStore) GetMessage(id int64) (Message, error) {
+	messages, err := s.queryMessages("SELECT "+messageFields+" FROM messages m WHERE m.id = ?", id)
+	if err != nil {
+		return Message{}, err
+	}
+	if len(messages) == 0 {
+		return Message{}, fmt.Errorf("%w: %d", ErrMessageNotFound, id)
+	}
+	return messages[0], nil
+}
+
+// SessionTree returns every message of a session, on any branch, in the
+// order they were written.
+func (s *SessionStore) SessionTree(id int64) ([]Message, error) {
+	return s.queryMessages("SELECT "+messageFields+" FROM messages m WHERE m.session_id = ? ORDER BY m.id ASC", id)
+}
+
+// Checkout makes session id current with message head as the last message
+// of its checked-out branch; head 0 checks out the start of the session, so
+// the next message begins a new branch there. A summary that covers messages
+// off the new branch is dropped, to be rebuilt from the branch's own messages.
+func (s *SessionStore) Checkout(id, head int64) error {
+	if _, err := s.GetSession(id); err != nil {
+		return err
+	}
+	if head != 0 {
+		msg, err := s.GetMessage(head)
+		if err != nil {
+			return err
+		}
+		if msg.SessionID != id {
+			return fmt.Errorf("message #%d belongs to session #%d, not #%d", head, msg.SessionID, id)
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to check out message: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE sessions SET head_id = ? WHERE id = ?", head, id); err != nil {
+		return fmt.Errorf("failed to check out message: %w", err)
+	}
+	var onBranch bool
+	err = tx.QueryRow(`WITH RECURSIVE branch(id) AS (
+			SELECT ?
+			UNION ALL
+			SELECT m.parent_id FROM messages m JOIN branch b ON m.id = b.id WHERE m.parent_id != 0
+		)
+		SELECT summary_through = 0 OR summary_through IN (SELECT id FROM branch) FROM sessions WHERE id = ?`,
+		head, id).Scan(&onBranch)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check out message: %w", err)
+	}
+	if !onBranch {
+		if _, err := tx.Exec("UPDATE sessions SET summary = '', summary_through = 0 WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to check out message: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to check out message: %w", err)
+	}
+
+	s.currentSession = id
+	if err := s.saveCurrentSession(); err != nil {
+		return fmt.Errorf("failed to save current session: %w", err)
+	}
+	return nil
+}
+
+// Branches returns the branches of a session, oldest first.
+func (s *SessionStore) Branches(id int64) ([]Branch, error) {
+	session, err := s.GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.SessionTree(id)
+	if err != nil {
+		return nil, err
+	}
+	return branchesOf(messages, session.HeadID), nil
+}
+
+// branchesOf splits a message tree, in id order, into its branches.
+func branchesOf(messages []Message, head int64) []Branch {
+	byID := make(map[int64]Message, len(messages))
+	children := map[int64]int{}
+	for _, msg := range messages {
+		byID[msg.ID] = msg
+		children[msg.ParentID]++
+	}
+
+	var branches []Branch
+	for _, msg := range messages {
+		if children[msg.ID] > 0 {
+			continue
+		}
+		branch := Branch{Head: msg, Current: msg.ID == head}
+		for cur, ok := msg, true; ok; cur, ok = byID[cur.ParentID] {
+			branch.Length++
+			// The first ancestor followed by more than one message is
+			// where this branch forks from another.
+			if branch.ForkID == 0 && cur.ID != msg.ID && children[cur.ID] > 1 {
+				branch.ForkID = cur.ID
+			}
+		}
+		branches = append(branches, branch)
+	}
+	return branches
+}
@@ -0,0 +1,92 @@
+package chat
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func contents(messages []Message) []string {
+	var out []string
+	for _, msg := range messages {
+		out = append(out, msg.Content)
+	}
+	return out
+}
+
+func TestBranching(t *testing.T) {
+	store, err := NewSessionStore(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	id, err := store.NewSession()
+	require.NoError(t, err)
+	for _, content := range []string{"q1", "a1", "q2", "a2"} {
+		role := "user"
+		if content[0] == 'a' {
+			role = "assistant"
+		}
+		require.NoError(t, store.AddMessage(role, content))
+	}
+	messages, err := store.GetMessages()
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	assert.Equal(t, int64(0), messages[0].ParentID)
+	assert.Equal(t, messages[2].ID, messages[3].ParentID)
+	require.NoError(t, store.SetSummary("q1 and a1", messages[1].ID))
+
+	// Fork after the first answer: the old branch is kept, the summary
+	// still covers the new branch
+	require.NoError(t, store.Checkout(id, messages[1].ID))
+	require.NoError(t, store.AddMessage("user", "q2 edited"))
+	branch, err := store.GetMessages()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"q1", "a1", "q2 edited"}, contents(branch))
+	session, err := store.GetSession(id)
+	require.NoError(t, err)
+	assert.Equal(t, "q1 and a1", session.Summary)
+	assert.Equal(t, branch[2].ID, session.HeadID)
+	assert.Equal(t, 5, session.MessageCount)
+
+	branches, err := store.Branches(id)
+	require.NoError(t, err)
+	require.Len(t, branches, 2)
+	assert.Equal(t, "a2", branches[0].Head.Content)
+	assert.Equal(t, messages[1].ID, branches[0].ForkID)
+	assert.Equal(t, 4, branches[0].Length)
+	assert.False(t, branches[0].Current)
+	assert.Equal(t, "q2 edited", branches[1].Head.Content)
+	assert.True(t, branches[1].Current)
+
+	// Resuming the old branch works the same way
+	require.NoError(t, store.Checkout(id, messages[3].ID))
+	branch, err = store.GetMessages()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"q1", "a1", "q2", "a2"}, contents(branch))
+
+	// A branch from the start of the session drops the summary, which covers
+	// messages that are not on it
+	require.NoError(t, store.Checkout(id, 0))
+	require.NoError(t, store.AddMessage("user", "q1 edited"))
+	branch, err = store.GetMessages()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"q1 edited"}, contents(branch))
+	session, err = store.GetSession(id)
+	require.NoError(t, err)
+	assert.Empty(t, session.Summary)
+	assert.Equal(t, int64(0), session.SummaryThrough)
+
+	branches, err = store.Branches(id)
+	require.NoError(t, err)
+	require.Len(t, branches, 3)
+	assert.Equal(t, int64(0), branches[2].ForkID)
+
+	// Messages are checked out within their own session
+	other, err := store.NewSession()
+	require.NoError(t, err)
+	assert.Error(t, store.Checkout(other, messages[0].ID))
+	_, err = store.GetMessage(999)
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+}
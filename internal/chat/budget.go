@@ -0,0 +1,100 @@
+package chat
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// charsPerToken is the divisor of the token estimate; it errs on the high
+	// side for English prose so history stays inside the window.
+	charsPerToken = 4
+	// messageOverheadTokens covers the role and framing of each message.
+	messageOverheadTokens = 4
+	// defaultContextWindow is assumed for models missing from contextWindows.
+	defaultContextWindow = 8192
+	// MaxHistoryTokens caps the default history budget, so long sessions on
+	// large-window models don't cost more with every reply.
+	MaxHistoryTokens = 16000
+)
+
+// contextWindows maps model name fragments to their context window in
+// tokens. The longest fragment found in a model id wins, so "gpt-4o-mini"
+// and Bedrock ids like "us.anthropic.claude-sonnet-4" resolve too.
+var contextWindows = map[string]int{
+	"gpt-3.5":       16385,
+	"gpt-4":         8192,
+	"gpt-4-turbo":   128000,
+	"gpt-4o":        128000,
+	"gpt-4.1":       1000000,
+	"gpt-5":         400000,
+	"o1":            200000,
+	"o3":            200000,
+	"o4":            200000,
+	"codex":         200000,
+	"claude":        200000,
+	"gemini":        1000000,
+	"mistral":       32000,
+	"mistral-large": 128000,
+	"mistral-small": 128000,
+	"mimo":          128000,
+	"llama3":        8192,
+	"llama3.1":      128000,
+	"llama3.2":      128000,
+	"llama3.3":      128000,
+	"qwen":          32768,
+}
+
+// EstimateTokens approximates the number of tokens in text.
+func EstimateTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// MessageTokens approximates the tokens a message takes up as history.
+func MessageTokens(msg Message) int {
+	return EstimateTokens(msg.Content) + messageOverheadTokens
+}
+
+// ContextWindow returns the context window of a model in tokens, or a
+// conservative default for models it does not know.
+func ContextWindow(model string) int {
+	model = strings.ToLower(model)
+	fragments := make([]string, 0, len(contextWindows))
+	for fragment := range contextWindows {
+		fragments = append(fragments, fragment)
+	}
+	sort.Slice(fragments, func(i, j int) bool { return len(fragments[i]) > len(fragments[j]) })
+	for _, fragment := range fragments {
+		if strings.Contains(model, fragment) {
+			return contextWindows[fragment]
+		}
+	}
+	return defaultContextWindow
+}
+
+// HistoryBudget returns how many tokens of history to send to a model by
+// default: a quarter of its window, leaving room for the system prompt, the
+// new message and the reply, capped at MaxHistoryTokens.
+func HistoryBudget(model string) int {
+	return min(ContextWindow(model)/4, MaxHistoryTokens)
+}
+
+// SplitHistory divides messages, oldest first, into the older ones that do
+// not fit in budget tokens and the most recent ones that do. The kept part
+// always starts with a user message, so no reply is sent without its
+// question.
+func SplitHistory(messages []Message, budget int) (older, recent []Message) {
+	start := len(messages)
+	used := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		used += MessageTokens(messages[i])
+		if used > budget {
+			break
+		}
+		start = i
+	}
+	for start < len(messages) && messages[start].Role != "user" {
+		start++
+	}
+	return messages[:start], messages[start:]
+}
@@ -0,0 +1,45 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextWindow(t *testing.T) {
+	assert.Equal(t, 128000, ContextWindow("gpt-4o-mini"))
+	assert.Equal(t, 8192, ContextWindow("gpt-4"))
+	assert.Equal(t, 200000, ContextWindow("us.anthropic.claude-sonnet-4-20250514-v1:0"))
+	assert.Equal(t, 128000, ContextWindow("llama3.2"))
+	assert.Equal(t, 8192, ContextWindow("llama3"))
+	assert.Equal(t, defaultContextWindow, ContextWindow("some-local-model"))
+
+	assert.Equal(t, MaxHistoryTokens, HistoryBudget("gpt-4o"))
+	assert.Equal(t, 2048, HistoryBudget("llama3"))
+}
+
+func TestSplitHistory(t *testing.T) {
+	turn := func(question, answer string) []Message {
+		return []Message{{Role: "user", Content: question}, {Role: "assistant", Content: answer}}
+	}
+	long := strings.Repeat("x", 400) // 100 tokens + overhead
+	var messages []Message
+	messages = append(messages, turn(long, long)...)
+	messages = append(messages, turn("short question", long)...)
+	messages = append(messages, turn("latest", "reply")...)
+
+	older, recent := SplitHistory(messages, 1000)
+	assert.Empty(t, older)
+	assert.Len(t, recent, 6)
+
+	// The budget ends inside the second turn's reply: the reply is not sent
+	// without its question, so the whole turn is folded.
+	older, recent = SplitHistory(messages, 60)
+	assert.Len(t, older, 4)
+	assert.Equal(t, "latest", recent[0].Content)
+
+	older, recent = SplitHistory(messages, 0)
+	assert.Len(t, older, 6)
+	assert.Empty(t, recent)
+}
@@ -0,0 +1,201 @@
+package chat
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/fts"
+)
+
+// SearchResult is a message matching a search, with a snippet of its content
+// around the match.
+type SearchResult struct {
+	SessionID    int64
+	SessionTitle string
+	MessageID    int64
+	Role         string
+	Snippet      string
+	CreatedAt    time.Time
+}
+
+// searchTriggers keep a message index in sync; %[1]s is the index. FTS5 is
+// told the old content on delete, FTS4 reads it from messages, so its delete
+// trigger must run before the row is gone.
+var searchTriggers = map[string]string{
+	"fts5": `
+CREATE TRIGGER %[1]s_insert AFTER INSERT ON messages BEGIN
+    INSERT INTO %[1]s(rowid, content) VALUES (new.id, new.content);
+END;
+CREATE TRIGGER %[1]s_delete AFTER DELETE ON messages BEGIN
+    INSERT INTO %[1]s(%[1]s, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+`,
+	"fts4": `
+CREATE TRIGGER %[1]s_insert AFTER INSERT ON messages BEGIN
+    INSERT INTO %[1]s(docid, content) VALUES (new.id, new.content);
+END;
+CREATE TRIGGER %[1]s_delete BEFORE DELETE ON messages BEGIN
+    DELETE FROM %[1]s WHERE docid = old.id;
+END;
+`,
+}
+
+// setupSearch creates the full-text index over message content, kept in sync
+// by triggers, and returns the module it uses. Each module has its own index
+// (see package fts). The triggers of other modules' indexes are dropped, as
+// they would fail every write to messages in a build without their module;
+// their tables are left alone, and rebuilt when a build with the module next
+// finds its triggers gone. Without any module it returns "" and search falls
+// back to scanning.
+func setupSearch(db *sql.DB) (string, error) {
+	module, err := fts.Best(db)
+	if err != nil {
+		return "", err
+	}
+	for _, other := range fts.Modules {
+		if other == module {
+			continue
+		}
+		table := fts.Table("messages", other)
+		for _, stmt := range []string{
+			"DROP TRIGGER IF EXISTS " + table + "_insert",
+			"DROP TRIGGER IF EXISTS " + table + "_delete",
+		} {
+			if _, err := db.Exec(stmt); err != nil {
+				return "", err
+			}
+		}
+	}
+	if module == "" {
+		return "", nil
+	}
+
+	table := fts.Table("messages", module)
+	var triggers int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?", table+"_insert").Scan(&triggers); err != nil {
+		return "", err
+	}
+	if triggers > 0 {
+		return module, nil
+	}
+
+	create := fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(content, content='messages', content_rowid='id')", table)
+	if module == "fts4" {
+		create = fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts4(content, content='messages')", table)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		create,
+		"DROP TRIGGER IF EXISTS " + table + "_delete",
+		fmt.Sprintf(searchTriggers[module], table),
+		fmt.Sprintf("INSERT INTO %[1]s(%[1]s) VALUES ('rebuild')", table),
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return "", err
+		}
+	}
+	return module, tx.Commit()
+}
+
+// SearchMessages returns messages whose content matches every word of text,
+// newest first. Words are matched as terms, not as query syntax.
+func (s *SessionStore) SearchMessages(text string, limit int) ([]SearchResult, error) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("search text is empty")
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var (
+		query string
+		args  []any
+	)
+	const columns = "m.session_id, s.title, m.id, m.role, m.created_at"
+	switch s.fts {
+	case "fts5", "fts4":
+		quoted := make([]string, len(words))
+		for i, word := range words {
+			quoted[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		}
+		table := fts.Table("messages", s.fts)
+		snippet := fmt.Sprintf("snippet(%s, 0, '[', ']', '…', 12)", table)
+		if s.fts == "fts4" {
+			snippet = fmt.Sprintf("snippet(%s, '[', ']', '…', 0, 12)", table)
+		}
+		query = fmt.Sprintf(`SELECT %[1]s, %[2]s FROM %[3]s
+			JOIN messages m ON m.id = %[3]s.rowid
+			JOIN sessions s ON s.id = m.session_id
+			WHERE %[3]s MATCH ? ORDER BY m.id DESC LIMIT ?`, columns, snippet, table)
+		args = []any{strings.Join(quoted, " "), limit}
+	default:
+		conditions := make([]string, len(words))
+		for i, word := range words {
+			conditions[i] = "m.content LIKE ? ESCAPE '\\'"
+			args = append(args, "%"+escapeLike(word)+"%")
+		}
+		query = fmt.Sprintf(`SELECT %s, m.content FROM messages m
+			JOIN sessions s ON s.id = m.session_id
+			WHERE %s ORDER BY m.id DESC LIMIT ?`, columns, strings.Join(conditions, " AND "))
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.SessionID, &r.SessionTitle, &r.MessageID, &r.Role, &r.CreatedAt, &r.Snippet); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		if s.fts == "" {
+			r.Snippet = likeSnippet(r.Snippet, words[0])
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// likeSnippet cuts content down to a window around the first match of word,
+// marking the match like the full-text snippets do.
+func likeSnippet(content, word string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	needle := []rune(strings.ToLower(word))
+	at := -1
+	for i := 0; i+len(needle) <= len(lower); i++ {
+		if string(lower[i:i+len(needle)]) == string(needle) {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return content
+	}
+	const context = 40
+	start, end := max(0, at-context), min(len(runes), at+len(needle)+context)
+	snippet := string(runes[start:at]) + "[" + string(runes[at:at+len(needle)]) + "]" + string(runes[at+len(needle):end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
@@ -2,9 +2,12 @@ package chat
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -23,12 +26,67 @@ CREATE TABLE IF NOT EXISTS messages (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (session_id) REFERENCES sessions(id)
 );
+
+CREATE INDEX IF NOT EXISTS messages_session ON messages(session_id);
 `
 
+type column struct{ name, decl string }
+
+// sessionColumns are the session columns added after the first release; they
+// are added to existing databases when the store opens. SQLite cannot add a
+// column with a CURRENT_TIMESTAMP default, so updated_at is set explicitly.
+var sessionColumns = []column{
+	{"title", "TEXT NOT NULL DEFAULT ''"},
+	{"provider", "TEXT NOT NULL DEFAULT ''"},
+	{"model", "TEXT NOT NULL DEFAULT ''"},
+	{"updated_at", "TIMESTAMP"},
+	{"summary", "TEXT NOT NULL DEFAULT ''"},
+	{"summary_through", "INTEGER NOT NULL DEFAULT 0"},
+	// head_id is the last message of the checked-out branch (see branch.go).
+	{"head_id", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// messageColumns are the message columns added after the first release.
+// parent_id is the message a message follows, 0 for the first one.
+var messageColumns = []column{
+	{"parent_id", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// ErrSessionNotFound is returned for a session id that does not exist.
+var ErrSessionNotFound = errors.New("chat session not found")
+
+// maxTitleLength bounds generated session titles, in runes.
+const maxTitleLength = 60
+
 // Message represents a chat message
 type Message struct {
-	Role    string
-	Content string
+	ID        int64
+	SessionID int64
+	// ParentID is the message this one follows; 0 for the first message of
+	// a branch that starts at the beginning of the session.
+	ParentID int64
+	Role     string
+	Content  string
+}
+
+// Session describes a stored conversation.
+type Session struct {
+	ID       int64
+	Title    string
+	Provider string
+	Model    string
+	// CreatedAt and UpdatedAt are UTC; UpdatedAt is the time of the latest
+	// message.
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	MessageCount int
+	// Summary condenses the messages up to and including SummaryThrough, so
+	// they need not be sent as history again.
+	Summary        string
+	SummaryThrough int64
+	// HeadID is the last message of the checked-out branch, or 0 when the
+	// next message starts the session (or a branch at its beginning).
+	HeadID int64
 }
 
 // SessionStore manages chat sessions and messages
@@ -36,6 +94,9 @@ type SessionStore struct {
 	db              *sql.DB
 	currentSession  int64
 	sessionFilePath string
+	// fts is the full-text module indexing message content ("fts5" or
+	// "fts4"), or empty when search falls back to scanning.
+	fts string
 }
 
 // NewSessionStore creates a new session store with the given database path
@@ -56,6 +117,10 @@ func NewSessionStore(dbPath string) (*SessionStore, error) {
 		db.Close()
 		return nil, fmt.Errorf("failed to create schema: %w", err)
 	}
+	if err := migrateSessions(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
 
 	sessionFilePath := filepath.Join(dir, "current_session")
 
@@ -63,6 +128,12 @@ func NewSessionStore(dbPath string) (*SessionStore, error) {
 		db:              db,
 		sessionFilePath: sessionFilePath,
 	}
+	fts, err := setupSearch(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create search index: %w", err)
+	}
+	store.fts = fts
 
 	// Load current session ID
 	if err := store.loadCurrentSession(); err != nil {
@@ -73,6 +144,78 @@ func NewSessionStore(dbPath string) (*SessionStore, error) {
 	return store, nil
 }
 
+// migrateSessions adds the columns missing from a database created by an
+// older release and backfills updated_at from the latest message. Messages
+// stored before branching existed form a single branch per session, in id
+// order.
+func migrateSessions(db *sql.DB) error {
+	addedParent, err := addColumns(db, "messages", messageColumns)
+	if err != nil {
+		return err
+	}
+	addedSession, err := addColumns(db, "sessions", sessionColumns)
+	if err != nil {
+		return err
+	}
+	if addedParent["parent_id"] {
+		if _, err := db.Exec(`UPDATE messages SET parent_id = COALESCE(
+			(SELECT MAX(p.id) FROM messages p WHERE p.session_id = messages.session_id AND p.id < messages.id),
+			0)`); err != nil {
+			return err
+		}
+	}
+	if addedSession["head_id"] {
+		if _, err := db.Exec(`UPDATE sessions SET head_id = COALESCE(
+			(SELECT MAX(id) FROM messages WHERE session_id = sessions.id), 0)`); err != nil {
+			return err
+		}
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS messages_parent ON messages(parent_id)"); err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE sessions SET updated_at = COALESCE(
+		(SELECT created_at FROM messages WHERE session_id = sessions.id ORDER BY id DESC LIMIT 1),
+		created_at) WHERE updated_at IS NULL`)
+	return err
+}
+
+// addColumns adds the columns a table is missing and reports which it added.
+func addColumns(db *sql.DB, table string, columns []column) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	added := map[string]bool{}
+	for _, column := range columns {
+		if existing[column.name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column.name, column.decl)); err != nil {
+			return nil, err
+		}
+		added[column.name] = true
+	}
+	return added, nil
+}
+
 // Close closes the database connection
 func (s *SessionStore) Close() error {
 	return s.db.Close()
@@ -108,7 +251,7 @@ func (s *SessionStore) saveCurrentSession() error {
 
 // NewSession creates a new session and sets it as current
 func (s *SessionStore) NewSession() (int64, error) {
-	result, err := s.db.Exec("INSERT INTO sessions DEFAULT VALUES")
+	result, err := s.db.Exec("INSERT INTO sessions (updated_at) VALUES (CURRENT_TIMESTAMP)")
 	if err != nil {
 		return 0, fmt.Errorf("failed to create session: %w", err)
 	}
@@ -134,19 +277,35 @@ func (s *SessionStore) GetOrCreateSession() (int64, error) {
 	return s.NewSession()
 }
 
-// AddMessage adds a message to the current session
+// AddMessage adds a message to the current session, following the last
+// message of the checked-out branch.
 func (s *SessionStore) AddMessage(role, content string) error {
 	if s.currentSession == 0 {
 		return fmt.Errorf("no active session")
 	}
 
-	_, err := s.db.Exec(
-		"INSERT INTO messages (session_id, role, content) VALUES (?, ?, ?)",
-		s.currentSession, role, content,
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to add message: %w", err)
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(
+		"INSERT INTO messages (session_id, parent_id, role, content) VALUES (?, (SELECT head_id FROM sessions WHERE id = ?), ?, ?)",
+		s.currentSession, s.currentSession, role, content,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to add message: %w", err)
 	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to add message: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE sessions SET head_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", id, s.currentSession); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to add message: %w", err)
+	}
 
 	return nil
 }
@@ -156,11 +315,34 @@ func (s *SessionStore) GetMessages() ([]Message, error) {
 	if s.currentSession == 0 {
 		return []Message{}, nil
 	}
+	return s.SessionMessages(s.currentSession)
+}
 
-	rows, err := s.db.Query(
-		"SELECT role, content FROM messages WHERE session_id = ? ORDER BY id ASC",
-		s.currentSession,
+// SessionMessages returns the messages of a session's checked-out branch,
+// oldest first.
+func (s *SessionStore) SessionMessages(id int64) ([]Message, error) {
+	return s.SessionMessagesAfter(id, 0)
+}
+
+// SessionMessagesAfter returns the messages of a session's checked-out branch
+// with an id above after, oldest first. A message always has a higher id than
+// its parent, so these are the branch's messages after message after.
+func (s *SessionStore) SessionMessagesAfter(id, after int64) ([]Message, error) {
+	return s.queryMessages(`WITH RECURSIVE branch(id) AS (
+			SELECT head_id FROM sessions WHERE id = ?
+			UNION ALL
+			SELECT m.parent_id FROM messages m JOIN branch b ON m.id = b.id WHERE m.parent_id != 0
+		)
+		SELECT `+messageFields+` FROM messages m JOIN branch b ON m.id = b.id
+		WHERE m.session_id = ? AND m.id > ? ORDER BY m.id ASC`,
+		id, id, after,
 	)
+}
+
+const messageFields = "m.id, m.session_id, m.parent_id, m.role, m.content"
+
+func (s *SessionStore) queryMessages(query string, args ...any) ([]Message, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query messages: %w", err)
 	}
@@ -169,16 +351,164 @@ func (s *SessionStore) GetMessages() ([]Message, error) {
 	var messages []Message
 	for rows.Next() {
 		var msg Message
-		if err := rows.Scan(&msg.Role, &msg.Content); err != nil {
+		if err := rows.Scan(&msg.ID, &msg.SessionID, &msg.ParentID, &msg.Role, &msg.Content); err != nil {
 			return nil, fmt.Errorf("failed to scan message: %w", err)
 		}
 		messages = append(messages, msg)
 	}
 
-	return messages, nil
+	return messages, rows.Err()
 }
 
 // CurrentSessionID returns the current session ID
 func (s *SessionStore) CurrentSessionID() int64 {
 	return s.currentSession
 }
+
+const sessionQuery = `SELECT s.id, s.title, s.provider, s.model, s.created_at, s.updated_at,
+	(SELECT COUNT(*) FROM messages m WHERE m.session_id = s.id), s.summary, s.summary_through, s.head_id
+	FROM sessions s`
+
+func scanSession(row interface{ Scan(...any) error }) (Session, error) {
+	var session Session
+	err := row.Scan(&session.ID, &session.Title, &session.Provider, &session.Model, &session.CreatedAt, &session.UpdatedAt, &session.MessageCount, &session.Summary, &session.SummaryThrough, &session.HeadID)
+	return session, err
+}
+
+// ListSessions returns every session, most recently updated first.
+func (s *SessionStore) ListSessions() ([]Session, error) {
+	rows, err := s.db.Query(sessionQuery + " ORDER BY s.updated_at DESC, s.id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// GetSession returns a session by id.
+func (s *SessionStore) GetSession(id int64) (Session, error) {
+	session, err := scanSession(s.db.QueryRow(sessionQuery+" WHERE s.id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, fmt.Errorf("%w: %d", ErrSessionNotFound, id)
+	}
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to query session: %w", err)
+	}
+	return session, nil
+}
+
+// SwitchSession makes an existing session the current one.
+func (s *SessionStore) SwitchSession(id int64) error {
+	if _, err := s.GetSession(id); err != nil {
+		return err
+	}
+	s.currentSession = id
+	if err := s.saveCurrentSession(); err != nil {
+		return fmt.Errorf("failed to save current session: %w", err)
+	}
+	return nil
+}
+
+// RenameSession sets a session's title.
+func (s *SessionStore) RenameSession(id int64, title string) error {
+	return s.updateSession(id, "UPDATE sessions SET title = ? WHERE id = ?", strings.TrimSpace(title), id)
+}
+
+// SetSessionModel records the provider and model the current session talks to.
+func (s *SessionStore) SetSessionModel(provider, model string) error {
+	if s.currentSession == 0 {
+		return fmt.Errorf("no active session")
+	}
+	return s.updateSession(s.currentSession, "UPDATE sessions SET provider = ?, model = ? WHERE id = ?", provider, model, s.currentSession)
+}
+
+// SetTitleIfEmpty titles the current session unless it already has a title,
+// so a generated title never replaces one the user chose.
+func (s *SessionStore) SetTitleIfEmpty(title string) error {
+	title = strings.TrimSpace(title)
+	if s.currentSession == 0 || title == "" {
+		return nil
+	}
+	_, err := s.db.Exec("UPDATE sessions SET title = ? WHERE id = ? AND title = ''", title, s.currentSession)
+	if err != nil {
+		return fmt.Errorf("failed to set session title: %w", err)
+	}
+	return nil
+}
+
+// SetSummary replaces the current session's summary with one that covers the
+// messages up to and including message id through.
+func (s *SessionStore) SetSummary(summary string, through int64) error {
+	if s.currentSession == 0 {
+		return fmt.Errorf("no active session")
+	}
+	return s.updateSession(s.currentSession, "UPDATE sessions SET summary = ?, summary_through = ? WHERE id = ?", strings.TrimSpace(summary), through, s.currentSession)
+}
+
+func (s *SessionStore) updateSession(id int64, query string, args ...any) error {
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %d", ErrSessionNotFound, id)
+	}
+	return nil
+}
+
+// DeleteSession removes a session and its messages. Deleting the current
+// session leaves no current session, so the next chat starts a new one.
+func (s *SessionStore) DeleteSession(id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM messages WHERE session_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+	result, err := tx.Exec("DELETE FROM sessions WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %d", ErrSessionNotFound, id)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	if s.currentSession == id {
+		s.currentSession = 0
+		if err := os.Remove(s.sessionFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to clear current session: %w", err)
+		}
+	}
+	return nil
+}
+
+// TitleFromMessage derives a session title from the first message of a
+// conversation: its first non-blank line with whitespace collapsed, shortened
+// to maxTitleLength runes.
+func TitleFromMessage(message string) string {
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			continue
+		}
+		if runes := []rune(line); len(runes) > maxTitleLength {
+			line = strings.TrimSpace(string(runes[:maxTitleLength-1])) + "…"
+		}
+		return line
+	}
+	return ""
+}
@@ -1,7 +1,10 @@
 package chat
 
 import (
+	"database/sql"
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -131,4 +134,196 @@ func TestSessionStore(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "no active session")
 	})
+	t.Run("ManageSessions", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "test.db")
+
+		store, err := NewSessionStore(dbPath)
+		require.NoError(t, err)
+		defer store.Close()
+
+		first, err := store.NewSession()
+		require.NoError(t, err)
+		require.NoError(t, store.AddMessage("user", "first question"))
+		require.NoError(t, store.SetSessionModel("bedrock", "claude"))
+		require.NoError(t, store.SetTitleIfEmpty("First"))
+		require.NoError(t, store.SetTitleIfEmpty("Ignored"))
+
+		second, err := store.NewSession()
+		require.NoError(t, err)
+
+		sessions, err := store.ListSessions()
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.Equal(t, second, sessions[0].ID)
+		assert.Equal(t, "First", sessions[1].Title)
+		assert.Equal(t, "bedrock", sessions[1].Provider)
+		assert.Equal(t, "claude", sessions[1].Model)
+		assert.Equal(t, 1, sessions[1].MessageCount)
+		assert.False(t, sessions[1].UpdatedAt.IsZero())
+
+		require.NoError(t, store.SwitchSession(first))
+		assert.Equal(t, first, store.CurrentSessionID())
+		require.NoError(t, store.RenameSession(first, "  Renamed  "))
+		session, err := store.GetSession(first)
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed", session.Title)
+
+		assert.ErrorIs(t, store.SwitchSession(99), ErrSessionNotFound)
+		assert.ErrorIs(t, store.RenameSession(99, "x"), ErrSessionNotFound)
+
+		require.NoError(t, store.DeleteSession(first))
+		assert.Equal(t, int64(0), store.CurrentSessionID())
+		_, err = store.GetSession(first)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		assert.ErrorIs(t, store.DeleteSession(first), ErrSessionNotFound)
+
+		// The deleted session's messages are gone from search too
+		results, err := store.SearchMessages("first", 10)
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("SummaryCoversEarlierMessages", func(t *testing.T) {
+		store, err := NewSessionStore(filepath.Join(t.TempDir(), "test.db"))
+		require.NoError(t, err)
+		defer store.Close()
+
+		id, err := store.NewSession()
+		require.NoError(t, err)
+		require.NoError(t, store.AddMessage("user", "one"))
+		require.NoError(t, store.AddMessage("assistant", "two"))
+		require.NoError(t, store.AddMessage("user", "three"))
+
+		messages, err := store.GetMessages()
+		require.NoError(t, err)
+		require.NoError(t, store.SetSummary(" user said one, got two ", messages[1].ID))
+
+		session, err := store.GetSession(id)
+		require.NoError(t, err)
+		assert.Equal(t, "user said one, got two", session.Summary)
+		assert.Equal(t, messages[1].ID, session.SummaryThrough)
+
+		pending, err := store.SessionMessagesAfter(id, session.SummaryThrough)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, "three", pending[0].Content)
+	})
+
+	t.Run("SearchMessages", func(t *testing.T) {
+		store, err := NewSessionStore(filepath.Join(t.TempDir(), "test.db"))
+		require.NoError(t, err)
+		defer store.Close()
+		require.NotEmpty(t, store.fts)
+
+		_, err = store.NewSession()
+		require.NoError(t, err)
+		require.NoError(t, store.SetTitleIfEmpty("Kubernetes"))
+		require.NoError(t, store.AddMessage("user", "How do I restart a kubernetes deployment?"))
+		require.NoError(t, store.AddMessage("assistant", "Use kubectl rollout restart deployment/NAME."))
+		require.NoError(t, store.AddMessage("user", "And list \"pods\" in a namespace?"))
+
+		for _, fts := range []string{store.fts, ""} {
+			store.fts = fts
+
+			results, err := store.SearchMessages("restart deployment", 10)
+			require.NoError(t, err, fts)
+			require.Len(t, results, 2, fts)
+			assert.Equal(t, "assistant", results[0].Role)
+			assert.Equal(t, "Kubernetes", results[0].SessionTitle)
+			assert.Contains(t, results[0].Snippet, "[restart]")
+
+			// Query syntax is matched literally rather than failing
+			results, err = store.SearchMessages(`"pods" OR`, 10)
+			require.NoError(t, err, fts)
+			assert.Empty(t, results, fts)
+
+			results, err = store.SearchMessages("deployment", 1)
+			require.NoError(t, err, fts)
+			assert.Len(t, results, 1, fts)
+		}
+
+		_, err = store.SearchMessages("  ", 10)
+		assert.Error(t, err)
+	})
+
+	t.Run("SearchIndexPerModule", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "test.db")
+		store, err := NewSessionStore(dbPath)
+		require.NoError(t, err)
+		module := store.fts
+		_, err = store.NewSession()
+		require.NoError(t, err)
+		require.NoError(t, store.AddMessage("user", "first question"))
+		require.NoError(t, store.Close())
+
+		// Another build left a trigger for an index whose module this build
+		// lacks, and this build's index fell behind while its triggers were gone.
+		other := "fts5"
+		if module == "fts5" {
+			other = "fts4"
+		}
+		db, err := sql.Open("sqlite3", dbPath)
+		require.NoError(t, err)
+		_, err = db.Exec(fmt.Sprintf(`DROP TRIGGER messages_%[1]s_insert;
+INSERT INTO messages (session_id, role, content) VALUES (1, 'assistant', 'second answer');
+CREATE TRIGGER messages_%[2]s_insert AFTER INSERT ON messages BEGIN
+    INSERT INTO messages_%[2]s(rowid, content) VALUES (new.id, new.content);
+END;`, module, other))
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		store, err = NewSessionStore(dbPath)
+		require.NoError(t, err)
+		defer store.Close()
+		require.NoError(t, store.AddMessage("user", "third question"))
+		results, err := store.SearchMessages("second", 10)
+		require.NoError(t, err)
+		assert.Len(t, results, 1)
+		results, err = store.SearchMessages("question", 10)
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+	})
+
+	t.Run("MigratesLegacyDatabase", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "test.db")
+		db, err := sql.Open("sqlite3", dbPath)
+		require.NoError(t, err)
+		_, err = db.Exec(`CREATE TABLE sessions (id INTEGER PRIMARY KEY AUTOINCREMENT, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
+CREATE TABLE messages (id INTEGER PRIMARY KEY AUTOINCREMENT, session_id INTEGER NOT NULL, role TEXT NOT NULL, content TEXT NOT NULL, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
+INSERT INTO sessions DEFAULT VALUES;
+INSERT INTO messages (session_id, role, content) VALUES (1, 'user', 'legacy question');
+INSERT INTO messages (session_id, role, content) VALUES (1, 'assistant', 'legacy answer');`)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		store, err := NewSessionStore(dbPath)
+		require.NoError(t, err)
+		defer store.Close()
+
+		sessions, err := store.ListSessions()
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, "", sessions[0].Title)
+		assert.False(t, sessions[0].UpdatedAt.IsZero())
+
+		results, err := store.SearchMessages("legacy", 10)
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+
+		// Existing messages become a single branch
+		messages, err := store.SessionMessages(1)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, messages[0].ID, messages[1].ParentID)
+		assert.Equal(t, messages[1].ID, sessions[0].HeadID)
+	})
+}
+
+func TestTitleFromMessage(t *testing.T) {
+	assert.Equal(t, "How do I list files?", TitleFromMessage("\n  How do I   list files?\nWith details"))
+	assert.Equal(t, "", TitleFromMessage(" \n "))
+
+	title := TitleFromMessage(strings.Repeat("word ", 30))
+	assert.Equal(t, maxTitleLength, len([]rune(title)))
+	assert.True(t, strings.HasSuffix(title, "…"))
 }
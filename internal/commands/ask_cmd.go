@@ -10,7 +10,7 @@ import (
 	"github.com/laszukdawid/terminal-agent/internal/app"
 	"github.com/laszukdawid/terminal-agent/internal/config"
 	"github.com/laszukdawid/terminal-agent/internal/connector"
-	"github.com/laszukdawid/terminal-agent/internal/history"
+	"github.com/laszukdawid/terminal-agent/internal/memory"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -21,6 +21,8 @@ func NewQuestionCommand(config config.Config) *cobra.Command {
 	var promptFlag *string
 	var contextFiles []string
 	var terminalContextCount int
+	var rag bool
+	var ragTopK int
 	var terminalContext1 bool
 	var terminalContext2 bool
 	var terminalContext3 bool
@@ -68,6 +70,8 @@ func NewQuestionCommand(config config.Config) *cobra.Command {
 				MemoryPath:           getMemoryPath(),
 				ContextFiles:         contextFiles,
 				TerminalContextCount: terminalContextCount,
+				RAG:                  rag,
+				RAGTopK:              ragTopK,
 				Stream:               streamFlag,
 				UseWebSearch:         webSearchFlag,
 				Device:               device,
@@ -91,7 +95,10 @@ func NewQuestionCommand(config config.Config) *cobra.Command {
 			}
 
 			if logFlag, err := flags.GetBool("log"); logFlag && err == nil {
-				hClient := history.NewHistory(getLogPath())
+				hClient, err := newRedactedHistory()
+				if err != nil {
+					return err
+				}
 				hClient.Log("ask", result.Question, response)
 			}
 
@@ -134,6 +141,10 @@ func NewQuestionCommand(config config.Config) *cobra.Command {
 	// 'context' flag to include file contents as context (can be used multiple times)
 	cmd.Flags().StringArrayVarP(&contextFiles, "context", "c", []string{}, "Include file content as context (can be used multiple times)")
 
+	// 'rag' flag to include the closest chunks of the semantic index as context
+	cmd.Flags().BoolVar(&rag, "rag", false, "Include the most relevant snippets from the project's semantic index as context (see agent index build)")
+	cmd.Flags().IntVar(&ragTopK, "rag-top-k", app.DefaultRAGTopK, "Number of snippets --rag includes")
+
 	// 'use-terminal-context' flag to include the latest terminal commands and output
 	cmd.Flags().IntVar(&terminalContextCount, "use-terminal-context", 0, "Include latest N terminal commands and output as context (1-5, requires bash-reader plugin)")
 
@@ -199,8 +210,8 @@ func buildContextFromFiles(files []string) (string, error) {
 }
 
 // BuildAskPrompt builds the system prompt for the ask command, optionally including memory
-func BuildAskPrompt(basePrompt string, includeMemory bool, memoryPath string) (string, error) {
-	return app.BuildAskPrompt(basePrompt, includeMemory, memoryPath)
+func BuildAskPrompt(basePrompt string, includeMemory bool, memoryPath string, query memory.Query) (string, error) {
+	return app.BuildAskPrompt(basePrompt, includeMemory, memoryPath, query)
 }
 
 func resolveTerminalContextCount(flags *pflag.FlagSet) (int, error) {
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -22,30 +23,67 @@ func NewChatCommand(config config.Config) *cobra.Command {
 	var promptFlag *string
 	var newSession bool
 	var contextFiles []string
+	var historyLimit int
+	var interactive bool
+	var editID int64
+	var regenerate bool
 
 	cmd := &cobra.Command{
 		Use:          "chat",
 		SilenceUsage: true,
-		Args:         cobra.MinimumNArgs(1),
-		Short:        "Chat with the LLM while maintaining conversation history",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if interactive {
+				return nil
+			}
+			if regenerate {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		Short: "Chat with the LLM while maintaining conversation history",
 		Long: `Chat with the LLM while maintaining conversation history across invocations.
 
 Examples:
   agent chat "how to check what commits were added?"
   agent chat "how to check just the last 2 commits"
   agent chat --new "start a fresh conversation"
-
-The conversation history is persisted between calls. Use --new to start a fresh session.`,
+  agent chat sessions list
+  agent chat /compact
+  agent chat --edit 12 "how to check just the last 3 commits"
+  agent chat --regenerate
+  agent chat -i
+
+The conversation history is persisted between calls. Use --new to start a fresh session,
+and the sessions subcommands to list, resume, rename, delete or search past ones.
+
+Only the most recent turns that fit the model's history budget are sent with each
+message; older turns are folded into a running summary of the session. Use
+--history-limit to change the budget, or send /compact to fold the whole session
+into the summary now.
+
+--edit replaces an earlier message of yours and --regenerate asks again for the
+last reply. Both start a new branch and keep the old one; the sessions branches
+and checkout subcommands list branches and switch between them.
+
+With -i the chat stays open as an interactive session with line editing, history
+and slash commands; type /help inside it for the list.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			flags := cmd.Flags()
-			service := app.NewService()
+			service := newService()
 			execConfig := config
 			device, err := resolveDevice(flags, execConfig)
 			if err != nil {
 				return err
 			}
 
+			if historyLimit < 0 {
+				return fmt.Errorf("--history-limit must not be negative")
+			}
+			if err := checkChatBranchFlags(editID, regenerate, newSession, interactive); err != nil {
+				return err
+			}
+
 			streamFlag, _ := flags.GetBool("stream")
 			plainFlag, _ := flags.GetBool("plain")
 			memoryFlag, _ := flags.GetBool("memory")
@@ -53,7 +91,7 @@ The conversation history is persisted between calls. Use --new to start a fresh
 			// Concatenate all remaining args to form the message
 			userMessage := strings.Join(args, " ")
 
-			result, err := service.Chat(ctx, app.ChatRequest{
+			req := app.ChatRequest{
 				Message:        userMessage,
 				Provider:       *provider,
 				Model:          *modelID,
@@ -65,8 +103,19 @@ The conversation history is persisted between calls. Use --new to start a fresh
 				Device:         device,
 				NewSession:     newSession,
 				ChatDBPath:     getChatDBPath(),
+				HistoryLimit:   historyLimit,
+				EditMessageID:  editID,
+				Regenerate:     regenerate,
 				Config:         execConfig,
-			})
+			}
+			if interactive {
+				return newChatREPL(cmd, service, execConfig, req, plainFlag).run(userMessage)
+			}
+			if strings.TrimSpace(userMessage) == chatCompactCommand {
+				return runChatCompact(cmd, service, req)
+			}
+
+			result, err := service.Chat(ctx, req)
 			if err != nil {
 				handleError(err)
 				return nil
@@ -105,6 +154,50 @@ The conversation history is persisted between calls. Use --new to start a fresh
 	cmd.Flags().BoolP("memory", "M", false, "Include memory in the system prompt")
 	cmd.Flags().BoolVarP(&newSession, "new", "n", false, "Start a new chat session")
 	cmd.Flags().StringArrayVarP(&contextFiles, "context", "c", []string{}, "Include file content as context (can be used multiple times)")
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Keep the chat open as an interactive session with slash commands")
+	cmd.Flags().Int64Var(&editID, "edit", 0, "Replace your earlier message with this ID, on a new branch")
+	cmd.Flags().BoolVar(&regenerate, "regenerate", false, "Ask again for the last reply, on a new branch")
+	cmd.Flags().IntVar(&historyLimit, "history-limit", 0, "Most tokens of past messages to send; older ones are summarised (0 = model default)")
+
+	cmd.AddCommand(newChatSessionsCommand())
 
 	return cmd
 }
+
+// checkChatBranchFlags rejects the flag combinations --edit and --regenerate
+// cannot honour: both pick a message of an existing session to branch from.
+func checkChatBranchFlags(editID int64, regenerate, newSession, interactive bool) error {
+	if editID < 0 {
+		return fmt.Errorf("invalid message id %d", editID)
+	}
+	if editID == 0 && !regenerate {
+		return nil
+	}
+	switch {
+	case editID != 0 && regenerate:
+		return fmt.Errorf("--edit and --regenerate cannot be combined")
+	case newSession:
+		return fmt.Errorf("--edit and --regenerate branch an existing session and cannot be combined with --new")
+	case interactive:
+		return fmt.Errorf("--edit and --regenerate cannot be combined with -i; use /regenerate inside the session")
+	}
+	return nil
+}
+
+// chatCompactCommand, sent as the message, folds the session into its summary
+// instead of asking the model.
+const chatCompactCommand = "/compact"
+
+func runChatCompact(cmd *cobra.Command, service app.Service, req app.ChatRequest) error {
+	result, err := service.CompactChat(cmd.Context(), req)
+	if err != nil {
+		handleError(err)
+		return nil
+	}
+	if result.Folded == 0 {
+		cmd.Printf("Nothing to compact in session #%d.\n", result.SessionID)
+		return nil
+	}
+	cmd.Printf("Compacted %d messages of session #%d into its summary (~%d tokens).\n", result.Folded, result.SessionID, result.SummaryTokens)
+	return nil
+}
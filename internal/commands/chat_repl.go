@@ -0,0 +1,522 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/laszukdawid/terminal-agent/internal/app"
+	"github.com/laszukdawid/terminal-agent/internal/chat"
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	chatREPLPrompt         = "you> "
+	chatREPLContinuePrompt = "...> "
+	// chatREPLBlockDelimiter opens and closes a multi-line message.
+	chatREPLBlockDelimiter = `"""`
+)
+
+const chatREPLHelp = `Type a message and press Enter. End a line with \ to continue it, or wrap
+several lines in """ ... """. Pasted text keeps its line breaks.
+Ctrl-C cancels the reply being written; at the prompt, Ctrl-C or Ctrl-D leaves.
+
+  /model [id]              Show or set the model
+  /provider [name [model]] Show or set the provider (model resets to its default)
+  /new                     Start a new session with the next message
+  /context <file...>       Attach files to the next message
+  /task <prompt>           Hand the prompt and this conversation to the task agent
+  /regenerate              Ask for the last reply again, keeping the old one on a branch
+  /compact                 Fold the session so far into its summary
+  /save [file]             Save the session as markdown (default: chat-<id>.md)
+  /help                    Show this help
+  /exit                    Leave`
+
+// errChatInputDiscarded is returned by readMessage when input ends in the
+// middle of a multi-line message.
+var errChatInputDiscarded = errors.New("input discarded")
+
+// chatLineReader reads one line of REPL input. pasted reports a line that is
+// part of a multi-line paste, with more lines to follow.
+type chatLineReader interface {
+	ReadLine(prompt string) (line string, pasted bool, err error)
+}
+
+// terminalLineReader edits lines in raw mode, with history on the arrow keys.
+// The terminal is only raw while a line is read, so replies print normally
+// and Ctrl-C raises SIGINT while they do.
+type terminalLineReader struct {
+	fd   int
+	term *term.Terminal
+}
+
+func newTerminalLineReader(in *os.File, out io.Writer) *terminalLineReader {
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, "")
+	return &terminalLineReader{fd: int(in.Fd()), term: t}
+}
+
+func (r *terminalLineReader) ReadLine(prompt string) (string, bool, error) {
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", false, err
+	}
+	defer term.Restore(r.fd, state)
+	if width, height, err := term.GetSize(r.fd); err == nil {
+		r.term.SetSize(width, height)
+	}
+	r.term.SetBracketedPasteMode(true)
+	defer r.term.SetBracketedPasteMode(false)
+
+	r.term.SetPrompt(prompt)
+	line, err := r.term.ReadLine()
+	if errors.Is(err, term.ErrPasteIndicator) {
+		return line, true, nil
+	}
+	return line, false, err
+}
+
+// plainLineReader reads lines from a pipe or file.
+type plainLineReader struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
+
+func (r *plainLineReader) ReadLine(prompt string) (string, bool, error) {
+	fmt.Fprint(r.out, prompt)
+	line, err := r.reader.ReadString('\n')
+	if errors.Is(err, io.EOF) && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), false, err
+}
+
+// chatREPL is an interactive chat session: `agent chat -i`.
+type chatREPL struct {
+	cmd     *cobra.Command
+	service app.Service
+	cfg     config.Config
+	// req holds the settings for the next message; slash commands change it.
+	req   app.ChatRequest
+	in    chatLineReader
+	input *bufio.Reader // answers to task confirmations and clarifications
+	plain bool
+	// attached are files sent with the next message, from /context.
+	attached []string
+}
+
+func newChatREPL(cmd *cobra.Command, service app.Service, cfg config.Config, req app.ChatRequest, plain bool) *chatREPL {
+	r := &chatREPL{cmd: cmd, service: service, cfg: cfg, req: req, attached: req.ContextFiles}
+	r.req.ContextFiles = nil
+	r.req.Stream = true
+
+	stdin, stdinOk := cmd.InOrStdin().(*os.File)
+	if stdinOk && term.IsTerminal(int(stdin.Fd())) && isTerminalWriter(cmd.OutOrStdout()) {
+		r.in = newTerminalLineReader(stdin, cmd.OutOrStdout())
+		r.input = bufio.NewReader(stdin)
+		r.plain = plain
+		return r
+	}
+	r.input = bufio.NewReader(cmd.InOrStdin())
+	r.in = &plainLineReader{reader: r.input, out: cmd.OutOrStdout()}
+	r.plain = true
+	return r
+}
+
+// run reads and answers messages until /exit or the end of input. A message
+// given on the command line is sent first.
+func (r *chatREPL) run(first string) error {
+	r.cmd.Printf("Chatting with %s. /help lists commands, /exit leaves.\n", r.modelName())
+	if strings.TrimSpace(first) != "" {
+		r.send(first)
+	}
+	for {
+		message, err := r.readMessage()
+		switch {
+		case errors.Is(err, io.EOF):
+			r.cmd.Println()
+			return nil
+		case errors.Is(err, errChatInputDiscarded):
+			r.cmd.Println("(input discarded)")
+			continue
+		case err != nil:
+			return err
+		}
+
+		trimmed := strings.TrimSpace(message)
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "/"):
+			if quit := r.runCommand(trimmed); quit {
+				return nil
+			}
+		default:
+			r.send(message)
+		}
+	}
+}
+
+// readMessage reads one message, joining continued lines, """ blocks and
+// pasted lines.
+func (r *chatREPL) readMessage() (string, error) {
+	var lines []string
+	prompt := chatREPLPrompt
+	block := false
+	for {
+		line, pasted, err := r.in.ReadLine(prompt)
+		if err != nil {
+			if errors.Is(err, io.EOF) && (len(lines) > 0 || block) {
+				return "", errChatInputDiscarded
+			}
+			return "", err
+		}
+		prompt = chatREPLContinuePrompt
+		switch {
+		case pasted:
+			lines = append(lines, line)
+			continue
+		case block:
+			if strings.TrimSpace(line) == chatREPLBlockDelimiter {
+				return strings.Join(lines, "\n"), nil
+			}
+			lines = append(lines, line)
+			continue
+		case len(lines) == 0 && strings.TrimSpace(line) == chatREPLBlockDelimiter:
+			block = true
+			continue
+		case strings.HasSuffix(line, `\`):
+			lines = append(lines, strings.TrimSuffix(line, `\`))
+			continue
+		}
+		lines = append(lines, line)
+		return strings.Join(lines, "\n"), nil
+	}
+}
+
+// interruptible returns a context cancelled by Ctrl-C, so an interrupt stops
+// the current reply or task instead of the REPL.
+func (r *chatREPL) interruptible() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(r.cmd.Context(), os.Interrupt)
+}
+
+func (r *chatREPL) send(message string) {
+	req := r.req
+	req.Message = message
+	req.ContextFiles = r.attached
+	if r.reply(req) {
+		r.req.NewSession = false
+		r.attached = nil
+	}
+}
+
+// regenerate asks again for the last reply, keeping the old one on its own
+// branch.
+func (r *chatREPL) regenerate() {
+	if r.req.NewSession {
+		r.cmd.PrintErrln("There is no reply to regenerate before the new session's first message.")
+		return
+	}
+	req := r.req
+	req.Regenerate = true
+	r.reply(req)
+}
+
+// reply streams the answer to req and reports whether it was requested.
+func (r *chatREPL) reply(req app.ChatRequest) bool {
+	ctx, stop := r.interruptible()
+	defer stop()
+
+	events, err := r.service.ChatEvents(ctx, req)
+	if err != nil {
+		r.cmd.PrintErrf("Error: %v\n", err)
+		return false
+	}
+
+	var renderer *connector.MarkdownStreamRenderer
+	if !r.plain {
+		renderer, _ = connector.NewMarkdownStreamRenderer()
+	}
+	streamed := false
+	print := func(text string) {
+		if renderer != nil {
+			renderer.ProcessChunk(text)
+		} else {
+			r.cmd.Print(text)
+		}
+	}
+	for event := range events {
+		switch event.Type {
+		case app.EventOutputDelta:
+			streamed = true
+			print(event.Text)
+		case app.EventCompleted:
+			// Not every connector streams; print the whole reply then.
+			if !streamed {
+				print(event.FinalOutput)
+			}
+			if renderer != nil {
+				renderer.Flush()
+			}
+			r.cmd.Print("\n\n")
+		case app.EventFailed:
+			if renderer != nil {
+				renderer.Flush()
+			}
+			if ctx.Err() != nil {
+				r.cmd.Println("\n(reply cancelled)")
+			} else {
+				r.cmd.PrintErrf("\nError: %v\n", event.Err)
+			}
+		}
+	}
+	return true
+}
+
+// runCommand runs a slash command and reports whether the REPL should exit.
+func (r *chatREPL) runCommand(line string) bool {
+	name, rest, _ := strings.Cut(line, " ")
+	args := strings.Fields(rest)
+	switch name {
+	case "/exit", "/quit":
+		return true
+	case "/help":
+		r.cmd.Println(chatREPLHelp)
+	case "/model":
+		if len(args) > 0 {
+			r.req.Model = args[0]
+		}
+		r.cmd.Printf("Model: %s\n", r.modelName())
+	case "/provider":
+		if len(args) > 0 {
+			if err := r.setProvider(args[0], args[1:]); err != nil {
+				r.cmd.PrintErrf("Error: %v\n", err)
+				return false
+			}
+		}
+		r.cmd.Printf("Model: %s\n", r.modelName())
+	case "/new":
+		r.req.NewSession = true
+		r.cmd.Println("The next message starts a new session.")
+	case "/context":
+		r.attach(args)
+	case "/task":
+		r.runTask(strings.TrimSpace(rest))
+	case "/regenerate":
+		r.regenerate()
+	case "/compact":
+		r.compact()
+	case "/save":
+		r.save(strings.TrimSpace(rest))
+	default:
+		r.cmd.PrintErrf("Unknown command %s; /help lists the commands.\n", name)
+	}
+	return false
+}
+
+func (r *chatREPL) modelName() string {
+	model := r.req.Model
+	if model == "" {
+		model = connector.DefaultModelFor(r.req.Provider)
+	}
+	if model == "" {
+		return r.req.Provider
+	}
+	return r.req.Provider + "/" + model
+}
+
+func (r *chatREPL) setProvider(provider string, model []string) error {
+	if !slices.Contains(connector.SupportedProviders(), provider) {
+		return fmt.Errorf("unknown provider %q (want one of %s)", provider, strings.Join(connector.SupportedProviders(), ", "))
+	}
+	if err := config.CheckProviderAllowed(provider); err != nil {
+		return err
+	}
+	r.req.Provider = provider
+	r.req.Model = connector.DefaultModelFor(provider)
+	if len(model) > 0 {
+		r.req.Model = model[0]
+	}
+	return nil
+}
+
+func (r *chatREPL) attach(files []string) {
+	if len(files) == 0 {
+		if len(r.attached) == 0 {
+			r.cmd.Println("Usage: /context <file...>")
+			return
+		}
+		r.cmd.Printf("Attached to the next message: %s\n", strings.Join(r.attached, ", "))
+		return
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			r.cmd.PrintErrf("Error: %v\n", err)
+			return
+		}
+		if info.IsDir() {
+			r.cmd.PrintErrf("Error: %s is a directory\n", file)
+			return
+		}
+	}
+	r.attached = append(r.attached, files...)
+	r.cmd.Printf("Attached to the next message: %s\n", strings.Join(r.attached, ", "))
+}
+
+// runTask hands the prompt to the task agent with the conversation as
+// background, then records the exchange in the session so the chat can refer
+// to what the task did.
+func (r *chatREPL) runTask(prompt string) {
+	if prompt == "" {
+		r.cmd.Println("Usage: /task <prompt>")
+		return
+	}
+	ctx, stop := r.interruptible()
+	defer stop()
+
+	background := ""
+	if !r.req.NewSession {
+		var err error
+		if background, err = app.ChatTaskBackground(r.req); err != nil {
+			r.cmd.PrintErrf("Error: %v\n", err)
+			return
+		}
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		r.cmd.PrintErrf("Error: %v\n", err)
+		return
+	}
+	events, err := r.service.TaskEvents(ctx, app.TaskRequest{
+		Message:    prompt,
+		Background: background,
+		Provider:   r.req.Provider,
+		Model:      r.req.Model,
+		WorkingDir: workingDir,
+		Device:     r.req.Device,
+		Timeout:    r.cfg.GetTaskTimeout(),
+		Config:     r.cfg,
+	})
+	if err != nil {
+		r.cmd.PrintErrf("Error: failed to request a task: %v\n", err)
+		return
+	}
+	result, _, err := printTaskEvents(r.cmd, events, r.input, taskOutputOptions{
+		print:           true,
+		plain:           r.plain,
+		progress:        "auto",
+		liveOutputLimit: r.cfg.GetTaskLiveOutputLimit(),
+	})
+	r.cmd.Println()
+	if err != nil {
+		if ctx.Err() != nil {
+			r.cmd.Println("(task cancelled)")
+			return
+		}
+		r.cmd.PrintErrf("Error: %v\n", err)
+		return
+	}
+
+	if err := r.recordTask(prompt, result.Response); err != nil {
+		r.cmd.PrintErrf("Warning: the task is not in the chat history: %v\n", err)
+	}
+}
+
+func (r *chatREPL) recordTask(prompt, response string) error {
+	store, err := chat.NewSessionStore(r.req.ChatDBPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if r.req.NewSession {
+		if _, err := store.NewSession(); err != nil {
+			return err
+		}
+		r.req.NewSession = false
+	} else if _, err := store.GetOrCreateSession(); err != nil {
+		return err
+	}
+	if strings.TrimSpace(response) == "" {
+		response = "(the task finished without a summary)"
+	}
+	if err := store.AddMessage("user", "/task "+prompt); err != nil {
+		return err
+	}
+	if err := store.SetTitleIfEmpty(chat.TitleFromMessage(prompt)); err != nil {
+		return err
+	}
+	return store.AddMessage("assistant", response)
+}
+
+func (r *chatREPL) compact() {
+	ctx, stop := r.interruptible()
+	defer stop()
+	result, err := r.service.CompactChat(ctx, r.req)
+	if err != nil {
+		r.cmd.PrintErrf("Error: %v\n", err)
+		return
+	}
+	if result.Folded == 0 {
+		r.cmd.Println("Nothing to compact.")
+		return
+	}
+	r.cmd.Printf("Compacted %d messages into the session summary (~%d tokens).\n", result.Folded, result.SummaryTokens)
+}
+
+// save writes the current session to a markdown file.
+func (r *chatREPL) save(path string) {
+	store, err := chat.NewSessionStore(r.req.ChatDBPath)
+	if err != nil {
+		r.cmd.PrintErrf("Error: %v\n", err)
+		return
+	}
+	defer store.Close()
+	if store.CurrentSessionID() == 0 || r.req.NewSession {
+		r.cmd.Println("Nothing to save yet.")
+		return
+	}
+	session, err := store.GetSession(store.CurrentSessionID())
+	if err != nil {
+		r.cmd.PrintErrf("Error: %v\n", err)
+		return
+	}
+	messages, err := store.SessionMessages(session.ID)
+	if err != nil {
+		r.cmd.PrintErrf("Error: %v\n", err)
+		return
+	}
+	if path == "" {
+		path = fmt.Sprintf("chat-%d.md", session.ID)
+	}
+	if err := os.WriteFile(path, []byte(formatChatMarkdown(session, messages)), 0o600); err != nil {
+		r.cmd.PrintErrf("Error: %v\n", err)
+		return
+	}
+	r.cmd.Printf("Saved session #%d to %s\n", session.ID, path)
+}
+
+func formatChatMarkdown(session chat.Session, messages []chat.Message) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %s\n\n", sessionTitle(session))
+	fmt.Fprintf(&out, "Session #%d, %s, %s\n", session.ID, sessionModel(session), formatRoutineTime(session.CreatedAt))
+	for _, msg := range messages {
+		role := "User"
+		if msg.Role == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&out, "\n## %s\n\n%s\n", role, strings.TrimSpace(msg.Content))
+	}
+	return out.String()
+}
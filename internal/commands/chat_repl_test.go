@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/laszukdawid/terminal-agent/internal/app"
+	"github.com/laszukdawid/terminal-agent/internal/chat"
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChatService answers every chat message with "reply" and records the
+// requests it was sent.
+type fakeChatService struct {
+	fakeTaskService
+	requests []app.ChatRequest
+}
+
+func (s *fakeChatService) ChatEvents(_ context.Context, req app.ChatRequest) (<-chan app.Event, error) {
+	s.requests = append(s.requests, req)
+	ch := make(chan app.Event, 2)
+	ch <- app.Event{Type: app.EventOutputDelta, Text: "reply"}
+	ch <- app.Event{Type: app.EventCompleted, FinalOutput: "reply"}
+	close(ch)
+	return ch, nil
+}
+
+func runChatREPL(t *testing.T, service app.Service, input string, args ...string) string {
+	t.Helper()
+	originalNewService := newService
+	t.Cleanup(func() { newService = originalNewService })
+	newService = func() app.Service { return service }
+
+	cmd := NewChatCommand(config.NewDefaultConfig())
+	cmd.Flags().String("device", "", "")
+	var output bytes.Buffer
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetOut(&output)
+	cmd.SetErr(&output)
+	cmd.SetArgs(append([]string{"-i", "-p", "openai"}, args...))
+	require.NoError(t, cmd.Execute())
+	return output.String()
+}
+
+func TestChatREPLReadsMessagesAndSlashCommands(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	attachment := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(attachment, []byte("notes"), 0o600))
+
+	service := &fakeChatService{}
+	output := runChatREPL(t, service, strings.Join([]string{
+		`hello \`,
+		`world`,
+		`"""`,
+		`first line`,
+		`second line`,
+		`"""`,
+		`/model gpt-test`,
+		`/provider nope`,
+		`/provider ollama`,
+		`/context ` + attachment,
+		`with a file`,
+		`/new`,
+		`/regenerate`,
+		`fresh start`,
+		`/regenerate`,
+		`/bogus`,
+		`/exit`,
+		`never sent`,
+	}, "\n"), "opening message")
+
+	require.Len(t, service.requests, 6)
+	assert.Equal(t, "opening message", service.requests[0].Message)
+	assert.True(t, service.requests[0].Stream)
+	assert.Equal(t, "hello \nworld", service.requests[1].Message)
+	assert.Equal(t, "first line\nsecond line", service.requests[2].Message)
+
+	assert.Equal(t, "with a file", service.requests[3].Message)
+	assert.Equal(t, "ollama", service.requests[3].Provider)
+	assert.NotEqual(t, "gpt-test", service.requests[3].Model)
+	assert.Equal(t, []string{attachment}, service.requests[3].ContextFiles)
+	assert.False(t, service.requests[3].NewSession)
+
+	assert.Equal(t, "fresh start", service.requests[4].Message)
+	assert.True(t, service.requests[4].NewSession)
+	assert.Empty(t, service.requests[4].ContextFiles)
+	assert.False(t, service.requests[4].Regenerate)
+
+	// /regenerate waits for the new session's first message
+	assert.True(t, service.requests[5].Regenerate)
+	assert.False(t, service.requests[5].NewSession)
+	assert.Contains(t, output, "There is no reply to regenerate")
+
+	assert.Contains(t, output, "Model: openai/gpt-test")
+	assert.Contains(t, output, `unknown provider "nope"`)
+	assert.Contains(t, output, "Unknown command /bogus")
+	assert.Equal(t, 6, strings.Count(output, "reply\n"))
+}
+
+func TestChatREPLHandsTaskOffWithHistoryAndSaves(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	store, err := chat.NewSessionStore(getChatDBPath())
+	require.NoError(t, err)
+	_, err = store.NewSession()
+	require.NoError(t, err)
+	require.NoError(t, store.AddMessage("user", "the build fails on main"))
+	require.NoError(t, store.SetTitleIfEmpty("Broken build"))
+	require.NoError(t, store.AddMessage("assistant", "run go vet first"))
+	require.NoError(t, store.Close())
+
+	var taskReq app.TaskRequest
+	service := &fakeChatService{fakeTaskService: fakeTaskService{events: func(_ context.Context, req app.TaskRequest) (<-chan app.Event, error) {
+		taskReq = req
+		ch := make(chan app.Event, 1)
+		ch <- app.Event{Type: app.EventCompleted, FinalOutput: "fixed the build"}
+		close(ch)
+		return ch, nil
+	}}}
+	saved := filepath.Join(home, "saved.md")
+	output := runChatREPL(t, service, "/task fix it\n/save "+saved+"\n")
+
+	assert.Equal(t, "fix it", taskReq.Message)
+	assert.Contains(t, taskReq.Background, "[user]\nthe build fails on main")
+	assert.Contains(t, taskReq.Background, "[assistant]\nrun go vet first")
+	assert.Contains(t, output, "fixed the build")
+
+	content, err := os.ReadFile(saved)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "# Broken build")
+	assert.Contains(t, string(content), "## User\n\n/task fix it\n\n## Assistant\n\nfixed the build\n")
+}
@@ -0,0 +1,375 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/laszukdawid/terminal-agent/internal/chat"
+	"github.com/spf13/cobra"
+)
+
+// sessionTitleWidth bounds titles in the session list, in runes.
+const sessionTitleWidth = 50
+
+func newChatSessionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "List, switch between, rename, delete and search chat sessions",
+		Long: `Manage stored chat sessions.
+
+Sessions are titled automatically from their first message. The current
+session is the one ` + "`agent chat`" + ` continues; switch to resume an older one.
+
+Editing a message or regenerating a reply starts a branch in the session.
+branches lists them, and checkout continues from any message, which also forks
+a conversation from its midpoint.`,
+	}
+	cmd.AddCommand(
+		chatSessionsListCommand(),
+		chatSessionsShowCommand(),
+		chatSessionsSwitchCommand(),
+		chatSessionsRenameCommand(),
+		chatSessionsDeleteCommand(),
+		chatSessionsSearchCommand(),
+		chatSessionsBranchesCommand(),
+		chatSessionsCheckoutCommand(),
+	)
+	return cmd
+}
+
+// withSessionStore opens the chat database for the duration of fn.
+func withSessionStore(fn func(store *chat.SessionStore) error) error {
+	store, err := chat.NewSessionStore(getChatDBPath())
+	if err != nil {
+		return fmt.Errorf("failed to open chat sessions: %w", err)
+	}
+	defer store.Close()
+	return fn(store)
+}
+
+func parseSessionID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid session id %q", arg)
+	}
+	return id, nil
+}
+
+func chatSessionsListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "list",
+		Short:        "List chat sessions, most recent first",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withSessionStore(func(store *chat.SessionStore) error {
+				sessions, err := store.ListSessions()
+				if err != nil {
+					return err
+				}
+				if len(sessions) == 0 {
+					cmd.Println("No chat sessions yet. Start one with `agent chat`.")
+					return nil
+				}
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "ID\tUPDATED\tMESSAGES\tMODEL\tTITLE")
+				for _, session := range sessions {
+					marker := " "
+					if session.ID == store.CurrentSessionID() {
+						marker = "*"
+					}
+					fmt.Fprintf(w, "%s%d\t%s\t%d\t%s\t%s\n",
+						marker,
+						session.ID,
+						formatRoutineTime(session.UpdatedAt),
+						session.MessageCount,
+						sessionModel(session),
+						shortenTitle(sessionTitle(session)),
+					)
+				}
+				return w.Flush()
+			})
+		},
+	}
+}
+
+func chatSessionsShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "show [id]",
+		Short:        "Show the messages on a session's checked-out branch (default: the current session)",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withSessionStore(func(store *chat.SessionStore) error {
+				id := store.CurrentSessionID()
+				if len(args) == 1 {
+					var err error
+					if id, err = parseSessionID(args[0]); err != nil {
+						return err
+					}
+				}
+				if id == 0 {
+					return fmt.Errorf("no current chat session")
+				}
+				session, err := store.GetSession(id)
+				if err != nil {
+					return err
+				}
+				messages, err := store.SessionMessages(id)
+				if err != nil {
+					return err
+				}
+
+				cmd.Printf("#%d %s\n", session.ID, sessionTitle(session))
+				cmd.Printf("Model: %s  Created: %s  Updated: %s\n",
+					sessionModel(session), formatRoutineTime(session.CreatedAt), formatRoutineTime(session.UpdatedAt))
+				if session.Summary != "" {
+					cmd.Printf("\n[summary of the earlier messages, sent in their place]\n%s\n", session.Summary)
+				}
+				for _, msg := range messages {
+					cmd.Printf("\n[#%d %s]\n%s\n", msg.ID, msg.Role, strings.TrimSpace(msg.Content))
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func chatSessionsSwitchCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "switch <id>",
+		Short:        "Make a session the one `agent chat` continues",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseSessionID(args[0])
+			if err != nil {
+				return err
+			}
+			return withSessionStore(func(store *chat.SessionStore) error {
+				if err := store.SwitchSession(id); err != nil {
+					return err
+				}
+				session, err := store.GetSession(id)
+				if err != nil {
+					return err
+				}
+				cmd.Printf("Switched to session #%d: %s\n", id, sessionTitle(session))
+				return nil
+			})
+		},
+	}
+}
+
+func chatSessionsRenameCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "rename <id> <title...>",
+		Short:        "Set a session's title",
+		Args:         cobra.MinimumNArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseSessionID(args[0])
+			if err != nil {
+				return err
+			}
+			title := strings.Join(args[1:], " ")
+			if strings.TrimSpace(title) == "" {
+				return fmt.Errorf("title is empty")
+			}
+			return withSessionStore(func(store *chat.SessionStore) error {
+				if err := store.RenameSession(id, title); err != nil {
+					return err
+				}
+				cmd.Printf("Renamed session #%d\n", id)
+				return nil
+			})
+		},
+	}
+}
+
+func chatSessionsDeleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "delete <id>",
+		Short:        "Delete a session and its messages",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseSessionID(args[0])
+			if err != nil {
+				return err
+			}
+			return withSessionStore(func(store *chat.SessionStore) error {
+				current := store.CurrentSessionID() == id
+				if err := store.DeleteSession(id); err != nil {
+					return err
+				}
+				cmd.Printf("Deleted session #%d\n", id)
+				if current {
+					cmd.Println("It was the current session; the next `agent chat` starts a new one.")
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func chatSessionsSearchCommand() *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:          "search <text...>",
+		Short:        "Search messages across all sessions",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withSessionStore(func(store *chat.SessionStore) error {
+				results, err := store.SearchMessages(strings.Join(args, " "), limit)
+				if err != nil {
+					return err
+				}
+				if len(results) == 0 {
+					cmd.Println("No matching messages.")
+					return nil
+				}
+				for _, r := range results {
+					title := r.SessionTitle
+					if title == "" {
+						title = "(untitled)"
+					}
+					cmd.Printf("#%d %s  [%s] %s\n", r.SessionID, shortenTitle(title), r.Role, formatRoutineTime(r.CreatedAt))
+					cmd.Printf("    %s\n", strings.Join(strings.Fields(r.Snippet), " "))
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().IntVarP(&limit, "limit", "l", 20, "Maximum number of messages to show")
+	return cmd
+}
+
+func chatSessionsBranchesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "branches [id]",
+		Short:        "List a session's branches (default: the current session)",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withSessionStore(func(store *chat.SessionStore) error {
+				id := store.CurrentSessionID()
+				if len(args) == 1 {
+					var err error
+					if id, err = parseSessionID(args[0]); err != nil {
+						return err
+					}
+				}
+				if id == 0 {
+					return fmt.Errorf("no current chat session")
+				}
+				session, err := store.GetSession(id)
+				if err != nil {
+					return err
+				}
+				branches, err := store.Branches(id)
+				if err != nil {
+					return err
+				}
+				if len(branches) == 0 {
+					cmd.Printf("Session #%d has no messages yet.\n", id)
+					return nil
+				}
+
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "HEAD\tFORK\tMESSAGES\tLAST MESSAGE")
+				current := false
+				for _, branch := range branches {
+					marker := " "
+					if branch.Current {
+						marker, current = "*", true
+					}
+					fork := "-"
+					if branch.ForkID != 0 {
+						fork = fmt.Sprintf("#%d", branch.ForkID)
+					}
+					fmt.Fprintf(w, "%s#%d\t%s\t%d\t[%s] %s\n",
+						marker, branch.Head.ID, fork, branch.Length, branch.Head.Role, messagePreview(branch.Head))
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				// A checked-out midpoint is not the head of any branch yet.
+				if !current {
+					if session.HeadID == 0 {
+						cmd.Println("Checked out the start of the session; the next message starts a new branch.")
+					} else {
+						cmd.Printf("Checked out #%d; the next message starts a new branch.\n", session.HeadID)
+					}
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func chatSessionsCheckoutCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "checkout <message-id>",
+		Short: "Continue the conversation from a message",
+		Long: `Make the message's session current and continue from the message.
+
+Checking out the last message of a branch resumes that branch. Checking out an
+earlier message forks the conversation there: the next message starts a new
+branch and the messages that followed stay on theirs. Message IDs are shown by
+sessions show and sessions branches.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+			if err != nil || id <= 0 {
+				return fmt.Errorf("invalid message id %q", args[0])
+			}
+			return withSessionStore(func(store *chat.SessionStore) error {
+				msg, err := store.GetMessage(id)
+				if err != nil {
+					return err
+				}
+				if err := store.Checkout(msg.SessionID, msg.ID); err != nil {
+					return err
+				}
+				cmd.Printf("Checked out #%d in session #%d: [%s] %s\n", msg.ID, msg.SessionID, msg.Role, messagePreview(msg))
+				return nil
+			})
+		},
+	}
+}
+
+// messagePreview is a message's content on one line, shortened like a title.
+func messagePreview(msg chat.Message) string {
+	return shortenTitle(strings.Join(strings.Fields(msg.Content), " "))
+}
+
+func sessionTitle(session chat.Session) string {
+	if session.Title == "" {
+		return "(untitled)"
+	}
+	return session.Title
+}
+
+func sessionModel(session chat.Session) string {
+	switch {
+	case session.Provider == "" && session.Model == "":
+		return "-"
+	case session.Model == "":
+		return session.Provider
+	case session.Provider == "":
+		return session.Model
+	}
+	return session.Provider + "/" + session.Model
+}
+
+func shortenTitle(title string) string {
+	if runes := []rune(title); len(runes) > sessionTitleWidth {
+		return string(runes[:sessionTitleWidth-1]) + "…"
+	}
+	return title
+}
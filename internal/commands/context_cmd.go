@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/laszukdawid/terminal-agent/internal/agent"
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func NewContextCommand(config config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "context",
+		Short: "Inspect the project context given to tasks",
+		Long: `Inspect the project context given to tasks.
+
+Tasks read a context file (AGENTS.md, CLAUDE.md or .agentrules) from every
+directory between the repository root and the working directory, deepest last.
+An AGENTS.override.md file is read instead and replaces the context of the
+directories above it, and an "@include path" line inlines another file.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(contextShowCommand(config))
+
+	return cmd
+}
+
+func contextShowCommand(config config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:          "show [dir]",
+		Short:        "Print the merged project context of a directory and the files it comes from",
+		SilenceUsage: true,
+		Args:         cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			dir, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("invalid directory %q: %w", dir, err)
+			}
+			if _, err := os.Stat(dir); err != nil {
+				return err
+			}
+			pc, err := agent.LoadProjectContext(dir)
+			if err != nil {
+				return err
+			}
+
+			if config != nil && !config.GetProjectContext() {
+				cmd.Println("Note: project_context is disabled in the config, so tasks do not use this context.")
+			}
+			sources := pc.Sources()
+			if len(sources) == 0 {
+				cmd.Printf("No project context files found for %s.\n", dir)
+				return nil
+			}
+			cmd.Printf("Root: %s\n", pc.Root)
+			cmd.Println("Sources:")
+			for _, source := range sources {
+				line := "  " + relativeTo(pc.Root, source.Path)
+				switch {
+				case source.Override:
+					line += " (override)"
+				case source.IncludedFrom != "":
+					line += " (included from " + relativeTo(pc.Root, source.IncludedFrom) + ")"
+				}
+				cmd.Println(line)
+			}
+			cmd.Printf("\n%s\n", pc.Content())
+			return nil
+		},
+	}
+}
+
+// relativeTo shortens path to be relative to root when it lies below it.
+func relativeTo(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextShowCommand(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "svc")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0755))
+	require.NoError(t, os.MkdirAll(sub, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "AGENTS.md"), []byte("Use make.\n@include docs.md\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs.md"), []byte("Docs live in docs/."), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "AGENTS.md"), []byte("Service rules."), 0644))
+
+	cmd := contextShowCommand(config.NewDefaultConfig())
+	out := new(bytes.Buffer)
+	cmd.SetArgs([]string{sub})
+	cmd.SetOut(out)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, "Root: "+root+"\n"+
+		"Sources:\n"+
+		"  AGENTS.md\n"+
+		"  docs.md (included from AGENTS.md)\n"+
+		"  svc/AGENTS.md\n"+
+		"\n"+
+		"<!-- AGENTS.md -->\nUse make.\nDocs live in docs/.\n\n<!-- svc/AGENTS.md -->\nService rules.\n", out.String())
+}
+
+func TestContextShowCommandWithoutFiles(t *testing.T) {
+	dir := t.TempDir()
+	cmd := contextShowCommand(nil)
+	out := new(bytes.Buffer)
+	cmd.SetArgs([]string{dir})
+	cmd.SetOut(out)
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, "No project context files found for "+dir+".\n", out.String())
+}
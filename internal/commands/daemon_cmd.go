@@ -9,6 +9,8 @@ import (
 
 	"github.com/laszukdawid/terminal-agent/internal/config"
 	"github.com/laszukdawid/terminal-agent/internal/daemon"
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
 	"github.com/spf13/cobra"
 )
 
@@ -26,6 +28,10 @@ func NewDaemonCommand(cfg config.Config) *cobra.Command {
 	cmd.AddCommand(daemonStartCommand(cfg))
 	cmd.AddCommand(daemonStopCommand())
 	cmd.AddCommand(daemonStatusCommand(cfg))
+	cmd.AddCommand(daemonPauseCommand(true))
+	cmd.AddCommand(daemonPauseCommand(false))
+	cmd.AddCommand(daemonReloadCommand())
+	cmd.AddCommand(daemonTriggerCommand())
 	cmd.AddCommand(daemonServiceCommand(true))
 	cmd.AddCommand(daemonServiceCommand(false))
 	return cmd
@@ -74,16 +80,24 @@ func daemonStopCommand() *cobra.Command {
 func daemonStatusCommand(cfg config.Config) *cobra.Command {
 	return &cobra.Command{
 		Use:          "status",
-		Short:        "Show daemon status and scheduled routines",
+		Short:        "Show daemon status, scheduled routines and the run queue",
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			status, err := daemon.CurrentStatus()
 			if err != nil {
 				return err
 			}
-			if status.Running {
+			// A running daemon reports its own view; the files are the fallback.
+			live, liveErr := daemon.NewClient().Status(cmd.Context())
+			switch {
+			case liveErr == nil:
+				cmd.Printf("Daemon: running (pid %d, since %s)\n", live.PID, live.StartedAt.Local().Format("2006-01-02 15:04"))
+				if live.Paused {
+					cmd.Println("Run queue: paused (resume with `agent daemon resume`)")
+				}
+			case status.Running:
 				cmd.Printf("Daemon: running (pid %d)\n", status.PID)
-			} else {
+			default:
 				cmd.Println("Daemon: stopped")
 			}
 			if !cfg.GetRoutinesEnabled() {
@@ -94,9 +108,12 @@ func daemonStatusCommand(cfg config.Config) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			scheduled := 0
+			scheduled, triggered := 0, 0
 			var nextFire time.Time
 			for _, v := range views {
+				if v.Routine.Enabled && len(v.Routine.Triggers) > 0 {
+					triggered++
+				}
 				if !v.Routine.Enabled || strings.TrimSpace(v.Routine.Schedule) == "" {
 					continue
 				}
@@ -106,14 +123,183 @@ func daemonStatusCommand(cfg config.Config) *cobra.Command {
 				}
 			}
 			cmd.Printf("Scheduled routines: %d\n", scheduled)
+			if triggered > 0 {
+				cmd.Printf("Event-triggered routines: %d\n", triggered)
+			}
+			if liveErr == nil {
+				for _, s := range live.Scheduled {
+					cmd.Printf("  %-20s next %s\n", s.ID, s.NextRunAt.Local().Format("2006-01-02 15:04"))
+				}
+				if len(live.Runs) > 0 {
+					cmd.Printf("\nQueue (%d):\n", len(live.Runs))
+				}
+				for _, run := range live.Runs {
+					line := formatQueuedRun(run.QueuedRun)
+					if run.LastEvent != nil {
+						line += "; last: " + describeRunEvent(*run.LastEvent)
+					}
+					cmd.Printf("  %s\n", line)
+				}
+				return nil
+			}
 			if !nextFire.IsZero() {
 				cmd.Printf("Next fire: %s\n", nextFire.Local().Format("2006-01-02 15:04"))
 			}
+
+			queue, err := routines.DefaultQueueStore().List()
+			if err != nil {
+				return err
+			}
+			if len(queue) > 0 {
+				cmd.Printf("\nQueue (%d):\n", len(queue))
+				for _, run := range queue {
+					cmd.Printf("  %s\n", formatQueuedRun(run))
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func daemonPauseCommand(pause bool) *cobra.Command {
+	use, short := "resume", "Let the daemon start queued runs again"
+	if pause {
+		use, short = "pause", "Hold the daemon's run queue; fired runs are queued but none start"
+	}
+	return &cobra.Command{
+		Use:          use,
+		Short:        short,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			status, err := daemon.NewClient().SetPaused(cmd.Context(), pause)
+			if err != nil {
+				return err
+			}
+			if status.Paused {
+				cmd.Println("Run queue paused.")
+			} else {
+				cmd.Println("Run queue resumed.")
+			}
 			return nil
 		},
 	}
 }
 
+func daemonReloadCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "reload",
+		Short:        "Make the daemon re-read the routine definitions and config",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			status, err := daemon.NewClient().Reload(cmd.Context())
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Reloaded; %d routines scheduled.\n", len(status.Scheduled))
+			return nil
+		},
+	}
+}
+
+func daemonTriggerCommand() *cobra.Command {
+	var paramFlag []string
+	var follow bool
+	cmd := &cobra.Command{
+		Use:          "trigger <id>",
+		Short:        "Queue a run of a routine in the daemon",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params := map[string]string{}
+			for _, spec := range paramFlag {
+				name, value, err := routines.ParseParam(spec)
+				if err != nil {
+					return fmt.Errorf("invalid --param: %w", err)
+				}
+				params[name] = value
+			}
+			client := daemon.NewClient()
+			run, err := client.Run(cmd.Context(), args[0], params)
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Queued %s (run %s).\n", run.RoutineID, run.ID)
+			if !follow {
+				return nil
+			}
+			return followRun(cmd, client, run)
+		},
+	}
+	cmd.Flags().StringArrayVar(&paramFlag, "param", nil, "set a declared prompt parameter as name=value (repeatable)")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "wait for the run to start and print its progress until it finishes")
+	return cmd
+}
+
+// followRun waits for a queued run to start, then prints its session log
+// events until it finishes.
+func followRun(cmd *cobra.Command, client *daemon.Client, run routines.QueuedRun) error {
+	for started := false; !started; {
+		status, err := client.Status(cmd.Context())
+		if err != nil {
+			return err
+		}
+		queued := false
+		for _, r := range status.Runs {
+			if r.ID == run.ID {
+				queued, started = true, r.Running()
+			}
+		}
+		if !queued {
+			return nil // finished between polls
+		}
+		if !started {
+			select {
+			case <-cmd.Context().Done():
+				return cmd.Context().Err()
+			case <-time.After(time.Second):
+			}
+		}
+	}
+	return client.Events(cmd.Context(), run.RoutineID, func(rec sessionlog.Record) {
+		cmd.Printf("%s  %s\n", rec.Timestamp.Local().Format("15:04:05"), describeRunEvent(rec))
+	})
+}
+
+// describeRunEvent renders a session log record as a one-line progress event.
+func describeRunEvent(rec sessionlog.Record) string {
+	text := string(rec.Type)
+	switch {
+	case rec.ToolName != "":
+		text += " " + rec.ToolName
+	case rec.StepID != "":
+		text += " " + rec.StepID + " " + rec.Status
+	case rec.Error != "":
+		text += ": " + rec.Error
+	case rec.Status != "":
+		text += " " + rec.Status
+	}
+	return strings.TrimSpace(text)
+}
+
+// formatQueuedRun renders one entry of the daemon's run queue.
+func formatQueuedRun(run routines.QueuedRun) string {
+	state := "queued " + run.EnqueuedAt.Local().Format("15:04")
+	if run.Running() {
+		state = "running since " + run.StartedAt.Local().Format("15:04")
+	}
+	line := fmt.Sprintf("%-20s %-10s %s", run.RoutineID, run.Trigger, state)
+	if run.Provider != "" {
+		line += ", provider " + run.Provider
+	}
+	if run.Priority != 0 {
+		line += fmt.Sprintf(", priority %d", run.Priority)
+	}
+	if !run.MissedAt.IsZero() {
+		line += ", catching up " + run.MissedAt.Local().Format("2006-01-02 15:04")
+	}
+	return line
+}
+
 func daemonServiceCommand(install bool) *cobra.Command {
 	use := "uninstall"
 	short := "Remove the daemon from the OS service manager"
@@ -1,9 +1,17 @@
 package commands
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
 	"github.com/laszukdawid/terminal-agent/internal/app"
 	"github.com/laszukdawid/terminal-agent/internal/config"
 	"github.com/laszukdawid/terminal-agent/internal/history"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
 	"github.com/spf13/cobra"
 )
 
@@ -11,44 +19,313 @@ func getLogPath() string {
 	return app.LogPath()
 }
 
+// newRedactedHistory opens the interaction log with secrets masked in logged
+// queries and answers, unless redaction is disabled in the config.
+func newRedactedHistory() (history.History, error) {
+	redactor, err := redact.Load()
+	if err != nil {
+		return nil, err
+	}
+	return history.NewHistoryWithRedactor(getLogPath(), redactor), nil
+}
+
+// historySources is where the history subcommands read runs from; tests
+// point it at temporary directories.
+var historySources = app.DefaultHistorySources
+
+// withHistory opens the unified history, brought up to date, for the duration
+// of fn.
+func withHistory(fn func(store *history.Store) error) error {
+	store, err := app.OpenHistory(historySources())
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer store.Close()
+	return fn(store)
+}
+
 func NewHistoryCommand(config config.Config) *cobra.Command {
-	hClient := history.NewHistory(getLogPath())
+	var limit int
 
 	cmd := &cobra.Command{
-		Use:   "history",
-		Short: "Query interaction history",
-		Long: `Query interaction history
+		Use:          "history",
+		Short:        "Query interaction history",
+		SilenceUsage: true,
+		Long: `Query the history of ask, chat, task and routine runs.
 
-		When using --after and --before flags, the date can be in any of these formats:
-		YYYY, YYYY-MM-DD,  HH:MM:SS, YYYY-MM-DDTHH:MM:SS, YYYY-MM-DDTHH:MM:SSZ
+The history gathers every run from the per-run session logs, the routines' run
+logs, the chat sessions and the interaction log written with --log, and indexes
+their transcripts for full-text search.
 
-		When specifying only time it's implicitly today. When specifying only date it's implicitly midnight.
-		Incorrect time values will be ignored.
+Without a subcommand it lists the most recent runs, or the ones matching the
+remaining arguments. When using --after and --before flags, the date can be in
+any of these formats:
+YYYY, YYYY-MM-DD,  HH:MM:SS, YYYY-MM-DDTHH:MM:SS, YYYY-MM-DDTHH:MM:SSZ
 
-		Any remaining argument that isn't captured by the flags will be concatenated to form the query.`,
+When specifying only time it's implicitly today. When specifying only date it's implicitly midnight.
+Incorrect time values will be ignored.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-
 			flags := cmd.Flags()
 			afterStr, _ := flags.GetString("after")
 			beforeStr, _ := flags.GetString("before")
 
-			query := history.HistoryQuery{
-				AfterStr:  afterStr,
-				BeforeStr: beforeStr,
+			query := history.SearchQuery{Text: strings.Join(args, " "), Limit: limit}
+			if after, err := history.ParseSince(afterStr, time.Now()); afterStr != "" && err == nil {
+				query.Since = after
 			}
-			if logs, err := hClient.Query(query); err == nil {
-				for _, log := range logs {
-					cmd.Println(log)
-				}
-			} else {
-				return err
+			if before, err := history.ParseSince(beforeStr, time.Now()); beforeStr != "" && err == nil {
+				query.Until = before
 			}
-			return nil
+			return withHistory(func(store *history.Store) error {
+				results, err := store.Search(query)
+				if err != nil {
+					return err
+				}
+				printHistoryRuns(cmd.OutOrStdout(), results)
+				return nil
+			})
 		},
 	}
 
 	cmd.Flags().String("after", "", "Filter logs after this date")
 	cmd.Flags().String("before", "", "Filter logs before this date")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Maximum number of runs to list")
+
+	cmd.AddCommand(historySearchCommand())
+	cmd.AddCommand(historyShowCommand())
+	cmd.AddCommand(historyRerunCommand(config))
+
+	return cmd
+}
+
+func historySearchCommand() *cobra.Command {
+	var query history.SearchQuery
+	var since string
 
+	cmd := &cobra.Command{
+		Use:   "search [text...]",
+		Short: "Search runs by transcript text, kind, provider, status and age",
+		Long: `Search runs by the text of their transcripts: requests, answers, tool calls,
+tool output and errors. Every word must match. The filters narrow the results
+further, and can be used without any text.
+
+For example:
+  agent history search "docker compose" --kind task --provider bedrock --since 7d --status failed
+  agent history search --kind routine --status failed`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query.Text = strings.Join(args, " ")
+			if since != "" {
+				var err error
+				if query.Since, err = history.ParseSince(since, time.Now()); err != nil {
+					return err
+				}
+			}
+			return withHistory(func(store *history.Store) error {
+				results, err := store.Search(query)
+				if err != nil {
+					return err
+				}
+				printHistoryRuns(cmd.OutOrStdout(), results)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&query.Kind, "kind", "", "Only runs of this kind: ask, chat, task or routine")
+	cmd.Flags().StringVarP(&query.Provider, "provider", "p", "", "Only runs using this provider")
+	cmd.Flags().StringVar(&query.Status, "status", "", "Only runs with this status: completed, failed or incomplete")
+	cmd.Flags().StringVar(&since, "since", "", "Only runs started since a duration ago (e.g. 7d, 12h) or a date")
+	cmd.Flags().IntVarP(&query.Limit, "limit", "n", 20, "Maximum number of runs to show")
 	return cmd
 }
+
+// printHistoryRuns lists runs one per line, with the matching snippet of a
+// text search below each.
+func printHistoryRuns(out io.Writer, results []history.SearchResult) {
+	if len(results) == 0 {
+		fmt.Fprintln(out, "No matching runs.")
+		return
+	}
+	for _, r := range results {
+		fmt.Fprintf(out, "%-12s  %s  %-7s  %-10s  %s  %s\n", r.ShortID(), formatRoutineTime(r.CreatedAt),
+			r.Kind, r.Status, runModel(r.Run), shortenTitle(strings.Join(strings.Fields(r.Request), " ")))
+		if r.Snippet != "" {
+			fmt.Fprintf(out, "    %s\n", r.Snippet)
+		}
+	}
+}
+
+func runModel(run history.Run) string {
+	switch {
+	case run.Provider == "" && run.Model == "":
+		return "-"
+	case run.Model == "":
+		return run.Provider
+	case run.Provider == "":
+		return run.Model
+	}
+	return run.Provider + "/" + run.Model
+}
+
+func historyShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "show <run-id>",
+		Short:        "Show a run's full transcript",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withHistory(func(store *history.Store) error {
+				run, err := store.Get(args[0])
+				if err != nil {
+					return err
+				}
+				return printHistoryRun(cmd.OutOrStdout(), run)
+			})
+		},
+	}
+}
+
+func printHistoryRun(out io.Writer, run history.Run) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Run:\t%s\n", run.ID)
+	fmt.Fprintf(w, "Kind:\t%s\n", run.Kind)
+	fmt.Fprintf(w, "Status:\t%s\n", run.Status)
+	fmt.Fprintf(w, "Model:\t%s\n", runModel(run))
+	if run.RoutineID != "" {
+		fmt.Fprintf(w, "Routine:\t%s\n", run.RoutineID)
+	}
+	if run.Cwd != "" {
+		fmt.Fprintf(w, "Directory:\t%s\n", run.Cwd)
+	}
+	fmt.Fprintf(w, "Started:\t%s\n", formatRoutineTime(run.CreatedAt))
+	if !run.CompletedAt.IsZero() {
+		fmt.Fprintf(w, "Finished:\t%s\n", formatRoutineTime(run.CompletedAt))
+	}
+	fmt.Fprintf(w, "Source:\t%s (%s)\n", run.Path, run.Source)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, entry := range run.Entries {
+		label := entry.Type
+		if entry.Tool != "" {
+			label += " " + entry.Tool
+		}
+		fmt.Fprintf(out, "\n[%s]\n", label)
+		if text := strings.TrimSpace(entry.Text); text != "" {
+			fmt.Fprintln(out, text)
+		}
+	}
+	return nil
+}
+
+func historyRerunCommand(config config.Config) *cobra.Command {
+	var provider, model string
+
+	cmd := &cobra.Command{
+		Use:   "rerun <run-id>",
+		Short: "Run an earlier ask, task or routine run again",
+		Long: `Run an earlier ask, task or routine run again, with the same request, provider
+and model. A task runs again in its original directory, and a routine run
+runs its routine again, with the routine's settings, so --provider and
+--model do not apply to it. Chat sessions are continued rather than rerun; switch
+to one with agent chat sessions switch.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var run history.Run
+			err := withHistory(func(store *history.Store) error {
+				var err error
+				run, err = store.Get(args[0])
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if run.Kind == string(app.RunKindRoutine) && (provider != "" || model != "") {
+				return fmt.Errorf("a routine run reruns its routine %q with the routine's own provider and model; --provider and --model do not apply", run.RoutineID)
+			}
+			if provider != "" {
+				run.Provider = provider
+			}
+			if model != "" {
+				run.Model = model
+			}
+
+			rerun, rerunArgs, err := rerunCommand(config, run)
+			if err != nil {
+				return err
+			}
+			if run.Kind == string(app.RunKindTask) && run.Cwd != "" {
+				restore, err := changeDirectory(run.Cwd)
+				if err != nil {
+					return fmt.Errorf("cannot rerun in %s: %w", run.Cwd, err)
+				}
+				defer restore()
+			}
+
+			// The fresh command has no parent, so it lacks the root's persistent
+			// flags; pass on the one ask and task read.
+			device, _ := cmd.Flags().GetString("device")
+			rerun.Flags().String("device", device, "")
+
+			cmd.PrintErrf("Rerunning %s run %s\n", run.Kind, run.ShortID())
+			rerun.SetArgs(rerunArgs)
+			rerun.SetIn(cmd.InOrStdin())
+			rerun.SetOut(cmd.OutOrStdout())
+			rerun.SetErr(cmd.ErrOrStderr())
+			return rerun.ExecuteContext(cmd.Context())
+		},
+	}
+	cmd.Flags().StringVarP(&provider, "provider", "p", "", "Run with this provider instead of the original one")
+	cmd.Flags().StringVarP(&model, "model", "m", "", "Run with this model instead of the original one")
+	return cmd
+}
+
+// rerunCommand returns the command and arguments that run a recorded run
+// again.
+func rerunCommand(config config.Config, run history.Run) (*cobra.Command, []string, error) {
+	switch run.Kind {
+	case string(app.RunKindChat):
+		return nil, nil, fmt.Errorf("chat sessions are continued rather than rerun: use `agent chat sessions switch %s` and `agent chat`", strings.TrimPrefix(run.ID, "chat-"))
+	case string(app.RunKindRoutine):
+		if run.RoutineID == "" {
+			return nil, nil, fmt.Errorf("run %s does not name its routine", run.ShortID())
+		}
+		return NewRoutineCommand(config), []string{"run", run.RoutineID}, nil
+	case string(app.RunKindAsk), string(app.RunKindTask):
+	default:
+		return nil, nil, fmt.Errorf("cannot rerun a %q run", run.Kind)
+	}
+	if strings.TrimSpace(run.Request) == "" {
+		return nil, nil, fmt.Errorf("run %s has no recorded request", run.ShortID())
+	}
+
+	var args []string
+	if run.Provider != "" {
+		args = append(args, "--provider", run.Provider)
+	}
+	if run.Model != "" {
+		args = append(args, "--model", run.Model)
+	}
+	// The request follows "--" so that one starting with a dash is not read
+	// as a flag.
+	args = append(args, "--", run.Request)
+	if run.Kind == string(app.RunKindAsk) {
+		return NewQuestionCommand(config), args, nil
+	}
+	return NewTaskCommand(config), args, nil
+}
+
+// changeDirectory makes dir the working directory and returns a function
+// restoring the previous one.
+func changeDirectory(dir string) (func(), error) {
+	previous, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return nil, err
+	}
+	return func() { _ = os.Chdir(previous) }, nil
+}
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/app"
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/history"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useTestHistory points the history commands at empty sources and database
+// in a temporary directory, returning the sources.
+func useTestHistory(t *testing.T) app.HistorySources {
+	t.Helper()
+	root := t.TempDir()
+	sources := app.HistorySources{
+		LogPath:     filepath.Join(root, "query_log.jsonl"),
+		SessionDir:  filepath.Join(root, "sessions"),
+		RoutinesDir: filepath.Join(root, "routines"),
+		ChatDB:      filepath.Join(root, "chat.db"),
+	}
+	t.Setenv(app.HistoryDBEnv, filepath.Join(root, "history.db"))
+	original := historySources
+	historySources = func() app.HistorySources { return sources }
+	t.Cleanup(func() { historySources = original })
+	return sources
+}
+
+func runHistoryCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := NewHistoryCommand(config.NewDefaultConfig())
+	out := new(bytes.Buffer)
+	cmd.SetArgs(args)
+	cmd.SetOut(out)
+	cmd.SetErr(new(bytes.Buffer))
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestHistorySearchAndShowCommands(t *testing.T) {
+	sources := useTestHistory(t)
+	start := time.Now().Add(-time.Hour)
+	task := sessionlog.New(sources.SessionDir, sessionlog.Meta{Kind: "task", Provider: "bedrock", Model: "m", Cwd: "/src", CreatedAt: start})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: "bring up the stack"})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordToolCall, ToolName: "unix", ToolInput: map[string]any{"command": "docker compose up"}})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordFailed, Error: "port in use"})
+	ask := sessionlog.New(sources.SessionDir, sessionlog.Meta{Kind: "ask", Provider: "openai", CreatedAt: start.Add(time.Minute)})
+	ask.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: "what is docker compose?"})
+	ask.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Text: "A tool."})
+
+	out, err := runHistoryCommand(t, "search", "docker", "compose", "--kind", "task", "--provider", "bedrock", "--since", "7d", "--status", "failed")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	shortID := task.RunID()[:8]
+	assert.True(t, strings.HasPrefix(lines[0], shortID), lines[0])
+	assert.Contains(t, lines[0], "task     failed      bedrock/m  bring up the stack")
+	assert.Contains(t, lines[1], "[docker]")
+
+	out, err = runHistoryCommand(t)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+
+	out, err = runHistoryCommand(t, "search", "kubernetes")
+	require.NoError(t, err)
+	assert.Equal(t, "No matching runs.\n", out)
+
+	out, err = runHistoryCommand(t, "show", shortID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Run:        "+task.RunID()+"\n")
+	assert.Contains(t, out, "Directory:  /src\n")
+	assert.Contains(t, out, "\n[user]\nbring up the stack\n\n[tool_call unix]\n{\"command\":\"docker compose up\"}\n\n[error]\nport in use\n")
+
+	_, err = runHistoryCommand(t, "show", "nope")
+	assert.ErrorIs(t, err, history.ErrRunNotFound)
+}
+
+func TestHistoryRerunRunsTaskAgainInItsDirectory(t *testing.T) {
+	sources := useTestHistory(t)
+	workDir := t.TempDir()
+	task := sessionlog.New(sources.SessionDir, sessionlog.Meta{Kind: "task", Provider: "ollama", Model: "qwen", Cwd: workDir})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: "-v list files"})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Text: "done"})
+
+	originalNewService := newService
+	defer func() { newService = originalNewService }()
+	var got app.TaskRequest
+	newService = func() app.Service {
+		return &fakeTaskService{events: func(_ context.Context, req app.TaskRequest) (<-chan app.Event, error) {
+			got = req
+			ch := make(chan app.Event, 1)
+			ch <- app.Event{Type: app.EventCompleted, FinalOutput: "listed", Status: req.Message}
+			close(ch)
+			return ch, nil
+		}}
+	}
+
+	previous, err := os.Getwd()
+	require.NoError(t, err)
+	out, err := runHistoryCommand(t, "rerun", task.RunID()[:8], "--model", "llama3")
+	require.NoError(t, err)
+	assert.Contains(t, out, "listed")
+	assert.Equal(t, "-v list files", got.Message)
+	assert.Equal(t, "ollama", got.Provider)
+	assert.Equal(t, "llama3", got.Model)
+	resolvedWorkDir, _ := filepath.EvalSymlinks(workDir)
+	resolvedGot, _ := filepath.EvalSymlinks(got.WorkingDir)
+	assert.Equal(t, resolvedWorkDir, resolvedGot)
+	current, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, previous, current)
+}
+
+func TestHistoryRerunRejectsModelOverrideForRoutineRun(t *testing.T) {
+	sources := useTestHistory(t)
+	run := sessionlog.New(sources.SessionDir, sessionlog.Meta{Kind: "routine", RoutineID: "nightly", Provider: "ollama"})
+	run.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Text: "done"})
+
+	_, err := runHistoryCommand(t, "rerun", run.RunID()[:8], "--model", "llama3")
+	assert.ErrorContains(t, err, "--provider and --model do not apply")
+}
+
+func TestRerunCommandByKind(t *testing.T) {
+	cfg := config.NewDefaultConfig()
+
+	cmd, args, err := rerunCommand(cfg, history.Run{ID: "r1", Kind: "ask", Request: "why?", Provider: "openai"})
+	require.NoError(t, err)
+	assert.Equal(t, "ask", cmd.Name())
+	assert.Equal(t, []string{"--provider", "openai", "--", "why?"}, args)
+
+	cmd, args, err = rerunCommand(cfg, history.Run{ID: "r2", Kind: "routine", RoutineID: "nightly"})
+	require.NoError(t, err)
+	assert.Equal(t, "routine", cmd.Name())
+	assert.Equal(t, []string{"run", "nightly"}, args)
+
+	_, _, err = rerunCommand(cfg, history.Run{ID: "chat-3", Kind: "chat"})
+	assert.ErrorContains(t, err, "agent chat sessions switch 3")
+
+	_, _, err = rerunCommand(cfg, history.Run{ID: "r4", Kind: "task"})
+	assert.ErrorContains(t, err, "no recorded request")
+}
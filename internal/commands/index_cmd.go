@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/laszukdawid/terminal-agent/internal/app"
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
+	"github.com/spf13/cobra"
+)
+
+func NewIndexCommand(config config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index",
+		Short: "Build and inspect the semantic search index of a project",
+		Long: `Build and inspect the semantic search index of a project.
+
+The index splits the project's text files into chunks, skipping what .gitignore
+excludes, and stores an embedding of each chunk. Tasks run in an indexed
+directory get a semantic_search tool, and ask --rag adds the most relevant
+chunks to the question.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(indexBuildCommand(config))
+	cmd.AddCommand(indexStatusCommand())
+
+	return cmd
+}
+
+func indexBuildCommand(config config.Config) *cobra.Command {
+	var req app.IndexBuildRequest
+	var watch bool
+	cmd := &cobra.Command{
+		Use:          "build [dir]",
+		Short:        "Create or update the index of a directory",
+		SilenceUsage: true,
+		Long: `Create or update the index of a directory (default: the current one). Only
+files added or changed since the last build are embedded again, and removed
+files are dropped.
+
+The embedding model defaults to the one the index was built with, or else the
+default provider's embedding model. Embeddings are supported by openai
+(text-embedding-3-small), ollama (nomic-embed-text) and llama (pass a GGUF
+embedding model with --model). Changing the model rebuilds the whole index.
+
+For example:
+  agent index build
+  agent index build ~/src/app --provider ollama --model mxbai-embed-large
+  agent index build --watch`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				req.Dir = args[0]
+			}
+			req.Config = config
+			out := cmd.ErrOrStderr()
+			req.Progress = func(done, total int) {
+				fmt.Fprintf(out, "\rEmbedding files %d/%d", done, total)
+				if done == total {
+					fmt.Fprintln(out)
+				}
+			}
+
+			result, err := app.BuildIndex(cmd.Context(), req)
+			if err != nil {
+				return fmt.Errorf("failed to build index: %w", err)
+			}
+			printIndexBuild(cmd.OutOrStdout(), result)
+			if !watch {
+				return nil
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			cmd.Printf("Watching %s for changes (Ctrl+C to stop)\n", result.Root)
+			return app.WatchIndex(ctx, req, func(result app.IndexBuildResult, err error) {
+				if err != nil {
+					cmd.PrintErrf("failed to update index: %v\n", err)
+					return
+				}
+				printIndexBuild(cmd.OutOrStdout(), result)
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&req.Provider, "provider", "p", "", "Embedding provider (openai, ollama or llama)")
+	cmd.Flags().StringVarP(&req.Model, "model", "m", "", "Embedding model")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Keep running and update the index when files change")
+
+	return cmd
+}
+
+func printIndexBuild(out io.Writer, result app.IndexBuildResult) {
+	stats := result.Stats
+	verb := "Updated"
+	if stats.Rebuilt {
+		verb = "Rebuilt"
+	}
+	fmt.Fprintf(out, "%s index of %s with %s: %d files embedded (%d chunks), %d removed, %d unchanged\n",
+		verb, result.Root, result.Model, stats.Indexed, stats.Chunks, stats.Removed, stats.Unchanged)
+	if summary := redact.Summary(stats.Redactions); summary != "" {
+		fmt.Fprintf(out, "Redacted secrets before embedding: %s\n", summary)
+	}
+}
+
+func indexStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "status [dir]",
+		Short:        "Show the index covering a directory and the files changed since it was built",
+		SilenceUsage: true,
+		Args:         cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := ""
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			status, err := app.GetIndexStatus(dir)
+			if err != nil {
+				return err
+			}
+
+			info := status.Info
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintf(w, "Root:\t%s\n", info.Root)
+			fmt.Fprintf(w, "Index:\t%s\n", status.Path)
+			fmt.Fprintf(w, "Model:\t%s (%d dimensions)\n", info.Model, info.Dimensions)
+			fmt.Fprintf(w, "Files:\t%d (%d chunks)\n", info.Files, info.Chunks)
+			updated := "never"
+			if !info.UpdatedAt.IsZero() {
+				updated = info.UpdatedAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "Updated:\t%s\n", updated)
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			changes := status.Changes
+			if changes.Empty() {
+				cmd.Println("Up to date.")
+				return nil
+			}
+			cmd.Printf("\nChanged since the last build (run `agent index build` to update):\n")
+			for _, change := range []struct {
+				mark  string
+				paths []string
+			}{{"A", changes.Added}, {"M", changes.Modified}, {"D", changes.Removed}} {
+				for _, path := range change.paths {
+					cmd.Printf("  %s %s\n", change.mark, path)
+				}
+			}
+			return nil
+		},
+	}
+}
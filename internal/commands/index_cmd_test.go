@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/laszukdawid/terminal-agent/internal/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexBuildAndStatusCommands(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"embeddings":[[1,0]]}`))
+	}))
+	defer server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+	t.Setenv(app.IndexDirEnv, t.TempDir())
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644))
+
+	status := indexStatusCommand()
+	status.SetArgs([]string{root})
+	status.SetOut(new(bytes.Buffer))
+	status.SetErr(new(bytes.Buffer))
+	assert.ErrorIs(t, status.Execute(), app.ErrNoIndex)
+
+	build := indexBuildCommand(nil)
+	out := new(bytes.Buffer)
+	build.SetArgs([]string{root, "--provider", "ollama"})
+	build.SetOut(out)
+	build.SetErr(new(bytes.Buffer))
+	require.NoError(t, build.Execute())
+	assert.Equal(t, "Updated index of "+root+" with ollama/nomic-embed-text: 1 files embedded (1 chunks), 0 removed, 0 unchanged\n", out.String())
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+	status = indexStatusCommand()
+	out.Reset()
+	status.SetArgs([]string{root})
+	status.SetOut(out)
+	require.NoError(t, status.Execute())
+	assert.Contains(t, out.String(), "Model:    ollama/nomic-embed-text (2 dimensions)")
+	assert.Contains(t, out.String(), "Files:    1 (1 chunks)")
+	assert.Contains(t, out.String(), "  M main.go\n")
+}
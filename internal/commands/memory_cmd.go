@@ -2,7 +2,13 @@ package commands
 
 import (
 	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/laszukdawid/terminal-agent/internal/app"
 	"github.com/laszukdawid/terminal-agent/internal/memory"
@@ -19,6 +25,13 @@ func NewMemoryCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "memory",
 		Short: "Store and retrieve things to remember",
+		Long: `Store and retrieve things to remember.
+
+With memory enabled (memory: true in the config, or --memory/-M), the entries
+relevant to a run are added to its system prompt: global entries, entries for
+the project directory it runs in and entries for the routine it belongs to.
+Expired entries are skipped. When more than ` + strconv.Itoa(memory.MaxPromptEntries) + ` entries apply, the ones
+matching the request best are used.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.Help()
 		},
@@ -26,35 +39,83 @@ func NewMemoryCommand() *cobra.Command {
 
 	cmd.AddCommand(memoryAddCommand(mClient))
 	cmd.AddCommand(memoryListCommand(mClient))
+	cmd.AddCommand(memoryEditCommand(mClient))
+	cmd.AddCommand(memoryRemoveCommand(mClient))
+	cmd.AddCommand(memorySearchCommand(mClient))
 
 	return cmd
 }
 
+// memoryEntryFlags are the flags describing an entry's tags, scope and expiry.
+type memoryEntryFlags struct {
+	tags    []string
+	project string
+	routine string
+	expires string
+}
+
+func (f *memoryEntryFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&f.tags, "tag", "t", nil, "Tag the entry (repeatable)")
+	cmd.Flags().StringVar(&f.project, "project", "", "Only use the entry in this directory and below (--project alone: the current directory)")
+	cmd.Flags().Lookup("project").NoOptDefVal = "."
+	cmd.Flags().StringVar(&f.routine, "routine", "", "Only use the entry in runs of this routine")
+	cmd.Flags().StringVar(&f.expires, "expires", "", "Stop using the entry after a duration (e.g. 12h, 30d, 2w) or on a date (2006-01-02)")
+	cmd.MarkFlagsMutuallyExclusive("project", "routine")
+}
+
+// scope resolves the scope flags to the entry's project and routine.
+func (f *memoryEntryFlags) scope() (project, routine string, err error) {
+	if f.project != "" {
+		if project, err = filepath.Abs(f.project); err != nil {
+			return "", "", fmt.Errorf("invalid project directory %q: %w", f.project, err)
+		}
+	}
+	return project, strings.TrimSpace(f.routine), nil
+}
+
 func memoryAddCommand(mClient memory.Memory) *cobra.Command {
+	var flags memoryEntryFlags
 	cmd := &cobra.Command{
 		Use:   "add",
 		Short: "Add an entry to memory",
-		Long: `Add an entry to memory. If the entry already exists, it will be silently skipped.
+		Long: `Add an entry to memory and print its ID. If the entry already exists in the same
+scope, it is not added again.
 
 For example:
   agent memory add "viewing images is with catimg"
-  agent memory add use kubectl for kubernetes`,
+  agent memory add use kubectl for kubernetes
+  agent memory add --project -t testing "run tests with task test, not go test"
+  agent memory add --routine nightly-report "the report goes to #ops"
+  agent memory add --expires 2w "staging is frozen until the release"`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			content := strings.Join(args, " ")
+			entry := memory.MemoryEntry{Content: content, Tags: flags.tags, Source: memory.SourceCLI}
 
-			if err := mClient.Add(content); err != nil {
+			var err error
+			if entry.Project, entry.Routine, err = flags.scope(); err != nil {
+				return err
+			}
+			if entry.ExpiresAt, err = parseMemoryExpiry(flags.expires, time.Now()); err != nil {
+				return err
+			}
+
+			entry, err = mClient.AddEntry(entry)
+			if err != nil {
 				return fmt.Errorf("failed to add memory: %w", err)
 			}
 
+			cmd.Printf("Remembered #%d\n", entry.ID)
 			return nil
 		},
 	}
+	flags.register(cmd)
 
 	return cmd
 }
 
 func memoryListCommand(mClient memory.Memory) *cobra.Command {
+	var tag string
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List all memory entries",
@@ -63,19 +124,188 @@ func memoryListCommand(mClient memory.Memory) *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to list memory: %w", err)
 			}
+			if tag != "" {
+				entries = slices.DeleteFunc(entries, func(entry memory.MemoryEntry) bool {
+					return !slices.Contains(entry.Tags, strings.ToLower(tag))
+				})
+			}
 
 			if len(entries) == 0 {
 				cmd.Println("No memory entries found.")
 				return nil
 			}
 
-			for _, entry := range entries {
-				cmd.Printf("%s %s\n", entry.Timestamp, entry.Content)
+			return printMemoryEntries(cmd.OutOrStdout(), entries)
+		},
+	}
+	cmd.Flags().StringVarP(&tag, "tag", "t", "", "Only list entries with this tag")
+
+	return cmd
+}
+
+func memoryEditCommand(mClient memory.Memory) *cobra.Command {
+	var flags memoryEntryFlags
+	var global bool
+	cmd := &cobra.Command{
+		Use:   "edit <id> [content...]",
+		Short: "Change a memory entry's content, tags, scope or expiry",
+		Long: `Change a memory entry. Only what is given changes: new content replaces the
+old, --tag replaces all tags (--tag "" removes them), --global, --project or
+--routine moves the entry to that scope, and --expires never removes the expiry.
+
+For example:
+  agent memory edit 3 "run tests with task test"
+  agent memory edit 3 --project=/src/app --expires 30d`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseMemoryID(args[0])
+			if err != nil {
+				return err
+			}
+			content := strings.Join(args[1:], " ")
+			project, routine, err := flags.scope()
+			if err != nil {
+				return err
+			}
+			var expires time.Time
+			if cmd.Flags().Changed("expires") {
+				if expires, err = parseMemoryExpiry(flags.expires, time.Now()); err != nil {
+					return err
+				}
 			}
 
-			return nil
+			changed := cmd.Flags().Changed
+			if content == "" && !changed("tag") && !changed("expires") && !global && project == "" && routine == "" {
+				return fmt.Errorf("nothing to change; give new content or a --tag, --global, --project, --routine or --expires flag")
+			}
+
+			entry, err := mClient.Update(id, func(entry *memory.MemoryEntry) {
+				if content != "" {
+					entry.Content = content
+				}
+				if changed("tag") {
+					entry.Tags = flags.tags
+				}
+				switch {
+				case global:
+					entry.Project, entry.Routine = "", ""
+				case project != "":
+					entry.Project, entry.Routine = project, ""
+				case routine != "":
+					entry.Project, entry.Routine = "", routine
+				}
+				if changed("expires") {
+					entry.ExpiresAt = expires
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("failed to edit memory: %w", err)
+			}
+			return printMemoryEntries(cmd.OutOrStdout(), []memory.MemoryEntry{entry})
 		},
 	}
+	flags.register(cmd)
+	cmd.Flags().BoolVar(&global, "global", false, "Use the entry everywhere")
+	cmd.MarkFlagsMutuallyExclusive("global", "project", "routine")
 
 	return cmd
 }
+
+func memoryRemoveCommand(mClient memory.Memory) *cobra.Command {
+	return &cobra.Command{
+		Use:     "rm <id>...",
+		Aliases: []string{"remove"},
+		Short:   "Remove memory entries",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids := make([]int, 0, len(args))
+			for _, arg := range args {
+				id, err := parseMemoryID(arg)
+				if err != nil {
+					return err
+				}
+				ids = append(ids, id)
+			}
+			if err := mClient.Remove(ids...); err != nil {
+				return fmt.Errorf("failed to remove memory: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+func memorySearchCommand(mClient memory.Memory) *cobra.Command {
+	return &cobra.Command{
+		Use:   "search <text...>",
+		Short: "Search memory entries, best match first",
+		Long: `Search the content and tags of every memory entry, in any scope. Words match
+by prefix, and an entry matching more of them ranks higher.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := mClient.Search(strings.Join(args, " "))
+			if err != nil {
+				return fmt.Errorf("failed to search memory: %w", err)
+			}
+			if len(entries) == 0 {
+				cmd.Println("No matching memory entries.")
+				return nil
+			}
+			return printMemoryEntries(cmd.OutOrStdout(), entries)
+		},
+	}
+}
+
+func printMemoryEntries(out io.Writer, entries []memory.MemoryEntry) error {
+	now := time.Now()
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tADDED\tSCOPE\tTAGS\tEXPIRES\tSOURCE\tCONTENT")
+	for _, entry := range entries {
+		added, _, _ := strings.Cut(entry.Timestamp, "T")
+		tags := strings.Join(entry.Tags, ",")
+		if tags == "" {
+			tags = "-"
+		}
+		expires := "-"
+		switch {
+		case entry.Expired(now):
+			expires = "expired"
+		case !entry.ExpiresAt.IsZero():
+			expires = entry.ExpiresAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.ID, added, entry.ScopeLabel(), tags, expires, entry.Provenance(), strings.Join(strings.Fields(entry.Content), " "))
+	}
+	return w.Flush()
+}
+
+func parseMemoryID(arg string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid memory id %q", arg)
+	}
+	return id, nil
+}
+
+// parseMemoryExpiry reads an expiry as a duration from now (Go durations plus
+// days and weeks, e.g. 30d or 2w), a date or an RFC 3339 time. "never" and ""
+// mean no expiry.
+func parseMemoryExpiry(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "never" {
+		return time.Time{}, nil
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(value, suffix)); err == nil && strings.HasSuffix(value, suffix) && n > 0 {
+			return now.Add(time.Duration(n) * unit), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q: want a duration like 12h, 30d or 2w, a date like 2006-01-02, or never", value)
+}
@@ -3,11 +3,14 @@ package commands
 import (
 	"bytes"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/laszukdawid/terminal-agent/internal/memory"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMemoryListCommand(t *testing.T) {
@@ -45,19 +48,32 @@ func TestMemoryListCommand(t *testing.T) {
 		assert.Contains(t, output, "first entry")
 		assert.Contains(t, output, "second entry")
 
-		// Verify format: timestamp followed by content
+		// Verify format: a header, then one row per entry starting with its ID
 		lines := strings.Split(strings.TrimSpace(output), "\n")
-		assert.Len(t, lines, 2)
-
-		// Each line should have timestamp and content
-		for _, line := range lines {
-			parts := strings.SplitN(line, " ", 2)
-			assert.Len(t, parts, 2, "Line should have timestamp and content")
-			// Timestamp should contain date markers
-			assert.Contains(t, parts[0], "-", "Timestamp should be in RFC3339 format")
-			assert.Contains(t, parts[0], ":", "Timestamp should be in RFC3339 format")
+		assert.Len(t, lines, 3)
+		assert.True(t, strings.HasPrefix(lines[0], "ID"), "First line should be the header")
+		for i, line := range lines[1:] {
+			fields := strings.Fields(line)
+			assert.Equal(t, strconv.Itoa(i+1), fields[0], "Row should start with the entry ID")
+			assert.Contains(t, fields[1], "-", "Added date should be in YYYY-MM-DD format")
+			assert.Equal(t, "global", fields[2])
 		}
 	})
+
+	t.Run("ListByTag", func(t *testing.T) {
+		mClient := memory.NewMemory(filepath.Join(t.TempDir(), "memory.jsonl"))
+		mClient.AddEntry(memory.MemoryEntry{Content: "tagged entry", Tags: []string{"go"}})
+		mClient.Add("untagged entry")
+
+		cmd := memoryListCommand(mClient)
+		buf := new(bytes.Buffer)
+		cmd.SetOut(buf)
+		cmd.SetArgs([]string{"--tag", "Go"})
+
+		assert.NoError(t, cmd.Execute())
+		assert.Contains(t, buf.String(), "tagged entry")
+		assert.NotContains(t, buf.String(), "untagged entry")
+	})
 }
 
 func TestMemoryAddCommand(t *testing.T) {
@@ -75,6 +91,7 @@ func TestMemoryAddCommand(t *testing.T) {
 		entries, _ := mClient.List()
 		assert.Len(t, entries, 1)
 		assert.Equal(t, "test entry", entries[0].Content)
+		assert.Equal(t, memory.SourceCLI, entries[0].Source)
 	})
 
 	t.Run("AddQuotedEntry", func(t *testing.T) {
@@ -93,3 +110,183 @@ func TestMemoryAddCommand(t *testing.T) {
 		assert.Equal(t, "viewing images is with catimg", entries[0].Content)
 	})
 }
+
+func TestMemoryAddCommandScopes(t *testing.T) {
+	t.Run("TagsAndProject", func(t *testing.T) {
+		mClient := memory.NewMemory(filepath.Join(t.TempDir(), "memory.jsonl"))
+		projectDir := t.TempDir()
+
+		cmd := memoryAddCommand(mClient)
+		cmd.SetArgs([]string{"-t", "Testing", "--tag", "ci", "--project=" + projectDir, "run tests with task test"})
+		require.NoError(t, cmd.Execute())
+
+		entries, _ := mClient.List()
+		require.Len(t, entries, 1)
+		assert.Equal(t, []string{"testing", "ci"}, entries[0].Tags)
+		assert.Equal(t, projectDir, entries[0].Project)
+		assert.Equal(t, memory.ScopeProject, entries[0].Scope())
+	})
+
+	t.Run("RoutineWithExpiry", func(t *testing.T) {
+		mClient := memory.NewMemory(filepath.Join(t.TempDir(), "memory.jsonl"))
+
+		cmd := memoryAddCommand(mClient)
+		cmd.SetArgs([]string{"--routine", "nightly", "--expires", "2d", "the report goes to ops"})
+		require.NoError(t, cmd.Execute())
+
+		entries, _ := mClient.List()
+		require.Len(t, entries, 1)
+		assert.Equal(t, "nightly", entries[0].Routine)
+		assert.WithinDuration(t, time.Now().Add(48*time.Hour), entries[0].ExpiresAt, time.Minute)
+	})
+
+	t.Run("ProjectAndRoutineConflict", func(t *testing.T) {
+		mClient := memory.NewMemory(filepath.Join(t.TempDir(), "memory.jsonl"))
+
+		cmd := memoryAddCommand(mClient)
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetErr(new(bytes.Buffer))
+		cmd.SetArgs([]string{"--project", "--routine", "nightly", "entry"})
+		assert.Error(t, cmd.Execute())
+	})
+}
+
+func TestMemoryEditCommand(t *testing.T) {
+	setup := func(t *testing.T) memory.Memory {
+		mClient := memory.NewMemory(filepath.Join(t.TempDir(), "memory.jsonl"))
+		_, err := mClient.AddEntry(memory.MemoryEntry{
+			Content:   "old content",
+			Tags:      []string{"old"},
+			Routine:   "nightly",
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+		return mClient
+	}
+
+	t.Run("ContentOnly", func(t *testing.T) {
+		mClient := setup(t)
+
+		cmd := memoryEditCommand(mClient)
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetArgs([]string{"1", "new", "content"})
+		require.NoError(t, cmd.Execute())
+
+		entry, err := mClient.Get(1)
+		require.NoError(t, err)
+		assert.Equal(t, "new content", entry.Content)
+		assert.Equal(t, []string{"old"}, entry.Tags)
+		assert.Equal(t, "nightly", entry.Routine)
+		assert.False(t, entry.ExpiresAt.IsZero())
+		assert.NotEmpty(t, entry.UpdatedAt)
+	})
+
+	t.Run("TagsScopeAndExpiry", func(t *testing.T) {
+		mClient := setup(t)
+
+		cmd := memoryEditCommand(mClient)
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetArgs([]string{"#1", "--tag", "new", "--global", "--expires", "never"})
+		require.NoError(t, cmd.Execute())
+
+		entry, err := mClient.Get(1)
+		require.NoError(t, err)
+		assert.Equal(t, "old content", entry.Content)
+		assert.Equal(t, []string{"new"}, entry.Tags)
+		assert.Equal(t, memory.ScopeGlobal, entry.Scope())
+		assert.True(t, entry.ExpiresAt.IsZero())
+	})
+
+	t.Run("NothingToChange", func(t *testing.T) {
+		mClient := setup(t)
+
+		cmd := memoryEditCommand(mClient)
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetErr(new(bytes.Buffer))
+		cmd.SetArgs([]string{"1"})
+		assert.ErrorContains(t, cmd.Execute(), "nothing to change")
+	})
+
+	t.Run("UnknownID", func(t *testing.T) {
+		mClient := setup(t)
+
+		cmd := memoryEditCommand(mClient)
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetErr(new(bytes.Buffer))
+		cmd.SetArgs([]string{"7", "content"})
+		assert.ErrorIs(t, cmd.Execute(), memory.ErrNotFound)
+	})
+}
+
+func TestMemoryRemoveCommand(t *testing.T) {
+	mClient := memory.NewMemory(filepath.Join(t.TempDir(), "memory.jsonl"))
+	mClient.Add("first entry")
+	mClient.Add("second entry")
+	mClient.Add("third entry")
+
+	cmd := memoryRemoveCommand(mClient)
+	cmd.SetArgs([]string{"1", "3"})
+	require.NoError(t, cmd.Execute())
+
+	entries, _ := mClient.List()
+	require.Len(t, entries, 1)
+	assert.Equal(t, 2, entries[0].ID)
+	assert.Equal(t, "second entry", entries[0].Content)
+
+	cmd = memoryRemoveCommand(mClient)
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"abc"})
+	assert.ErrorContains(t, cmd.Execute(), "invalid memory id")
+}
+
+func TestMemorySearchCommand(t *testing.T) {
+	mClient := memory.NewMemory(filepath.Join(t.TempDir(), "memory.jsonl"))
+	mClient.Add("deploy with helm upgrade")
+	mClient.Add("images are viewed with catimg")
+	mClient.AddEntry(memory.MemoryEntry{Content: "staging is frozen", Tags: []string{"deployment"}})
+
+	cmd := memorySearchCommand(mClient)
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"deploy"})
+	require.NoError(t, cmd.Execute())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "staging is frozen", "tag matches rank first")
+	assert.Contains(t, lines[2], "deploy with helm upgrade")
+
+	cmd = memorySearchCommand(mClient)
+	buf = new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"kubernetes"})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), "No matching memory entries.")
+}
+
+func TestParseMemoryExpiry(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for value, want := range map[string]time.Time{
+		"":                     {},
+		"never":                {},
+		"90m":                  now.Add(90 * time.Minute),
+		"3d":                   now.Add(72 * time.Hour),
+		"1w":                   now.Add(7 * 24 * time.Hour),
+		"2025-04-01T10:00:00Z": time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC),
+	} {
+		got, err := parseMemoryExpiry(value, now)
+		require.NoError(t, err, value)
+		assert.True(t, want.Equal(got), "%s: got %v, want %v", value, got, want)
+	}
+
+	date, err := parseMemoryExpiry("2025-04-01", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.Local), date)
+
+	for _, value := range []string{"soon", "-1h", "0d"} {
+		_, err := parseMemoryExpiry(value, now)
+		assert.Error(t, err, value)
+	}
+}
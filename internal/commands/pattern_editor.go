@@ -24,6 +24,8 @@ type interactiveConfirmation struct {
 	toolName        string
 	command         string
 	action          string
+	reason          string
+	risk            string
 	levels          []string
 	pos             int
 	stdin           *os.File
@@ -164,6 +166,12 @@ func (c *interactiveConfirmation) render() {
 
 	var lines []string
 	lines = append(lines, c.headerText())
+	if c.risk != "" {
+		lines = append(lines, "Risk: "+c.risk)
+	}
+	if c.reason != "" {
+		lines = append(lines, "Policy: "+c.reason)
+	}
 	lines = append(lines, "")
 	for _, commandLine := range splitDisplayLines(c.currentDisplayCommand()) {
 		lines = append(lines, "  "+commandLine)
@@ -0,0 +1,272 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/app"
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/laszukdawid/terminal-agent/internal/history"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	"github.com/spf13/cobra"
+)
+
+// replayOutputLines is how many lines of a tool's output or a response the
+// timeline shows without --full.
+const replayOutputLines = 20
+
+func NewReplayCommand(config config.Config) *cobra.Command {
+	var full, step, reexecute, autoApprove bool
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "replay <run-id|session-log>",
+		Short: "Step through a recorded run, or execute it again with its recorded model responses",
+		Long: `Step through a recorded ask, task or routine run: its timeline of model
+responses, tool calls with their inputs and outputs, confirmations and
+progress, with the time each record came after the start of the run and after
+the record before it. The run is named by its id, or a unique prefix of it, as
+listed by agent history, or by the path of its session log.
+
+With --step the timeline pauses after each record; press Enter to go on or q
+to stop.
+
+With --reexecute the run is executed again, with its recorded model responses
+fed back in place of the provider: the same tool calls are made, in the
+recorded directory, without calling a model. This reproduces tool-side bugs;
+confirmations are asked for as in a live run. A re-executed run that takes a
+different path, e.g. because a tool now fails, stops once the recorded
+responses run out.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			run, err := app.LoadRecordedRun(args[0], historySources())
+			if err != nil {
+				return err
+			}
+			if !reexecute {
+				var pause func() bool
+				if step {
+					pause = replayPause(cmd)
+				}
+				return printReplayTimeline(cmd.OutOrStdout(), run, full, pause)
+			}
+
+			req, err := run.ReplayRequest()
+			if err != nil {
+				return err
+			}
+			if dir != "" {
+				req.WorkingDir = dir
+			}
+			if req.WorkingDir == "" {
+				if req.WorkingDir, err = os.Getwd(); err != nil {
+					return err
+				}
+			}
+			if info, err := os.Stat(req.WorkingDir); err != nil || !info.IsDir() {
+				return fmt.Errorf("cannot re-execute in %s: the directory is gone; pick another with --dir", req.WorkingDir)
+			}
+			if autoApprove {
+				if err := checkAutoApproveAllowed(); err != nil {
+					return err
+				}
+			}
+			if req.Device, err = resolveDevice(cmd.Flags(), config); err != nil {
+				return err
+			}
+			req.AutoApprove = autoApprove
+			req.Config = config
+
+			replay, _ := req.Connector.(*connector.ReplayConnector)
+			recorded := 0
+			if replay != nil {
+				recorded = replay.Remaining()
+			}
+			cmd.PrintErrf("Re-executing %s run %s in %s with %d recorded model responses\n", run.Kind, shortRunID(run.RunID), req.WorkingDir, recorded)
+
+			events, err := newService().TaskEvents(cmd.Context(), req)
+			if err != nil {
+				return fmt.Errorf("failed to re-execute the run: %w", err)
+			}
+			_, _, err = printTaskEvents(cmd, events, bufio.NewReader(cmd.InOrStdin()), taskOutputOptions{
+				print:           true,
+				progress:        "auto",
+				liveOutputLimit: config.GetTaskLiveOutputLimit(),
+			})
+			if replay != nil && replay.Remaining() > 0 {
+				cmd.PrintErrf("The re-executed run finished with %d of %d recorded model responses unused\n", replay.Remaining(), recorded)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&full, "full", false, "Show tool outputs and responses in full rather than their first lines")
+	cmd.Flags().BoolVar(&step, "step", false, "Pause after each record of the timeline")
+	cmd.Flags().BoolVar(&reexecute, "reexecute", false, "Execute the run again, feeding back its recorded model responses instead of calling the provider")
+	cmd.Flags().StringVar(&dir, "dir", "", "Re-execute in this directory instead of the recorded one")
+	cmd.Flags().BoolVar(&autoApprove, "auto-approve", false, "Automatically approve confirmation prompts of the re-executed run except explicit denies")
+	return cmd
+}
+
+// shortRunID cuts a session run id to the prefix the history lists.
+func shortRunID(id string) string {
+	return history.Run{ID: id, Source: history.SourceSession}.ShortID()
+}
+
+// replayPause returns a function waiting for Enter between timeline records.
+// It reports false once the user asks to stop or the input ends.
+func replayPause(cmd *cobra.Command) func() bool {
+	reader := bufio.NewReader(cmd.InOrStdin())
+	return func() bool {
+		cmd.PrintErr("-- Enter for the next record, q to quit -- ")
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			cmd.PrintErrln()
+			return false
+		}
+		return !strings.EqualFold(strings.TrimSpace(line), "q")
+	}
+}
+
+// printReplayTimeline prints the run's header and then its records in order,
+// each with its time from the start of the run and from the previous record.
+// pause, when set, is called after each record and stops the timeline when it
+// returns false.
+func printReplayTimeline(out io.Writer, run app.RecordedRun, full bool, pause func() bool) error {
+	start := run.CreatedAt
+	var records []sessionlog.Record
+	status := history.StatusIncomplete
+	for _, rec := range run.Records {
+		switch rec.Type {
+		case sessionlog.RecordMeta:
+			continue
+		case sessionlog.RecordCompleted:
+			status = history.StatusCompleted
+		case sessionlog.RecordFailed:
+			status = history.StatusFailed
+		}
+		if start.IsZero() {
+			start = rec.Timestamp
+		}
+		records = append(records, rec)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Run:\t%s\n", run.RunID)
+	fmt.Fprintf(w, "Kind:\t%s\n", run.Kind)
+	fmt.Fprintf(w, "Model:\t%s\n", runModel(history.Run{Provider: run.Provider, Model: run.Model}))
+	if run.RoutineID != "" {
+		fmt.Fprintf(w, "Routine:\t%s\n", run.RoutineID)
+	}
+	if run.Cwd != "" {
+		fmt.Fprintf(w, "Directory:\t%s\n", run.Cwd)
+	}
+	fmt.Fprintf(w, "Started:\t%s\n", start.Local().Format(time.DateTime))
+	if len(records) > 0 {
+		fmt.Fprintf(w, "Duration:\t%s\n", formatReplayDuration(records[len(records)-1].Timestamp.Sub(start)))
+	}
+	fmt.Fprintf(w, "Status:\t%s\n", status)
+	fmt.Fprintf(w, "Records:\t%d\n", len(records))
+	fmt.Fprintf(w, "Source:\t%s\n", run.Path)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	previous := start
+	for i, rec := range records {
+		label := string(rec.Type)
+		if rec.ToolName != "" {
+			label += " " + rec.ToolName
+		}
+		if rec.StepID != "" {
+			label += " " + rec.StepID
+		}
+		timing := "+" + formatReplayDuration(rec.Timestamp.Sub(start))
+		if i > 0 {
+			timing += fmt.Sprintf(" (Δ%s)", formatReplayDuration(rec.Timestamp.Sub(previous)))
+		}
+		previous = rec.Timestamp
+
+		// Progress updates are frequent and short; keep them to one line.
+		if rec.Type == sessionlog.RecordProgress {
+			fmt.Fprintf(out, "\n[%d] %s  %s  %s\n", i+1, timing, label, strings.Join(strings.Fields(rec.Text), " "))
+		} else {
+			fmt.Fprintf(out, "\n[%d] %s  %s\n", i+1, timing, label)
+			printReplayRecord(out, rec, full)
+		}
+
+		if pause != nil && i < len(records)-1 && !pause() {
+			return nil
+		}
+	}
+	return nil
+}
+
+func printReplayRecord(out io.Writer, rec sessionlog.Record, full bool) {
+	if rec.Status != "" {
+		printReplayField(out, "status", rec.Status, full)
+	}
+	text := rec.Text
+	if rec.Type == sessionlog.RecordConfirmation && rec.Confirmation != "" {
+		text = rec.Confirmation
+	}
+	printReplayField(out, "text", text, full)
+	if len(rec.ToolInput) > 0 {
+		// Commands are shown as run, without <, > and & escaped.
+		var input strings.Builder
+		encoder := json.NewEncoder(&input)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(rec.ToolInput); err == nil {
+			printReplayField(out, "input", input.String(), full)
+		}
+	}
+	printReplayField(out, "output", rec.ToolResult, full)
+	if rec.Allowed != nil {
+		printReplayField(out, "allowed", fmt.Sprint(*rec.Allowed), full)
+	}
+	printReplayField(out, "error", rec.Error, full)
+}
+
+// printReplayField prints a record's field indented under its record, cut to
+// replayOutputLines lines unless full is set.
+func printReplayField(out io.Writer, name, value string, full bool) {
+	value = strings.TrimRight(value, "\n")
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	lines := strings.Split(value, "\n")
+	if len(lines) == 1 {
+		fmt.Fprintf(out, "    %s: %s\n", name, lines[0])
+		return
+	}
+	fmt.Fprintf(out, "    %s:\n", name)
+	hidden := 0
+	if !full && len(lines) > replayOutputLines {
+		hidden = len(lines) - replayOutputLines
+		lines = lines[:replayOutputLines]
+	}
+	for _, line := range lines {
+		fmt.Fprintf(out, "      %s\n", line)
+	}
+	if hidden > 0 {
+		fmt.Fprintf(out, "      ... %d more lines (--full shows all)\n", hidden)
+	}
+}
+
+func formatReplayDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/app"
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/connector"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runReplayCommand(t *testing.T, stdin string, args ...string) (string, string, error) {
+	t.Helper()
+	cmd := NewReplayCommand(config.NewDefaultConfig())
+	cmd.Flags().String("device", "", "")
+	out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.SetArgs(args)
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetOut(out)
+	cmd.SetErr(errOut)
+	err := cmd.ExecuteContext(context.Background())
+	return out.String(), errOut.String(), err
+}
+
+func recordReplayTask(t *testing.T, dir, cwd string) *sessionlog.Recorder {
+	t.Helper()
+	start := time.Now().Add(-time.Hour)
+	task := sessionlog.New(dir, sessionlog.Meta{Kind: "task", Provider: "bedrock", Model: "m", Cwd: cwd, CreatedAt: start})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordRequest, Text: "list the logs", Timestamp: start})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordProgress, Text: "Running unix", Timestamp: start.Add(500 * time.Millisecond)})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordToolResult, Text: "Listing.", ToolName: "unix", ToolInput: map[string]any{"command": "ls > out && cat out"},
+		ToolResult: strings.Repeat("line\n", 30), Timestamp: start.Add(1500 * time.Millisecond)})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordToolResult, ToolName: "final_answer", ToolInput: map[string]any{"answer": "30 lines"},
+		ToolResult: "30 lines", Timestamp: start.Add(2 * time.Second)})
+	task.Write(sessionlog.Record{Type: sessionlog.RecordCompleted, Text: "30 lines", Timestamp: start.Add(2 * time.Second)})
+	return task
+}
+
+func TestReplayCommandPrintsTimeline(t *testing.T) {
+	sources := useTestHistory(t)
+	task := recordReplayTask(t, sources.SessionDir, "/src")
+
+	out, _, err := runReplayCommand(t, "", task.RunID()[:8])
+	require.NoError(t, err)
+	assert.Contains(t, out, "Run:        "+task.RunID()+"\n")
+	assert.Contains(t, out, "Duration:   2s\n")
+	assert.Contains(t, out, "Status:     completed\n")
+	assert.Contains(t, out, "\n[1] +0s  request\n    text: list the logs\n")
+	assert.Contains(t, out, "\n[2] +500ms (Δ500ms)  progress  Running unix\n")
+	assert.Contains(t, out, "\n[3] +1.5s (Δ1s)  tool_result unix\n    text: Listing.\n    input: {\"command\":\"ls > out && cat out\"}\n    output:\n")
+	assert.Contains(t, out, "      ... 10 more lines (--full shows all)\n")
+
+	out, _, err = runReplayCommand(t, "", "--full", task.Path())
+	require.NoError(t, err)
+	assert.NotContains(t, out, "more lines")
+	assert.Equal(t, 30, strings.Count(out, "      line\n"))
+}
+
+func TestReplayCommandStepsUntilQuit(t *testing.T) {
+	sources := useTestHistory(t)
+	task := recordReplayTask(t, sources.SessionDir, "/src")
+
+	out, errOut, err := runReplayCommand(t, "\nq\n", "--step", task.RunID())
+	require.NoError(t, err)
+	assert.Contains(t, out, "[2] ")
+	assert.NotContains(t, out, "[3] ")
+	assert.Equal(t, 2, strings.Count(errOut, "-- Enter for the next record, q to quit --"))
+}
+
+func TestReplayCommandReexecutesWithRecordedResponses(t *testing.T) {
+	sources := useTestHistory(t)
+	cwd := t.TempDir()
+	task := recordReplayTask(t, sources.SessionDir, cwd)
+
+	var captured app.TaskRequest
+	originalService := newService
+	newService = func() app.Service {
+		return &fakeTaskService{events: func(ctx context.Context, req app.TaskRequest) (<-chan app.Event, error) {
+			captured = req
+			events := make(chan app.Event, 1)
+			events <- app.Event{Kind: app.RunKindTask, Type: app.EventCompleted, FinalOutput: "30 lines"}
+			close(events)
+			return events, nil
+		}}
+	}
+	t.Cleanup(func() { newService = originalService })
+
+	out, errOut, err := runReplayCommand(t, "", "--reexecute", task.RunID())
+	require.NoError(t, err)
+	assert.Contains(t, out, "30 lines")
+	assert.Contains(t, errOut, "with 2 recorded model responses")
+	assert.Contains(t, errOut, "2 of 2 recorded model responses unused")
+	assert.Equal(t, "list the logs", captured.Message)
+	assert.Equal(t, "bedrock", captured.Provider)
+	assert.Equal(t, cwd, captured.WorkingDir)
+	replay, ok := captured.Connector.(*connector.ReplayConnector)
+	require.True(t, ok)
+	assert.Equal(t, 2, replay.Remaining())
+
+	_, _, err = runReplayCommand(t, "", "--reexecute", "--dir", "/does/not/exist", task.RunID())
+	assert.ErrorContains(t, err, "cannot re-execute in /does/not/exist")
+}
@@ -2,8 +2,10 @@ package commands
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"text/tabwriter"
@@ -39,6 +41,9 @@ func NewRoutineCommand(cfg config.Config) *cobra.Command {
 	cmd.AddCommand(routineEnableCommand(cfg, false))
 	cmd.AddCommand(routineDeleteCommand(cfg))
 	cmd.AddCommand(routineLogsCommand(cfg))
+	cmd.AddCommand(routineDiffCommand(cfg))
+	cmd.AddCommand(routineExportCommand(cfg))
+	cmd.AddCommand(routineImportCommand(cfg))
 
 	return cmd
 }
@@ -48,9 +53,12 @@ func routineCreateCommand(cfg config.Config) *cobra.Command {
 		name, promptFlag, promptFile, cron string
 		provider, model, timeout, workdir  string
 		id                                 string
-		toolsFlag, denyFlag                []string
+		toolsFlag, denyFlag, notifyFlag    []string
+		triggerFlag, paramFlag             []string
+		debounce, backoff, catchUp         string
 		tokenBudget, maxTurns, maxToolCall int
-		disabled                           bool
+		maxAttempts, disableAfter, prio    int
+		disabled, recursive, onlyOnChange  bool
 	)
 
 	cmd := &cobra.Command{
@@ -64,17 +72,24 @@ func routineCreateCommand(cfg config.Config) *cobra.Command {
 			}
 			flags := cmd.Flags()
 			routine := routines.Routine{
-				ID:         strings.TrimSpace(id),
-				Name:       strings.TrimSpace(name),
-				Prompt:     prompt,
-				Schedule:   strings.TrimSpace(cron),
-				Provider:   strings.TrimSpace(provider),
-				Model:      strings.TrimSpace(model),
-				Timeout:    strings.TrimSpace(timeout),
-				WorkingDir: strings.TrimSpace(workdir),
-				Tools:      toolsFlag,
-				Deny:       denyFlag,
-				Enabled:    !disabled,
+				ID:                 strings.TrimSpace(id),
+				Name:               strings.TrimSpace(name),
+				Prompt:             prompt,
+				Schedule:           strings.TrimSpace(cron),
+				Provider:           strings.TrimSpace(provider),
+				Model:              strings.TrimSpace(model),
+				Timeout:            strings.TrimSpace(timeout),
+				WorkingDir:         strings.TrimSpace(workdir),
+				Tools:              toolsFlag,
+				Deny:               denyFlag,
+				DisableAfter:       disableAfter,
+				Priority:           prio,
+				CatchUp:            strings.TrimSpace(catchUp),
+				OnlyNotifyOnChange: onlyOnChange,
+				Enabled:            !disabled,
+			}
+			if maxAttempts > 1 || strings.TrimSpace(backoff) != "" {
+				routine.Retry = &routines.RetryPolicy{MaxAttempts: maxAttempts, Backoff: strings.TrimSpace(backoff)}
 			}
 			if flags.Changed("token-budget") {
 				routine.TokenBudget = &tokenBudget
@@ -85,13 +100,43 @@ func routineCreateCommand(cfg config.Config) *cobra.Command {
 			if flags.Changed("max-tool-calls") {
 				routine.MaxToolCalls = &maxToolCall
 			}
+			for _, spec := range triggerFlag {
+				trigger, err := routines.ParseTrigger(spec)
+				if err != nil {
+					return fmt.Errorf("invalid --trigger %q: %w", spec, err)
+				}
+				if trigger.Type == routines.TriggerFile {
+					trigger.Debounce = strings.TrimSpace(debounce)
+					trigger.Recursive = recursive
+				}
+				routine.Triggers = append(routine.Triggers, trigger)
+			}
+			for _, spec := range paramFlag {
+				name, value, err := routines.ParseParam(spec)
+				if err != nil {
+					return fmt.Errorf("invalid --param: %w", err)
+				}
+				routine.Params = append(routine.Params, routines.Param{Name: name, Default: value})
+			}
+			for _, spec := range notifyFlag {
+				notification, err := routines.ParseNotification(spec)
+				if err != nil {
+					return fmt.Errorf("invalid --notify %q: %w", spec, err)
+				}
+				routine.Notify = append(routine.Notify, notification)
+			}
 
 			saved, err := newRoutineService(cfg).Create(cmd.Context(), routine)
 			if err != nil {
 				return err
 			}
 			cmd.Printf("Created routine %q.\n", saved.ID)
-			if saved.Schedule == "" {
+			for _, t := range saved.Triggers {
+				if t.Type == routines.TriggerWebhook {
+					cmd.Printf("Webhook: POST http://%s/routines/%s/trigger with the header Authorization: Bearer %s\n", routineWebhookAddr(), saved.ID, t.Token)
+				}
+			}
+			if saved.Schedule == "" && len(saved.Triggers) == 0 {
 				cmd.Println("No schedule set; run it with `agent routine run " + saved.ID + "`.")
 			} else {
 				offerDaemonInstall(cmd)
@@ -115,6 +160,17 @@ func routineCreateCommand(cfg config.Config) *cobra.Command {
 	flags.StringVar(&workdir, "workdir", "", "working directory for the run")
 	flags.StringSliceVar(&toolsFlag, "tools", nil, "enabled tools (default policy disables external-facing tools)")
 	flags.StringSliceVar(&denyFlag, "deny", nil, "routine-scoped deny rules")
+	flags.StringArrayVar(&triggerFlag, "trigger", nil, "fire on file:<path>[@<globs>], webhook[:<token>], routine:<id>[@<outcomes>] or system:<login|network-up|wake> (repeatable)")
+	flags.StringVar(&debounce, "debounce", "", "how long file triggers wait for changes to settle (Go duration; default 2s)")
+	flags.BoolVar(&recursive, "recursive", false, "file triggers also watch subdirectories")
+	flags.StringArrayVar(&notifyFlag, "notify", nil, "send results to desktop, email:<to>, webhook:<url> or slack:<url>, optionally followed by @<events> (repeatable)")
+	flags.BoolVar(&onlyOnChange, "only-notify-on-change", false, "notify on successful runs only when the output changed")
+	flags.StringArrayVar(&paramFlag, "param", nil, "declare a prompt parameter as name=default, read as {{.Params.name}} (repeatable)")
+	flags.IntVar(&maxAttempts, "max-attempts", 0, "attempts for a failed or timed-out unattended run, including the first (0 = no retries)")
+	flags.StringVar(&backoff, "backoff", "", "wait before the first retry, doubling per attempt (Go duration; default 30s)")
+	flags.IntVar(&disableAfter, "disable-after", 0, "disable the routine after this many consecutive failed runs (0 = never)")
+	flags.IntVar(&prio, "priority", 0, "position in the daemon's run queue; higher runs first")
+	flags.StringVar(&catchUp, "catch-up", "", "scheduled runs missed while asleep or stopped: skip (default), once or all")
 	flags.BoolVar(&disabled, "disabled", false, "create the routine disabled")
 	return cmd
 }
@@ -167,6 +223,12 @@ func routineShowCommand(cfg config.Config) *cobra.Command {
 			cmd.Printf("Name:      %s\n", orDefault(r.Name))
 			cmd.Printf("Status:    %s\n", v.Status)
 			cmd.Printf("Schedule:  %s\n", v.Frequency)
+			if r.CatchUp != "" && r.CatchUp != routines.CatchUpSkip {
+				cmd.Printf("Catch up:  %s missed runs\n", r.CatchUp)
+			}
+			if r.Priority != 0 {
+				cmd.Printf("Priority:  %d\n", r.Priority)
+			}
 			cmd.Printf("Provider:  %s\n", orDefault(r.Provider))
 			cmd.Printf("Model:     %s\n", orDefault(r.Model))
 			cmd.Printf("Timeout:   %s\n", orDefault(r.Timeout))
@@ -174,11 +236,47 @@ func routineShowCommand(cfg config.Config) *cobra.Command {
 			if len(r.Deny) > 0 {
 				cmd.Printf("Deny:      %s\n", strings.Join(r.Deny, ", "))
 			}
+			for _, t := range r.Triggers {
+				cmd.Printf("Trigger:   %s\n", t.Describe())
+				if t.Type == routines.TriggerWebhook {
+					cmd.Printf("           POST http://%s/routines/%s/trigger\n", routineWebhookAddr(), r.ID)
+					cmd.Printf("           Authorization: Bearer %s\n", t.Token)
+				}
+			}
+			for _, n := range r.Notify {
+				cmd.Printf("Notify:    %s\n", n.Describe())
+			}
+			if r.OnlyNotifyOnChange {
+				cmd.Println("Notify:    successful runs only when the output changed")
+			}
+			for _, p := range r.Params {
+				param := fmt.Sprintf("%s = %q", p.Name, p.Default)
+				if p.Description != "" {
+					param += " (" + p.Description + ")"
+				}
+				cmd.Printf("Param:     %s\n", param)
+			}
+			if r.Retry != nil {
+				cmd.Printf("Retry:     %s\n", formatRetryPolicy(r.Retry))
+			}
+			if r.DisableAfter > 0 {
+				cmd.Printf("Disable after: %d consecutive failures\n", r.DisableAfter)
+			}
 			if v.HasRun {
 				cmd.Printf("Last run:  %s (%s)\n", formatRoutineTime(v.Run.LastRunAt), v.Run.LastStatus)
 				if v.Run.LastError != "" {
 					cmd.Printf("Last error: %s\n", v.Run.LastError)
 				}
+				for _, attempt := range v.Run.Attempts {
+					cmd.Printf("  Attempt %d: %s\n", attempt.Number, formatAttempt(attempt))
+				}
+			}
+			if r.IsWorkflow() {
+				cmd.Printf("\nSteps:\n")
+				for _, step := range r.Steps {
+					cmd.Printf("  %s\n", step.Describe())
+				}
+				return nil
 			}
 			cmd.Printf("\nPrompt:\n%s\n", r.Prompt)
 			return nil
@@ -187,22 +285,49 @@ func routineShowCommand(cfg config.Config) *cobra.Command {
 }
 
 func routineRunCommand(cfg config.Config) *cobra.Command {
-	var scheduled bool
+	var scheduled, eventStdin, dryRun bool
 	var print bool
+	var paramFlag []string
 	cmd := &cobra.Command{
 		Use:          "run <id>",
 		Short:        "Run a routine now",
 		Args:         cobra.ExactArgs(1),
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			trigger := routines.TriggerManual
+			req := app.RoutineRunRequest{IDOrName: args[0], Trigger: routines.TriggerManual}
+			for _, spec := range paramFlag {
+				name, value, err := routines.ParseParam(spec)
+				if err != nil {
+					return fmt.Errorf("invalid --param: %w", err)
+				}
+				if req.Params == nil {
+					req.Params = map[string]string{}
+				}
+				req.Params[name] = value
+			}
 			if scheduled {
-				trigger = routines.TriggerScheduled
+				req.Trigger = routines.TriggerScheduled
 			}
-			result, err := newRoutineService(cfg).Run(cmd.Context(), app.RoutineRunRequest{
-				IDOrName: args[0],
-				Trigger:  trigger,
-			})
+			if eventStdin {
+				// The daemon passes a fired trigger's payload as JSON on stdin.
+				var event routines.TriggerEvent
+				if err := json.NewDecoder(cmd.InOrStdin()).Decode(&event); err != nil {
+					return fmt.Errorf("read trigger event: %w", err)
+				}
+				req.Event, req.Trigger = &event, ""
+			}
+			svc := newRoutineService(cfg)
+			if dryRun {
+				v, err := svc.Get(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				cmd.Printf("Dry run of routine %q: mutating tool calls are recorded, not executed.\n", v.Routine.ID)
+				printRoutineSettings(cmd, v.Settings)
+				cmd.Println()
+				req.DryRun = true
+			}
+			result, err := svc.Run(cmd.Context(), req)
 			if errors.Is(err, routines.ErrRunInProgress) {
 				cmd.Printf("Routine %q is already running; skipped.\n", args[0])
 				return nil
@@ -210,6 +335,9 @@ func routineRunCommand(cfg config.Config) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if result.DryRun {
+				printSimulatedCalls(cmd, result.Simulated)
+			}
 			if print && strings.TrimSpace(result.Output) != "" {
 				cmd.Println(strings.TrimSpace(result.Output))
 			}
@@ -221,7 +349,11 @@ func routineRunCommand(cfg config.Config) *cobra.Command {
 	}
 	cmd.Flags().BoolVar(&scheduled, "scheduled", false, "mark this as a scheduler-originated run")
 	cmd.Flags().BoolVar(&print, "print", true, "print the routine's final output")
+	cmd.Flags().BoolVar(&eventStdin, "event-stdin", false, "read the firing trigger's payload as JSON from stdin")
+	cmd.Flags().StringArrayVar(&paramFlag, "param", nil, "set a declared prompt parameter as name=value (repeatable)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "simulate mutating tool calls and list them instead of running them; nothing is recorded")
 	_ = cmd.Flags().MarkHidden("scheduled")
+	_ = cmd.Flags().MarkHidden("event-stdin")
 	return cmd
 }
 
@@ -280,6 +412,141 @@ func routineDeleteCommand(cfg config.Config) *cobra.Command {
 	return cmd
 }
 
+func routineDiffCommand(cfg config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <id> [run-a [run-b]]",
+		Short: "Show how a routine's output changed between runs",
+		Long: "Show a unified diff of two runs' outputs. Runs are named by run id or summary name (see `routine logs`).\n" +
+			"Without runs, the last two successful runs are compared; with one, it is compared with the latest run.",
+		Args:         cobra.RangeArgs(1, 3),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var from, to string
+			if len(args) > 1 {
+				from = args[1]
+			}
+			if len(args) > 2 {
+				to = args[2]
+			}
+			diff, err := newRoutineService(cfg).Diff(cmd.Context(), args[0], from, to)
+			if err != nil {
+				return err
+			}
+			if diff.Diff == "" {
+				cmd.Printf("No changes between %s and %s.\n", diff.From, diff.To)
+				return nil
+			}
+			cmd.Println(diff.Diff)
+			return nil
+		},
+	}
+}
+
+func routineExportCommand(cfg config.Config) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:          "export <id...>",
+		Short:        "Write routines to a portable bundle",
+		Long:         "Write the routines' definitions as a JSON bundle, to stdout or --output, for `agent routine import` on another machine.",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			bundle, err := newRoutineService(cfg).Export(cmd.Context(), args)
+			if err != nil {
+				return err
+			}
+			data, err := json.MarshalIndent(bundle, "", "  ")
+			if err != nil {
+				return err
+			}
+			data = append(data, '\n')
+			if output == "" || output == "-" {
+				_, err = cmd.OutOrStdout().Write(data)
+				return err
+			}
+			if err := os.WriteFile(output, data, 0o644); err != nil {
+				return err
+			}
+			cmd.Printf("Exported %d routine(s) to %s.\n", len(bundle.Routines), output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the bundle to a file instead of stdout")
+	return cmd
+}
+
+func routineImportCommand(cfg config.Config) *cobra.Command {
+	var opts app.RoutineImportOptions
+	cmd := &cobra.Command{
+		Use:   "import <bundle.json|->",
+		Short: "Import routines from a bundle",
+		Long: "Import the routines of a bundle written by `agent routine export` (or a routines definitions file).\n" +
+			"Routines are validated first; an invalid bundle imports nothing. Tools a routine enables by name are limited\n" +
+			"to the local built-in tools unless --allow-external-tools is set, deny rules must parse, workflow steps may\n" +
+			"only run routines of the bundle, and notifications are dropped unless --keep-notifications is set. The\n" +
+			"triggers, notifications and working directory each routine keeps are listed for review.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				data []byte
+				err  error
+			)
+			if args[0] == "-" {
+				data, err = io.ReadAll(cmd.InOrStdin())
+			} else {
+				data, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				return err
+			}
+			bundle, err := routines.ParseBundle(data)
+			if err != nil {
+				return err
+			}
+			results, err := newRoutineService(cfg).Import(cmd.Context(), bundle, opts)
+			if err != nil {
+				return err
+			}
+			imported, scheduled := 0, false
+			for _, result := range results {
+				switch result.Action {
+				case routines.ImportSkipped:
+					cmd.Printf("Skipped %q: a routine with that id exists (use --on-conflict rename or overwrite).\n", result.BundleID)
+					continue
+				case routines.ImportRenamed:
+					cmd.Printf("Imported %q as %q.\n", result.BundleID, result.ID)
+				case routines.ImportOverwritten:
+					cmd.Printf("Replaced routine %q.\n", result.ID)
+				default:
+					cmd.Printf("Imported routine %q.\n", result.ID)
+				}
+				imported++
+				for _, note := range result.Notes {
+					cmd.Printf("  %s\n", note)
+				}
+				for _, r := range bundle.Routines {
+					if r.ID == result.BundleID && !opts.Disabled && r.Enabled && (r.Schedule != "" || len(r.Triggers) > 0) {
+						scheduled = true
+					}
+				}
+			}
+			if imported > 0 && opts.Disabled {
+				cmd.Println("Imported routines are disabled; review them with `agent routine show` and enable them with `agent routine enable`.")
+			} else if scheduled {
+				offerDaemonInstall(cmd)
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&opts.OnConflict, "on-conflict", routines.ConflictSkip, "when a routine id exists: skip, rename or overwrite")
+	flags.BoolVar(&opts.Disabled, "disabled", false, "import every routine disabled")
+	flags.BoolVar(&opts.AllowExternalTools, "allow-external-tools", false, "keep external-facing and MCP tools the bundle enables by name")
+	flags.BoolVar(&opts.KeepNotifications, "keep-notifications", false, "keep the notification targets bundled routines send their results to")
+	return cmd
+}
+
 func routineLogsCommand(cfg config.Config) *cobra.Command {
 	var last bool
 	cmd := &cobra.Command{
@@ -433,6 +700,87 @@ func orDefault(value string) string {
 	return value
 }
 
+// formatRetryPolicy renders a retry policy on one line for `routine show`.
+func formatRetryPolicy(p *routines.RetryPolicy) string {
+	desc := fmt.Sprintf("%d attempts", p.Attempts())
+	if p.Attempts() > 1 {
+		desc += fmt.Sprintf(", waiting %s up to %s between them", p.Delay(1), p.Delay(p.Attempts()-1))
+	}
+	if len(p.On) > 0 {
+		desc += " on " + strings.Join(p.On, ", ")
+	}
+	return desc
+}
+
+// formatAttempt renders one attempt of the last run for `routine show`.
+func formatAttempt(a routines.Attempt) string {
+	desc := fmt.Sprintf("%s at %s", a.Outcome, formatRoutineTime(a.StartedAt))
+	if a.Duration != "" {
+		desc += " in " + a.Duration
+	}
+	if !a.NextRetryAt.IsZero() {
+		desc += ", retry at " + formatRoutineTime(a.NextRetryAt)
+	}
+	return desc
+}
+
+// routineWebhookAddr is the address the daemon serves webhook triggers on.
+func routineWebhookAddr() string {
+	if cfg, err := config.LoadRoutinesConfig(); err == nil && strings.TrimSpace(cfg.WebhookAddr) != "" {
+		return strings.TrimSpace(cfg.WebhookAddr)
+	}
+	return config.DefaultRoutineWebhookAddr
+}
+
+// printRoutineSettings prints the settings a run resolves, as `routine run
+// --dry-run` shows them before it starts.
+func printRoutineSettings(cmd *cobra.Command, s app.RoutineSettings) {
+	cmd.Printf("Provider:  %s\n", s.Provider)
+	cmd.Printf("Model:     %s\n", s.Model)
+	cmd.Printf("Workdir:   %s\n", orDefault(s.WorkingDir))
+	timeout := "unlimited"
+	if s.Timeout > 0 {
+		timeout = s.Timeout.String()
+	}
+	cmd.Printf("Timeout:   %s\n", timeout)
+	budget := "unlimited"
+	if s.TokenBudget > 0 {
+		budget = fmt.Sprintf("%d tokens", s.TokenBudget)
+	}
+	cmd.Printf("Budget:    %s\n", budget)
+	cmd.Printf("Max turns: %s, max tool calls: %s\n", formatStepLimit(s.MaxTurns), formatStepLimit(s.MaxToolCalls))
+	cmd.Printf("Tools:     %s\n", formatToolPolicy(s.Tools))
+	deny := "(none)"
+	if len(s.Deny) > 0 {
+		deny = strings.Join(s.Deny, ", ")
+	}
+	cmd.Printf("Deny:      %s\n", deny)
+}
+
+func formatStepLimit(limit int) string {
+	if limit <= 0 {
+		return "(default)"
+	}
+	return fmt.Sprint(limit)
+}
+
+// printSimulatedCalls lists the calls a dry run intercepted.
+func printSimulatedCalls(cmd *cobra.Command, calls []app.SimulatedCall) {
+	if len(calls) == 0 {
+		cmd.Println("Intended actions: none; the run made no mutating tool calls.")
+		return
+	}
+	cmd.Println("Intended actions:")
+	for i, call := range calls {
+		step := ""
+		if call.Step != "" {
+			step = "[" + call.Step + "] "
+		}
+		cmd.Printf("  %d. %s%s\n", i+1, step, call.Action)
+	}
+	cmd.Println()
+}
+
 func formatToolPolicy(toolsList []string) string {
 	if toolsList == nil {
 		return "default (external-facing disabled)"
@@ -13,7 +13,7 @@ import (
 	"github.com/laszukdawid/terminal-agent/internal/agent"
 	"github.com/laszukdawid/terminal-agent/internal/app"
 	"github.com/laszukdawid/terminal-agent/internal/config"
-	"github.com/laszukdawid/terminal-agent/internal/history"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
 	"github.com/laszukdawid/terminal-agent/internal/tools"
 	"github.com/mattn/go-runewidth"
 	"github.com/spf13/cobra"
@@ -22,6 +22,8 @@ import (
 
 var newService = app.NewService
 
+var checkAutoApproveAllowed = config.CheckAutoApproveAllowed
+
 func NewTaskCommand(config config.Config) *cobra.Command {
 	var provider *string
 	var modelID *string
@@ -69,6 +71,13 @@ func NewTaskCommand(config config.Config) *cobra.Command {
 			if err != nil {
 				autoApprove = false
 			}
+			if autoApprove {
+				if err := checkAutoApproveAllowed(); err != nil {
+					return err
+				}
+			}
+
+			memoryFlag, _ := flags.GetBool("memory")
 
 			events, err := service.TaskEvents(ctx, app.TaskRequest{
 				Message:        userRequest,
@@ -80,6 +89,8 @@ func NewTaskCommand(config config.Config) *cobra.Command {
 				AutoApprove:    autoApprove,
 				Device:         device,
 				Timeout:        taskTimeout,
+				UseMemory:      config.GetMemory() || memoryFlag,
+				MemoryPath:     getMemoryPath(),
 				Config:         config,
 			})
 			if err != nil {
@@ -94,93 +105,23 @@ func NewTaskCommand(config config.Config) *cobra.Command {
 			if err != nil {
 				progressMode = "auto"
 			}
-			progressConfig, err := resolveTaskProgress(progressMode, cmd.ErrOrStderr())
+			plain, _ := flags.GetBool("plain")
+			_, response, err := printTaskEvents(cmd, events, inputReader, taskOutputOptions{
+				print:           printFlag,
+				plain:           plain,
+				progress:        progressMode,
+				liveOutputLimit: config.GetTaskLiveOutputLimit(),
+			})
 			if err != nil {
 				return err
 			}
-			progress := newTaskProgressPrinter(cmd.ErrOrStderr(), progressConfig)
-			defer progress.Clear()
-			liveOutput := newTaskLiveOutputPrinter(cmd.OutOrStdout(), progress, config.GetTaskLiveOutputLimit())
-
-			result := app.TaskResult{Request: userRequest}
-			for event := range events {
-				switch event.Type {
-				case app.EventTaskStatus:
-					if event.Status == string(agent.TaskStatusRunningTool) {
-						liveOutput.BeginTool(event)
-					}
-					progress.Print(event.Text)
-				case app.EventToolProgress:
-					progress.Print(formatToolProgress(event))
-				case app.EventOutputDelta:
-					if printFlag {
-						liveOutput.PrintDelta(event.Text)
-					}
-				case app.EventWarning:
-					if event.Text != "" {
-						progress.Clear()
-						cmd.PrintErrf("Warning: %s\n", event.Text)
-					}
-				case app.EventConfirmationNeeded:
-					progress.Clear()
-					decision, promptErr := promptTaskConfirmation(cmd, inputReader, event.Confirmation)
-					if promptErr != nil {
-						return promptErr
-					}
-					if replyErr := event.Confirmation.Reply(decision); replyErr != nil {
-						return replyErr
-					}
-				case app.EventClarificationNeeded:
-					progress.Clear()
-					answer, promptErr := promptTaskClarification(cmd, inputReader, event.Clarification)
-					if promptErr != nil {
-						return promptErr
-					}
-					if replyErr := event.Clarification.Reply(answer); replyErr != nil {
-						return replyErr
-					}
-					if printFlag {
-						if liveOutput.NeedsNewlineBeforeFinal() {
-							cmd.Print("\n")
-						}
-						cmd.Println(formatTaskClarificationTrace(answer, isTerminalWriter(cmd.OutOrStdout())))
-					}
-				case app.EventCompleted:
-					result.Response = event.FinalOutput
-					if !liveOutput.PrintedTool(event.RawOutputTool) || liveOutput.TruncatedTool(event.RawOutputTool) {
-						result.RawOutput = event.RawOutput
-					}
-					result.RawOutputTool = event.RawOutputTool
-					result.DirectRawOutput = event.DirectRawOutput
-				case app.EventFailed:
-					progress.Clear()
-					return fmt.Errorf("failed to request a task: %w", event.Err)
-				}
-			}
-			progress.Clear()
-
-			response := result.Response
-
-			if liveOutput.PrintedTool(result.RawOutputTool) && !liveOutput.TruncatedTool(result.RawOutputTool) && result.DirectRawOutput {
-				response = ""
-			}
-
-			if printFlag && response != "" {
-				if liveOutput.NeedsNewlineBeforeFinal() {
-					cmd.Print("\n")
-				}
-				plain, _ := flags.GetBool("plain")
-				cmd.Print(formatTaskOutput(app.TaskResult{
-					Response:        response,
-					RawOutput:       result.RawOutput,
-					RawOutputTool:   result.RawOutputTool,
-					DirectRawOutput: result.DirectRawOutput,
-				}, plain))
-			}
 
 			if logFlag, err := flags.GetBool("log"); logFlag && err == nil {
-				hClient := history.NewHistory(getLogPath())
-				hClient.Log("task", result.Request, response)
+				hClient, err := newRedactedHistory()
+				if err != nil {
+					return err
+				}
+				hClient.Log("task", userRequest, response)
 			}
 
 			return nil
@@ -204,6 +145,9 @@ func NewTaskCommand(config config.Config) *cobra.Command {
 	// 'plain' flag whether to render the response as plain text (default: false)
 	cmd.Flags().BoolP("plain", "k", false, "Render the response as plain text")
 
+	// 'memory' flag whether to include the relevant memory entries in the prompt
+	cmd.Flags().BoolP("memory", "M", false, "Include relevant memory entries in the system prompt")
+
 	// 'log' flag whether to log the input and output to a file (default: false)
 	cmd.Flags().BoolP("log", "l", false, "Log the input and output to a file")
 
@@ -211,6 +155,108 @@ func NewTaskCommand(config config.Config) *cobra.Command {
 	return cmd
 }
 
+// taskOutputOptions controls how printTaskEvents shows a task run.
+type taskOutputOptions struct {
+	print           bool
+	plain           bool
+	progress        string // auto, always or never
+	liveOutputLimit int
+}
+
+// printTaskEvents shows a task run's progress and output, prompting for
+// confirmations and clarifications on the way. It returns the run's result
+// and the response as printed, which is empty when the tool output already
+// said it all.
+func printTaskEvents(cmd *cobra.Command, events <-chan app.Event, inputReader *bufio.Reader, opts taskOutputOptions) (app.TaskResult, string, error) {
+	progressConfig, err := resolveTaskProgress(opts.progress, cmd.ErrOrStderr())
+	if err != nil {
+		return app.TaskResult{}, "", err
+	}
+	progress := newTaskProgressPrinter(cmd.ErrOrStderr(), progressConfig)
+	defer progress.Clear()
+	liveOutput := newTaskLiveOutputPrinter(cmd.OutOrStdout(), progress, opts.liveOutputLimit)
+
+	result := app.TaskResult{}
+	for event := range events {
+		switch event.Type {
+		case app.EventTaskStatus:
+			if event.Status == string(agent.TaskStatusRunningTool) {
+				liveOutput.BeginTool(event)
+			}
+			progress.Print(event.Text)
+		case app.EventToolProgress:
+			progress.Print(formatToolProgress(event))
+		case app.EventOutputDelta:
+			if opts.print {
+				liveOutput.PrintDelta(event.Text)
+			}
+		case app.EventWarning:
+			if event.Text != "" {
+				progress.Clear()
+				cmd.PrintErrf("Warning: %s\n", event.Text)
+			}
+		case app.EventConfirmationNeeded:
+			progress.Clear()
+			decision, promptErr := promptTaskConfirmation(cmd, inputReader, event.Confirmation)
+			if promptErr != nil {
+				return app.TaskResult{}, "", promptErr
+			}
+			if replyErr := event.Confirmation.Reply(decision); replyErr != nil {
+				return app.TaskResult{}, "", replyErr
+			}
+		case app.EventClarificationNeeded:
+			progress.Clear()
+			answer, promptErr := promptTaskClarification(cmd, inputReader, event.Clarification)
+			if promptErr != nil {
+				return app.TaskResult{}, "", promptErr
+			}
+			if replyErr := event.Clarification.Reply(answer); replyErr != nil {
+				return app.TaskResult{}, "", replyErr
+			}
+			if opts.print {
+				if liveOutput.NeedsNewlineBeforeFinal() {
+					cmd.Print("\n")
+				}
+				cmd.Println(formatTaskClarificationTrace(answer, isTerminalWriter(cmd.OutOrStdout())))
+			}
+		case app.EventCompleted:
+			result.Response = event.FinalOutput
+			if !liveOutput.PrintedTool(event.RawOutputTool) || liveOutput.TruncatedTool(event.RawOutputTool) {
+				result.RawOutput = event.RawOutput
+			}
+			result.RawOutputTool = event.RawOutputTool
+			result.DirectRawOutput = event.DirectRawOutput
+			result.Redactions = event.Redactions
+		case app.EventFailed:
+			progress.Clear()
+			return app.TaskResult{}, "", fmt.Errorf("failed to request a task: %w", event.Err)
+		}
+	}
+	progress.Clear()
+	if summary := redact.Summary(result.Redactions); summary != "" {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Redacted secrets before sending to the model: %s\n", summary)
+	}
+
+	response := result.Response
+
+	if liveOutput.PrintedTool(result.RawOutputTool) && !liveOutput.TruncatedTool(result.RawOutputTool) && result.DirectRawOutput {
+		response = ""
+	}
+
+	if opts.print && response != "" {
+		if liveOutput.NeedsNewlineBeforeFinal() {
+			cmd.Print("\n")
+		}
+		cmd.Print(formatTaskOutput(app.TaskResult{
+			Response:        response,
+			RawOutput:       result.RawOutput,
+			RawOutputTool:   result.RawOutputTool,
+			DirectRawOutput: result.DirectRawOutput,
+		}, opts.plain))
+	}
+	return result, response, nil
+}
+
 type taskProgressPrinter struct {
 	enabled     bool
 	interactive bool
@@ -428,6 +474,8 @@ func promptTaskConfirmation(cmd *cobra.Command, reader *bufio.Reader, confirmati
 
 func promptTaskConfirmationInteractive(stdin *os.File, stderr *os.File, confirmation *app.TaskConfirmationEvent) (app.TaskConfirmationResponse, error) {
 	ic := newInteractiveConfirmation(confirmation.Action, stdin, stderr)
+	ic.reason = confirmation.Reason
+	ic.risk = formatConfirmationRisk(confirmation)
 	result, err := ic.run()
 	if err != nil {
 		return app.TaskConfirmationResponse{}, err
@@ -454,6 +502,12 @@ func promptTaskConfirmationLine(cmd *cobra.Command, reader *bufio.Reader, confir
 			header = "Run Python script?"
 		}
 	}
+	if risk := formatConfirmationRisk(confirmation); risk != "" {
+		header += "\nRisk: " + risk
+	}
+	if confirmation.Reason != "" {
+		header += "\nPolicy: " + confirmation.Reason
+	}
 	if _, err := fmt.Fprintf(cmd.ErrOrStderr(), "%s\n%s\n[y/N/a/b]: ", header, indentDisplayLines(display)); err != nil {
 		return app.TaskConfirmationResponse{}, err
 	}
@@ -466,6 +520,15 @@ func promptTaskConfirmationLine(cmd *cobra.Command, reader *bufio.Reader, confir
 	return processConfirmationResponse(strings.TrimSpace(response), confirmation.Action)
 }
 
+// formatConfirmationRisk renders a command's risk for the prompt, e.g.
+// "high (recursive forced delete)".
+func formatConfirmationRisk(confirmation *app.TaskConfirmationEvent) string {
+	if confirmation.Risk == "" || len(confirmation.RiskReasons) == 0 {
+		return confirmation.Risk
+	}
+	return confirmation.Risk + " (" + strings.Join(confirmation.RiskReasons, "; ") + ")"
+}
+
 func indentDisplayLines(display string) string {
 	var lines []string
 	for _, line := range splitDisplayLines(display) {
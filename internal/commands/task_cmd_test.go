@@ -32,6 +32,10 @@ func (s *fakeTaskService) Chat(context.Context, app.ChatRequest) (app.ChatResult
 	panic("unexpected Chat call")
 }
 
+func (s *fakeTaskService) CompactChat(context.Context, app.ChatRequest) (app.ChatCompactResult, error) {
+	panic("unexpected CompactChat call")
+}
+
 func (s *fakeTaskService) ChatEvents(context.Context, app.ChatRequest) (<-chan app.Event, error) {
 	panic("unexpected ChatEvents call")
 }
@@ -110,6 +114,21 @@ func TestPromptTaskConfirmationLineDisplaysToolActions(t *testing.T) {
 	}
 }
 
+func TestPromptTaskConfirmationLineShowsRisk(t *testing.T) {
+	cmd := NewTaskCommand(config.NewDefaultConfig())
+	output := &bytes.Buffer{}
+	cmd.SetErr(output)
+
+	_, err := promptTaskConfirmationLine(cmd, bufio.NewReader(bytes.NewBufferString("n\n")), &app.TaskConfirmationEvent{
+		Action:      tools.ToolNameUnix + `("rm -rf build")`,
+		Risk:        "high",
+		RiskReasons: []string{"recursive forced delete"},
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, output.String(), "Risk: high (recursive forced delete)")
+}
+
 func TestTaskCommandHandlesInteractiveEvents(t *testing.T) {
 	originalNewService := newService
 	defer func() {
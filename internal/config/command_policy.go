@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Command access classes used by the unix command-policy registry. A command
+// is auto-approved only when every part of it is read.
+const (
+	CommandAccessRead    = "read"
+	CommandAccessNetwork = "network"
+	CommandAccessWrite   = "write"
+)
+
+// CommandPolicy describes how one program's subcommands and flags affect the
+// system. Default classifies subcommands that are not listed (write when
+// empty); Flags escalate the class of any invocation that passes them.
+type CommandPolicy struct {
+	Default     string                       `json:"default,omitempty"`
+	Flags       map[string]string            `json:"flags,omitempty"`
+	Subcommands map[string]CommandSubcommand `json:"subcommands,omitempty"`
+}
+
+// CommandSubcommand classifies one subcommand. Nested subcommands are listed
+// with space-separated keys on the program, e.g. "config view". In JSON a bare
+// string is shorthand for {"access": "..."}.
+type CommandSubcommand struct {
+	Access string            `json:"access"`
+	Flags  map[string]string `json:"flags,omitempty"`
+	// UnlistedFlags, when set, classifies every flag the subcommand and program
+	// do not list, so flags the policy does not know can only widen the class.
+	// Flags are then matched by name, so a cluster such as -fd counts as
+	// unlisted.
+	UnlistedFlags string `json:"unlisted_flags,omitempty"`
+}
+
+func (s *CommandSubcommand) UnmarshalJSON(data []byte) error {
+	var access string
+	if err := json.Unmarshal(data, &access); err == nil {
+		s.Access = access
+		s.Flags = nil
+		return nil
+	}
+	type plain CommandSubcommand
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*s = CommandSubcommand(decoded)
+	return nil
+}
+
+// LoadCommandPolicies reads the command_policies key from the global config.
+// Entries replace the built-in policy for the same program.
+func LoadCommandPolicies() (map[string]CommandPolicy, error) {
+	config, _, err := readConfig()
+	if err != nil {
+		return nil, err
+	}
+	for program, policy := range config.CommandPolicies {
+		if err := validateCommandPolicy(program, policy); err != nil {
+			return nil, err
+		}
+	}
+	return config.CommandPolicies, nil
+}
+
+func validateCommandPolicy(program string, policy CommandPolicy) error {
+	if policy.Default != "" && !validCommandAccess(policy.Default) {
+		return fmt.Errorf("command_policies.%s: invalid default %q (expected read, network or write)", program, policy.Default)
+	}
+	if err := validateCommandFlags(program, policy.Flags); err != nil {
+		return err
+	}
+	for name, sub := range policy.Subcommands {
+		if !validCommandAccess(sub.Access) {
+			return fmt.Errorf("command_policies.%s: subcommand %q has invalid access %q (expected read, network or write)", program, name, sub.Access)
+		}
+		if sub.UnlistedFlags != "" && !validCommandAccess(sub.UnlistedFlags) {
+			return fmt.Errorf("command_policies.%s: subcommand %q has invalid unlisted_flags %q (expected read, network or write)", program, name, sub.UnlistedFlags)
+		}
+		if err := validateCommandFlags(program+" "+name, sub.Flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateCommandFlags(scope string, flags map[string]string) error {
+	for flag, access := range flags {
+		if !validCommandAccess(access) {
+			return fmt.Errorf("command_policies.%s: flag %q has invalid access %q (expected read, network or write)", scope, flag, access)
+		}
+	}
+	return nil
+}
+
+func validCommandAccess(access string) bool {
+	switch access {
+	case CommandAccessRead, CommandAccessNetwork, CommandAccessWrite:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCommandPolicies(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	configDir := filepath.Join(homeDir, ".config", "terminal-agent")
+	require.NoError(t, os.MkdirAll(configDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{
+		"command_policies": {
+			"terraform": {
+				"default": "write",
+				"flags": {"-auto-approve": "write"},
+				"subcommands": {
+					"plan": "read",
+					"state list": {"access": "read", "flags": {"-state-out": "write"}}
+				}
+			}
+		}
+	}`), 0o600))
+
+	policies, err := LoadCommandPolicies()
+	require.NoError(t, err)
+	terraform := policies["terraform"]
+	assert.Equal(t, CommandAccessWrite, terraform.Default)
+	assert.Equal(t, CommandSubcommand{Access: CommandAccessRead}, terraform.Subcommands["plan"])
+	assert.Equal(t, CommandSubcommand{Access: CommandAccessRead, Flags: map[string]string{"-state-out": CommandAccessWrite}}, terraform.Subcommands["state list"])
+}
+
+func TestLoadCommandPoliciesRejectsUnknownAccess(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	configDir := filepath.Join(homeDir, ".config", "terminal-agent")
+	require.NoError(t, os.MkdirAll(configDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{
+		"command_policies": {"make": {"subcommands": {"test": "safe"}}}
+	}`), 0o600))
+
+	_, err := LoadCommandPolicies()
+	assert.ErrorContains(t, err, `subcommand "test" has invalid access "safe"`)
+}
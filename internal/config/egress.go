@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// EgressConfig is the network egress policy for external-facing tools and,
+// when Proxy is set, for the processes started by the unix and python tools.
+// Deny entries win over Allow entries; a non-empty Allow list denies every host
+// it does not match.
+type EgressConfig struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+	// Proxy routes task child processes through a local HTTP proxy that applies
+	// the policy and logs every outbound host to the session log.
+	Proxy bool `json:"proxy,omitempty"`
+}
+
+// LoadEgressConfig reads the egress key from the global config.
+func LoadEgressConfig() (EgressConfig, error) {
+	config, _, err := readConfig()
+	if err != nil {
+		return EgressConfig{}, err
+	}
+	for _, list := range []struct {
+		name     string
+		patterns []string
+	}{{"allow", config.Egress.Allow}, {"deny", config.Egress.Deny}} {
+		for _, pattern := range list.patterns {
+			if err := validateEgressPattern(pattern); err != nil {
+				return EgressConfig{}, fmt.Errorf("egress.%s: %w", list.name, err)
+			}
+		}
+	}
+	return config.Egress, nil
+}
+
+func validateEgressPattern(pattern string) error {
+	pattern = strings.TrimSpace(pattern)
+	switch {
+	case pattern == "":
+		return fmt.Errorf("empty host pattern")
+	case pattern == "*":
+		return nil
+	case strings.Contains(pattern, "/"):
+		if _, _, err := net.ParseCIDR(pattern); err != nil {
+			return fmt.Errorf("invalid CIDR %q", pattern)
+		}
+	case strings.Contains(strings.TrimPrefix(pattern, "*."), "*"):
+		return fmt.Errorf("invalid host pattern %q (only a leading *. wildcard is supported)", pattern)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/laszukdawid/terminal-agent/internal/routines"
 	log "github.com/laszukdawid/terminal-agent/internal/utils"
 	"github.com/spf13/cobra"
 )
@@ -82,22 +83,26 @@ const (
 
 type config struct {
 	LogLevel            string
-	DefaultProvider     string            `json:"default_provider"`
-	Providers           map[string]string `json:"providers"`
-	Bedrock             BedrockConfig     `json:"bedrock,omitempty"`
-	LlamaModels         map[string]string `json:"llama_models,omitempty"`
-	GUI                 GUIConfig         `json:"gui,omitempty"`
-	Device              string            `json:"device,omitempty"`
-	McpFilePath         string            `json:"mcp_file_path"`
-	WorkingDir          string            `json:"working_dir"`
-	MaxTokens           int               `json:"max_tokens"`
-	TaskTimeout         string            `json:"task_timeout,omitempty"`
-	TaskLiveOutputLimit *int              `json:"task_live_output_limit,omitempty"`
-	Memory              bool              `json:"memory"`
-	WebSearch           *bool             `json:"web_search,omitempty"`
-	ProjectContext      *bool             `json:"project_context,omitempty"`
-	Permissions         Permissions       `json:"permissions,omitempty"`
-	Routines            RoutinesConfig    `json:"routines,omitempty"`
+	DefaultProvider     string                   `json:"default_provider"`
+	Providers           map[string]string        `json:"providers"`
+	Bedrock             BedrockConfig            `json:"bedrock,omitempty"`
+	LlamaModels         map[string]string        `json:"llama_models,omitempty"`
+	GUI                 GUIConfig                `json:"gui,omitempty"`
+	Device              string                   `json:"device,omitempty"`
+	McpFilePath         string                   `json:"mcp_file_path"`
+	WorkingDir          string                   `json:"working_dir"`
+	MaxTokens           int                      `json:"max_tokens"`
+	TaskTimeout         string                   `json:"task_timeout,omitempty"`
+	TaskLiveOutputLimit *int                     `json:"task_live_output_limit,omitempty"`
+	Memory              bool                     `json:"memory"`
+	WebSearch           *bool                    `json:"web_search,omitempty"`
+	ProjectContext      *bool                    `json:"project_context,omitempty"`
+	Permissions         Permissions              `json:"permissions,omitempty"`
+	PolicyFile          string                   `json:"policy_file,omitempty"`
+	CommandPolicies     map[string]CommandPolicy `json:"command_policies,omitempty"`
+	Redaction           RedactionConfig          `json:"redaction,omitempty"`
+	Egress              EgressConfig             `json:"egress,omitempty"`
+	Routines            RoutinesConfig           `json:"routines,omitempty"`
 }
 
 // RoutinesConfig holds the master toggle and default settings applied to
@@ -105,7 +110,34 @@ type config struct {
 type RoutinesConfig struct {
 	Enabled  *bool           `json:"enabled,omitempty"`
 	Defaults RoutineDefaults `json:"defaults,omitempty"`
-}
+	// Notifications apply to every routine, in addition to its own.
+	Notifications []routines.Notification `json:"notifications,omitempty"`
+	// SMTP is the mail server used by email notifications.
+	SMTP routines.SMTPSettings `json:"smtp,omitempty"`
+	// WebhookAddr is where the daemon listens for webhook triggers; empty =
+	// DefaultRoutineWebhookAddr.
+	WebhookAddr string `json:"webhook_addr,omitempty"`
+	// QuietHours is a local-time "HH:MM-HH:MM" window in which the daemon does
+	// not start scheduled runs.
+	QuietHours string `json:"quiet_hours,omitempty"`
+	// Jitter is a Go duration; each scheduled run is delayed by a random amount
+	// up to it, so routines sharing a schedule do not start at once.
+	Jitter string `json:"jitter,omitempty"`
+	// MaxConcurrent caps how many routine runs the daemon starts at once; 0 =
+	// DefaultRoutineMaxConcurrent. Further runs wait in the daemon's queue.
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+	// ProviderConcurrency caps concurrent runs per provider name, within
+	// MaxConcurrent.
+	ProviderConcurrency map[string]int `json:"provider_concurrency,omitempty"`
+}
+
+// DefaultRoutineMaxConcurrent is how many routine runs the daemon starts at
+// once unless routines.max_concurrent says otherwise.
+const DefaultRoutineMaxConcurrent = 2
+
+// DefaultRoutineWebhookAddr is the loopback address the daemon serves webhook
+// triggers on unless routines.webhook_addr says otherwise.
+const DefaultRoutineWebhookAddr = "127.0.0.1:8787"
 
 // RoutineDefaults are the per-field fallbacks for routines. Empty/nil fields mean
 // "not configured": the run resolves them against the routine's own fields first,
@@ -220,29 +252,44 @@ func LoadConfig() (*config, error) {
 		return nil, err
 	}
 
-	file, err := os.Open(configPath)
+	config, found, err := readConfig()
 	if err != nil {
 		return nil, err
 	}
+	if !found {
+		c := NewDefaultConfig()
+		SaveConfig(c)
+		return c, nil
+	}
+	return config, nil
+}
+
+// readConfig decodes the config file without creating it. A missing or empty
+// file decodes as an empty config and reports false.
+func readConfig() (*config, bool, error) {
+	file, err := os.Open(getConfigPath())
+	if os.IsNotExist(err) {
+		return &config{}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
 
 	content, err := io.ReadAll(file)
 	file.Close()
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return &config{}, false, nil
 	}
 
-	if len(content) == 0 {
-		c := NewDefaultConfig()
-		SaveConfig(c)
-		return c, nil
-	} else {
-		// Decode the JSON content
-		config := &config{}
-		if err := json.Unmarshal(content, config); err != nil {
-			return nil, err
-		}
-		return config, nil
+	// Decode the JSON content
+	config := &config{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, false, err
 	}
+	return config, true, nil
 }
 
 func SaveConfig(config *config) error {
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+const defaultManagedPolicyPath = "/etc/terminal-agent/policy.json"
+
+// managedPolicyPath is where the organisation policy is read from. It is not
+// configurable at runtime, so that a user cannot point it at a file of their
+// own and drop the policy; tests replace it.
+var managedPolicyPath = defaultManagedPolicyPath
+
+// ManagedPermissionPriority ranks managed permission rules above every user,
+// local, CLI and remembered rule so they cannot be overridden.
+const ManagedPermissionPriority = 1 << 30
+
+// ErrAutoApproveForbidden is returned when a run requests auto-approve but the
+// organisation-managed policy forbids it.
+var ErrAutoApproveForbidden = errors.New("auto-approve is disabled by the organisation policy")
+
+// ManagedPolicy is a system-level policy maintained by an administrator. Its
+// deny and ask rules and expression rules sit above all user configuration;
+// allow rules are not honoured because a managed file can only restrict.
+type ManagedPolicy struct {
+	Permissions      Permissions  `json:"permissions,omitempty"`
+	Rules            []PolicyRule `json:"rules,omitempty"`
+	DisabledTools    []string     `json:"disabled_tools,omitempty"`
+	AllowedProviders []string     `json:"allowed_providers,omitempty"`
+	// AutoApprove set to false refuses --auto-approve and makes unattended runs
+	// decline anything that would need confirmation.
+	AutoApprove *bool  `json:"auto_approve,omitempty"`
+	SourcePath  string `json:"-"`
+}
+
+// ManagedPolicyPath returns the organisation policy location.
+func ManagedPolicyPath() string {
+	return managedPolicyPath
+}
+
+// LoadManagedPolicy reads the organisation policy. A missing file means no
+// managed restrictions; any other read or parse failure is an error so a broken
+// policy fails closed instead of being ignored.
+func LoadManagedPolicy() (ManagedPolicy, error) {
+	path := ManagedPolicyPath()
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ManagedPolicy{}, nil
+	}
+	if err != nil {
+		return ManagedPolicy{}, fmt.Errorf("failed to read managed policy %s: %w", path, err)
+	}
+	policy := ManagedPolicy{SourcePath: path}
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return policy, nil
+	}
+	if err := json.Unmarshal(content, &policy); err != nil {
+		return ManagedPolicy{}, fmt.Errorf("failed to parse managed policy %s: %w", path, err)
+	}
+	if err := validatePolicyRules(path, policy.Rules); err != nil {
+		return ManagedPolicy{}, err
+	}
+	policy.Permissions.Allow = nil
+	return policy, nil
+}
+
+// ToolDisabled reports whether the policy removes the named tool.
+func (p ManagedPolicy) ToolDisabled(name string) bool {
+	return slices.Contains(p.DisabledTools, name)
+}
+
+// ProviderAllowed reports whether runs may use the provider. An empty
+// allow-list permits every provider.
+func (p ManagedPolicy) ProviderAllowed(provider string) bool {
+	return len(p.AllowedProviders) == 0 || slices.Contains(p.AllowedProviders, provider)
+}
+
+// AutoApproveAllowed reports whether runs may auto-approve confirmations.
+func (p ManagedPolicy) AutoApproveAllowed() bool {
+	return p.AutoApprove == nil || *p.AutoApprove
+}
+
+// CheckProviderAllowed returns an error when the managed policy does not allow
+// the provider.
+func CheckProviderAllowed(provider string) error {
+	policy, err := LoadManagedPolicy()
+	if err != nil {
+		return err
+	}
+	if !policy.ProviderAllowed(provider) {
+		return fmt.Errorf("provider %q is not allowed by the organisation policy %s (allowed: %s)", provider, policy.SourcePath, strings.Join(policy.AllowedProviders, ", "))
+	}
+	return nil
+}
+
+// CheckAutoApproveAllowed returns ErrAutoApproveForbidden, wrapped with the
+// policy path, when the managed policy forbids auto-approve.
+func CheckAutoApproveAllowed() error {
+	policy, err := LoadManagedPolicy()
+	if err != nil {
+		return err
+	}
+	if !policy.AutoApproveAllowed() {
+		return fmt.Errorf("%w (%s)", ErrAutoApproveForbidden, policy.SourcePath)
+	}
+	return nil
+}
+
+// LoadPolicyFiles returns the expression policy files in evaluation order: the
+// managed policy's rules first, then the user's configured policy file.
+func LoadPolicyFiles() ([]PolicyFile, error) {
+	managed, err := LoadManagedPolicy()
+	if err != nil {
+		return nil, err
+	}
+	var files []PolicyFile
+	if len(managed.Rules) > 0 {
+		files = append(files, PolicyFile{Rules: managed.Rules, SourcePath: managed.SourcePath})
+	}
+	user, err := LoadConfiguredPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		files = append(files, *user)
+	}
+	return files, nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeManagedPolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	useManagedPolicyPath(t, path)
+	return path
+}
+
+func useManagedPolicyPath(t *testing.T, path string) {
+	t.Helper()
+	original := managedPolicyPath
+	managedPolicyPath = path
+	t.Cleanup(func() { managedPolicyPath = original })
+}
+
+func TestLoadManagedPolicyMissingFileHasNoRestrictions(t *testing.T) {
+	useManagedPolicyPath(t, filepath.Join(t.TempDir(), "absent.json"))
+
+	policy, err := LoadManagedPolicy()
+	require.NoError(t, err)
+	assert.Empty(t, policy.SourcePath)
+	assert.True(t, policy.ProviderAllowed("openai"))
+	assert.True(t, policy.AutoApproveAllowed())
+	assert.NoError(t, CheckAutoApproveAllowed())
+}
+
+func TestLoadManagedPolicyDropsAllowRules(t *testing.T) {
+	path := writeManagedPolicy(t, `{
+		"permissions": {"allow": ["unix(\"rm *\")"], "deny": ["unix(\"curl *\")"], "ask": ["file_edit(path=\"*\")"]},
+		"disabled_tools": ["websearch"],
+		"allowed_providers": ["bedrock"],
+		"auto_approve": false
+	}`)
+
+	policy, err := LoadManagedPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, path, policy.SourcePath)
+	assert.Empty(t, policy.Permissions.Allow)
+	assert.Equal(t, []string{`unix("curl *")`}, policy.Permissions.Deny)
+	assert.True(t, policy.ToolDisabled("websearch"))
+	assert.False(t, policy.ToolDisabled("unix"))
+	assert.True(t, policy.ProviderAllowed("bedrock"))
+	assert.False(t, policy.ProviderAllowed("openai"))
+
+	err = CheckAutoApproveAllowed()
+	assert.True(t, errors.Is(err, ErrAutoApproveForbidden))
+	assert.ErrorContains(t, CheckProviderAllowed("openai"), `provider "openai" is not allowed`)
+	assert.NoError(t, CheckProviderAllowed("bedrock"))
+}
+
+func TestLoadManagedPolicyRejectsBrokenFile(t *testing.T) {
+	writeManagedPolicy(t, `{"rules": [{"when": "true", "decision": "maybe"}]}`)
+	_, err := LoadManagedPolicy()
+	assert.ErrorContains(t, err, "invalid decision")
+
+	writeManagedPolicy(t, `{not json`)
+	_, err = LoadManagedPolicy()
+	assert.ErrorContains(t, err, "failed to parse managed policy")
+}
+
+func TestLoadPermissionRuleSetsAppendsManagedRules(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := writeManagedPolicy(t, `{"permissions": {"deny": ["unix(\"curl *\")"]}}`)
+
+	sets, _, err := LoadPermissionRuleSets(t.TempDir())
+	require.NoError(t, err)
+	require.NotEmpty(t, sets)
+	managed := sets[len(sets)-1]
+	assert.True(t, managed.Managed)
+	assert.Equal(t, ManagedPermissionPriority, managed.Priority)
+	assert.Equal(t, path, managed.SourcePath)
+	assert.Equal(t, []string{`unix("curl *")`}, managed.Permissions.Deny)
+}
+
+func TestLoadPolicyFilesPutsManagedRulesFirst(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	configDir := filepath.Join(homeDir, ".config", "terminal-agent")
+	require.NoError(t, os.MkdirAll(configDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"policy_file": "~/policy.json"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(homeDir, "policy.json"), []byte(`{
+		"rules": [{"when": "true", "decision": "allow"}]
+	}`), 0o600))
+	managedPath := writeManagedPolicy(t, `{"rules": [{"when": "tool == \"unix\"", "decision": "deny"}]}`)
+
+	files, err := LoadPolicyFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, managedPath, files[0].SourcePath)
+	assert.Equal(t, filepath.Join(homeDir, "policy.json"), files[1].SourcePath)
+}
+
+func TestManagedPolicyPathIgnoresEnvironment(t *testing.T) {
+	t.Setenv("TERMINAL_AGENT_MANAGED_POLICY", filepath.Join(t.TempDir(), "absent.json"))
+
+	assert.Equal(t, "/etc/terminal-agent/policy.json", ManagedPolicyPath())
+}
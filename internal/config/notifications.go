@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+)
+
+// LoadRoutinesConfig reads the routines key from the global config and
+// validates its notifications and scheduler settings.
+func LoadRoutinesConfig() (RoutinesConfig, error) {
+	config, _, err := readConfig()
+	if err != nil {
+		return RoutinesConfig{}, err
+	}
+	for i, n := range config.Routines.Notifications {
+		if err := n.Validate(); err != nil {
+			return RoutinesConfig{}, fmt.Errorf("routines.notifications[%d]: %w", i, err)
+		}
+	}
+	if _, err := routines.ParseQuietHours(config.Routines.QuietHours); err != nil {
+		return RoutinesConfig{}, fmt.Errorf("routines.quiet_hours: %w", err)
+	}
+	if jitter := strings.TrimSpace(config.Routines.Jitter); jitter != "" {
+		if d, err := time.ParseDuration(jitter); err != nil || d < 0 {
+			return RoutinesConfig{}, fmt.Errorf("routines.jitter: invalid duration %q", config.Routines.Jitter)
+		}
+	}
+	if config.Routines.MaxConcurrent < 0 {
+		return RoutinesConfig{}, fmt.Errorf("routines.max_concurrent cannot be negative")
+	}
+	for provider, limit := range config.Routines.ProviderConcurrency {
+		if limit < 1 {
+			return RoutinesConfig{}, fmt.Errorf("routines.provider_concurrency[%q] must be at least 1", provider)
+		}
+	}
+	return config.Routines, nil
+}
@@ -21,6 +21,9 @@ type PermissionRuleSet struct {
 	Permissions Permissions
 	Priority    int
 	SourcePath  string
+	// Managed marks the organisation policy's rules, which rank above every
+	// other source regardless of Priority bookkeeping for CLI and remembered rules.
+	Managed bool
 }
 
 type PermissionStore struct {
@@ -59,6 +62,19 @@ func LoadPermissionRuleSets(startDir string) ([]PermissionRuleSet, PermissionSto
 		})
 	}
 
+	managed, err := LoadManagedPolicy()
+	if err != nil {
+		return nil, PermissionStore{}, err
+	}
+	if managed.SourcePath != "" {
+		rules = append(rules, PermissionRuleSet{
+			Permissions: managed.Permissions,
+			Priority:    ManagedPermissionPriority,
+			SourcePath:  managed.SourcePath,
+			Managed:     true,
+		})
+	}
+
 	return rules, PermissionStore{
 		GlobalPath: getConfigPath(),
 		LocalPaths: localPaths,
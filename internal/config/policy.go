@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Policy decisions an expression rule can return.
+const (
+	PolicyDecisionAllow = "allow"
+	PolicyDecisionDeny  = "deny"
+	PolicyDecisionAsk   = "ask"
+)
+
+// PolicyRule is one expression-based permission rule. When is a boolean
+// expression evaluated against the tool call; the first rule whose condition
+// holds decides the call. Reason is shown to the user with the decision.
+type PolicyRule struct {
+	Name     string `json:"name,omitempty"`
+	When     string `json:"when"`
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// PolicyFile is the on-disk shape of an expression policy file.
+type PolicyFile struct {
+	Rules      []PolicyRule `json:"rules"`
+	SourcePath string       `json:"-"`
+}
+
+// PolicyFilePath returns the expression policy file configured by the
+// policy_file key in the global config, with ~ expanded. An empty result means
+// no policy file is configured.
+func PolicyFilePath() (string, error) {
+	config, _, err := readConfig()
+	if err != nil {
+		return "", err
+	}
+	return expandHome(strings.TrimSpace(config.PolicyFile)), nil
+}
+
+// LoadPolicyFile reads and validates an expression policy file. Condition
+// syntax is validated by the evaluator when the policy is compiled.
+func LoadPolicyFile(path string) (PolicyFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return PolicyFile{}, err
+	}
+	policy := PolicyFile{SourcePath: path}
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return policy, nil
+	}
+	if err := json.Unmarshal(content, &policy); err != nil {
+		return PolicyFile{}, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+	if err := validatePolicyRules(path, policy.Rules); err != nil {
+		return PolicyFile{}, err
+	}
+	return policy, nil
+}
+
+func validatePolicyRules(path string, rules []PolicyRule) error {
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.When) == "" {
+			return fmt.Errorf("policy file %s: rule %d has an empty condition", path, i+1)
+		}
+		switch rule.Decision {
+		case PolicyDecisionAllow, PolicyDecisionDeny, PolicyDecisionAsk:
+		default:
+			return fmt.Errorf("policy file %s: rule %d has invalid decision %q (expected allow, deny or ask)", path, i+1, rule.Decision)
+		}
+	}
+	return nil
+}
+
+// LoadConfiguredPolicy loads the policy file named by the global config. It
+// returns nil when no policy file is configured.
+func LoadConfiguredPolicy() (*PolicyFile, error) {
+	path, err := PolicyFilePath()
+	if err != nil || path == "" {
+		return nil, err
+	}
+	policy, err := LoadPolicyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
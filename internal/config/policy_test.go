@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfiguredPolicy(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	configDir := filepath.Join(homeDir, ".config", "terminal-agent")
+	require.NoError(t, os.MkdirAll(configDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"policy_file": "~/policy.json"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(homeDir, "policy.json"), []byte(`{
+		"rules": [{"name": "docs-only", "when": "tool == \"file_edit\"", "decision": "ask", "reason": "review edits"}]
+	}`), 0o600))
+
+	policy, err := LoadConfiguredPolicy()
+	require.NoError(t, err)
+	require.NotNil(t, policy)
+	assert.Equal(t, filepath.Join(homeDir, "policy.json"), policy.SourcePath)
+	assert.Equal(t, []PolicyRule{{Name: "docs-only", When: `tool == "file_edit"`, Decision: PolicyDecisionAsk, Reason: "review edits"}}, policy.Rules)
+}
+
+func TestLoadConfiguredPolicyUnset(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	policy, err := LoadConfiguredPolicy()
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+}
+
+func TestConfigSectionLoadersDoNotCreateConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	_, err := LoadConfiguredPolicy()
+	require.NoError(t, err)
+	_, err = LoadCommandPolicies()
+	require.NoError(t, err)
+	_, err = LoadRedactionConfig()
+	require.NoError(t, err)
+	_, err = LoadEgressConfig()
+	require.NoError(t, err)
+	_, err = LoadRoutinesConfig()
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, ConfigPath())
+	assert.NoDirExists(t, filepath.Dir(ConfigPath()))
+}
+
+func TestLoadPolicyFileRejectsInvalidDecision(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"when": "true", "decision": "maybe"}]}`), 0o600))
+
+	_, err := LoadPolicyFile(path)
+	assert.ErrorContains(t, err, "invalid decision")
+}
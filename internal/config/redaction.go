@@ -0,0 +1,30 @@
+package config
+
+// RedactionConfig controls secret redaction of prompts, tool outputs and logs.
+// Redaction is on unless Enabled is explicitly false; Patterns add
+// user-defined detectors to the built-in ones.
+type RedactionConfig struct {
+	Enabled  *bool              `json:"enabled,omitempty"`
+	Patterns []RedactionPattern `json:"patterns,omitempty"`
+}
+
+// RedactionPattern is a user-defined secret detector. When Pattern has a
+// named group "secret" only that group is replaced; otherwise the whole match.
+type RedactionPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// IsEnabled reports whether redaction is on.
+func (c RedactionConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// LoadRedactionConfig reads the redaction key from the global config.
+func LoadRedactionConfig() (RedactionConfig, error) {
+	config, _, err := readConfig()
+	if err != nil {
+		return RedactionConfig{}, err
+	}
+	return config.Redaction, nil
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -51,3 +53,37 @@ func TestSetRoutineDefaultsPersists(t *testing.T) {
 	require.NotNil(t, defaults.TokenBudget)
 	assert.Equal(t, 777, *defaults.TokenBudget)
 }
+
+func TestLoadRoutinesConfigValidatesSchedulerSettings(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".config", "terminal-agent")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	write := func(payload string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(payload), 0o644))
+	}
+
+	write(`{"routines":{"quiet_hours":"22:00-07:00","jitter":"5m"}}`)
+	cfg, err := LoadRoutinesConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "22:00-07:00", cfg.QuietHours)
+	assert.Equal(t, "5m", cfg.Jitter)
+
+	write(`{"routines":{"quiet_hours":"late"}}`)
+	_, err = LoadRoutinesConfig()
+	assert.ErrorContains(t, err, "routines.quiet_hours")
+
+	write(`{"routines":{"jitter":"soon"}}`)
+	_, err = LoadRoutinesConfig()
+	assert.ErrorContains(t, err, "routines.jitter")
+
+	write(`{"routines":{"max_concurrent":3,"provider_concurrency":{"openai":1}}}`)
+	cfg, err = LoadRoutinesConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.MaxConcurrent)
+	assert.Equal(t, map[string]int{"openai": 1}, cfg.ProviderConcurrency)
+
+	write(`{"routines":{"provider_concurrency":{"openai":0}}}`)
+	_, err = LoadRoutinesConfig()
+	assert.ErrorContains(t, err, "routines.provider_concurrency")
+}
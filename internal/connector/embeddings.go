@@ -0,0 +1,247 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+	"github.com/laszukdawid/terminal-agent/internal/auth"
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	openai "github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const (
+	DefaultOpenAIEmbeddingModel = openai.EmbeddingModelTextEmbedding3Small
+	DefaultOllamaEmbeddingModel = "nomic-embed-text"
+)
+
+// Embedder turns texts into embedding vectors, one per text and in the same
+// order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// SupportsEmbeddings reports whether NewEmbedder accepts the provider.
+func SupportsEmbeddings(provider string) bool {
+	switch provider {
+	case OpenaiProvider, OllamaProvider, LlamaProvider:
+		return true
+	}
+	return false
+}
+
+// DefaultEmbeddingModel is the embedding model used for a provider when none
+// is configured; "" when the provider has no default.
+func DefaultEmbeddingModel(provider string) string {
+	switch provider {
+	case OpenaiProvider:
+		return DefaultOpenAIEmbeddingModel
+	case OllamaProvider:
+		return DefaultOllamaEmbeddingModel
+	}
+	return ""
+}
+
+// NewEmbedder returns an embedder for the provider's embeddings API, or a
+// local llama model. modelID defaults to DefaultEmbeddingModel(provider).
+func NewEmbedder(provider string, modelID string, cfg config.Config) (Embedder, error) {
+	if modelID == "" {
+		modelID = DefaultEmbeddingModel(provider)
+	}
+	if !SupportsEmbeddings(provider) {
+		return nil, fmt.Errorf("provider %s does not support embeddings; use %s, %s or %s", provider, OpenaiProvider, OllamaProvider, LlamaProvider)
+	}
+	switch provider {
+	case OpenaiProvider:
+		return newOpenAIEmbedder(modelID)
+	case OllamaProvider:
+		return newOllamaEmbedder(modelID), nil
+	}
+	if modelID == "" {
+		return nil, fmt.Errorf("llama embeddings need a model; pass a GGUF embedding model path or llama_models alias")
+	}
+	return newLlamaEmbedder(modelID, cfg)
+}
+
+type openAIEmbedder struct {
+	client  openai.Client
+	modelID string
+}
+
+func newOpenAIEmbedder(modelID string) (*openAIEmbedder, error) {
+	resolvedAuth, err := auth.NewManager().ResolveOpenAIAPIKeyAuth()
+	if err != nil {
+		return nil, err
+	}
+	clientOptions := []option.RequestOption{option.WithAPIKey(resolvedAuth.Token)}
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		clientOptions = append(clientOptions, option.WithBaseURL(baseURL))
+	}
+	return &openAIEmbedder{client: openai.NewClient(clientOptions...), modelID: modelID}, nil
+}
+
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := e.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+		Model: e.modelID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("openai embeddings request failed: %w", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, data := range resp.Data {
+		if data.Index < 0 || int(data.Index) >= len(texts) {
+			return nil, fmt.Errorf("openai embeddings response has unexpected index %d", data.Index)
+		}
+		vector := make([]float32, len(data.Embedding))
+		for i, v := range data.Embedding {
+			vector[i] = float32(v)
+		}
+		vectors[data.Index] = vector
+	}
+	return vectors, nil
+}
+
+type ollamaEmbedder struct {
+	baseURL    string
+	modelID    string
+	httpClient *http.Client
+}
+
+func newOllamaEmbedder(modelID string) *ollamaEmbedder {
+	baseURL := os.Getenv("OLLAMA_HOST")
+	if baseURL == "" {
+		baseURL = DefaultOllamaHost
+	}
+	return &ollamaEmbedder{
+		baseURL: baseURL,
+		modelID: modelID,
+		httpClient: &http.Client{
+			Timeout: 90 * time.Second,
+		},
+	}
+}
+
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": e.modelID, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Ollama API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var embedResp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("Ollama returned %d embeddings for %d texts", len(embedResp.Embeddings), len(texts))
+	}
+	return embedResp.Embeddings, nil
+}
+
+// llamaEmbedder runs a GGUF embedding model in process, mean-pooling the
+// token embeddings of each text.
+type llamaEmbedder struct {
+	modelID   string
+	modelPath string
+	loadOnce  sync.Once
+	loadErr   error
+	model     llama.Model
+	modelCtx  llama.Context
+	nEmbd     int32
+}
+
+func newLlamaEmbedder(modelID string, cfg config.Config) (*llamaEmbedder, error) {
+	modelPath, err := resolveLlamaModelPath(modelID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &llamaEmbedder{modelID: modelID, modelPath: modelPath}, nil
+}
+
+func (e *llamaEmbedder) load() error {
+	e.loadOnce.Do(func() {
+		if e.loadErr = initializeLlamaRuntime(); e.loadErr != nil {
+			return
+		}
+		model, err := llama.ModelLoadFromFile(e.modelPath, llama.ModelDefaultParams())
+		if err != nil || model == 0 {
+			e.loadErr = fmt.Errorf("failed to load llama model %q from %q: %v", e.modelID, e.modelPath, err)
+			return
+		}
+		ctxParams := llama.ContextDefaultParams()
+		ctxParams.NCtx = defaultLlamaNCtx
+		ctxParams.NBatch = defaultLlamaBatch
+		ctxParams.NUbatch = defaultLlamaBatch
+		ctxParams.Embeddings = 1
+		ctxParams.PoolingType = llama.PoolingTypeMean
+		modelCtx, err := llama.InitFromModel(model, ctxParams)
+		if err != nil {
+			llama.ModelFree(model)
+			e.loadErr = fmt.Errorf("failed to initialize llama context: %w", err)
+			return
+		}
+		e.model, e.modelCtx, e.nEmbd = model, modelCtx, llama.ModelNEmbd(model)
+	})
+	return e.loadErr
+}
+
+func (e *llamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	vocab := llama.ModelGetVocab(e.model)
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		tokens := llama.Tokenize(vocab, text, true, true)
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("llama tokenization returned no tokens")
+		}
+		tokens = tokens[:min(len(tokens), defaultLlamaBatch)]
+
+		mem, err := llama.GetMemory(e.modelCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get llama memory: %w", err)
+		}
+		if err := llama.MemoryClear(mem, true); err != nil {
+			return nil, fmt.Errorf("failed to clear llama memory: %w", err)
+		}
+		if _, err := llama.Decode(e.modelCtx, llama.BatchGetOne(tokens)); err != nil {
+			return nil, fmt.Errorf("failed to decode llama input: %w", err)
+		}
+		vector, err := llama.GetEmbeddingsSeq(e.modelCtx, 0, e.nEmbd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read llama embeddings: %w", err)
+		}
+		vectors = append(vectors, append([]float32(nil), vector...))
+	}
+	return vectors, nil
+}
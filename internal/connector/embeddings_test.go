@@ -0,0 +1,58 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEmbedderUnsupportedProvider(t *testing.T) {
+	_, err := NewEmbedder(AnthropicProvider, "", nil)
+	assert.ErrorContains(t, err, "provider anthropic does not support embeddings")
+}
+
+func TestNewEmbedderLlamaNeedsModel(t *testing.T) {
+	_, err := NewEmbedder(LlamaProvider, "", nil)
+	assert.ErrorContains(t, err, "llama embeddings need a model")
+}
+
+func TestOllamaEmbedder(t *testing.T) {
+	var request struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/embed", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{"model":"nomic-embed-text","embeddings":[[0.1,0.2],[0.3,0.4]]}`))
+	}))
+	defer server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+
+	embedder, err := NewEmbedder(OllamaProvider, "", nil)
+	require.NoError(t, err)
+	vectors, err := embedder.Embed(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultOllamaEmbeddingModel, request.Model)
+	assert.Equal(t, []string{"a", "b"}, request.Input)
+	assert.Equal(t, [][]float32{{0.1, 0.2}, {0.3, 0.4}}, vectors)
+}
+
+func TestOllamaEmbedderCountMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"embeddings":[[0.1,0.2]]}`))
+	}))
+	defer server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+
+	embedder, err := NewEmbedder(OllamaProvider, "all-minilm", nil)
+	require.NoError(t, err)
+	_, err = embedder.Embed(context.Background(), []string{"a", "b"})
+	assert.ErrorContains(t, err, "Ollama returned 1 embeddings for 2 texts")
+}
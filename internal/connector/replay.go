@@ -0,0 +1,68 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+
+	"github.com/laszukdawid/terminal-agent/internal/tools"
+)
+
+// ErrReplayExhausted is returned once a ReplayConnector has given out every
+// recorded response, e.g. when the re-executed run takes a different path.
+var ErrReplayExhausted = errors.New("the recorded run has no more model responses")
+
+// ReplayConnector answers with recorded model responses, in order, instead of
+// calling a provider, so that a recorded task run can be executed again
+// without one. It is safe for concurrent use.
+type ReplayConnector struct {
+	mu        sync.Mutex
+	responses []LlmResponseWithTools
+	next      int
+	final     string
+}
+
+// NewReplayConnector returns a connector giving out responses to tool-calling
+// queries, and final, the run's recorded answer, to plain ones such as the
+// closing summary of a task.
+func NewReplayConnector(responses []LlmResponseWithTools, final string) *ReplayConnector {
+	return &ReplayConnector{responses: responses, final: final}
+}
+
+func (c *ReplayConnector) Query(ctx context.Context, params *QueryParams) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if c.final == "" {
+		return "", ErrReplayExhausted
+	}
+	return c.final, nil
+}
+
+func (c *ReplayConnector) SupportsNativeToolCalling() bool {
+	return true
+}
+
+func (c *ReplayConnector) QueryWithTool(ctx context.Context, params *QueryParams, tools map[string]tools.Tool) (LlmResponseWithTools, error) {
+	if err := ctx.Err(); err != nil {
+		return LlmResponseWithTools{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next >= len(c.responses) {
+		return LlmResponseWithTools{}, ErrReplayExhausted
+	}
+	response := c.responses[c.next]
+	c.next++
+	// Tools may change their input, and the recording must stay as it was.
+	response.ToolInput = maps.Clone(response.ToolInput)
+	return response, nil
+}
+
+// Remaining is the number of recorded responses not given out yet.
+func (c *ReplayConnector) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.responses) - c.next
+}
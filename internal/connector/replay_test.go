@@ -0,0 +1,39 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayConnectorGivesOutRecordedResponsesInOrder(t *testing.T) {
+	recorded := []LlmResponseWithTools{
+		{ToolUse: true, ToolName: "unix", ToolInput: map[string]any{"command": "ls"}},
+		{ToolUse: true, ToolName: "final_answer", ToolInput: map[string]any{"answer": "done"}},
+	}
+	conn := NewReplayConnector(recorded, "summary")
+	ctx := context.Background()
+
+	first, err := conn.QueryWithTool(ctx, &QueryParams{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, recorded[0], first)
+	first.ToolInput["command"] = "rm -rf /"
+	assert.Equal(t, "ls", recorded[0].ToolInput["command"])
+	assert.Equal(t, 1, conn.Remaining())
+
+	second, err := conn.QueryWithTool(ctx, &QueryParams{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "final_answer", second.ToolName)
+
+	_, err = conn.QueryWithTool(ctx, &QueryParams{}, nil)
+	assert.ErrorIs(t, err, ErrReplayExhausted)
+
+	answer, err := conn.Query(ctx, &QueryParams{})
+	require.NoError(t, err)
+	assert.Equal(t, "summary", answer)
+
+	_, err = NewReplayConnector(nil, "").Query(ctx, &QueryParams{})
+	assert.ErrorIs(t, err, ErrReplayExhausted)
+}
@@ -0,0 +1,326 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	log "github.com/laszukdawid/terminal-agent/internal/utils"
+)
+
+// The control API is HTTP over a Unix socket in the data dir, so only the user
+// running the daemon can reach it. The CLI and the GUI use it (see Client) to
+// ask the running daemon what it is doing and to steer it:
+//
+//	GET  /v1/status                 ControlStatus
+//	POST /v1/routines/{id}/run      queue a manual run; body {"params": {...}}
+//	GET  /v1/runs/{id}/events       the in-flight run's session log records, as JSON lines
+//	POST /v1/pause, /v1/resume      hold or release the run queue
+//	POST /v1/reload                 reload the definitions and config
+//	POST /v1/stop                   stop the daemon
+const socketFileName = "daemon.sock"
+
+// eventPollInterval is how often an events stream checks the run's session logs
+// for new records.
+const eventPollInterval = 250 * time.Millisecond
+
+func socketPath() string { return filepath.Join(routines.DataDir(), socketFileName) }
+
+// ControlStatus is the running daemon's view of its schedule and runs.
+type ControlStatus struct {
+	PID       int                `json:"pid"`
+	StartedAt time.Time          `json:"started_at"`
+	Paused    bool               `json:"paused"`
+	Scheduled []ScheduledRoutine `json:"scheduled,omitempty"`
+	// Runs holds the running runs, then the queued ones in dispatch order.
+	Runs []RunStatus `json:"runs,omitempty"`
+}
+
+// ScheduledRoutine is a routine on the daemon's cron.
+type ScheduledRoutine struct {
+	ID        string    `json:"id"`
+	NextRunAt time.Time `json:"next_run_at"`
+}
+
+// RunStatus is a queued or running run. LastEvent is the latest record the run
+// wrote to its session log, for running runs.
+type RunStatus struct {
+	routines.QueuedRun
+	LastEvent *sessionlog.Record `json:"last_event,omitempty"`
+}
+
+// RunRequest is the body of a run request.
+type RunRequest struct {
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// controlServer serves the control API while the daemon runs.
+type controlServer struct {
+	d        *Daemon
+	listener net.Listener
+	server   *http.Server
+}
+
+func startControlServer(d *Daemon) (*controlServer, error) {
+	path := socketPath()
+	// The single-instance lock is held, so a socket left here belongs to a
+	// daemon that is gone.
+	_ = os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	c := &controlServer{d: d, listener: listener}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", c.status)
+	mux.HandleFunc("POST /v1/routines/{id}/run", c.run)
+	mux.HandleFunc("GET /v1/runs/{id}/events", c.events)
+	mux.HandleFunc("POST /v1/pause", func(rw http.ResponseWriter, r *http.Request) { c.setPaused(rw, true) })
+	mux.HandleFunc("POST /v1/resume", func(rw http.ResponseWriter, r *http.Request) { c.setPaused(rw, false) })
+	mux.HandleFunc("POST /v1/reload", c.reload)
+	mux.HandleFunc("POST /v1/stop", c.stop)
+	c.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = c.server.Serve(listener) }()
+	return c, nil
+}
+
+func (c *controlServer) Close() error {
+	err := c.server.Close()
+	_ = os.Remove(socketPath())
+	return err
+}
+
+func (c *controlServer) status(rw http.ResponseWriter, r *http.Request) {
+	c.writeStatus(rw)
+}
+
+func (c *controlServer) writeStatus(rw http.ResponseWriter) {
+	status, err := c.d.controlStatus()
+	if err != nil {
+		writeControlError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeControlJSON(rw, http.StatusOK, status)
+}
+
+func (c *controlServer) run(rw http.ResponseWriter, r *http.Request) {
+	var req RunRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxWebhookBody)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeControlError(rw, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	routine, err := c.d.store.Get(r.PathValue("id"))
+	if err != nil {
+		writeControlError(rw, http.StatusNotFound, err.Error())
+		return
+	}
+	if _, err := routine.ResolveParams(req.Params); err != nil {
+		writeControlError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	event := &routines.TriggerEvent{Type: routines.TriggerManual, FiredAt: c.d.now().UTC(), Params: req.Params}
+	run, _ := c.d.enqueue(routine.ID, event, time.Time{}, false)
+	log.Infow("daemon: run requested over the control API", "routine", routine.ID)
+	go c.d.dispatch()
+	writeControlJSON(rw, http.StatusAccepted, run)
+}
+
+// events streams the session log records of the routine's in-flight run, as
+// they are written, until the run finishes or the client goes away.
+func (c *controlServer) events(rw http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	run, ok := c.d.runningRun(id)
+	if !ok {
+		writeControlError(rw, http.StatusNotFound, "routine is not running: "+id)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	rw.WriteHeader(http.StatusOK)
+	flusher, _ := rw.(http.Flusher)
+	enc := json.NewEncoder(rw)
+	tail := newRunLogTail(routines.LogDir(id), run.StartedAt)
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+	for {
+		// Read the logs once more after the run ends so its final records are
+		// not lost.
+		_, running := c.d.runningRun(id)
+		for _, rec := range tail.next() {
+			if err := enc.Encode(rec); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if !running {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *controlServer) setPaused(rw http.ResponseWriter, paused bool) {
+	c.d.setPaused(paused)
+	c.writeStatus(rw)
+}
+
+func (c *controlServer) reload(rw http.ResponseWriter, r *http.Request) {
+	c.d.reload(true)
+	c.d.dispatch()
+	c.writeStatus(rw)
+}
+
+func (c *controlServer) stop(rw http.ResponseWriter, r *http.Request) {
+	c.d.mu.Lock()
+	cancel := c.d.cancel
+	c.d.mu.Unlock()
+	if cancel == nil {
+		writeControlError(rw, http.StatusServiceUnavailable, "daemon is not accepting control requests")
+		return
+	}
+	writeControlJSON(rw, http.StatusAccepted, map[string]string{"status": "stopping"})
+	cancel()
+}
+
+func writeControlJSON(rw http.ResponseWriter, code int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+func writeControlError(rw http.ResponseWriter, code int, message string) {
+	writeControlJSON(rw, code, map[string]string{"error": message})
+}
+
+// controlStatus snapshots the schedule, the queue and each running run's latest
+// session log record.
+func (d *Daemon) controlStatus() (ControlStatus, error) {
+	queued, err := d.queue.List()
+	if err != nil {
+		return ControlStatus{}, err
+	}
+	d.mu.Lock()
+	status := ControlStatus{PID: os.Getpid(), StartedAt: d.startedAt, Paused: d.paused}
+	for id, sched := range d.schedules {
+		status.Scheduled = append(status.Scheduled, ScheduledRoutine{ID: id, NextRunAt: sched.Next(d.now())})
+	}
+	d.mu.Unlock()
+	slices.SortFunc(status.Scheduled, func(a, b ScheduledRoutine) int { return a.NextRunAt.Compare(b.NextRunAt) })
+
+	for _, run := range queued {
+		rs := RunStatus{QueuedRun: run}
+		if run.Running() {
+			if records := newRunLogTail(routines.LogDir(run.RoutineID), run.StartedAt).next(); len(records) > 0 {
+				rs.LastEvent = &records[len(records)-1]
+			}
+		}
+		status.Runs = append(status.Runs, rs)
+	}
+	return status, nil
+}
+
+// runningRun returns the routine's run in progress, if any.
+func (d *Daemon) runningRun(id string) (routines.QueuedRun, bool) {
+	queued, err := d.queue.List()
+	if err != nil {
+		return routines.QueuedRun{}, false
+	}
+	for _, run := range queued {
+		if run.RoutineID == id && run.Running() {
+			return run, true
+		}
+	}
+	return routines.QueuedRun{}, false
+}
+
+// setPaused holds or releases the run queue. While paused, fired runs are
+// still queued but none start.
+func (d *Daemon) setPaused(paused bool) {
+	d.mu.Lock()
+	d.paused = paused
+	d.mu.Unlock()
+	log.Infow("daemon: run queue paused", "paused", paused)
+	if !paused {
+		d.dispatch()
+	}
+}
+
+// runLogTail follows the session logs a routine run writes: those in the
+// routine's log dir modified since the run started. A workflow run writes
+// several.
+type runLogTail struct {
+	dir     string
+	since   time.Time
+	offsets map[string]int64
+}
+
+func newRunLogTail(dir string, since time.Time) *runLogTail {
+	// File modtimes can be coarser than the run's start time.
+	return &runLogTail{dir: dir, since: since.Add(-time.Second), offsets: map[string]int64{}}
+}
+
+// next returns the complete records written since the previous call.
+func (t *runLogTail) next() []sessionlog.Record {
+	// File names start with their creation time, so they sort in order.
+	paths, _ := filepath.Glob(filepath.Join(t.dir, "*.jsonl"))
+	slices.Sort(paths)
+	var records []sessionlog.Record
+	for _, path := range paths {
+		offset, tracked := t.offsets[path]
+		if !tracked {
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Before(t.since) {
+				continue
+			}
+		}
+		read, consumed := readRecords(path, offset)
+		t.offsets[path] = offset + consumed
+		records = append(records, read...)
+	}
+	return records
+}
+
+// readRecords reads the complete JSONL records of a file from offset, returning
+// them and the bytes consumed. A partly written last line is left for later.
+func readRecords(path string, offset int64) ([]sessionlog.Record, int64) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0
+	}
+	var records []sessionlog.Record
+	var consumed int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return records, consumed
+		}
+		consumed += int64(len(line))
+		var rec sessionlog.Record
+		if json.Unmarshal(line, &rec) == nil {
+			records = append(records, rec)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlAPIRunsPausesAndStreamsEvents(t *testing.T) {
+	runner := &fakeRunner{started: make(chan string, 1), release: make(chan struct{})}
+	d := newTestDaemon(t, runner)
+	require.NoError(t, d.store.Upsert(routines.Routine{ID: "report", Prompt: "p", Params: []routines.Param{{Name: "who"}}, Enabled: true}))
+	sched, err := cron.ParseStandard("0 2 * * *")
+	require.NoError(t, err)
+	d.schedules["report"] = sched
+	server, err := startControlServer(d)
+	require.NoError(t, err)
+	defer server.Close()
+	client := NewClient()
+	ctx := context.Background()
+
+	_, err = client.Run(ctx, "missing", nil)
+	assert.ErrorContains(t, err, "missing")
+	_, err = client.Run(ctx, "report", map[string]string{"when": "now"})
+	assert.ErrorContains(t, err, "unknown parameter")
+
+	status, err := client.SetPaused(ctx, true)
+	require.NoError(t, err)
+	assert.True(t, status.Paused)
+	run, err := client.Run(ctx, "report", map[string]string{"who": "ops"})
+	require.NoError(t, err)
+	assert.Equal(t, routines.TriggerManual, run.Trigger)
+
+	status, err = client.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []ScheduledRoutine{{ID: "report", NextRunAt: fixedNow.Add(2 * time.Hour)}}, status.Scheduled)
+	require.Len(t, status.Runs, 1)
+	assert.False(t, status.Runs[0].Running(), "a paused daemon starts no runs")
+	assert.Equal(t, 0, runner.callCount())
+
+	_, err = client.SetPaused(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, "report", <-runner.started)
+	runner.mu.Lock()
+	assert.Equal(t, map[string]string{"who": "ops"}, runner.events[0].Params)
+	runner.mu.Unlock()
+
+	// The run writes its session log; the stream relays it until the run ends.
+	rec := sessionlog.Record{Type: sessionlog.RecordToolCall, ToolName: "unix", Seq: 1}
+	line, err := json.Marshal(rec)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(routines.LogDir("report"), "run.jsonl"), append(line, '\n'), 0o644))
+	status, err = client.Status(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, status.Runs[0].LastEvent)
+	assert.Equal(t, "unix", status.Runs[0].LastEvent.ToolName)
+
+	got := make(chan sessionlog.Record, 4)
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- client.Events(ctx, "report", func(rec sessionlog.Record) { got <- rec })
+	}()
+	assert.Equal(t, "unix", (<-got).ToolName)
+	runner.release <- struct{}{}
+	require.NoError(t, <-streamErr)
+}
+
+func TestClientReportsNotRunning(t *testing.T) {
+	t.Setenv(routines.DataDirEnv, t.TempDir())
+	_, err := NewClient().Status(context.Background())
+	assert.ErrorIs(t, err, ErrNotRunning)
+}
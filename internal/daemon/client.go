@@ -0,0 +1,156 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	"github.com/laszukdawid/terminal-agent/internal/sessionlog"
+)
+
+// clientTimeout bounds control requests other than event streams.
+const clientTimeout = 5 * time.Second
+
+// Client talks to the running daemon's control API. Methods return an error
+// wrapping ErrNotRunning when no daemon is listening.
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+// NewClient returns a client for the daemon serving the standard data dir.
+func NewClient() *Client {
+	socket := socketPath()
+	return &Client{
+		socket: socket,
+		http: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}},
+	}
+}
+
+// Status returns the daemon's schedule, queue and running runs.
+func (c *Client) Status(ctx context.Context) (ControlStatus, error) {
+	var status ControlStatus
+	err := c.call(ctx, http.MethodGet, "/v1/status", nil, &status)
+	return status, err
+}
+
+// Run queues a manual run of the routine with the given parameters.
+func (c *Client) Run(ctx context.Context, id string, params map[string]string) (routines.QueuedRun, error) {
+	var run routines.QueuedRun
+	err := c.call(ctx, http.MethodPost, "/v1/routines/"+url.PathEscape(id)+"/run", RunRequest{Params: params}, &run)
+	return run, err
+}
+
+// SetPaused holds (true) or releases (false) the daemon's run queue.
+func (c *Client) SetPaused(ctx context.Context, paused bool) (ControlStatus, error) {
+	path := "/v1/resume"
+	if paused {
+		path = "/v1/pause"
+	}
+	var status ControlStatus
+	err := c.call(ctx, http.MethodPost, path, nil, &status)
+	return status, err
+}
+
+// Reload makes the daemon re-read the routine definitions and config.
+func (c *Client) Reload(ctx context.Context) (ControlStatus, error) {
+	var status ControlStatus
+	err := c.call(ctx, http.MethodPost, "/v1/reload", nil, &status)
+	return status, err
+}
+
+// Stop asks the daemon to shut down.
+func (c *Client) Stop(ctx context.Context) error {
+	return c.call(ctx, http.MethodPost, "/v1/stop", nil, nil)
+}
+
+// Events calls fn with each session log record of the routine's in-flight run
+// until the run finishes or ctx is done.
+func (c *Client) Events(ctx context.Context, id string, fn func(sessionlog.Record)) error {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/runs/"+url.PathEscape(id)+"/events", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var rec sessionlog.Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("daemon: invalid event: %w", err)
+		}
+		fn(rec)
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Client) call(ctx context.Context, method, path string, body, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, clientTimeout)
+	defer cancel()
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// do sends a request and returns the response of a successful one; an error
+// response becomes an error carrying the daemon's message.
+func (c *Client) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewReader(data)
+	}
+	// The host is ignored; the transport always dials the socket.
+	req, err := http.NewRequestWithContext(ctx, method, "http://daemon"+path, payload)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return nil, fmt.Errorf("%w (no control socket at %s)", ErrNotRunning, c.socket)
+		}
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		return nil, fmt.Errorf("daemon: %s", apiErr.Error)
+	}
+	return resp, nil
+}
@@ -1,14 +1,20 @@
 // Package daemon is the background scheduler for routines. A single long-lived
 // process holds an in-process cron over all enabled, scheduled routines and, when
 // one fires, spawns `agent routine run <id> --scheduled` as an isolated
-// subprocess. The OS only supervises the daemon itself (see service.go), not each
-// routine. The daemon watches the routines definitions file and reloads on change.
+// subprocess. Fired runs wait in a persistent queue until the concurrency caps
+// allow them to start (see queue.go). Event triggers (file changes, a local webhook, completion of
+// another routine, system events) fire the same way, with their payload passed
+// to the run (see triggers.go). The OS only supervises the daemon itself (see
+// service.go), not each routine. The daemon watches the routines definitions
+// file and reloads on change, and serves a control API on a Unix socket (see
+// api.go) through which the CLI and GUI inspect and steer it.
 package daemon
 
 import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -37,18 +43,39 @@ type Daemon struct {
 	cfg     config.Config
 	store   *routines.Store
 	state   *routines.StateStore
+	queue   *routines.QueueStore
 	runner  RoutineRunner
 	exePath string
 
 	reconcileInterval time.Duration
 	now               func() time.Time
+	// systemInterval is how often system events (network-up, wake) are polled;
+	// networkUp reports whether a non-loopback interface is up.
+	systemInterval time.Duration
+	networkUp      func() bool
 
 	mu            sync.Mutex
 	cron          *cron.Cron
-	schedules     map[string]cron.Schedule // id -> parsed schedule (for next-run refresh)
-	running       map[string]bool          // single-flight guard per routine id
-	lastMod       time.Time                // definitions file modtime for change detection
-	lastConfigMod time.Time                // config file modtime (global toggle changes)
+	schedules     map[string]cron.Schedule      // id -> parsed schedule (for next-run refresh)
+	running       map[string]bool               // routines with a run in progress
+	lastMod       time.Time                     // definitions file modtime for change detection
+	lastConfigMod time.Time                     // config file modtime (global toggle changes)
+	triggers      map[string][]routines.Trigger // id -> event triggers of enabled routines
+	lastRuns      map[string]time.Time          // id -> last seen LastRunAt, for routine triggers
+	sources       *triggerSources               // file watches and webhook server; nil outside Run
+	quietHours    routines.QuietHours           // window with no scheduled runs
+	jitter        time.Duration                 // max random delay before a scheduled run
+
+	maxConcurrent   int            // cap on runs in progress; 0 = config default
+	providerLimits  map[string]int // provider -> cap on its runs in progress
+	providerRunning map[string]int // provider -> runs in progress
+	dispatchMu      sync.Mutex     // serializes dispatch
+	inflight        sync.WaitGroup // execute goroutines
+	paused          bool           // hold the run queue (control API)
+
+	startedAt time.Time          // when Run started
+	cancel    context.CancelFunc // stops Run (control API); nil outside Run
+	stopped   <-chan struct{}    // closed when Run stops; nil outside Run
 }
 
 // New builds a daemon backed by the standard routine stores and the running
@@ -62,10 +89,13 @@ func New(cfg config.Config) (*Daemon, error) {
 		cfg:               cfg,
 		store:             routines.DefaultStore(),
 		state:             routines.DefaultStateStore(),
+		queue:             routines.DefaultQueueStore(),
 		runner:            newExecRunner(exe, nil),
 		exePath:           exe,
 		reconcileInterval: defaultReconcileInterval,
 		now:               time.Now,
+		systemInterval:    defaultSystemInterval,
+		networkUp:         networkUp,
 		schedules:         map[string]cron.Schedule{},
 		running:           map[string]bool{},
 	}, nil
@@ -86,9 +116,31 @@ func (d *Daemon) Run(ctx context.Context) error {
 
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d.mu.Lock()
+	d.startedAt, d.cancel, d.stopped = d.now(), cancel, ctx.Done()
+	d.mu.Unlock()
 
+	d.sources = newTriggerSources(d)
+	defer d.sources.close()
+	// Queue the runs missed while the daemon was stopped before the reload
+	// publishes new next run times over the missed ones.
+	d.recoverQueue()
+	d.loadSchedulerSettings()
+	d.catchUp(time.Time{})
 	d.reload(true)
+	d.dispatch()
+	if control, err := startControlServer(d); err != nil {
+		log.Warnw("daemon: control API unavailable", "socket", socketPath(), "error", err)
+	} else {
+		defer control.Close()
+	}
 	defer d.stopCron()
+	// Snapshot completed runs so only runs finishing from now on fire routine
+	// triggers.
+	d.checkCompletions()
+	go d.watchSystem(ctx)
 
 	watcher := d.startWatcher()
 	if watcher != nil {
@@ -113,11 +165,20 @@ func (d *Daemon) Run(ctx context.Context) error {
 		case <-ticker.C:
 			d.reload(false)
 			d.refreshNextRuns()
-		case _, ok := <-events:
+			d.checkCompletions()
+			d.dispatch()
+		case event, ok := <-events:
 			if !ok {
 				events = nil
 				continue
 			}
+			if filepath.Clean(event.Name) == filepath.Clean(routines.StatePath()) {
+				// A run finished (possibly a manual one outside the daemon, which
+				// may have held back a queued run of the same routine).
+				d.checkCompletions()
+				d.dispatch()
+				continue
+			}
 			// reload() decides relevance by comparing the definitions and config
 			// file modtimes, so any change in the watched directory is funneled here.
 			d.reload(false)
@@ -146,6 +207,12 @@ func (d *Daemon) startWatcher() *fsnotify.Watcher {
 		watcher.Close()
 		return nil
 	}
+	// The data dir holds the run state; its changes drive routine triggers.
+	dataDir := routines.DataDir()
+	_ = os.MkdirAll(dataDir, 0o755)
+	if err := watcher.Add(dataDir); err != nil {
+		log.Warnw("daemon: watching routines data dir failed, routine triggers use periodic checks only", "dir", dataDir, "error", err)
+	}
 	return watcher
 }
 
@@ -188,12 +255,14 @@ func (d *Daemon) loadAndSchedule() {
 		log.Errorw("daemon: reading routines failed, keeping previous schedule", "error", err)
 		return
 	}
+	d.loadSchedulerSettings()
 	schedules := selectSchedules(routineList, d.cfg.GetRoutinesEnabled())
+	triggers := selectTriggers(routineList, d.cfg.GetRoutinesEnabled())
 
 	c := cron.New()
 	for id, sched := range schedules {
 		id := id
-		c.Schedule(sched, cron.FuncJob(func() { d.fire(id) }))
+		c.Schedule(sched, cron.FuncJob(func() { d.fireScheduled(id) }))
 	}
 	c.Start()
 
@@ -202,10 +271,15 @@ func (d *Daemon) loadAndSchedule() {
 	oldSchedules := d.schedules
 	d.cron = c
 	d.schedules = schedules
+	d.triggers = triggers
+	sources := d.sources
 	d.mu.Unlock()
 	if old != nil {
 		old.Stop()
 	}
+	if sources != nil {
+		sources.update(routineList, triggers)
+	}
 
 	// Clear the published next-run time for routines that are no longer scheduled
 	// (disabled or removed) so stale "next run" data does not linger.
@@ -218,7 +292,33 @@ func (d *Daemon) loadAndSchedule() {
 	}
 
 	d.refreshNextRuns()
-	log.Infow("daemon: scheduled routines", "count", len(schedules), "enabled", d.cfg.GetRoutinesEnabled())
+	log.Infow("daemon: scheduled routines", "count", len(schedules), "triggered", len(triggers), "enabled", d.cfg.GetRoutinesEnabled())
+}
+
+// loadSchedulerSettings reads the quiet hours, jitter and concurrency caps from
+// the routines config. Invalid settings are logged and the previous ones kept.
+func (d *Daemon) loadSchedulerSettings() {
+	cfg, err := config.LoadRoutinesConfig()
+	if err != nil {
+		log.Warnw("daemon: reading routines config failed, keeping previous scheduler settings", "error", err)
+		return
+	}
+	quiet, err := routines.ParseQuietHours(cfg.QuietHours)
+	if err != nil {
+		log.Warnw("daemon: invalid quiet hours, keeping previous scheduler settings", "quiet_hours", cfg.QuietHours, "error", err)
+		return
+	}
+	var jitter time.Duration
+	if value := strings.TrimSpace(cfg.Jitter); value != "" {
+		if jitter, err = time.ParseDuration(value); err != nil {
+			log.Warnw("daemon: invalid jitter, keeping previous scheduler settings", "jitter", cfg.Jitter, "error", err)
+			return
+		}
+	}
+	d.mu.Lock()
+	d.quietHours, d.jitter = quiet, max(jitter, 0)
+	d.maxConcurrent, d.providerLimits = cfg.MaxConcurrent, cfg.ProviderConcurrency
+	d.mu.Unlock()
 }
 
 // selectSchedules parses the cron schedule of every enabled routine that has one,
@@ -243,29 +343,43 @@ func selectSchedules(routineList []routines.Routine, enabled bool) map[string]cr
 	return schedules
 }
 
-// fire runs one routine, enforcing single-flight: a routine whose previous run is
-// still in progress is skipped rather than run concurrently.
-func (d *Daemon) fire(id string) {
+// fireScheduled is the cron job of a routine. A fire that falls in quiet hours
+// is skipped; otherwise the run starts after a random delay of up to the
+// configured jitter, unless the daemon stops first.
+func (d *Daemon) fireScheduled(id string) {
 	d.mu.Lock()
-	if d.running[id] {
-		d.mu.Unlock()
-		log.Infow("daemon: skipping fire, routine still running", "routine", id)
-		return
-	}
-	d.running[id] = true
+	quiet, jitter, stopped := d.quietHours, d.jitter, d.stopped
 	d.mu.Unlock()
-	defer func() {
-		d.mu.Lock()
-		delete(d.running, id)
-		d.mu.Unlock()
+	if quiet.Contains(d.now().Local()) {
+		log.Infow("daemon: skipping scheduled run in quiet hours", "routine", id)
 		d.refreshNextRun(id)
-	}()
-
-	log.Infow("daemon: running routine", "routine", id)
-	if err := d.runner.Run(context.Background(), id); err != nil {
-		// The run records its own failure; this is a subprocess-level diagnostic.
-		log.Warnw("daemon: routine run subprocess returned an error", "routine", id, "error", err)
+		return
 	}
+	if jitter > 0 {
+		timer := time.NewTimer(rand.N(jitter))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-stopped:
+			return
+		}
+	}
+	d.fire(id)
+}
+
+// fire queues a run of one routine on its schedule. A scheduled run already
+// waiting in the queue absorbs the fire.
+func (d *Daemon) fire(id string) {
+	d.enqueue(id, nil, time.Time{}, true)
+	d.refreshNextRun(id)
+	d.dispatch()
+}
+
+// fireEvent queues a run of one routine for a trigger; event is its payload.
+// Each event is queued, so none is lost while the routine runs.
+func (d *Daemon) fireEvent(id string, event *routines.TriggerEvent) {
+	d.enqueue(id, event, time.Time{}, false)
+	d.dispatch()
 }
 
 func (d *Daemon) refreshNextRuns() {
@@ -321,8 +435,9 @@ func CurrentStatus() (Status, error) {
 	return Status{Running: running, PID: pid}, nil
 }
 
-// Stop signals the running daemon to terminate gracefully. It relies on the lock
-// to confirm a daemon is actually running before signaling any PID.
+// Stop asks the running daemon to terminate gracefully, over the control API
+// or else with SIGTERM. It relies on the lock to confirm a daemon is actually
+// running before signaling any PID.
 func Stop() error {
 	running, pid, err := daemonRunning()
 	if err != nil {
@@ -332,6 +447,9 @@ func Stop() error {
 		removePIDFile()
 		return ErrNotRunning
 	}
+	if err := NewClient().Stop(context.Background()); err == nil {
+		return nil
+	}
 	if pid <= 0 {
 		return fmt.Errorf("daemon is running but its PID is unknown")
 	}
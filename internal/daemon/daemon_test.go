@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -21,13 +22,15 @@ var fixedNow = time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC)
 type fakeRunner struct {
 	mu      sync.Mutex
 	calls   []string
+	events  []*routines.TriggerEvent
 	started chan string
 	release chan struct{}
 }
 
-func (r *fakeRunner) Run(_ context.Context, id string) error {
+func (r *fakeRunner) Run(_ context.Context, id string, event *routines.TriggerEvent) error {
 	r.mu.Lock()
 	r.calls = append(r.calls, id)
+	r.events = append(r.events, event)
 	r.mu.Unlock()
 	if r.started != nil {
 		r.started <- id
@@ -49,10 +52,11 @@ func newTestDaemon(t *testing.T, runner RoutineRunner) *Daemon {
 	dir := t.TempDir()
 	t.Setenv(routines.DataDirEnv, dir)
 	t.Setenv(routines.DefinitionsFileEnv, filepath.Join(dir, "routines.json"))
-	return &Daemon{
+	d := &Daemon{
 		cfg:               config.NewDefaultConfig(),
 		store:             routines.DefaultStore(),
 		state:             routines.DefaultStateStore(),
+		queue:             routines.DefaultQueueStore(),
 		runner:            runner,
 		exePath:           "/fake/agent",
 		reconcileInterval: time.Hour,
@@ -60,6 +64,10 @@ func newTestDaemon(t *testing.T, runner RoutineRunner) *Daemon {
 		schedules:         map[string]cron.Schedule{},
 		running:           map[string]bool{},
 	}
+	// Finished runs update the queue and state files, so wait for them before
+	// the temp dir is removed.
+	t.Cleanup(d.inflight.Wait)
+	return d
 }
 
 func TestSelectSchedules(t *testing.T) {
@@ -88,19 +96,23 @@ func TestFireSingleFlight(t *testing.T) {
 	runner := &fakeRunner{started: make(chan string), release: make(chan struct{})}
 	d := newTestDaemon(t, runner)
 
-	done := make(chan struct{})
-	go func() {
-		d.fire("a")
-		close(done)
-	}()
-
-	<-runner.started // first run has begun and holds the single-flight slot
-	d.fire("a")      // second fire while the first is in flight must be skipped
+	d.fire("a")
+	<-runner.started // first run has begun
+	d.fireEvent("a", &routines.TriggerEvent{Type: routines.TriggerWebhook})
 	assert.Equal(t, 1, runner.callCount(), "in-flight routine must not run concurrently")
+	queued, err := d.queue.List()
+	require.NoError(t, err)
+	require.Len(t, queued, 2, "the second fire waits in the queue")
+	assert.False(t, queued[1].Running())
 
+	runner.release <- struct{}{} // the first run finishes and the queued one starts
+	<-runner.started
+	assert.Equal(t, 2, runner.callCount())
 	runner.release <- struct{}{}
-	<-done
-	assert.Equal(t, 1, runner.callCount())
+	require.Eventually(t, func() bool {
+		queued, err := d.queue.List()
+		return err == nil && len(queued) == 0
+	}, 2*time.Second, 10*time.Millisecond)
 }
 
 func TestRefreshNextRunPublishesToState(t *testing.T) {
@@ -204,7 +216,7 @@ func TestRunHoldsSingleInstanceLock(t *testing.T) {
 	// A second instance sharing the same data dir cannot acquire the lock.
 	d2 := &Daemon{
 		cfg: config.NewDefaultConfig(), store: routines.DefaultStore(), state: routines.DefaultStateStore(),
-		runner: runner, exePath: "/fake/agent", reconcileInterval: time.Hour,
+		queue: routines.DefaultQueueStore(), runner: runner, exePath: "/fake/agent", reconcileInterval: time.Hour,
 		now: time.Now, schedules: map[string]cron.Schedule{}, running: map[string]bool{},
 	}
 	assert.ErrorIs(t, d2.Run(context.Background()), ErrAlreadyRunning)
@@ -212,3 +224,39 @@ func TestRunHoldsSingleInstanceLock(t *testing.T) {
 	cancel()
 	require.NoError(t, <-errc)
 }
+
+func TestFireScheduledSkipsQuietHours(t *testing.T) {
+	runner := &fakeRunner{}
+	d := newTestDaemon(t, runner)
+	hour := fixedNow.Local().Hour()
+	quiet, err := routines.ParseQuietHours(fmt.Sprintf("%02d:00-%02d:00", hour, (hour+1)%24))
+	require.NoError(t, err)
+	d.quietHours = quiet
+
+	d.fireScheduled("nightly")
+	assert.Equal(t, 0, runner.callCount(), "no scheduled runs start in quiet hours")
+
+	d.quietHours = routines.QuietHours{}
+	d.fireScheduled("nightly")
+	require.Eventually(t, func() bool { return runner.callCount() == 1 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestFireScheduledJitterStopsWithDaemon(t *testing.T) {
+	runner := &fakeRunner{}
+	d := newTestDaemon(t, runner)
+	stopped := make(chan struct{})
+	d.jitter, d.stopped = time.Hour, stopped
+
+	done := make(chan struct{})
+	go func() {
+		d.fireScheduled("nightly")
+		close(done)
+	}()
+	close(stopped)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("a scheduled run waiting out its jitter must return when the daemon stops")
+	}
+	assert.Equal(t, 0, runner.callCount())
+}
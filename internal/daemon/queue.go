@@ -0,0 +1,211 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	log "github.com/laszukdawid/terminal-agent/internal/utils"
+	"github.com/robfig/cron/v3"
+)
+
+// Fired routines do not start directly: they join a persistent queue (see
+// routines.QueueStore) and dispatch starts queued runs, by priority and then in
+// order, while the global and per-provider concurrency caps allow. A routine
+// still runs at most once at a time; further fires wait behind it.
+
+// enqueue adds a run of the routine to the queue and returns it. A scheduled
+// run (no event) is coalesced with one already waiting when coalesce is set,
+// and then false is returned.
+func (d *Daemon) enqueue(id string, event *routines.TriggerEvent, missedAt time.Time, coalesce bool) (routines.QueuedRun, bool) {
+	run := routines.QueuedRun{
+		RoutineID:  id,
+		Trigger:    routines.TriggerScheduled,
+		Event:      event,
+		EnqueuedAt: d.now().UTC(),
+		MissedAt:   missedAt,
+	}
+	if event != nil {
+		run.Trigger = event.Type
+	}
+	if r, err := d.store.Get(id); err == nil {
+		run.Priority = r.Priority
+		run.Provider = d.routineProvider(r)
+	}
+	run, added, err := d.queue.Push(run, coalesce)
+	switch {
+	case err != nil:
+		log.Errorw("daemon: queueing routine run failed", "routine", id, "error", err)
+	case !added:
+		log.Infow("daemon: routine already queued, coalescing fire", "routine", id, "trigger", run.Trigger)
+	}
+	return run, added
+}
+
+// routineProvider resolves the provider a routine runs with, for the
+// per-provider caps.
+func (d *Daemon) routineProvider(r routines.Routine) string {
+	for _, provider := range []string{r.Provider, d.cfg.GetRoutineDefaults().Provider, d.cfg.GetDefaultProvider()} {
+		if provider = strings.TrimSpace(provider); provider != "" {
+			return provider
+		}
+	}
+	return ""
+}
+
+// dispatch starts queued runs while the concurrency caps allow. A run whose
+// routine is already running, whose provider is at its cap, or which a manual
+// run holds the lock of stays queued for a later dispatch.
+func (d *Daemon) dispatch() {
+	d.dispatchMu.Lock()
+	defer d.dispatchMu.Unlock()
+	d.mu.Lock()
+	paused := d.paused
+	d.mu.Unlock()
+	if paused {
+		return
+	}
+
+	queued, err := d.queue.List()
+	if err != nil {
+		log.Warnw("daemon: reading run queue failed", "error", err)
+		return
+	}
+	for _, run := range queued {
+		if run.Running() {
+			continue
+		}
+		d.mu.Lock()
+		limit := d.maxConcurrent
+		if limit <= 0 {
+			limit = config.DefaultRoutineMaxConcurrent
+		}
+		full := len(d.running) >= limit
+		providerLimit, capped := d.providerLimits[run.Provider]
+		blocked := d.running[run.RoutineID] || (capped && d.providerRunning[run.Provider] >= providerLimit)
+		d.mu.Unlock()
+		if full {
+			return
+		}
+		if blocked || routines.RunInProgress(run.RoutineID) {
+			continue
+		}
+		if err := d.queue.Start(run.ID, d.now().UTC()); err != nil {
+			log.Warnw("daemon: starting queued run failed", "routine", run.RoutineID, "error", err)
+			continue
+		}
+		d.mu.Lock()
+		d.running[run.RoutineID] = true
+		if d.providerRunning == nil {
+			d.providerRunning = map[string]int{}
+		}
+		d.providerRunning[run.Provider]++
+		d.mu.Unlock()
+		d.inflight.Add(1)
+		go d.execute(run)
+	}
+}
+
+// execute runs a dispatched run, then frees its slot and dispatches again.
+func (d *Daemon) execute(run routines.QueuedRun) {
+	defer d.inflight.Done()
+	defer func() {
+		if err := d.queue.Remove(run.ID); err != nil {
+			log.Warnw("daemon: removing finished run from queue failed", "routine", run.RoutineID, "error", err)
+		}
+		d.mu.Lock()
+		delete(d.running, run.RoutineID)
+		d.providerRunning[run.Provider]--
+		d.mu.Unlock()
+		d.refreshNextRun(run.RoutineID)
+		d.checkCompletions()
+		d.dispatch()
+	}()
+
+	log.Infow("daemon: running routine", "routine", run.RoutineID, "trigger", run.Trigger, "provider", run.Provider)
+	if err := d.runner.Run(context.Background(), run.RoutineID, run.Event); err != nil {
+		// The run records its own failure; this is a subprocess-level diagnostic.
+		log.Warnw("daemon: routine run subprocess returned an error", "routine", run.RoutineID, "error", err)
+	}
+}
+
+// recoverQueue drops runs a previous daemon started but did not see finish;
+// their subprocesses recorded their own outcome. Runs still waiting are kept.
+func (d *Daemon) recoverQueue() {
+	dropped, err := d.queue.DropRunning()
+	if err != nil {
+		log.Warnw("daemon: recovering run queue failed", "error", err)
+		return
+	}
+	for _, run := range dropped {
+		log.Infow("daemon: dropping run left in progress by a previous daemon", "routine", run.RoutineID, "started", run.StartedAt)
+	}
+}
+
+// catchUp queues the scheduled runs missed since the given time according to
+// each routine's catch-up policy, skipping those that fell in quiet hours. A
+// zero since uses each routine's published next run time, which covers the
+// runs missed while the daemon was stopped.
+func (d *Daemon) catchUp(since time.Time) {
+	if !d.cfg.GetRoutinesEnabled() {
+		return
+	}
+	routineList, err := d.store.List()
+	if err != nil {
+		log.Warnw("daemon: reading routines for catch-up failed", "error", err)
+		return
+	}
+	states, err := d.state.All()
+	if err != nil {
+		log.Warnw("daemon: reading run state for catch-up failed", "error", err)
+		return
+	}
+	d.mu.Lock()
+	quiet := d.quietHours
+	d.mu.Unlock()
+	now := d.now()
+
+	for _, r := range routineList {
+		if !r.Enabled || r.CatchUp == "" || r.CatchUp == routines.CatchUpSkip || strings.TrimSpace(r.Schedule) == "" {
+			continue
+		}
+		sched, err := cron.ParseStandard(r.Schedule)
+		if err != nil {
+			continue
+		}
+		first := states[r.ID].NextRunAt
+		if !since.IsZero() {
+			first = sched.Next(since)
+		}
+		missed := missedRuns(sched, first, now, quiet)
+		if len(missed) == 0 {
+			continue
+		}
+		if r.CatchUp == routines.CatchUpOnce {
+			missed = missed[len(missed)-1:]
+		}
+		log.Infow("daemon: catching up missed runs", "routine", r.ID, "policy", r.CatchUp, "runs", len(missed))
+		for _, at := range missed {
+			d.enqueue(r.ID, nil, at, r.CatchUp == routines.CatchUpOnce)
+		}
+	}
+	d.dispatch()
+}
+
+// missedRuns lists the schedule's fire times from first up to now, outside
+// quiet hours, keeping the latest routines.MaxCatchUpRuns.
+func missedRuns(sched cron.Schedule, first, now time.Time, quiet routines.QuietHours) []time.Time {
+	var missed []time.Time
+	for at := first; !at.IsZero() && !at.After(now); at = sched.Next(at) {
+		if quiet.Contains(at.Local()) {
+			continue
+		}
+		missed = append(missed, at)
+		if len(missed) > routines.MaxCatchUpRuns {
+			missed = missed[1:]
+		}
+	}
+	return missed
+}
@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatchRespectsConcurrencyCaps(t *testing.T) {
+	runner := &fakeRunner{started: make(chan string, 3), release: make(chan struct{})}
+	d := newTestDaemon(t, runner)
+	d.maxConcurrent = 2
+	d.providerLimits = map[string]int{"openai": 1}
+	for _, r := range []routines.Routine{
+		{ID: "a", Prompt: "p", Provider: "openai", Enabled: true},
+		{ID: "b", Prompt: "p", Provider: "openai", Priority: 1, Enabled: true},
+		{ID: "c", Prompt: "p", Provider: "anthropic", Enabled: true},
+	} {
+		require.NoError(t, d.store.Upsert(r))
+	}
+
+	d.fire("a")
+	d.fire("b")
+	d.fire("c")
+	started := []string{<-runner.started, <-runner.started}
+	assert.ElementsMatch(t, []string{"a", "c"}, started)
+	assert.Equal(t, 2, runner.callCount(), "b waits for the openai slot")
+
+	runner.release <- struct{}{}
+	runner.release <- struct{}{}
+	assert.Equal(t, "b", <-runner.started)
+	runner.release <- struct{}{}
+	require.Eventually(t, func() bool {
+		queued, err := d.queue.List()
+		return err == nil && len(queued) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestCatchUpQueuesMissedRunsPerPolicy(t *testing.T) {
+	runner := &fakeRunner{}
+	d := newTestDaemon(t, runner)
+	for _, r := range []routines.Routine{
+		{ID: "all", Prompt: "p", Schedule: "0 * * * *", CatchUp: routines.CatchUpAll, Enabled: true},
+		{ID: "once", Prompt: "p", Schedule: "0 * * * *", CatchUp: routines.CatchUpOnce, Enabled: true},
+		{ID: "skip", Prompt: "p", Schedule: "0 * * * *", Enabled: true},
+		{ID: "current", Prompt: "p", Schedule: "0 * * * *", CatchUp: routines.CatchUpAll, Enabled: true},
+	} {
+		require.NoError(t, d.store.Upsert(r))
+		next := fixedNow.Add(-3 * time.Hour) // missed 21:00, 22:00, 23:00 and 00:00
+		if r.ID == "current" {
+			next = fixedNow.Add(time.Hour)
+		}
+		require.NoError(t, d.state.SetNextRun(r.ID, next))
+	}
+
+	d.catchUp(time.Time{})
+
+	require.Eventually(t, func() bool { return runner.callCount() == 5 }, 2*time.Second, 10*time.Millisecond)
+	runner.mu.Lock()
+	calls := slices.Clone(runner.calls)
+	runner.mu.Unlock()
+	slices.Sort(calls)
+	assert.Equal(t, []string{"all", "all", "all", "all", "once"}, calls)
+}
+
+func TestMissedRunsSkipsQuietHoursAndCaps(t *testing.T) {
+	sched, err := cron.ParseStandard("* * * * *")
+	require.NoError(t, err)
+	quiet, err := routines.ParseQuietHours(fixedNow.Local().Add(-time.Hour).Format("15:04") + "-" + fixedNow.Local().Format("15:04"))
+	require.NoError(t, err)
+
+	missed := missedRuns(sched, fixedNow.Add(-2*time.Hour), fixedNow, quiet)
+	require.Len(t, missed, routines.MaxCatchUpRuns)
+	assert.Equal(t, fixedNow, missed[len(missed)-1], "the latest missed runs are kept")
+	assert.Empty(t, missedRuns(sched, fixedNow.Add(-30*time.Minute), fixedNow.Add(-time.Minute), quiet), "quiet hours runs are not caught up")
+}
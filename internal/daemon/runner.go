@@ -1,9 +1,13 @@
 package daemon
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"os/exec"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
 )
 
 // Subcommand pieces used to invoke a scheduled routine run as a subprocess.
@@ -11,6 +15,7 @@ const (
 	routineSubcommand = "routine"
 	runSubcommand     = "run"
 	scheduledFlag     = "--scheduled"
+	eventStdinFlag    = "--event-stdin"
 )
 
 // RoutineRunner runs a single routine. The default implementation spawns the
@@ -18,10 +23,12 @@ const (
 // failure in one run cannot affect the daemon or other runs. Tests inject a fake.
 type RoutineRunner interface {
 	// Run blocks until the routine run completes and returns its error (if any).
-	Run(ctx context.Context, routineID string) error
+	// event is the payload of the trigger that fired the run; nil for cron runs.
+	Run(ctx context.Context, routineID string, event *routines.TriggerEvent) error
 }
 
-// execRunner spawns `<exePath> routine run <id> --scheduled`. The child records
+// execRunner spawns `<exePath> routine run <id> --scheduled`, or
+// `--event-stdin` with the trigger payload as JSON on stdin. The child records
 // its own JSONL transcript, result summary, and status entry through the normal
 // run path, so its stdout/stderr are only diagnostic and default to discard.
 type execRunner struct {
@@ -36,10 +43,20 @@ func newExecRunner(exePath string, output io.Writer) execRunner {
 	return execRunner{exePath: exePath, output: output}
 }
 
-func (r execRunner) Run(ctx context.Context, routineID string) error {
+func (r execRunner) Run(ctx context.Context, routineID string, event *routines.TriggerEvent) error {
 	// Intentionally not bound to the daemon's context: an in-flight routine run is
 	// an independent process that should finish even if the daemon is stopping.
 	cmd := exec.Command(r.exePath, routineSubcommand, runSubcommand, routineID, scheduledFlag)
+	if event != nil {
+		// The payload can be a large webhook body, so it goes over stdin rather
+		// than the argument list.
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		cmd = exec.Command(r.exePath, routineSubcommand, runSubcommand, routineID, eventStdinFlag)
+		cmd.Stdin = bytes.NewReader(payload)
+	}
 	cmd.Stdout = r.output
 	cmd.Stderr = r.output
 	return cmd.Run()
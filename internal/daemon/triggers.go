@@ -0,0 +1,456 @@
+package daemon
+
+import (
+	"context"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	log "github.com/laszukdawid/terminal-agent/internal/utils"
+)
+
+// defaultSystemInterval is how often the daemon polls for system events. A gap
+// of several intervals between polls means the machine was asleep.
+const defaultSystemInterval = 15 * time.Second
+
+// maxTriggerOutput bounds the upstream output passed to a routine trigger.
+const maxTriggerOutput = 64 * 1024
+
+// selectTriggers collects the event triggers of every enabled routine, keyed by
+// id. Routine triggers that form a cycle (a triggers b triggers a) are dropped
+// and logged, since they would run forever.
+func selectTriggers(routineList []routines.Routine, enabled bool) map[string][]routines.Trigger {
+	triggers := map[string][]routines.Trigger{}
+	if !enabled {
+		return triggers
+	}
+	for _, r := range routineList {
+		if r.Enabled && len(r.Triggers) > 0 {
+			triggers[r.ID] = r.Triggers
+		}
+	}
+
+	// upstream[a] lists the routines whose completion fires a.
+	upstream := map[string][]string{}
+	for id, list := range triggers {
+		for _, t := range list {
+			if t.Type == routines.TriggerRoutine {
+				upstream[id] = append(upstream[id], t.Routine)
+			}
+		}
+	}
+	for id := range upstream {
+		if !reachesSelf(id, upstream) {
+			continue
+		}
+		log.Warnw("daemon: routine triggers form a cycle, ignoring them", "routine", id)
+		triggers[id] = slices.DeleteFunc(slices.Clone(triggers[id]), func(t routines.Trigger) bool {
+			return t.Type == routines.TriggerRoutine
+		})
+	}
+	return triggers
+}
+
+// reachesSelf reports whether following upstream edges from id leads back to it.
+func reachesSelf(id string, upstream map[string][]string) bool {
+	seen := map[string]bool{}
+	stack := slices.Clone(upstream[id])
+	for len(stack) > 0 {
+		next := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if next == id {
+			return true
+		}
+		if seen[next] {
+			continue
+		}
+		seen[next] = true
+		stack = append(stack, upstream[next]...)
+	}
+	return false
+}
+
+// checkCompletions compares each routine's last run time against the previous
+// check and fires the routine triggers waiting on runs that finished since. The
+// first call only takes the snapshot.
+func (d *Daemon) checkCompletions() {
+	states, err := d.state.All()
+	if err != nil {
+		log.Warnw("daemon: reading run state failed", "error", err)
+		return
+	}
+	d.mu.Lock()
+	first := d.lastRuns == nil
+	if first {
+		d.lastRuns = map[string]time.Time{}
+	}
+	var completed []string
+	for id, rec := range states {
+		if rec.LastRunAt.IsZero() {
+			continue
+		}
+		prev, seen := d.lastRuns[id]
+		if !first && (!seen || rec.LastRunAt.After(prev)) {
+			completed = append(completed, id)
+		}
+		d.lastRuns[id] = rec.LastRunAt
+	}
+	triggers := d.triggers
+	d.mu.Unlock()
+
+	for _, upstream := range completed {
+		rec := states[upstream]
+		for id, list := range triggers {
+			for _, t := range list {
+				if t.Type != routines.TriggerRoutine || t.Routine != upstream || !slices.Contains(t.UpstreamOutcomes(), rec.LastStatus) {
+					continue
+				}
+				event := &routines.TriggerEvent{
+					Type:    routines.TriggerRoutine,
+					FiredAt: d.now().UTC(),
+					Routine: upstream,
+					Outcome: rec.LastStatus,
+					Output:  readRunOutput(rec.LastResultPath),
+				}
+				go d.fireEvent(id, event)
+				break
+			}
+		}
+	}
+}
+
+// readRunOutput extracts the output section of a run's result summary.
+func readRunOutput(path string) string {
+	output := routines.ReadResultOutput(path)
+	if len(output) > maxTriggerOutput {
+		output = output[:maxTriggerOutput]
+	}
+	return output
+}
+
+// fireSystem fires every routine with a trigger on the given system event.
+func (d *Daemon) fireSystem(name string) {
+	d.mu.Lock()
+	triggers := d.triggers
+	d.mu.Unlock()
+	for id, list := range triggers {
+		if slices.ContainsFunc(list, func(t routines.Trigger) bool {
+			return t.Type == routines.TriggerSystem && t.Event == name
+		}) {
+			log.Infow("daemon: system event", "event", name, "routine", id)
+			go d.fireEvent(id, &routines.TriggerEvent{Type: routines.TriggerSystem, FiredAt: d.now().UTC(), Event: name})
+		}
+	}
+}
+
+// watchSystem fires the login event once, then polls for network-up and wake
+// until ctx is done.
+func (d *Daemon) watchSystem(ctx context.Context) {
+	interval, isUp := d.systemInterval, d.networkUp
+	if interval <= 0 {
+		interval = defaultSystemInterval
+	}
+	if isUp == nil {
+		isUp = networkUp
+	}
+	d.fireSystem(routines.SystemLogin)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// Round(0) drops the monotonic reading: the monotonic clock stops during
+	// suspend, so only the wall clock shows the gap.
+	lastTick := time.Now().Round(0)
+	online := isUp()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now().Round(0)
+			if now.Sub(lastTick) > 3*interval {
+				// Catch up the runs missed while asleep, then rebuild the cron, whose
+				// timers ran on the stopped monotonic clock.
+				d.catchUp(lastTick)
+				d.reload(true)
+				d.fireSystem(routines.SystemWake)
+			}
+			lastTick = now
+			up := isUp()
+			if up && !online {
+				d.fireSystem(routines.SystemNetworkUp)
+			}
+			online = up
+		}
+	}
+}
+
+// networkUp reports whether any non-loopback interface is up with a routable
+// address.
+func networkUp() bool {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return false
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsGlobalUnicast() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fileRoot is one watched path of a file trigger.
+type fileRoot struct {
+	id      string
+	trigger routines.Trigger
+	path    string
+	isDir   bool
+}
+
+// pendingFire collects changed paths for a routine until its debounce expires.
+type pendingFire struct {
+	paths map[string]struct{}
+	timer *time.Timer
+}
+
+// triggerSources owns the long-lived trigger inputs of a running daemon: the
+// file watches and the webhook server. update rebuilds them on reload.
+type triggerSources struct {
+	d *Daemon
+
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+	roots   []fileRoot
+	pending map[string]*pendingFire
+	webhook *webhookServer
+}
+
+func newTriggerSources(d *Daemon) *triggerSources {
+	return &triggerSources{d: d, pending: map[string]*pendingFire{}}
+}
+
+// update rebuilds the file watches from triggers and starts the webhook server
+// once a routine needs it.
+func (s *triggerSources) update(routineList []routines.Routine, triggers map[string][]routines.Trigger) {
+	workDirs := map[string]string{}
+	for _, r := range routineList {
+		workDirs[r.ID] = r.WorkingDir
+	}
+	var roots []fileRoot
+	needsWebhook := false
+	for id, list := range triggers {
+		for _, t := range list {
+			switch t.Type {
+			case routines.TriggerFile:
+				for _, path := range t.Paths {
+					path = resolveTriggerPath(path, workDirs[id])
+					info, err := os.Stat(path)
+					if err != nil {
+						log.Warnw("daemon: file trigger path unavailable", "routine", id, "path", path, "error", err)
+						continue
+					}
+					roots = append(roots, fileRoot{id: id, trigger: t, path: path, isDir: info.IsDir()})
+				}
+			case routines.TriggerWebhook:
+				needsWebhook = true
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeWatcherLocked()
+	s.roots = roots
+	if len(roots) > 0 {
+		s.startWatcherLocked()
+	}
+	if needsWebhook && s.webhook == nil {
+		server, err := startWebhookServer(s.d)
+		if err != nil {
+			log.Warnw("daemon: starting webhook server failed", "error", err)
+		} else {
+			s.webhook = server
+			log.Infow("daemon: webhook triggers listening", "addr", server.Addr())
+		}
+	}
+}
+
+func (s *triggerSources) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeWatcherLocked()
+	if s.webhook != nil {
+		_ = s.webhook.Close()
+		s.webhook = nil
+	}
+}
+
+func (s *triggerSources) closeWatcherLocked() {
+	for id, pending := range s.pending {
+		pending.timer.Stop()
+		delete(s.pending, id)
+	}
+	if s.watcher != nil {
+		_ = s.watcher.Close()
+		s.watcher = nil
+	}
+}
+
+func (s *triggerSources) startWatcherLocked() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Warnw("daemon: fsnotify unavailable, file triggers disabled", "error", err)
+		return
+	}
+	for _, root := range s.roots {
+		switch {
+		case !root.isDir:
+			// Watch the parent so editors that replace the file are still seen.
+			_ = watcher.Add(filepath.Dir(root.path))
+		case root.trigger.Recursive:
+			addTree(watcher, root.path)
+		default:
+			_ = watcher.Add(root.path)
+		}
+	}
+	s.watcher = watcher
+	go s.watch(watcher)
+}
+
+// addTree watches dir and every directory below it.
+func addTree(watcher *fsnotify.Watcher, dir string) {
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if err := watcher.Add(path); err != nil {
+				log.Warnw("daemon: watching directory failed", "dir", path, "error", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *triggerSources) watch(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			s.handle(watcher, event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warnw("daemon: file trigger watch error", "error", err)
+		}
+	}
+}
+
+func (s *triggerSources) handle(watcher *fsnotify.Watcher, event fsnotify.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watcher != watcher {
+		return // a stale event from a watcher replaced by update
+	}
+	name := filepath.Clean(event.Name)
+	for _, root := range s.roots {
+		rel, ok := root.relative(name)
+		if !ok {
+			continue
+		}
+		if root.isDir && root.trigger.Recursive && event.Has(fsnotify.Create) {
+			if info, err := os.Stat(name); err == nil && info.IsDir() {
+				addTree(watcher, name)
+			}
+		}
+		if root.trigger.MatchesPath(rel) {
+			s.queueLocked(root.id, root.trigger.DebounceDuration(), name)
+		}
+	}
+}
+
+// relative returns name relative to the root when the root covers it.
+func (r fileRoot) relative(name string) (string, bool) {
+	if !r.isDir {
+		return filepath.Base(name), name == r.path
+	}
+	rel, err := filepath.Rel(r.path, name)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if !r.trigger.Recursive && strings.ContainsRune(rel, filepath.Separator) {
+		return "", false
+	}
+	return rel, true
+}
+
+// queueLocked adds a changed path to the routine's pending fire and restarts
+// its debounce timer.
+func (s *triggerSources) queueLocked(id string, debounce time.Duration, path string) {
+	pending, ok := s.pending[id]
+	if ok {
+		pending.paths[path] = struct{}{}
+		pending.timer.Reset(debounce)
+		return
+	}
+	pending = &pendingFire{paths: map[string]struct{}{path: {}}}
+	pending.timer = time.AfterFunc(debounce, func() { s.flush(id, pending) })
+	s.pending[id] = pending
+}
+
+func (s *triggerSources) flush(id string, pending *pendingFire) {
+	s.mu.Lock()
+	if s.pending[id] != pending {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.pending, id)
+	paths := make([]string, 0, len(pending.paths))
+	for path := range pending.paths {
+		paths = append(paths, path)
+	}
+	s.mu.Unlock()
+
+	slices.Sort(paths)
+	log.Infow("daemon: file trigger fired", "routine", id, "paths", len(paths))
+	s.d.fireEvent(id, &routines.TriggerEvent{Type: routines.TriggerFile, FiredAt: s.d.now().UTC(), Paths: paths})
+}
+
+// resolveTriggerPath expands a leading ~ and resolves relative paths against the
+// routine's working directory (or the home directory).
+func resolveTriggerPath(path, workDir string) string {
+	home := os.Getenv("HOME")
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	base := workDir
+	if base == "" {
+		base = home
+	}
+	return filepath.Join(base, path)
+}
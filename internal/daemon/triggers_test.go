@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectTriggersDropsCycles(t *testing.T) {
+	list := []routines.Routine{
+		{ID: "a", Enabled: true, Triggers: []routines.Trigger{{Type: routines.TriggerRoutine, Routine: "b"}, {Type: routines.TriggerWebhook}}},
+		{ID: "b", Enabled: true, Triggers: []routines.Trigger{{Type: routines.TriggerRoutine, Routine: "a"}}},
+		{ID: "c", Enabled: true, Triggers: []routines.Trigger{{Type: routines.TriggerRoutine, Routine: "a"}}},
+		{ID: "off", Enabled: false, Triggers: []routines.Trigger{{Type: routines.TriggerWebhook}}},
+	}
+
+	got := selectTriggers(list, true)
+	assert.Equal(t, []routines.Trigger{{Type: routines.TriggerWebhook}}, got["a"], "the cyclic routine trigger is dropped")
+	assert.Empty(t, got["b"])
+	assert.Len(t, got["c"], 1, "a routine downstream of a cycle keeps its trigger")
+	assert.NotContains(t, got, "off")
+	assert.Empty(t, selectTriggers(list, false))
+}
+
+func TestCheckCompletionsFiresRoutineTriggers(t *testing.T) {
+	runner := &fakeRunner{started: make(chan string, 1)}
+	d := newTestDaemon(t, runner)
+	d.triggers = map[string][]routines.Trigger{
+		"deploy": {{Type: routines.TriggerRoutine, Routine: "build"}},
+		"alert":  {{Type: routines.TriggerRoutine, Routine: "build", On: []string{routines.OutcomeFailed}}},
+	}
+	require.NoError(t, d.state.Record("build", routines.RunRecord{LastRunAt: fixedNow.Add(-time.Hour), LastStatus: routines.OutcomeSuccess}))
+	d.checkCompletions() // snapshot: runs from before the daemon started do not fire
+
+	summary := filepath.Join(t.TempDir(), "build.md")
+	require.NoError(t, os.WriteFile(summary, []byte("# Routine: build\n\n- Status: success\n\n## Output\n\nbuilt v1.2\n"), 0o644))
+	require.NoError(t, d.state.Record("build", routines.RunRecord{LastRunAt: fixedNow, LastStatus: routines.OutcomeSuccess, LastResultPath: summary}))
+	d.checkCompletions()
+
+	assert.Equal(t, "deploy", <-runner.started)
+	d.checkCompletions() // already seen
+	time.Sleep(20 * time.Millisecond)
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	require.Len(t, runner.events, 1)
+	assert.Equal(t, &routines.TriggerEvent{
+		Type:    routines.TriggerRoutine,
+		FiredAt: fixedNow,
+		Routine: "build",
+		Outcome: routines.OutcomeSuccess,
+		Output:  "built v1.2",
+	}, runner.events[0])
+}
+
+func TestFileTriggerDebouncesAndFilters(t *testing.T) {
+	runner := &fakeRunner{started: make(chan string, 1)}
+	d := newTestDaemon(t, runner)
+	dir := t.TempDir()
+	list := []routines.Routine{{ID: "docs", Prompt: "p", Enabled: true, Triggers: []routines.Trigger{
+		{Type: routines.TriggerFile, Paths: []string{dir}, Include: []string{"*.md"}, Debounce: "100ms"},
+	}}}
+	d.sources = newTriggerSources(d)
+	defer d.sources.close()
+	d.sources.update(list, selectTriggers(list, true))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.md"), []byte("1"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("1"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.md"), []byte("1"), 0o644))
+
+	select {
+	case id := <-runner.started:
+		assert.Equal(t, "docs", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("file trigger did not fire")
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	require.Len(t, runner.events, 1, "changes within the debounce window fire once")
+	assert.Equal(t, routines.TriggerFile, runner.events[0].Type)
+	assert.Equal(t, []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md")}, runner.events[0].Paths)
+}
+
+func TestWebhookTriggerPassesRequest(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := filepath.Join(home, ".config", "terminal-agent")
+	require.NoError(t, os.MkdirAll(configDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"routines":{"webhook_addr":"127.0.0.1:0"}}`), 0o644))
+
+	runner := &fakeRunner{started: make(chan string, 1)}
+	d := newTestDaemon(t, runner)
+	d.triggers = map[string][]routines.Trigger{
+		"deploy":    {{Type: routines.TriggerWebhook, Token: "s3cret"}},
+		"tokenless": {{Type: routines.TriggerWebhook}},
+	}
+	server, err := startWebhookServer(d)
+	require.NoError(t, err)
+	defer server.Close()
+	base := "http://" + server.Addr() + "/routines/"
+
+	post := func(path, token string) int {
+		req, err := http.NewRequest(http.MethodPost, base+path, strings.NewReader(`{"ref":"main"}`))
+		require.NoError(t, err)
+		req.Header.Set("X-GitHub-Event", "push")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusNotFound, post("other/trigger", "s3cret"))
+	assert.Equal(t, http.StatusUnauthorized, post("deploy/trigger", "wrong"))
+	assert.Equal(t, http.StatusUnauthorized, post("deploy/trigger", ""))
+	assert.Equal(t, http.StatusUnauthorized, post("tokenless/trigger", ""))
+	assert.Equal(t, http.StatusAccepted, post("deploy/trigger?env=prod", "s3cret"))
+
+	assert.Equal(t, "deploy", <-runner.started)
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	event := runner.events[0]
+	assert.Equal(t, routines.TriggerWebhook, event.Type)
+	assert.Equal(t, `{"ref":"main"}`, event.Body)
+	assert.Equal(t, "push", event.Headers["X-Github-Event"])
+	assert.NotContains(t, event.Headers, "Authorization", "credentials are not passed to the run")
+	assert.Equal(t, map[string]string{"env": "prod"}, event.Query)
+}
+
+func TestFireSystemFiresMatchingRoutines(t *testing.T) {
+	runner := &fakeRunner{started: make(chan string, 2)}
+	d := newTestDaemon(t, runner)
+	d.triggers = map[string][]routines.Trigger{
+		"sync":  {{Type: routines.TriggerSystem, Event: routines.SystemNetworkUp}},
+		"hello": {{Type: routines.TriggerSystem, Event: routines.SystemLogin}},
+	}
+
+	d.fireSystem(routines.SystemNetworkUp)
+
+	assert.Equal(t, "sync", <-runner.started)
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	assert.Equal(t, routines.SystemNetworkUp, runner.events[0].Event)
+}
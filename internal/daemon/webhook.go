@@ -0,0 +1,130 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	log "github.com/laszukdawid/terminal-agent/internal/utils"
+)
+
+// maxWebhookBody bounds the request body passed to a webhook-triggered run.
+const maxWebhookBody = 1 << 20
+
+// webhookTokenHeader carries a webhook trigger's token for callers that cannot
+// set an Authorization header.
+const webhookTokenHeader = "X-Agent-Token"
+
+// webhookServer accepts POST /routines/<id>/trigger and fires the routine's
+// webhook trigger with the request as its payload.
+type webhookServer struct {
+	d        *Daemon
+	listener net.Listener
+	server   *http.Server
+}
+
+func startWebhookServer(d *Daemon) (*webhookServer, error) {
+	addr := config.DefaultRoutineWebhookAddr
+	if cfg, err := config.LoadRoutinesConfig(); err == nil && strings.TrimSpace(cfg.WebhookAddr) != "" {
+		addr = strings.TrimSpace(cfg.WebhookAddr)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	w := &webhookServer{d: d, listener: listener}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /routines/{id}/trigger", w.trigger)
+	w.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = w.server.Serve(listener) }()
+	return w, nil
+}
+
+// Addr returns the address the server listens on.
+func (w *webhookServer) Addr() string {
+	return w.listener.Addr().String()
+}
+
+func (w *webhookServer) Close() error {
+	return w.server.Close()
+}
+
+func (w *webhookServer) trigger(rw http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	w.d.mu.Lock()
+	var hook *routines.Trigger
+	for _, t := range w.d.triggers[id] {
+		if t.Type == routines.TriggerWebhook {
+			hook = &t
+			break
+		}
+	}
+	w.d.mu.Unlock()
+
+	if hook == nil {
+		writeWebhookStatus(rw, http.StatusNotFound, "no enabled routine with a webhook trigger: "+id)
+		return
+	}
+	// A trigger without a token, written to routines.json by hand, is never
+	// fired: any web page could otherwise post to the loopback endpoint.
+	if hook.Token == "" {
+		writeWebhookStatus(rw, http.StatusUnauthorized, "the routine's webhook trigger has no token; set one in routines.json")
+		return
+	}
+	if !validWebhookToken(r, hook.Token) {
+		writeWebhookStatus(rw, http.StatusUnauthorized, "invalid or missing token")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody+1))
+	if err != nil {
+		writeWebhookStatus(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(body) > maxWebhookBody {
+		writeWebhookStatus(rw, http.StatusRequestEntityTooLarge, "request body exceeds 1 MiB")
+		return
+	}
+
+	event := &routines.TriggerEvent{
+		Type:    routines.TriggerWebhook,
+		FiredAt: w.d.now().UTC(),
+		Body:    string(body),
+		Headers: map[string]string{},
+		Query:   map[string]string{},
+	}
+	for key, values := range r.Header {
+		// Credentials stay out of the prompt and the run logs.
+		if key == "Authorization" || key == webhookTokenHeader || len(values) == 0 {
+			continue
+		}
+		event.Headers[key] = values[0]
+	}
+	for key, values := range r.URL.Query() {
+		if len(values) > 0 {
+			event.Query[key] = values[0]
+		}
+	}
+	log.Infow("daemon: webhook trigger fired", "routine", id, "bytes", len(body))
+	go w.d.fireEvent(id, event)
+	writeWebhookStatus(rw, http.StatusAccepted, "accepted")
+}
+
+func validWebhookToken(r *http.Request, token string) bool {
+	presented := r.Header.Get(webhookTokenHeader)
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		presented = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+func writeWebhookStatus(rw http.ResponseWriter, code int, message string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(map[string]string{"status": message})
+}
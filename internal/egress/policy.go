@@ -0,0 +1,186 @@
+// Package egress enforces the network egress policy: which hosts
+// external-facing tools and task child processes may contact.
+//
+// Tools that reach the network check their hosts with Check before connecting.
+// Child processes of the unix and python tools are pointed at a local Proxy,
+// which applies the same policy per request and reports every host it sees.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+)
+
+// ErrDenied is returned when the egress policy blocks a host.
+var ErrDenied = errors.New("egress denied by policy")
+
+// lookupIP resolves host names for CIDR rules; tests replace it.
+var lookupIP = net.DefaultResolver.LookupIP
+
+// Policy decides whether a host may be contacted. A nil Policy allows every
+// host.
+type Policy struct {
+	allow []hostPattern
+	deny  []hostPattern
+}
+
+type hostPattern struct {
+	// exact matches one host, suffix matches subdomains ("*.example.com"),
+	// network matches IP addresses, and any matches everything ("*").
+	exact   string
+	suffix  string
+	network *net.IPNet
+	any     bool
+}
+
+// NewPolicy builds a Policy from the configured host patterns. It returns nil
+// when neither list is set.
+func NewPolicy(cfg config.EgressConfig) *Policy {
+	if len(cfg.Allow) == 0 && len(cfg.Deny) == 0 {
+		return nil
+	}
+	return &Policy{allow: parseHostPatterns(cfg.Allow), deny: parseHostPatterns(cfg.Deny)}
+}
+
+// Load builds the Policy configured in the global config.
+func Load() (*Policy, error) {
+	cfg, err := config.LoadEgressConfig()
+	if err != nil {
+		return nil, err
+	}
+	return NewPolicy(cfg), nil
+}
+
+// Check loads the configured policy and checks every host against it, CIDR
+// rules included (see CheckResolved).
+func Check(hosts ...string) error {
+	policy, err := Load()
+	if err != nil {
+		return fmt.Errorf("failed to load egress policy: %w", err)
+	}
+	for _, host := range hosts {
+		if err := policy.CheckResolved(context.Background(), host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check returns an error wrapping ErrDenied when host may not be contacted.
+// host may carry a port, which is ignored.
+func (p *Policy) Check(host string) error {
+	if p.Allowed(host) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrDenied, normalizeHost(host))
+}
+
+// CheckResolved is Check that also matches CIDR rules against the addresses a
+// host name resolves to, as the Proxy does. A name that does not resolve is
+// not denied here; contacting it fails anyway.
+func (p *Policy) CheckResolved(ctx context.Context, host string) error {
+	if _, err := p.resolve(ctx, host); errors.Is(err, ErrDenied) {
+		return err
+	}
+	return nil
+}
+
+// Allowed reports whether host may be contacted.
+func (p *Policy) Allowed(host string) bool {
+	if p == nil {
+		return true
+	}
+	host = normalizeHost(host)
+	if matchesAny(p.deny, host) {
+		return false
+	}
+	return len(p.allow) == 0 || matchesAny(p.allow, host)
+}
+
+// resolve checks host like Check and, because CIDR rules otherwise match IP
+// literals only, also checks the addresses a host name resolves to: the host is
+// denied when any of them is. It returns the checked addresses for the caller
+// to dial, so a second lookup cannot lead elsewhere, or nil when host is an IP
+// literal or the policy has no CIDR rules and host may be dialled by name.
+func (p *Policy) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	name := normalizeHost(host)
+	if p == nil || net.ParseIP(name) != nil || !p.hasNetworks() || matchesAny(p.deny, name) {
+		return nil, p.Check(name)
+	}
+
+	ips, err := lookupIP(ctx, "ip", name)
+	if err != nil {
+		return nil, err
+	}
+	nameAllowed := len(p.allow) == 0 || matchesAny(p.allow, name)
+	for _, ip := range ips {
+		addr := ip.String()
+		if matchesAny(p.deny, addr) || (!nameAllowed && !matchesAny(p.allow, addr)) {
+			return nil, fmt.Errorf("%w: %s (resolves to %s)", ErrDenied, name, addr)
+		}
+	}
+	return ips, nil
+}
+
+func (p *Policy) hasNetworks() bool {
+	isNetwork := func(pattern hostPattern) bool { return pattern.network != nil }
+	return slices.ContainsFunc(p.allow, isNetwork) || slices.ContainsFunc(p.deny, isNetwork)
+}
+
+func parseHostPatterns(values []string) []hostPattern {
+	patterns := make([]hostPattern, 0, len(values))
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		switch {
+		case value == "*":
+			patterns = append(patterns, hostPattern{any: true})
+		case strings.Contains(value, "/"):
+			if _, network, err := net.ParseCIDR(value); err == nil {
+				patterns = append(patterns, hostPattern{network: network})
+			}
+		case strings.HasPrefix(value, "*."):
+			patterns = append(patterns, hostPattern{suffix: value[1:]})
+		case value != "":
+			patterns = append(patterns, hostPattern{exact: normalizeHost(value)})
+		}
+	}
+	return patterns
+}
+
+func matchesAny(patterns []hostPattern, host string) bool {
+	ip := net.ParseIP(host)
+	for _, pattern := range patterns {
+		switch {
+		case pattern.any:
+			return true
+		case pattern.network != nil:
+			if ip != nil && pattern.network.Contains(ip) {
+				return true
+			}
+		case pattern.suffix != "":
+			if strings.HasSuffix(host, pattern.suffix) {
+				return true
+			}
+		case pattern.exact == host:
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost lower-cases host and strips a port, IPv6 brackets and a
+// trailing dot.
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(host, ".")
+}
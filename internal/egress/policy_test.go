@@ -0,0 +1,116 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyAllowed(t *testing.T) {
+	policy := NewPolicy(config.EgressConfig{
+		Allow: []string{"api.tavily.com", "*.github.com", "10.0.0.0/8"},
+		Deny:  []string{"gist.github.com", "10.1.0.0/16"},
+	})
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"api.tavily.com", true},
+		{"API.Tavily.com:443", true},
+		{"api.github.com", true},
+		{"github.com", false},
+		{"gist.github.com:443", false},
+		{"10.2.3.4:8080", true},
+		{"10.1.2.3", false},
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, policy.Allowed(tt.host), tt.host)
+	}
+}
+
+func TestPolicyDenyOnlyAllowsEverythingElse(t *testing.T) {
+	policy := NewPolicy(config.EgressConfig{Deny: []string{"*.internal", "[::1]"}})
+
+	assert.True(t, policy.Allowed("example.com"))
+	assert.False(t, policy.Allowed("db.internal:5432"))
+	assert.False(t, policy.Allowed("[::1]:80"))
+
+	err := policy.Check("db.internal:5432")
+	assert.True(t, errors.Is(err, ErrDenied))
+	assert.EqualError(t, err, "egress denied by policy: db.internal")
+}
+
+// useLookup replaces host name resolution with the given addresses.
+func useLookup(t *testing.T, hosts map[string]string) {
+	t.Helper()
+	original := lookupIP
+	lookupIP = func(_ context.Context, _, host string) ([]net.IP, error) {
+		addr, ok := hosts[host]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return []net.IP{net.ParseIP(addr)}, nil
+	}
+	t.Cleanup(func() { lookupIP = original })
+}
+
+func TestPolicyResolveMatchesCIDRRulesOnResolvedAddresses(t *testing.T) {
+	useLookup(t, map[string]string{"metadata.example": "169.254.169.254", "build.corp": "10.2.0.5", "example.com": "93.184.215.14"})
+	policy := NewPolicy(config.EgressConfig{Allow: []string{"10.0.0.0/8", "example.com"}, Deny: []string{"169.254.0.0/16"}})
+
+	_, err := policy.resolve(context.Background(), "metadata.example:80")
+	assert.EqualError(t, err, "egress denied by policy: metadata.example (resolves to 169.254.169.254)")
+	ips, err := policy.resolve(context.Background(), "build.corp:443")
+	require.NoError(t, err)
+	assert.Equal(t, "10.2.0.5", ips[0].String())
+	ips, err = policy.resolve(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Len(t, ips, 1)
+	_, err = policy.resolve(context.Background(), "unknown.example")
+	assert.False(t, errors.Is(err, ErrDenied))
+	assert.Error(t, err)
+
+	ips, err = NewPolicy(config.EgressConfig{Deny: []string{"*.internal"}}).resolve(context.Background(), "example.com")
+	assert.NoError(t, err)
+	assert.Nil(t, ips, "without CIDR rules the host is dialled by name")
+}
+
+func TestCheckResolvedDeniesOnlyByPolicy(t *testing.T) {
+	useLookup(t, map[string]string{"db.corp": "10.1.2.3"})
+	policy := NewPolicy(config.EgressConfig{Deny: []string{"10.0.0.0/8"}})
+
+	assert.ErrorIs(t, policy.CheckResolved(context.Background(), "db.corp:5432"), ErrDenied)
+	assert.NoError(t, policy.CheckResolved(context.Background(), "unknown.example"), "a name that does not resolve is left to fail on connect")
+	assert.NoError(t, (*Policy)(nil).CheckResolved(context.Background(), "db.corp"))
+}
+
+func TestNilPolicyAllowsEverything(t *testing.T) {
+	policy := NewPolicy(config.EgressConfig{Proxy: true})
+
+	assert.Nil(t, policy)
+	assert.True(t, policy.Allowed("anything.example"))
+	assert.NoError(t, policy.Check("anything.example"))
+}
+
+func TestCheckLoadsGlobalConfig(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	dir := filepath.Join(homeDir, ".config", "terminal-agent")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"egress": {"allow": ["api.tavily.com"]}}`), 0o600))
+
+	assert.NoError(t, Check("api.tavily.com"))
+	assert.ErrorIs(t, Check("api.tavily.com", "evil.example"), ErrDenied)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"egress": {"deny": ["exa*mple.com"]}}`), 0o600))
+	assert.ErrorContains(t, Check("example.com"), "egress.deny: invalid host pattern")
+}
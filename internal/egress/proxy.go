@@ -0,0 +1,255 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const proxyDialTimeout = 30 * time.Second
+
+// hopHeaders are connection-scoped and must not be forwarded by a proxy.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Request describes one outbound connection: seen by the Proxy, or declared by
+// a tool before it runs.
+type Request struct {
+	// Tool names the tool that declared the host; empty for proxied requests.
+	Tool    string
+	Method  string
+	Host    string
+	Allowed bool
+}
+
+// Proxy is a local HTTP proxy handling plain HTTP requests and CONNECT tunnels.
+// It applies a Policy to every request and reports each one to onRequest.
+type Proxy struct {
+	policy    *Policy
+	onRequest func(Request)
+	listener  net.Listener
+	server    *http.Server
+	transport *http.Transport
+
+	mu      sync.Mutex
+	tunnels map[net.Conn]struct{}
+}
+
+// StartProxy listens on a loopback port and serves until Close. onRequest may
+// be nil.
+func StartProxy(policy *Policy, onRequest func(Request)) (*Proxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &Proxy{
+		policy:    policy,
+		onRequest: onRequest,
+		listener:  listener,
+		tunnels:   map[net.Conn]struct{}{},
+	}
+	// The proxy must not itself honour HTTP_PROXY, or requests would loop.
+	p.transport = &http.Transport{Proxy: nil, DialContext: dialChecked}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: proxyDialTimeout}
+	go func() { _ = p.server.Serve(listener) }()
+	return p, nil
+}
+
+// URL returns the proxy address for HTTP_PROXY-style variables.
+func (p *Proxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+// Env returns environment variables that point HTTP clients at the proxy.
+// NO_PROXY is cleared so no destination bypasses it.
+func (p *Proxy) Env() []string {
+	url := p.URL()
+	return []string{
+		"HTTP_PROXY=" + url,
+		"HTTPS_PROXY=" + url,
+		"ALL_PROXY=" + url,
+		"http_proxy=" + url,
+		"https_proxy=" + url,
+		"all_proxy=" + url,
+		"NO_PROXY=",
+		"no_proxy=",
+	}
+}
+
+// Close stops the proxy and closes open tunnels.
+func (p *Proxy) Close() error {
+	err := p.server.Close()
+	p.mu.Lock()
+	for conn := range p.tunnels {
+		_ = conn.Close()
+	}
+	p.mu.Unlock()
+	p.transport.CloseIdleConnections()
+	return err
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if r.Method != http.MethodConnect {
+		if !r.URL.IsAbs() {
+			http.Error(w, "this is a forward proxy; requests must use an absolute URL", http.StatusBadRequest)
+			return
+		}
+		host = r.URL.Host
+	}
+
+	addrs, err := p.policy.resolve(r.Context(), host)
+	allowed := !errors.Is(err, ErrDenied)
+	if p.onRequest != nil {
+		p.onRequest(Request{Method: r.Method, Host: host, Allowed: allowed})
+	}
+	if !allowed {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), checkedAddrsKey{}, addrs))
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	p.forward(w, r)
+}
+
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	outbound := r.Clone(r.Context())
+	outbound.RequestURI = ""
+	for _, header := range hopHeaders {
+		outbound.Header.Del(header)
+	}
+
+	resp, err := p.transport.RoundTrip(outbound)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, header := range hopHeaders {
+		resp.Header.Del(header)
+	}
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunnelling is not supported", http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), proxyDialTimeout)
+	defer cancel()
+	upstream, err := p.transport.DialContext(ctx, "tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		return
+	}
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		_ = client.Close()
+		_ = upstream.Close()
+		return
+	}
+
+	p.track(client, upstream)
+	defer p.untrack(client, upstream)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// Bytes the client sent after the CONNECT line are already buffered.
+		_, _ = io.Copy(upstream, buffered.Reader)
+		closeWrite(upstream)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(client, upstream)
+		closeWrite(client)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+// checkedAddrsKey carries the addresses the policy checked for a request's
+// host to dialChecked.
+type checkedAddrsKey struct{}
+
+// dialChecked dials the addresses the policy checked for the request, trying
+// each in turn, or addr itself when the host is dialled by name.
+func dialChecked(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: proxyDialTimeout}
+	ips, _ := ctx.Value(checkedAddrsKey{}).([]net.IP)
+	if len(ips) == 0 {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+func (p *Proxy) track(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range conns {
+		p.tunnels[conn] = struct{}{}
+	}
+}
+
+func (p *Proxy) untrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range conns {
+		delete(p.tunnels, conn)
+		_ = conn.Close()
+	}
+}
+
+// closeWrite half-closes conn so the peer sees EOF while the other direction
+// of the tunnel drains.
+func closeWrite(conn net.Conn) {
+	if tcp, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = tcp.CloseWrite()
+		return
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,147 @@
+package egress
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type requestLog struct {
+	mu       sync.Mutex
+	requests []Request
+}
+
+func (l *requestLog) record(request Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests = append(l.requests, request)
+}
+
+func (l *requestLog) all() []Request {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Request(nil), l.requests...)
+}
+
+func proxiedClient(t *testing.T, proxy *Proxy, base *http.Transport) *http.Client {
+	t.Helper()
+	proxyURL, err := url.Parse(proxy.URL())
+	require.NoError(t, err)
+	transport := base.Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return &http.Client{Transport: transport}
+}
+
+func TestProxyForwardsAllowedHTTPRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello from "+r.URL.Path)
+	}))
+	defer upstream.Close()
+	log := &requestLog{}
+	proxy, err := StartProxy(NewPolicy(config.EgressConfig{Allow: []string{"127.0.0.1"}}), log.record)
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	resp, err := proxiedClient(t, proxy, &http.Transport{}).Get(upstream.URL + "/status")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello from /status", string(body))
+	host := upstream.Listener.Addr().String()
+	assert.Equal(t, []Request{{Method: http.MethodGet, Host: host, Allowed: true}}, log.all())
+}
+
+func TestProxyTunnelsAllowedHTTPS(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "secure")
+	}))
+	defer upstream.Close()
+	log := &requestLog{}
+	proxy, err := StartProxy(nil, log.record)
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	resp, err := proxiedClient(t, proxy, upstream.Client().Transport.(*http.Transport)).Get(upstream.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "secure", string(body))
+	require.Len(t, log.all(), 1)
+	assert.Equal(t, http.MethodConnect, log.all()[0].Method)
+	assert.True(t, log.all()[0].Allowed)
+}
+
+func TestProxyBlocksDeniedHosts(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("denied request reached the upstream server")
+	}))
+	defer upstream.Close()
+	log := &requestLog{}
+	proxy, err := StartProxy(NewPolicy(config.EgressConfig{Deny: []string{"127.0.0.0/8"}}), log.record)
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	resp, err := proxiedClient(t, proxy, &http.Transport{}).Get(upstream.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Len(t, log.all(), 1)
+	assert.False(t, log.all()[0].Allowed)
+}
+
+func TestProxyAppliesCIDRRulesToResolvedHosts(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "internal "+r.Host)
+	}))
+	defer upstream.Close()
+	useLookup(t, map[string]string{"build.corp": "127.0.0.1"})
+	_, port, err := net.SplitHostPort(upstream.Listener.Addr().String())
+	require.NoError(t, err)
+	target := "http://build.corp:" + port + "/"
+
+	allowed, err := StartProxy(NewPolicy(config.EgressConfig{Allow: []string{"127.0.0.0/8"}}), nil)
+	require.NoError(t, err)
+	defer allowed.Close()
+	resp, err := proxiedClient(t, allowed, &http.Transport{}).Get(target)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "internal build.corp:"+port, string(body))
+
+	log := &requestLog{}
+	denied, err := StartProxy(NewPolicy(config.EgressConfig{Deny: []string{"127.0.0.0/8"}}), log.record)
+	require.NoError(t, err)
+	defer denied.Close()
+	resp, err = proxiedClient(t, denied, &http.Transport{}).Get(target)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, []Request{{Method: http.MethodGet, Host: "build.corp:" + port, Allowed: false}}, log.all())
+}
+
+func TestProxyEnvPointsAtProxy(t *testing.T) {
+	proxy, err := StartProxy(nil, nil)
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	env := proxy.Env()
+	assert.Contains(t, env, "HTTPS_PROXY="+proxy.URL())
+	assert.Contains(t, env, "http_proxy="+proxy.URL())
+	assert.Contains(t, env, "NO_PROXY=")
+}
@@ -0,0 +1,49 @@
+// Package fts picks the SQLite full-text module the chat and history stores
+// index with. Each store names its index after the module, e.g. messages_fts5,
+// so a database opened by builds with different modules keeps an index per
+// module: an index whose module the running build lacks is left alone rather
+// than dropped, which SQLite refuses without the module.
+package fts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Modules are the full-text modules tried in order. FTS5 needs the
+// sqlite_fts5 build tag; FTS4 is always compiled in.
+var Modules = []string{"fts5", "fts4"}
+
+// Best returns the first of Modules the database's SQLite build has, or ""
+// when it has none.
+func Best(db *sql.DB) (string, error) {
+	// Temporary tables belong to one connection, so the probe holds one.
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	for _, module := range Modules {
+		probe := fmt.Sprintf("CREATE VIRTUAL TABLE temp.fts_probe USING %s(body)", module)
+		if _, err := conn.ExecContext(context.Background(), probe); err != nil {
+			continue
+		}
+		if _, err := conn.ExecContext(context.Background(), "DROP TABLE temp.fts_probe"); err != nil {
+			return "", err
+		}
+		return module, nil
+	}
+	return "", nil
+}
+
+// Table returns the name of the index over base built with module: base_fts5
+// or base_fts4, or base_text for a plain table searched with LIKE when there
+// is no module.
+func Table(base, module string) string {
+	if module == "" {
+		return base + "_text"
+	}
+	return base + "_" + module
+}
@@ -39,11 +39,12 @@ type App struct {
 
 	// routineRefreshStop stops the background poller that keeps the Routine list in
 	// sync with runs produced out-of-process (the daemon, the CLI). lastRoutineMod
-	// is the routine files' modtime at the last reload, so the poller only rebuilds
-	// the list when something actually changed.
+	// is the routine files' modtime and lastDaemonState the daemon's reported
+	// state at the last reload, so the poller only rebuilds the list when
+	// something actually changed.
 	routineRefreshStop chan struct{}
 	lastRoutineMod     time.Time
-	lastDaemonRunning  bool
+	lastDaemonState    string
 }
 
 type AppOptions struct {
@@ -263,7 +264,9 @@ func (g *App) wire() {
 	g.popup.onSelectTask = func() { g.setMode(guiModeTask) }
 	g.popup.onSelectHistory = func() { g.setMode(guiModeHistory) }
 	g.popup.onSelectRoutine = func() { g.setMode(guiModeRoutine) }
+	g.popup.onShowChatTree = g.showChatTree
 	g.popup.onCreateRoutine = func() { g.showRoutineForm(nil) }
+	g.popup.onPauseRoutines = g.toggleRoutinePause
 	g.popup.onTest = g.openTestMenu
 	g.popup.onVoiceToggle = g.toggleVoice
 	g.popup.input.voiceTriggerKey = fyne.KeyName(g.cfg.GetGUIVoiceTriggerKey())
@@ -273,6 +276,9 @@ func (g *App) wire() {
 			if g.popup.dismissHistoryDetail() {
 				return
 			}
+			if g.popup.dismissChatTree() {
+				return
+			}
 			if g.popup.dismissRoutineDetail() {
 				return
 			}
@@ -516,12 +522,12 @@ func (g *App) finishRun(err error) {
 	g.FocusInput()
 }
 
-// taskAutoApprove reports whether GUI Task runs auto-approve actions. Today it is
-// always true; permission prompts are a future release, at which point this
-// becomes config/UI-driven and EventConfirmationNeeded graduates from a backstop
-// into a real confirmation dialog routed through the interaction controller.
+// taskAutoApprove reports whether GUI Task runs auto-approve actions. It is true
+// unless the organisation policy forbids auto-approve; permission prompts are a
+// future release, so until then such runs decline anything needing confirmation
+// through the EventConfirmationNeeded backstop.
 func (g *App) taskAutoApprove() bool {
-	return true
+	return config.CheckAutoApproveAllowed() == nil
 }
 
 // inputHeadingForMode returns the input section heading for the active mode.
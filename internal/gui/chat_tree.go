@@ -0,0 +1,228 @@
+package gui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	appservice "github.com/laszukdawid/terminal-agent/internal/app"
+	"github.com/laszukdawid/terminal-agent/internal/chat"
+)
+
+// chatTreeBranchPrefix marks the tree node that groups the messages of one
+// branch, keyed by the branch's first message.
+const chatTreeBranchPrefix = "branch:"
+
+// chatTree is the current chat session's messages, arranged for a tree view.
+// A run of messages without alternatives is listed flat; where a message is
+// followed by more than one, each continuation becomes a branch node under it,
+// so the view only nests where the conversation forked.
+type chatTree struct {
+	session  chat.Session
+	messages map[int64]chat.Message
+	children map[int64][]int64
+	// checkedOut holds the messages on the session's checked-out branch.
+	checkedOut map[int64]bool
+}
+
+func loadChatTree(dbPath string) (*chatTree, error) {
+	store, err := chat.NewSessionStore(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chat sessions: %w", err)
+	}
+	defer store.Close()
+	if store.CurrentSessionID() == 0 {
+		return nil, fmt.Errorf("no current chat session; start one with `agent chat`")
+	}
+	session, err := store.GetSession(store.CurrentSessionID())
+	if err != nil {
+		return nil, err
+	}
+	messages, err := store.SessionTree(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	branch, err := store.SessionMessages(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return newChatTree(session, messages, branch), nil
+}
+
+func newChatTree(session chat.Session, messages, checkedOut []chat.Message) *chatTree {
+	t := &chatTree{
+		session:    session,
+		messages:   make(map[int64]chat.Message, len(messages)),
+		children:   map[int64][]int64{},
+		checkedOut: make(map[int64]bool, len(checkedOut)),
+	}
+	for _, msg := range messages {
+		t.messages[msg.ID] = msg
+		t.children[msg.ParentID] = append(t.children[msg.ParentID], msg.ID)
+	}
+	for _, msg := range checkedOut {
+		t.checkedOut[msg.ID] = true
+	}
+	return t
+}
+
+// run lists the messages from id until one that is followed by zero or
+// several messages.
+func (t *chatTree) run(id int64) []widget.TreeNodeID {
+	var nodes []widget.TreeNodeID
+	for {
+		nodes = append(nodes, strconv.FormatInt(id, 10))
+		if len(t.children[id]) != 1 {
+			return nodes
+		}
+		id = t.children[id][0]
+	}
+}
+
+// forks lists a branch node per continuation of message id (0 for the start
+// of the session).
+func (t *chatTree) forks(id int64) []widget.TreeNodeID {
+	nodes := make([]widget.TreeNodeID, 0, len(t.children[id]))
+	for _, child := range t.children[id] {
+		nodes = append(nodes, chatTreeBranchPrefix+strconv.FormatInt(child, 10))
+	}
+	return nodes
+}
+
+func (t *chatTree) childUIDs(uid widget.TreeNodeID) []widget.TreeNodeID {
+	if uid == "" {
+		if roots := t.children[0]; len(roots) == 1 {
+			return t.run(roots[0])
+		}
+		return t.forks(0)
+	}
+	if first, ok := strings.CutPrefix(uid, chatTreeBranchPrefix); ok {
+		id, _ := strconv.ParseInt(first, 10, 64)
+		return t.run(id)
+	}
+	return t.forks(t.messageID(uid))
+}
+
+func (t *chatTree) isBranch(uid widget.TreeNodeID) bool {
+	if uid == "" || strings.HasPrefix(uid, chatTreeBranchPrefix) {
+		return true
+	}
+	return len(t.children[t.messageID(uid)]) > 1
+}
+
+// messageID returns the message a node stands for; a branch node stands for
+// its first message.
+func (t *chatTree) messageID(uid widget.TreeNodeID) int64 {
+	id, _ := strconv.ParseInt(strings.TrimPrefix(uid, chatTreeBranchPrefix), 10, 64)
+	return id
+}
+
+// target is the message checking out a node continues from: a branch node
+// resumes the branch's last message before it forks again or ends.
+func (t *chatTree) target(uid widget.TreeNodeID) int64 {
+	if !strings.HasPrefix(uid, chatTreeBranchPrefix) {
+		return t.messageID(uid)
+	}
+	run := t.run(t.messageID(uid))
+	return t.messageID(run[len(run)-1])
+}
+
+func (t *chatTree) label(uid widget.TreeNodeID) string {
+	msg := t.messages[t.messageID(uid)]
+	marker := "  "
+	if t.checkedOut[msg.ID] {
+		marker = "• "
+	}
+	preview, _ := historyPreview(msg.Content, "(empty)")
+	if runes := []rune(preview); len(runes) > 80 {
+		preview = string(runes[:79]) + "…"
+	}
+	if strings.HasPrefix(uid, chatTreeBranchPrefix) {
+		return fmt.Sprintf("%sbranch at #%d: %s", marker, msg.ID, preview)
+	}
+	text := fmt.Sprintf("%s#%d %s: %s", marker, msg.ID, msg.Role, preview)
+	if msg.ID == t.session.HeadID {
+		text += "  (checked out)"
+	}
+	return text
+}
+
+func (g *App) showChatTree() {
+	tree, err := loadChatTree(appservice.ChatDBPath())
+	if err != nil {
+		dialog.ShowError(err, g.popup.window)
+		return
+	}
+	g.popup.showChatTree(tree, func(id int64) error {
+		store, err := chat.NewSessionStore(appservice.ChatDBPath())
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		return store.Checkout(tree.session.ID, id)
+	})
+}
+
+// showChatTree shows the current chat session as a tree of its branches.
+// Checking out a message makes the next `agent chat` continue from it, which
+// forks the conversation when the message is not the end of a branch.
+func (p *popupWindow) showChatTree(tree *chatTree, checkout func(id int64) error) {
+	p.dismissChatTree()
+	title := fmt.Sprintf("#%d %s", tree.session.ID, tree.session.Title)
+	if tree.session.Title == "" {
+		title = fmt.Sprintf("#%d (untitled)", tree.session.ID)
+	}
+	heading := widget.NewLabelWithStyle(title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true, Monospace: true})
+	hint := widget.NewLabel("• marks the checked-out branch. Check out a message to continue the chat from it.")
+	hint.Wrapping = fyne.TextWrapWord
+
+	view := widget.NewTree(
+		tree.childUIDs,
+		tree.isBranch,
+		func(bool) fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(uid widget.TreeNodeID, _ bool, node fyne.CanvasObject) {
+			node.(*widget.Label).SetText(tree.label(uid))
+		},
+	)
+	view.OpenAllBranches()
+
+	var selected int64
+	checkoutButton := widget.NewButton("Check out", func() {
+		if err := checkout(selected); err != nil {
+			dialog.ShowError(err, p.window)
+			return
+		}
+		p.dismissChatTree()
+	})
+	checkoutButton.Disable()
+	view.OnSelected = func(uid widget.TreeNodeID) {
+		selected = tree.target(uid)
+		checkoutButton.Enable()
+	}
+	closeButton := widget.NewButton("Close", func() { p.dismissChatTree() })
+
+	size := historyDetailPopupSize(p.window.Canvas().Size())
+	footer := container.NewGridWithColumns(2, checkoutButton, closeButton)
+	body := container.NewBorder(container.NewVBox(heading, hint), footer, nil, nil, view)
+	content := borderedBox(body, currentBrandPalette().border)
+	pop := widget.NewModalPopUp(content, p.window.Canvas())
+	p.chatTree = pop
+	p.window.Canvas().Unfocus()
+	pop.Show()
+	pop.Resize(size)
+}
+
+func (p *popupWindow) dismissChatTree() bool {
+	if p.chatTree == nil || !p.chatTree.Visible() {
+		return false
+	}
+	p.chatTree.Hide()
+	return true
+}
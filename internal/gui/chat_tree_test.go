@@ -0,0 +1,44 @@
+package gui
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/laszukdawid/terminal-agent/internal/chat"
+)
+
+func TestChatTreeNestsOnlyWhereTheConversationForks(t *testing.T) {
+	messages := []chat.Message{
+		{ID: 1, Role: "user", Content: "question"},
+		{ID: 2, ParentID: 1, Role: "assistant", Content: "answer"},
+		{ID: 3, ParentID: 2, Role: "user", Content: "follow-up"},
+		{ID: 4, ParentID: 3, Role: "assistant", Content: "second answer"},
+		{ID: 5, ParentID: 2, Role: "user", Content: "edited follow-up"},
+		{ID: 6, ParentID: 5, Role: "assistant", Content: "other answer"},
+	}
+	tree := newChatTree(chat.Session{ID: 1, HeadID: 6}, messages, []chat.Message{messages[0], messages[1], messages[4], messages[5]})
+
+	if got := tree.childUIDs(""); !slices.Equal(got, []string{"1", "2"}) {
+		t.Fatalf("root children = %v, want [1 2]", got)
+	}
+	if tree.isBranch("1") || !tree.isBranch("2") {
+		t.Fatalf("only the forking message should be a branch")
+	}
+	if got := tree.childUIDs("2"); !slices.Equal(got, []string{"branch:3", "branch:5"}) {
+		t.Fatalf("fork children = %v", got)
+	}
+	if got := tree.childUIDs("branch:5"); !slices.Equal(got, []string{"5", "6"}) {
+		t.Fatalf("branch children = %v", got)
+	}
+	if got := tree.target("branch:3"); got != 4 {
+		t.Fatalf("target(branch:3) = %d, want 4", got)
+	}
+
+	if label := tree.label("6"); !strings.HasPrefix(label, "• #6 assistant") || !strings.Contains(label, "(checked out)") {
+		t.Fatalf("label(6) = %q", label)
+	}
+	if label := tree.label("3"); strings.HasPrefix(label, "•") {
+		t.Fatalf("label(3) = %q, should not be marked as checked out", label)
+	}
+}
@@ -66,9 +66,11 @@ type popupWindow struct {
 	historySection  *fyne.Container
 	historyBody     *fyne.Container
 	historyDetail   *widget.PopUp
+	chatTree        *widget.PopUp
 	routineSection  *fyne.Container
 	routineBody     *fyne.Container
 	routineDetail   *widget.PopUp
+	routinePause    *widget.Button
 	settings        *settingsDialog
 	outputField     *widget.RichText
 	transcriptBody  *fyne.Container
@@ -138,7 +140,9 @@ type popupWindow struct {
 	onSelectTask    func()
 	onSelectHistory func()
 	onSelectRoutine func()
+	onShowChatTree  func()
 	onCreateRoutine func()
+	onPauseRoutines func()
 	onTest          func()
 	onInput         func(string)
 	onQuit          func()
@@ -430,8 +434,14 @@ func (p *popupWindow) buildWorkspace() fyne.CanvasObject {
 	askGroup := container.NewVBox(headingRow, inputPanel)
 	p.inputGroup = askGroup
 	p.historyBody = container.NewVBox()
+	chatTreeButton := newCommandButton("CHAT TREE", iconPathChat, func() {
+		if p.onShowChatTree != nil {
+			p.onShowChatTree()
+		}
+	})
+	historyHeader := container.NewBorder(nil, nil, brandSectionLabel(sectionHistory), chatTreeButton, nil)
 	p.historySection = container.NewBorder(
-		container.NewVBox(brandSectionLabel(sectionHistory)),
+		container.NewVBox(historyHeader),
 		nil,
 		nil, nil,
 		borderedBox(container.NewVScroll(p.historyBody), palette.border),
@@ -444,7 +454,15 @@ func (p *popupWindow) buildWorkspace() fyne.CanvasObject {
 			p.onCreateRoutine()
 		}
 	})
-	routineHeader := container.NewBorder(nil, nil, brandSectionLabel(sectionRoutine), newRoutineButton, nil)
+	// Pausing needs the daemon's control API, so the button shows only while a
+	// daemon answers (see setRoutinePaused).
+	p.routinePause = newCommandButton("PAUSE", iconPathServer, func() {
+		if p.onPauseRoutines != nil {
+			p.onPauseRoutines()
+		}
+	})
+	p.routinePause.Hide()
+	routineHeader := container.NewBorder(nil, nil, brandSectionLabel(sectionRoutine), container.NewHBox(p.routinePause, newRoutineButton), nil)
 	p.routineSection = container.NewBorder(
 		container.NewVBox(routineHeader),
 		nil,
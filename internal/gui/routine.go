@@ -22,6 +22,7 @@ import (
 
 	appservice "github.com/laszukdawid/terminal-agent/internal/app"
 	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/daemon"
 	"github.com/laszukdawid/terminal-agent/internal/routines"
 	"github.com/laszukdawid/terminal-agent/internal/tools"
 )
@@ -45,6 +46,8 @@ const (
 	// routineDaemonOffNotice is shown when scheduled routines exist but the
 	// scheduler daemon is not running, so the user knows why they will not fire.
 	routineDaemonOffNotice = "Scheduler is not running, so scheduled routines won't fire. Start it with `agent daemon install` (once) or `agent daemon start`."
+	// routineDaemonPausedNotice is shown while the daemon's run queue is paused.
+	routineDaemonPausedNotice = "Scheduler is paused: fired runs are queued but none start. Use RESUME to continue."
 
 	// Preferred widths for the routine create/edit form and the routine defaults
 	// form. The scroll width is the inner field column; the dialog width is the
@@ -77,13 +80,15 @@ const (
 // routineLocalToolNames are the built-in, non-external tools enabled when a
 // routine opts into web search via the GUI form (the explicit allow-list then
 // also names web search). With no allow-list a routine uses the default policy
-// (these local tools on, external off).
+// (these local tools on, external and remember off).
 var routineLocalToolNames = []string{
 	tools.ToolNameRead,
 	tools.ToolNameFileSearch,
 	tools.ToolNameFileEdit,
 	tools.ToolNameUnix,
 	tools.ToolNamePython,
+	tools.ToolNameRecall,
+	tools.ToolNameSemanticSearch,
 }
 
 func (g *App) markRoutineRunning(id string, running bool) {
@@ -107,27 +112,96 @@ func (g *App) isRoutineRunning(id string) bool {
 
 func (g *App) loadRoutines() {
 	g.lastRoutineMod = latestRoutineMod()
-	daemonRunning := g.routineService.DaemonRunning()
-	g.lastDaemonRunning = daemonRunning
+	scheduler := g.routineDaemonSnapshot()
+	g.lastDaemonState = scheduler.key()
+	g.popup.setRoutinePaused(scheduler.live, scheduler.status.Paused)
 	views, err := g.routineService.List(context.Background())
 	if err != nil {
 		g.popup.setRoutines(nil, "Routines unavailable: "+err.Error(), nil, "")
 		return
 	}
-	// Overlay the live "running" status for routines launched from the GUI.
+	// Overlay the live "running" status for routines launched from the GUI and
+	// the runs the daemon reports in progress.
 	for i := range views {
-		if g.isRoutineRunning(views[i].Routine.ID) {
+		if id := views[i].Routine.ID; g.isRoutineRunning(id) || scheduler.runningRoutine(id) {
 			views[i].Status = statusRunning
 		}
 	}
 	// Warn when routines are scheduled but no scheduler is running to fire them.
 	notice := ""
-	if routinesHaveSchedule(views) && !daemonRunning {
+	switch {
+	case scheduler.status.Paused:
+		notice = routineDaemonPausedNotice
+	case routinesHaveSchedule(views) && !scheduler.running:
 		notice = routineDaemonOffNotice
 	}
 	g.popup.setRoutines(views, "", g.showRoutineDetail, notice)
 }
 
+// routineDaemonState is what the Routine tab shows of the scheduler daemon.
+type routineDaemonState struct {
+	status daemon.ControlStatus
+	// live is set when the daemon answered its control API; running also
+	// covers a daemon that holds the lock without answering.
+	live, running bool
+}
+
+func (g *App) routineDaemonSnapshot() routineDaemonState {
+	status, err := g.routineService.DaemonStatus(context.Background())
+	if err == nil {
+		return routineDaemonState{status: status, live: true, running: true}
+	}
+	return routineDaemonState{running: g.routineService.DaemonRunning()}
+}
+
+func (s routineDaemonState) runningRoutine(id string) bool {
+	for _, run := range s.status.Runs {
+		if run.RoutineID == id && run.Running() {
+			return true
+		}
+	}
+	return false
+}
+
+// key summarises the state the list depends on, so a refresh can tell whether
+// it changed.
+func (s routineDaemonState) key() string {
+	key := fmt.Sprintf("%t/%t/%t", s.live, s.running, s.status.Paused)
+	for _, run := range s.status.Runs {
+		key += fmt.Sprintf("/%s:%t", run.RoutineID, run.Running())
+	}
+	return key
+}
+
+// toggleRoutinePause pauses or resumes the daemon's run queue.
+func (g *App) toggleRoutinePause() {
+	status, err := g.routineService.DaemonStatus(context.Background())
+	if err == nil {
+		err = g.routineService.PauseDaemon(context.Background(), !status.Paused)
+	}
+	if err != nil {
+		dialog.ShowError(err, g.popup.window)
+	}
+	g.loadRoutines()
+}
+
+// setRoutinePaused shows the PAUSE/RESUME button while a daemon answers.
+func (p *popupWindow) setRoutinePaused(live, paused bool) {
+	if p.routinePause == nil {
+		return
+	}
+	if !live {
+		p.routinePause.Hide()
+		return
+	}
+	if paused {
+		p.routinePause.SetText("RESUME")
+	} else {
+		p.routinePause.SetText("PAUSE")
+	}
+	p.routinePause.Show()
+}
+
 // maybeRefreshRoutines reloads the Routine list when it is the visible tab and
 // something changed since the last load — either the routine files (a run was
 // recorded or a routine edited) or the daemon's running state (so the
@@ -137,7 +211,7 @@ func (g *App) maybeRefreshRoutines() {
 	if !g.state.isVisible || g.state.mode != guiModeRoutine {
 		return
 	}
-	if latestRoutineMod().Equal(g.lastRoutineMod) && g.routineService.DaemonRunning() == g.lastDaemonRunning {
+	if latestRoutineMod().Equal(g.lastRoutineMod) && g.routineDaemonSnapshot().key() == g.lastDaemonState {
 		return
 	}
 	g.loadRoutines()
@@ -266,10 +340,16 @@ func (g *App) showRoutineDetail(view appservice.RoutineView) {
 	if r.Tools != nil {
 		config = append(config, "tools: "+routineToolPolicyText(r.Tools))
 	}
+	if r.Retry.Attempts() > 1 {
+		config = append(config, fmt.Sprintf("%d attempts", r.Retry.Attempts()))
+	}
 	lastRun := "Last: " + routineTimeText(view.Run.LastRunAt)
 	if view.HasRun {
 		lastRun += " (" + view.Run.LastStatus + ")"
 	}
+	if n := len(view.Run.Attempts); n > 1 {
+		lastRun += fmt.Sprintf(" after %d attempts", n)
+	}
 	metaLabel := widget.NewLabel(strings.Join(config, metaSeparator) + "\n" + lastRun + metaSeparator + "Next: " + routineTimeText(view.Run.NextRunAt))
 	metaLabel.Wrapping = fyne.TextWrapWord
 
@@ -280,18 +360,52 @@ func (g *App) showRoutineDetail(view appservice.RoutineView) {
 		errLabel.Wrapping = fyne.TextWrapWord
 		objects = append(objects, errLabel)
 	}
+	if len(view.Run.Attempts) > 1 {
+		lines := make([]string, 0, len(view.Run.Attempts))
+		for _, attempt := range view.Run.Attempts {
+			line := fmt.Sprintf("#%d %s at %s", attempt.Number, attempt.Outcome, routineTimeText(attempt.StartedAt))
+			if !attempt.NextRetryAt.IsZero() {
+				line += " · retry at " + routineTimeText(attempt.NextRetryAt)
+			}
+			lines = append(lines, line)
+		}
+		attemptsLabel := widget.NewLabel("Attempts:\n" + strings.Join(lines, "\n"))
+		attemptsLabel.Wrapping = fyne.TextWrapWord
+		objects = append(objects, attemptsLabel)
+	}
 
 	// The prompt is set apart in its own framed, padded box so it reads as the
 	// routine's content rather than more metadata; the label above is clearly a
 	// heading, not the first line of the prompt.
-	promptBody := widget.NewLabel(r.Prompt)
+	promptText, promptHeading := r.Prompt, "PROMPT"
+	if r.IsWorkflow() {
+		steps := make([]string, 0, len(r.Steps))
+		for _, step := range r.Steps {
+			steps = append(steps, step.Describe())
+		}
+		promptText, promptHeading = strings.Join(steps, "\n"), "STEPS"
+	}
+	promptBody := widget.NewLabel(promptText)
 	promptBody.Wrapping = fyne.TextWrapWord
 	promptBox := borderedBox(container.NewPadded(promptBody), palette.borderBright)
 
 	objects = append(objects,
 		brandSeparator(),
-		brandSectionLabel("PROMPT"),
+		brandSectionLabel(promptHeading),
 		promptBox,
+	)
+	// The change between the last two successful runs, for monitor-style
+	// routines; routines without two such runs simply have no section.
+	if diff, err := g.routineService.Diff(context.Background(), r.ID, "", ""); err == nil && diff.Diff != "" {
+		diffBody := widget.NewLabelWithStyle(diff.Diff, fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+		diffBody.Wrapping = fyne.TextWrapWord
+		objects = append(objects,
+			brandSeparator(),
+			brandSectionLabel("LATEST CHANGES"),
+			borderedBox(container.NewPadded(diffBody), palette.borderBright),
+		)
+	}
+	objects = append(objects,
 		brandSeparator(),
 		brandSectionLabel("RUN LOGS"),
 	)
@@ -328,7 +442,7 @@ func (g *App) routineLogList(id string) []fyne.CanvasObject {
 // of the detail header.
 func (g *App) routineHeaderActions(view appservice.RoutineView) fyne.CanvasObject {
 	r := view.Routine
-	runButton := widget.NewButton("Run now", func() { g.runRoutineNow(r.ID) })
+	runButton := widget.NewButton("Run now", func() { g.runRoutineWithParams(r) })
 	runButton.Importance = widget.HighImportance
 	if g.isRoutineRunning(r.ID) {
 		runButton.SetText("Running…")
@@ -521,8 +635,62 @@ func routineLogText(data string) string {
 	return strings.Join(pretty, "\n\n")
 }
 
-func (g *App) runRoutineNow(id string) {
+// runRoutineWithParams runs a routine now, first asking for the values of its
+// declared parameters (prefilled with their defaults) when it has any.
+func (g *App) runRoutineWithParams(r routines.Routine) {
+	if len(r.Params) == 0 {
+		g.runRoutineNow(r.ID, nil)
+		return
+	}
+	win := g.popup.window
+	var dlg dialog.Dialog
+	form := container.New(layout.NewFormLayout())
+	entries := make(map[string]*settingsTextEntry, len(r.Params))
+	for _, p := range r.Params {
+		entry := newSettingsTextEntry(p.Default)
+		entry.SetPlaceHolder(p.Description)
+		entries[p.Name] = entry
+		form.Add(formFieldLabel(p.Name))
+		form.Add(themedFormField(entry))
+	}
+
+	run := widget.NewButton("Run", func() {
+		params := make(map[string]string, len(entries))
+		for name, entry := range entries {
+			params[name] = entry.Text
+		}
+		dlg.Hide()
+		g.runRoutineNow(r.ID, params)
+	})
+	run.Importance = widget.HighImportance
+	cancel := widget.NewButton("Cancel", func() { dlg.Hide() })
+	footer := container.NewBorder(nil, nil, nil, container.NewHBox(cancel, run))
+
+	scroll := container.NewVScroll(form)
+	dlg = dialog.NewCustomWithoutButtons("Run "+r.ID, container.NewBorder(nil, footer, nil, nil, scroll), win)
+	fitRoutineFormDialog(dlg, win, scroll, form, footer, routineDefaultsScrollWidth, routineDefaultsDialogWidth)
+	dlg.Show()
+	fitRoutineFormDialog(dlg, win, scroll, form, footer, routineDefaultsScrollWidth, routineDefaultsDialogWidth)
+}
+
+func (g *App) runRoutineNow(id string, params map[string]string) {
 	g.popup.dismissRoutineDetail()
+	// A running daemon queues the run, so it counts against the concurrency caps
+	// like any other; the list shows it running from the daemon's status.
+	if g.routineDaemonSnapshot().live {
+		_, err := g.routineService.Enqueue(context.Background(), appservice.RoutineRunRequest{
+			IDOrName: id,
+			Trigger:  routines.TriggerManual,
+			Params:   params,
+		})
+		if err != nil {
+			dialog.ShowError(err, g.popup.window)
+		}
+		if g.state.mode == guiModeRoutine {
+			g.loadRoutines()
+		}
+		return
+	}
 	// Mark the routine running and refresh the list so its status reads "running"
 	// until the (background) run finishes.
 	g.markRoutineRunning(id, true)
@@ -533,6 +701,7 @@ func (g *App) runRoutineNow(id string) {
 		_, err := g.routineService.Run(context.Background(), appservice.RoutineRunRequest{
 			IDOrName: id,
 			Trigger:  routines.TriggerManual,
+			Params:   params,
 		})
 		g.voiceSchedule(func() {
 			g.markRoutineRunning(id, false)
@@ -49,6 +49,10 @@ func (s *recordingService) Chat(context.Context, appservice.ChatRequest) (appser
 	return appservice.ChatResult{}, nil
 }
 
+func (s *recordingService) CompactChat(context.Context, appservice.ChatRequest) (appservice.ChatCompactResult, error) {
+	return appservice.ChatCompactResult{}, nil
+}
+
 func (s *recordingService) ChatEvents(context.Context, appservice.ChatRequest) (<-chan appservice.Event, error) {
 	return closedEvents(), nil
 }
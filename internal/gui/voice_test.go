@@ -302,6 +302,10 @@ func (s *voiceTestService) Chat(context.Context, appservice.ChatRequest) (appser
 	return appservice.ChatResult{}, nil
 }
 
+func (s *voiceTestService) CompactChat(context.Context, appservice.ChatRequest) (appservice.ChatCompactResult, error) {
+	return appservice.ChatCompactResult{}, nil
+}
+
 func (s *voiceTestService) ChatEvents(context.Context, appservice.ChatRequest) (<-chan appservice.Event, error) {
 	return nil, nil
 }
@@ -7,6 +7,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/laszukdawid/terminal-agent/internal/redact"
 	"github.com/laszukdawid/terminal-agent/internal/utils"
 )
 
@@ -19,13 +20,20 @@ type History interface {
 }
 
 type history struct {
-	path string
+	path     string
+	redactor *redact.Redactor
 }
 
 func NewHistory(path string) History {
 	return &history{path: path}
 }
 
+// NewHistoryWithRedactor returns a History that masks secrets in logged
+// queries and answers. A nil redactor logs them unchanged.
+func NewHistoryWithRedactor(path string, redactor *redact.Redactor) History {
+	return &history{path: path, redactor: redactor}
+}
+
 type HistoryQuery struct {
 	AfterStr  string
 	BeforeStr string
@@ -101,8 +109,8 @@ func readAllLogs(filePath string) ([]HistoryLog, error) {
 func (h *history) Log(method, query, answer string) error {
 	l := HistoryLog{
 		Method:    method,
-		Query:     query,
-		Answer:    answer,
+		Query:     h.redactor.String(query),
+		Answer:    h.redactor.String(answer),
 		Timestamp: time.Now().Format(time.RFC3339),
 	}
 
@@ -0,0 +1,517 @@
+package history
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/fts"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Run sources, telling where a run in the store was read from.
+const (
+	SourceSession = "session"
+	SourceChat    = "chat"
+	SourceLog     = "log"
+)
+
+// Run statuses.
+const (
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+	StatusIncomplete = "incomplete"
+)
+
+// ErrRunNotFound is returned for a run id that matches no run.
+var ErrRunNotFound = errors.New("run not found in history")
+
+// Run is one ask, chat, task or routine run, with its transcript.
+type Run struct {
+	ID string
+	// Kind is ask, chat, task or routine.
+	Kind      string
+	Source    string
+	Status    string
+	Provider  string
+	Model     string
+	Cwd       string
+	RoutineID string
+	// Request is the first user message, Response the final answer and Error
+	// the reason a failed run stopped.
+	Request     string
+	Response    string
+	Error       string
+	CreatedAt   time.Time
+	CompletedAt time.Time
+	// Path is the file the run was read from.
+	Path    string
+	Entries []Entry
+}
+
+// ShortID is the id shown in listings: session run ids, which are UUIDs, are
+// cut to their first 8 characters. Get accepts it as a prefix.
+func (r Run) ShortID() string {
+	if r.Source == SourceSession && len(r.ID) > 8 {
+		return r.ID[:8]
+	}
+	return r.ID
+}
+
+// Entry is one step of a run's transcript.
+type Entry struct {
+	// Type is user or assistant for messages, and otherwise the session log
+	// record type: thought, tool_call, tool_result, error and so on.
+	Type string
+	Tool string
+	Text string
+	Time time.Time
+}
+
+// SearchQuery selects runs. Empty fields match every run.
+type SearchQuery struct {
+	// Text is matched word by word against the whole transcript.
+	Text     string
+	Kind     string
+	Provider string
+	Status   string
+	Since    time.Time
+	Until    time.Time
+	// Limit defaults to 20.
+	Limit int
+}
+
+// SearchResult is a matching run, without its entries, and a snippet of the
+// transcript around the match when Text was given.
+type SearchResult struct {
+	Run
+	Snippet string
+}
+
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS meta (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sources (
+    key TEXT PRIMARY KEY,
+    state TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS runs (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    id TEXT NOT NULL UNIQUE,
+    source_key TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    source TEXT NOT NULL,
+    status TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    cwd TEXT NOT NULL,
+    routine_id TEXT NOT NULL,
+    request TEXT NOT NULL,
+    response TEXT NOT NULL,
+    error TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    completed_at INTEGER NOT NULL,
+    path TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS runs_source_key ON runs(source_key);
+CREATE INDEX IF NOT EXISTS runs_created_at ON runs(created_at);
+
+CREATE TABLE IF NOT EXISTS entries (
+    run_seq INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    tool TEXT NOT NULL,
+    text TEXT NOT NULL,
+    time INTEGER NOT NULL,
+    PRIMARY KEY (run_seq, position)
+);
+`
+
+// Store is the unified history: every run read from the interaction log, the
+// session logs and the chat database, indexed for full-text search. It is a
+// cache of those sources, brought up to date with ReplaceSource.
+type Store struct {
+	db *sql.DB
+	// fts is the full-text module of the search index, or "" when the index
+	// is a plain table searched with LIKE; index is the index's table.
+	fts   string
+	index string
+}
+
+// OpenStore opens the history database at path, creating it if needed.
+func OpenStore(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	store := &Store{db: db}
+	if err := store.setup(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *Store) setup() error {
+	if _, err := s.db.Exec(storeSchema); err != nil {
+		return fmt.Errorf("failed to create history schema: %w", err)
+	}
+	module, err := fts.Best(s.db)
+	if err != nil {
+		return err
+	}
+	s.fts, s.index = module, fts.Table("runs", module)
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (body TEXT NOT NULL)", s.index)
+	if s.fts != "" {
+		create = fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING %s(body)", s.index, s.fts)
+	}
+	if _, err := s.db.Exec(create); err != nil {
+		return fmt.Errorf("failed to create history search index: %w", err)
+	}
+
+	// Each module has its own index (see package fts); meta names the one kept
+	// up to date. When a build with another module kept its own instead, the
+	// cache is emptied so every run is read again into this build's index. The
+	// other index is left alone.
+	var current string
+	err = s.db.QueryRow("SELECT value FROM meta WHERE key = 'fts'").Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if current == s.index {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		"DELETE FROM " + s.index,
+		"DELETE FROM entries",
+		"DELETE FROM runs",
+		"DELETE FROM sources",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO meta(key, value) VALUES ('fts', ?)", s.index); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// SourceStates returns the state recorded for every source by ReplaceSource.
+func (s *Store) SourceStates() (map[string]string, error) {
+	rows, err := s.db.Query("SELECT key, state FROM sources")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history sources: %w", err)
+	}
+	defer rows.Close()
+	states := map[string]string{}
+	for rows.Next() {
+		var key, state string
+		if err := rows.Scan(&key, &state); err != nil {
+			return nil, err
+		}
+		states[key] = state
+	}
+	return states, rows.Err()
+}
+
+// ReplaceSource replaces the runs read from a source, such as a session log
+// file, and records its state, e.g. the file's size and modification time, to
+// tell later whether it changed.
+func (s *Store) ReplaceSource(key, state string, runs []Run) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.deleteSourceRuns(tx, key); err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if err := s.insertRun(tx, key, run); err != nil {
+			return fmt.Errorf("failed to store run %s: %w", run.ID, err)
+		}
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO sources(key, state) VALUES (?, ?)", key, state); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveSource drops a source that no longer exists and its runs.
+func (s *Store) RemoveSource(key string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.deleteSourceRuns(tx, key); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sources WHERE key = ?", key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) deleteSourceRuns(tx *sql.Tx, key string) error {
+	for _, stmt := range []string{
+		"DELETE FROM " + s.index + " WHERE rowid IN (SELECT seq FROM runs WHERE source_key = ?)",
+		"DELETE FROM entries WHERE run_seq IN (SELECT seq FROM runs WHERE source_key = ?)",
+		"DELETE FROM runs WHERE source_key = ?",
+	} {
+		if _, err := tx.Exec(stmt, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) insertRun(tx *sql.Tx, key string, run Run) error {
+	// A run id seen in another source, e.g. a session log copied elsewhere,
+	// keeps the copy read last.
+	var seq int64
+	err := tx.QueryRow("SELECT seq FROM runs WHERE id = ?", run.ID).Scan(&seq)
+	if err == nil {
+		for _, stmt := range []string{
+			"DELETE FROM " + s.index + " WHERE rowid = ?",
+			"DELETE FROM entries WHERE run_seq = ?",
+			"DELETE FROM runs WHERE seq = ?",
+		} {
+			if _, err := tx.Exec(stmt, seq); err != nil {
+				return err
+			}
+		}
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	result, err := tx.Exec(`INSERT INTO runs(id, source_key, kind, source, status, provider, model, cwd,
+		routine_id, request, response, error, created_at, completed_at, path)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, key, run.Kind, run.Source, run.Status, run.Provider, run.Model, run.Cwd,
+		run.RoutineID, run.Request, run.Response, run.Error,
+		unixNano(run.CreatedAt), unixNano(run.CompletedAt), run.Path)
+	if err != nil {
+		return err
+	}
+	if seq, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	body := []string{run.Request, run.Response, run.Error}
+	for i, entry := range run.Entries {
+		if _, err := tx.Exec("INSERT INTO entries(run_seq, position, type, tool, text, time) VALUES (?, ?, ?, ?, ?, ?)",
+			seq, i, entry.Type, entry.Tool, entry.Text, unixNano(entry.Time)); err != nil {
+			return err
+		}
+		body = append(body, entry.Tool, entry.Text)
+	}
+	_, err = tx.Exec("INSERT INTO "+s.index+"(rowid, body) VALUES (?, ?)", seq, strings.Join(nonEmpty(body), "\n"))
+	return err
+}
+
+func nonEmpty(values []string) []string {
+	kept := values[:0]
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+const runColumns = `r.seq, r.id, r.kind, r.source, r.status, r.provider, r.model, r.cwd, r.routine_id,
+	r.request, r.response, r.error, r.created_at, r.completed_at, r.path`
+
+func scanRun(row interface{ Scan(...any) error }, extra ...any) (int64, Run, error) {
+	var (
+		seq                  int64
+		run                  Run
+		createdAt, completed int64
+	)
+	dest := []any{&seq, &run.ID, &run.Kind, &run.Source, &run.Status, &run.Provider, &run.Model, &run.Cwd,
+		&run.RoutineID, &run.Request, &run.Response, &run.Error, &createdAt, &completed, &run.Path}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return 0, Run{}, err
+	}
+	run.CreatedAt, run.CompletedAt = fromUnixNano(createdAt), fromUnixNano(completed)
+	return seq, run, nil
+}
+
+// Get returns a run with its transcript. id is a run id or a prefix matching a
+// single one.
+func (s *Store) Get(id string) (Run, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return Run{}, ErrRunNotFound
+	}
+	seq, run, err := scanRun(s.db.QueryRow("SELECT "+runColumns+" FROM runs r WHERE r.id = ?", id))
+	if err == sql.ErrNoRows {
+		seq, run, err = s.getByPrefix(id)
+	}
+	if err != nil {
+		return Run{}, err
+	}
+
+	rows, err := s.db.Query("SELECT type, tool, text, time FROM entries WHERE run_seq = ? ORDER BY position", seq)
+	if err != nil {
+		return Run{}, fmt.Errorf("failed to read run transcript: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry Entry
+		var at int64
+		if err := rows.Scan(&entry.Type, &entry.Tool, &entry.Text, &at); err != nil {
+			return Run{}, err
+		}
+		entry.Time = fromUnixNano(at)
+		run.Entries = append(run.Entries, entry)
+	}
+	return run, rows.Err()
+}
+
+func (s *Store) getByPrefix(prefix string) (int64, Run, error) {
+	rows, err := s.db.Query("SELECT "+runColumns+" FROM runs r WHERE r.id LIKE ? ESCAPE '\\' LIMIT 2", escapeLike(prefix)+"%")
+	if err != nil {
+		return 0, Run{}, err
+	}
+	defer rows.Close()
+	var (
+		seq   int64
+		run   Run
+		found int
+	)
+	for rows.Next() {
+		if seq, run, err = scanRun(rows); err != nil {
+			return 0, Run{}, err
+		}
+		found++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, Run{}, err
+	}
+	switch found {
+	case 0:
+		return 0, Run{}, fmt.Errorf("%w: %s", ErrRunNotFound, prefix)
+	case 1:
+		return seq, run, nil
+	}
+	return 0, Run{}, fmt.Errorf("run id %q is ambiguous; give more of it", prefix)
+}
+
+// Search returns the runs matching q, newest first. Words of q.Text are
+// matched as terms, not as query syntax.
+func (s *Store) Search(q SearchQuery) ([]SearchResult, error) {
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	var (
+		conditions []string
+		args       []any
+	)
+	snippet := "''"
+	from := "runs r"
+	if words := strings.Fields(q.Text); len(words) > 0 {
+		from = fmt.Sprintf("runs r JOIN %[1]s ON %[1]s.rowid = r.seq", s.index)
+		switch s.fts {
+		case "fts5", "fts4":
+			quoted := make([]string, len(words))
+			for i, word := range words {
+				quoted[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+			}
+			snippet = fmt.Sprintf("snippet(%s, 0, '[', ']', '…', 12)", s.index)
+			if s.fts == "fts4" {
+				snippet = fmt.Sprintf("snippet(%s, '[', ']', '…', 0, 12)", s.index)
+			}
+			conditions = append(conditions, s.index+" MATCH ?")
+			args = append(args, strings.Join(quoted, " "))
+		default:
+			for _, word := range words {
+				conditions = append(conditions, s.index+".body LIKE ? ESCAPE '\\'")
+				args = append(args, "%"+escapeLike(word)+"%")
+			}
+		}
+	}
+	for _, filter := range []struct{ column, value string }{
+		{"r.kind", q.Kind}, {"r.provider", q.Provider}, {"r.status", q.Status},
+	} {
+		if filter.value != "" {
+			conditions = append(conditions, filter.column+" = ? COLLATE NOCASE")
+			args = append(args, filter.value)
+		}
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "r.created_at >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		conditions = append(conditions, "r.created_at <= ?")
+		args = append(args, q.Until.UnixNano())
+	}
+
+	query := fmt.Sprintf("SELECT %s, %s FROM %s", runColumns, snippet, from)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY r.created_at DESC, r.seq DESC LIMIT ?"
+	args = append(args, q.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search history: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		if _, result.Run, err = scanRun(rows, &result.Snippet); err != nil {
+			return nil, fmt.Errorf("failed to scan history run: %w", err)
+		}
+		result.Snippet = strings.Join(strings.Fields(result.Snippet), " ")
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
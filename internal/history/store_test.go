@@ -0,0 +1,141 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := OpenStore(filepath.Join(t.TempDir(), "history.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStoreSearchFiltersAndMatchesTranscript(t *testing.T) {
+	store := openTestStore(t)
+	start := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, store.ReplaceSource("a.jsonl", "1", []Run{{
+		ID: "0a1b2c3d-task", Kind: "task", Source: SourceSession, Status: StatusFailed,
+		Provider: "bedrock", Request: "start the stack", Error: "exit status 1",
+		CreatedAt: start,
+		Entries: []Entry{
+			{Type: "user", Text: "start the stack"},
+			{Type: "tool_call", Tool: "unix", Text: `{"command":"docker compose up"}`},
+		},
+	}}))
+	require.NoError(t, store.ReplaceSource("b.jsonl", "1", []Run{{
+		ID: "9f8e7d6c-ask", Kind: "ask", Source: SourceSession, Status: StatusCompleted,
+		Provider: "openai", Request: "what is docker compose?", Response: "A tool.",
+		CreatedAt: start.Add(time.Hour),
+	}}))
+
+	results, err := store.Search(SearchQuery{Text: "docker compose"})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "9f8e7d6c-ask", results[0].ID)
+	assert.Equal(t, "0a1b2c3d-task", results[1].ID)
+	assert.Contains(t, results[1].Snippet, "[docker]")
+
+	results, err = store.Search(SearchQuery{Text: "docker", Kind: "task", Provider: "Bedrock", Status: StatusFailed, Since: start.Add(-time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "0a1b2c3d-task", results[0].ID)
+
+	results, err = store.Search(SearchQuery{Since: start.Add(time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "ask", results[0].Kind)
+
+	results, err = store.Search(SearchQuery{Text: "kubernetes"})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestStoreGetByPrefixAndReplaceSource(t *testing.T) {
+	store := openTestStore(t)
+	created := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	runs := []Run{
+		{ID: "abc123-1", Kind: "task", Source: SourceSession, Request: "one", CreatedAt: created,
+			Entries: []Entry{{Type: "user", Text: "one", Time: created}, {Type: "assistant", Text: "done"}}},
+		{ID: "abc456-2", Kind: "task", Source: SourceSession, Request: "two", CreatedAt: created},
+	}
+	require.NoError(t, store.ReplaceSource("src", "v1", runs))
+
+	run, err := store.Get("abc1")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123-1", run.ID)
+	assert.Equal(t, created, run.CreatedAt.UTC())
+	require.Len(t, run.Entries, 2)
+	assert.Equal(t, Entry{Type: "assistant", Text: "done"}, run.Entries[1])
+
+	_, err = store.Get("abc")
+	assert.ErrorContains(t, err, "ambiguous")
+	_, err = store.Get("zzz")
+	assert.ErrorIs(t, err, ErrRunNotFound)
+
+	require.NoError(t, store.ReplaceSource("src", "v2", runs[1:]))
+	_, err = store.Get("abc1")
+	assert.ErrorIs(t, err, ErrRunNotFound)
+	states, err := store.SourceStates()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"src": "v2"}, states)
+
+	results, err := store.Search(SearchQuery{Text: "one"})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	require.NoError(t, store.RemoveSource("src"))
+	results, err = store.Search(SearchQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	states, err = store.SourceStates()
+	require.NoError(t, err)
+	assert.Empty(t, states)
+}
+
+func TestOpenStoreReopensExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := OpenStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.ReplaceSource("src", "v1", []Run{{ID: "run-1", Kind: "ask", Request: "hello"}}))
+	require.NoError(t, store.Close())
+
+	store, err = OpenStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	run, err := store.Get("run-1")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", run.Request)
+}
+
+func TestOpenStoreEmptiesCacheIndexedByAnotherModule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store, err := OpenStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.ReplaceSource("src", "v1", []Run{{ID: "run-1", Kind: "ask", Request: "hello"}}))
+	// A build with another full-text module kept its own index up to date.
+	_, err = store.db.Exec("UPDATE meta SET value = 'runs_other' WHERE key = 'fts'")
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	store, err = OpenStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	states, err := store.SourceStates()
+	require.NoError(t, err)
+	assert.Empty(t, states, "every source is read again")
+	_, err = store.Get("run-1")
+	assert.ErrorIs(t, err, ErrRunNotFound)
+
+	require.NoError(t, store.ReplaceSource("src", "v1", []Run{{ID: "run-1", Kind: "ask", Request: "hello again"}}))
+	results, err := store.Search(SearchQuery{Text: "again"})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+}
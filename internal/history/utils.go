@@ -2,6 +2,8 @@ package history
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -52,3 +54,22 @@ func strToTime(input string) (*time.Time, error) {
 
 	return &t, nil
 }
+
+// ParseSince turns a --since value into a time: a duration back from now,
+// such as 7d, 12h or 30m, or a date in one of the formats --after accepts.
+func ParseSince(input string, now time.Time) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	if days, ok := strings.CutSuffix(input, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(input); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	t, err := strToTime(input)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q: use a duration like 7d or 12h, or a date like 2006-01-02", input)
+	}
+	return *t, nil
+}
@@ -77,3 +77,22 @@ func TestStrToTime(t *testing.T) {
 	}
 
 }
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	since, err := ParseSince("7d", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, -7), since)
+
+	since, err = ParseSince("90m", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-90*time.Minute), since)
+
+	since, err = ParseSince("2026-06-01", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), since)
+
+	_, err = ParseSince("last week", now)
+	assert.Error(t, err)
+}
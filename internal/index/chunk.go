@@ -0,0 +1,164 @@
+package index
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxFileSize skips files too large to be source or docs, such as
+	// generated bundles and data dumps.
+	maxFileSize = 512 * 1024
+	// chunkLines and chunkOverlap size the windows a file is split into; the
+	// overlap keeps a function that straddles a boundary findable.
+	chunkLines   = 40
+	chunkOverlap = 8
+	// maxChunkBytes bounds a chunk of very long lines, to stay within the
+	// embedding models' input limits.
+	maxChunkBytes = 4000
+)
+
+// Chunk is a window of lines from an indexed file.
+type Chunk struct {
+	// Path is slash-separated and relative to the index root.
+	Path      string
+	StartLine int
+	EndLine   int
+	Content   string
+}
+
+// embeddingText is what is embedded for a chunk: naming the file helps
+// queries that mention it.
+func (c Chunk) embeddingText() string {
+	return "File: " + c.Path + "\n\n" + c.Content
+}
+
+// sourceFile is a file the index covers, with the hash of its content.
+type sourceFile struct {
+	path string
+	hash string
+}
+
+// walkFiles lists the indexable files under root: text files no larger than
+// maxFileSize that .gitignore does not exclude. .git is always skipped.
+func walkFiles(root string) ([]sourceFile, error) {
+	var files []sourceFile
+	err := walkTree(root, nil, func(rel, path string, entry fs.DirEntry) {
+		info, err := entry.Info()
+		if err != nil || info.Size() == 0 || info.Size() > maxFileSize {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || !isText(data) {
+			return
+		}
+		sum := sha256.Sum256(data)
+		files = append(files, sourceFile{path: rel, hash: hex.EncodeToString(sum[:])})
+	})
+	return files, err
+}
+
+// Dirs lists root and the directories below it that the index covers, for
+// watching them for changes.
+func Dirs(root string) ([]string, error) {
+	var dirs []string
+	err := walkTree(root, func(path string) { dirs = append(dirs, path) }, nil)
+	return dirs, err
+}
+
+// walkTree visits the directories and regular files under root that
+// .gitignore does not exclude, skipping .git. rel is slash-separated and
+// relative to root.
+func walkTree(root string, onDir func(path string), onFile func(rel, path string, entry fs.DirEntry)) error {
+	var matcher ignoreMatcher
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if rel != "." && (entry.Name() == ".git" || matcher.ignored(rel, true)) {
+				return filepath.SkipDir
+			}
+			if data, err := os.ReadFile(filepath.Join(path, ".gitignore")); err == nil {
+				base := rel
+				if base == "." {
+					base = ""
+				}
+				matcher.add(base, data)
+			}
+			if onDir != nil {
+				onDir(path)
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() && !matcher.ignored(rel, false) && onFile != nil {
+			onFile(rel, path, entry)
+		}
+		return nil
+	})
+}
+
+// isText reports whether data looks like text: valid UTF-8 without NUL bytes
+// in its first 8000 bytes, as git checks.
+func isText(data []byte) bool {
+	head := data[:min(len(data), 8000)]
+	return bytes.IndexByte(head, 0) < 0 && utf8.Valid(head[:lastRuneBoundary(head)])
+}
+
+// lastRuneBoundary trims a rune cut off at the end of a truncated buffer.
+func lastRuneBoundary(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if utf8.FullRune(data[i:]) {
+				return len(data)
+			}
+			return i
+		}
+	}
+	return len(data)
+}
+
+// chunkFile splits a file into overlapping windows of lines. Blank windows
+// are dropped.
+func chunkFile(path, content string) []Chunk {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	var chunks []Chunk
+	for start := 0; start < len(lines); {
+		end := min(start+chunkLines, len(lines))
+		size := 0
+		for i := start; i < end; i++ {
+			size += len(lines[i]) + 1
+			if size > maxChunkBytes && i > start {
+				end = i
+				break
+			}
+		}
+		text := strings.Join(lines[start:end], "\n")
+		if len(text) > maxChunkBytes {
+			text = strings.ToValidUTF8(text[:maxChunkBytes], "")
+		}
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, Chunk{Path: path, StartLine: start + 1, EndLine: end, Content: text})
+		}
+		if end == len(lines) {
+			break
+		}
+		start = max(end-chunkOverlap, start+1)
+	}
+	return chunks
+}
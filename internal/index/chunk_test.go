@@ -0,0 +1,86 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkFile(t *testing.T) {
+	t.Run("ShortFile", func(t *testing.T) {
+		chunks := chunkFile("a.go", "package a\n\nfunc A() {}\n")
+		require.Len(t, chunks, 1)
+		assert.Equal(t, Chunk{Path: "a.go", StartLine: 1, EndLine: 3, Content: "package a\n\nfunc A() {}"}, chunks[0])
+	})
+
+	t.Run("OverlappingWindows", func(t *testing.T) {
+		lines := make([]string, 100)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("line %d", i+1)
+		}
+		chunks := chunkFile("b.txt", strings.Join(lines, "\n"))
+		require.Len(t, chunks, 3)
+		assert.Equal(t, [2]int{1, 40}, [2]int{chunks[0].StartLine, chunks[0].EndLine})
+		assert.Equal(t, [2]int{33, 72}, [2]int{chunks[1].StartLine, chunks[1].EndLine})
+		assert.Equal(t, [2]int{65, 100}, [2]int{chunks[2].StartLine, chunks[2].EndLine})
+		assert.True(t, strings.HasPrefix(chunks[1].Content, "line 33\n"))
+	})
+
+	t.Run("LongLinesAreBounded", func(t *testing.T) {
+		line := strings.Repeat("x", 3000)
+		chunks := chunkFile("c.txt", line+"\n"+line+"\n"+line)
+		require.Len(t, chunks, 3)
+		for _, chunk := range chunks {
+			assert.LessOrEqual(t, len(chunk.Content), maxChunkBytes)
+		}
+	})
+
+	t.Run("BlankFile", func(t *testing.T) {
+		assert.Empty(t, chunkFile("d.txt", "\n\n  \n"))
+	})
+}
+
+func TestWalkFiles(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write(".gitignore", "*.log\nnode_modules/\n")
+	write("main.go", "package main\n")
+	write("debug.log", "noise\n")
+	write("node_modules/x/index.js", "module.exports = 1\n")
+	write(".git/config", "[core]\n")
+	write("pkg/.gitignore", "gen.go\n")
+	write("pkg/gen.go", "package pkg\n")
+	write("pkg/lib.go", "package pkg\n")
+	write("image.png", "\x89PNG\x00\x00")
+	write("empty.txt", "")
+
+	files, err := walkFiles(root)
+	require.NoError(t, err)
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.path)
+		assert.Len(t, file.hash, 64)
+	}
+	assert.Equal(t, []string{".gitignore", "main.go", "pkg/.gitignore", "pkg/lib.go"}, paths)
+}
+
+func TestDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{".git/objects", "node_modules/x", "pkg/sub"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".gitignore"), []byte("node_modules/\n"), 0644))
+
+	dirs, err := Dirs(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{root, filepath.Join(root, "pkg"), filepath.Join(root, "pkg", "sub")}, dirs)
+}
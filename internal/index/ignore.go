@@ -0,0 +1,131 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one pattern line of a .gitignore file.
+type ignoreRule struct {
+	// base is the directory of the .gitignore file, relative to the index
+	// root ("" for the root); the rule only applies below it.
+	base    string
+	regex   *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	// anchored rules match the path relative to base; the others match the
+	// name of any file or directory.
+	anchored bool
+}
+
+// ignoreMatcher applies .gitignore rules in the order git does: later rules,
+// and rules from deeper files, override earlier ones.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// add parses the .gitignore file found in base (slash-separated, relative to
+// the root).
+func (m *ignoreMatcher) add(base string, data []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		regex, err := regexp.Compile("^" + globToRegex(line) + "$")
+		if err != nil {
+			continue
+		}
+		rule.regex = regex
+		m.rules = append(m.rules, rule)
+	}
+}
+
+// ignored reports whether rel (slash-separated, relative to the root) is
+// ignored.
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := rel
+		if rule.base != "" {
+			var ok bool
+			if target, ok = strings.CutPrefix(rel, rule.base+"/"); !ok {
+				continue
+			}
+		}
+		if !rule.anchored {
+			target = path.Base(target)
+		}
+		if rule.regex.MatchString(target) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// globToRegex translates a gitignore glob: * and ? stay within a path
+// segment, ** spans segments, and [...] classes are kept.
+func globToRegex(glob string) string {
+	var out strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			out.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			out.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			out.WriteString(".*")
+			i++
+		case c == '*':
+			out.WriteString("[^/]*")
+		case c == '?':
+			out.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				out.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			out.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			out.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			out.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return out.String()
+}
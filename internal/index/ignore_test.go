@@ -0,0 +1,45 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	var m ignoreMatcher
+	m.add("", []byte("# build output\n*.log\n!keep.log\n/dist\nbuild/\ndocs/**/*.tmp\n\\#notes\n"))
+	m.add("sub", []byte("local.txt\n"))
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"app.log", false, true},
+		{"nested/app.log", false, true},
+		{"keep.log", false, false},
+		{"dist", true, true},
+		{"nested/dist", true, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"nested/build", true, true},
+		{"docs/a/b/c.tmp", false, true},
+		{"docs/c.tmp", false, true},
+		{"c.tmp", false, false},
+		{"#notes", false, true},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ignored, m.ignored(tt.path, tt.isDir), tt.path)
+	}
+}
+
+func TestGlobToRegex(t *testing.T) {
+	assert.Equal(t, `[^/]*\.go`, globToRegex("*.go"))
+	assert.Equal(t, `(?:.*/)?vendor`, globToRegex("**/vendor"))
+	assert.Equal(t, `out/.*`, globToRegex("out/**"))
+	assert.Equal(t, `file[^0-9]`, globToRegex("file[!0-9]"))
+}
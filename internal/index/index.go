@@ -0,0 +1,529 @@
+// Package index is a semantic search index over the text files of a project
+// directory. Files are split into chunks of lines, each chunk is embedded with
+// an embedding model, and the vectors are stored in SQLite. A search embeds
+// the query and returns the chunks whose vectors are closest to it.
+package index
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/redact"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS files (
+    path TEXT PRIMARY KEY,
+    hash TEXT NOT NULL,
+    indexed_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS chunks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    path TEXT NOT NULL,
+    start_line INTEGER NOT NULL,
+    end_line INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    vector BLOB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS chunks_path ON chunks(path);
+`
+
+// embedBatch is how many chunks are sent to the embedding model at once.
+const embedBatch = 32
+
+// ErrNoEmbedder is returned when a search or update is given no embedder.
+var ErrNoEmbedder = errors.New("no embedding model")
+
+// Embedder turns texts into vectors. Every vector an index holds must come
+// from the same model.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Model identifies the embedding model an index is built with.
+type Model struct {
+	Provider string
+	Name     string
+}
+
+func (m Model) String() string {
+	return m.Provider + "/" + m.Name
+}
+
+// Info describes an index.
+type Info struct {
+	Root       string
+	Model      Model
+	Dimensions int
+	Files      int
+	Chunks     int
+	// UpdatedAt is when the index was last brought up to date; zero before
+	// the first build.
+	UpdatedAt time.Time
+}
+
+// Changes lists the files that differ from what the index holds.
+type Changes struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+// Empty reports whether the index is up to date.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Modified) == 0 && len(c.Removed) == 0
+}
+
+// Stats reports what an update did.
+type Stats struct {
+	// Indexed counts the new and changed files that were (re-)embedded.
+	Indexed   int
+	Chunks    int
+	Removed   int
+	Unchanged int
+	// Rebuilt is set when the embedding model changed, so every file was
+	// embedded again.
+	Rebuilt bool
+	// Redactions counts the secrets masked per detector before chunks were
+	// embedded and stored; nil when none were.
+	Redactions map[string]int
+}
+
+// Result is a chunk found by a search.
+type Result struct {
+	Chunk
+	// Score is the cosine similarity between the chunk and the query.
+	Score float32
+}
+
+// Index is the search index of one directory tree.
+type Index struct {
+	db       *sql.DB
+	root     string
+	redactor *redact.Redactor
+}
+
+// Open opens the index stored at dbPath for the directory root, creating it
+// if needed.
+func Open(dbPath, root string) (*Index, error) {
+	return OpenWithRedactor(dbPath, root, nil)
+}
+
+// OpenWithRedactor is Open with secrets masked in every chunk before it is
+// embedded and stored. A nil redactor keeps chunks unchanged.
+func OpenWithRedactor(dbPath, root string, redactor *redact.Redactor) (*Index, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create index schema: %w", err)
+	}
+	ix := &Index{db: db, root: root, redactor: redactor}
+	stored, err := ix.meta("root")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if stored == "" {
+		err = ix.setMeta(db, map[string]string{"root": root})
+	} else if stored != root {
+		err = fmt.Errorf("index %s belongs to %s, not %s", dbPath, stored, root)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return ix, nil
+}
+
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// Root is the directory the index covers.
+func (ix *Index) Root() string {
+	return ix.root
+}
+
+func (ix *Index) meta(key string) (string, error) {
+	var value string
+	err := ix.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read index metadata: %w", err)
+	}
+	return value, nil
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (ix *Index) setMeta(db execer, values map[string]string) error {
+	for key, value := range values {
+		if _, err := db.Exec(`INSERT INTO meta (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value); err != nil {
+			return fmt.Errorf("failed to write index metadata: %w", err)
+		}
+	}
+	return nil
+}
+
+func (ix *Index) Info() (Info, error) {
+	info := Info{Root: ix.root}
+	values := map[string]string{}
+	rows, err := ix.db.Query(`SELECT key, value FROM meta`)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to read index metadata: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return Info{}, fmt.Errorf("failed to read index metadata: %w", err)
+		}
+		values[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return Info{}, fmt.Errorf("failed to read index metadata: %w", err)
+	}
+	info.Model = Model{Provider: values["provider"], Name: values["model"]}
+	info.Dimensions, _ = strconv.Atoi(values["dimensions"])
+	info.UpdatedAt, _ = time.Parse(time.RFC3339, values["updated_at"])
+
+	if err := ix.db.QueryRow(`SELECT COUNT(*) FROM files`).Scan(&info.Files); err != nil {
+		return Info{}, fmt.Errorf("failed to count indexed files: %w", err)
+	}
+	if err := ix.db.QueryRow(`SELECT COUNT(*) FROM chunks`).Scan(&info.Chunks); err != nil {
+		return Info{}, fmt.Errorf("failed to count indexed chunks: %w", err)
+	}
+	return info, nil
+}
+
+// indexedHashes returns the content hash of every indexed file.
+func (ix *Index) indexedHashes() (map[string]string, error) {
+	rows, err := ix.db.Query(`SELECT path, hash FROM files`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexed files: %w", err)
+	}
+	defer rows.Close()
+	hashes := map[string]string{}
+	for rows.Next() {
+		var path, hash string
+		if err := rows.Scan(&path, &hash); err != nil {
+			return nil, fmt.Errorf("failed to read indexed files: %w", err)
+		}
+		hashes[path] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// diff compares the files on disk with the indexed ones and returns the files
+// to (re-)embed along with the changes.
+func (ix *Index) diff() ([]sourceFile, Changes, int, error) {
+	files, err := walkFiles(ix.root)
+	if err != nil {
+		return nil, Changes{}, 0, fmt.Errorf("failed to list files in %s: %w", ix.root, err)
+	}
+	indexed, err := ix.indexedHashes()
+	if err != nil {
+		return nil, Changes{}, 0, err
+	}
+
+	var changes Changes
+	var stale []sourceFile
+	unchanged := 0
+	for _, file := range files {
+		hash, ok := indexed[file.path]
+		delete(indexed, file.path)
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, file.path)
+		case hash != file.hash:
+			changes.Modified = append(changes.Modified, file.path)
+		default:
+			unchanged++
+			continue
+		}
+		stale = append(stale, file)
+	}
+	for path := range indexed {
+		changes.Removed = append(changes.Removed, path)
+	}
+	slices.Sort(changes.Removed)
+	return stale, changes, unchanged, nil
+}
+
+// Changes lists the files added, modified or removed since the index was
+// last updated.
+func (ix *Index) Changes() (Changes, error) {
+	_, changes, _, err := ix.diff()
+	return changes, err
+}
+
+// Update brings the index up to date: new and changed files are embedded,
+// removed ones dropped and unchanged ones kept. When model differs from the
+// one the index was built with, every file is embedded again. Each file is
+// committed once embedded, so an interrupted update keeps its progress.
+// progress, if set, is called after each embedded file.
+func (ix *Index) Update(ctx context.Context, model Model, embedder Embedder, progress func(done, total int)) (Stats, error) {
+	if embedder == nil {
+		return Stats{}, ErrNoEmbedder
+	}
+	info, err := ix.Info()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var stats Stats
+	if info.Model != model && info.Model != (Model{}) {
+		if _, err := ix.db.Exec(`DELETE FROM chunks; DELETE FROM files;`); err != nil {
+			return Stats{}, fmt.Errorf("failed to clear index: %w", err)
+		}
+		info.Dimensions = 0
+		stats.Rebuilt = true
+	}
+	if err := ix.setMeta(ix.db, map[string]string{"provider": model.Provider, "model": model.Name}); err != nil {
+		return Stats{}, err
+	}
+
+	stale, changes, unchanged, err := ix.diff()
+	if err != nil {
+		return Stats{}, err
+	}
+	stats.Unchanged = unchanged
+
+	for _, path := range changes.Removed {
+		if err := ix.removeFile(path); err != nil {
+			return stats, err
+		}
+		stats.Removed++
+	}
+
+	dimensions := info.Dimensions
+	for i, file := range stale {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		data, err := os.ReadFile(filepath.Join(ix.root, filepath.FromSlash(file.path)))
+		if err != nil {
+			// The file went away since it was listed; the next update drops it.
+			continue
+		}
+		chunks := chunkFile(file.path, string(data))
+		for i := range chunks {
+			chunks[i].Content = ix.redactor.String(chunks[i].Content)
+		}
+		vectors, err := embedChunks(ctx, embedder, chunks)
+		if err != nil {
+			return stats, fmt.Errorf("failed to embed %s: %w", file.path, err)
+		}
+		for _, vector := range vectors {
+			if dimensions == 0 {
+				dimensions = len(vector)
+			}
+			if len(vector) != dimensions {
+				return stats, fmt.Errorf("embedding model returned %d dimensions for %s, the index has %d", len(vector), file.path, dimensions)
+			}
+		}
+		if err := ix.storeFile(file, chunks, vectors); err != nil {
+			return stats, err
+		}
+		stats.Indexed++
+		stats.Chunks += len(chunks)
+		if progress != nil {
+			progress(i+1, len(stale))
+		}
+	}
+
+	stats.Redactions = ix.redactor.Counts()
+	return stats, ix.setMeta(ix.db, map[string]string{
+		"dimensions": strconv.Itoa(dimensions),
+		"updated_at": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func embedChunks(ctx context.Context, embedder Embedder, chunks []Chunk) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(chunks))
+	for start := 0; start < len(chunks); start += embedBatch {
+		batch := chunks[start:min(start+embedBatch, len(chunks))]
+		texts := make([]string, len(batch))
+		for i, chunk := range batch {
+			texts[i] = chunk.embeddingText()
+		}
+		embedded, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, err
+		}
+		if len(embedded) != len(batch) {
+			return nil, fmt.Errorf("embedding model returned %d vectors for %d chunks", len(embedded), len(batch))
+		}
+		for _, vector := range embedded {
+			vectors = append(vectors, normalize(vector))
+		}
+	}
+	return vectors, nil
+}
+
+func (ix *Index) removeFile(path string) error {
+	tx, err := ix.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from index: %w", path, err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM chunks WHERE path = ?`, path); err != nil {
+		return fmt.Errorf("failed to remove %s from index: %w", path, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM files WHERE path = ?`, path); err != nil {
+		return fmt.Errorf("failed to remove %s from index: %w", path, err)
+	}
+	return tx.Commit()
+}
+
+// storeFile replaces a file's chunks in one transaction.
+func (ix *Index) storeFile(file sourceFile, chunks []Chunk, vectors [][]float32) error {
+	tx, err := ix.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to index %s: %w", file.path, err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM chunks WHERE path = ?`, file.path); err != nil {
+		return fmt.Errorf("failed to index %s: %w", file.path, err)
+	}
+	for i, chunk := range chunks {
+		if _, err := tx.Exec(`INSERT INTO chunks (path, start_line, end_line, content, vector) VALUES (?, ?, ?, ?, ?)`,
+			chunk.Path, chunk.StartLine, chunk.EndLine, chunk.Content, encodeVector(vectors[i])); err != nil {
+			return fmt.Errorf("failed to index %s: %w", file.path, err)
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO files (path, hash, indexed_at) VALUES (?, ?, ?) ON CONFLICT(path) DO UPDATE SET hash = excluded.hash, indexed_at = excluded.indexed_at`,
+		file.path, file.hash, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to index %s: %w", file.path, err)
+	}
+	return tx.Commit()
+}
+
+// Search returns the k chunks most similar to query, best first. embedder
+// must be the model the index was built with.
+func (ix *Index) Search(ctx context.Context, embedder Embedder, query string, k int) ([]Result, error) {
+	if embedder == nil {
+		return nil, ErrNoEmbedder
+	}
+	vectors, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedding model returned %d vectors for the query", len(vectors))
+	}
+	queryVector := normalize(vectors[0])
+
+	rows, err := ix.db.QueryContext(ctx, `SELECT path, start_line, end_line, content, vector FROM chunks`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search index: %w", err)
+	}
+	defer rows.Close()
+
+	var results []Result
+	for rows.Next() {
+		var result Result
+		var blob []byte
+		if err := rows.Scan(&result.Path, &result.StartLine, &result.EndLine, &result.Content, &blob); err != nil {
+			return nil, fmt.Errorf("failed to search index: %w", err)
+		}
+		vector := decodeVector(blob)
+		if len(vector) != len(queryVector) {
+			return nil, fmt.Errorf("query has %d dimensions, the index has %d; rebuild it with `agent index build`", len(queryVector), len(vector))
+		}
+		result.Score = dot(queryVector, vector)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search index: %w", err)
+	}
+
+	slices.SortStableFunc(results, func(a, b Result) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	return results[:min(k, len(results))], nil
+}
+
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(vector))
+	for i, v := range vector {
+		out[i] = v / norm
+	}
+	return out
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func encodeVector(vector []float32) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	vector := make([]float32, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vector
+}
+
+// FormatResults renders results as <context> blocks for a prompt, each naming
+// the file and lines it comes from.
+func FormatResults(results []Result) string {
+	parts := make([]string, 0, len(results))
+	for _, result := range results {
+		parts = append(parts, fmt.Sprintf("<context source=\"%s:%d-%d\">\n%s\n</context>", result.Path, result.StartLine, result.EndLine, strings.TrimSpace(result.Content)))
+	}
+	return strings.Join(parts, "\n\n")
+}
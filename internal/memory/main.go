@@ -3,23 +3,72 @@ package memory
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/laszukdawid/terminal-agent/internal/utils"
 )
 
 type Memory interface {
-	// Add stores a new entry if it doesn't already exist
+	// Add stores a new global entry if it doesn't already exist
 	Add(content string) error
 
-	// List returns all memory entries
+	// AddEntry stores an entry with its tags, scope and expiry, and returns
+	// it with its ID. An entry with the same content and scope is returned
+	// instead of being stored twice.
+	AddEntry(entry MemoryEntry) (MemoryEntry, error)
+
+	// List returns all memory entries, expired ones included
 	List() ([]MemoryEntry, error)
 
-	// FormatAsPrompt returns memory formatted for inclusion in a system prompt
-	FormatAsPrompt() (string, error)
+	// Get returns the entry with the given ID
+	Get(id int) (MemoryEntry, error)
+
+	// Update changes the entry with the given ID and returns the result
+	Update(id int, change func(*MemoryEntry)) (MemoryEntry, error)
+
+	// Remove deletes the entries with the given IDs
+	Remove(ids ...int) error
+
+	// Search returns the entries matching text, best match first
+	Search(text string) ([]MemoryEntry, error)
+
+	// Relevant returns up to limit unexpired entries in scope for q that
+	// match q.Text, are pinned or belong to q's routine (see relevance.go)
+	Relevant(q Query, limit int) ([]MemoryEntry, error)
+
+	// FormatAsPrompt returns the entries relevant to q formatted for
+	// inclusion in a system prompt
+	FormatAsPrompt(q Query) (string, error)
+}
+
+// ErrNotFound is returned for a memory ID that does not exist.
+var ErrNotFound = errors.New("memory entry not found")
+
+// Scopes an entry can be limited to. A global entry applies everywhere.
+const (
+	ScopeGlobal  = "global"
+	ScopeProject = "project"
+	ScopeRoutine = "routine"
+)
+
+// TagPinned marks an entry that applies to every run in its scope, whether or
+// not it matches the request.
+const TagPinned = "pinned"
+
+// SourceCLI is the Source of entries added with `agent memory add`. Entries
+// written by the task agent carry the name of the tool that wrote them.
+const SourceCLI = "cli"
+
+// DefaultPath is where memory is stored unless a caller chooses another file.
+func DefaultPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".local", "share", "terminal-agent", "memory.jsonl")
 }
 
 type memory struct {
@@ -31,35 +80,114 @@ func NewMemory(path string) Memory {
 }
 
 type MemoryEntry struct {
-	Content   string `json:"content"`
+	// ID is assigned when the entry is stored. Entries written before IDs
+	// existed are numbered on read, in file order, and keep that number once
+	// the file is rewritten.
+	ID      int      `json:"id,omitempty"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags,omitempty"`
+	// Project limits the entry to runs in this directory or below it.
+	Project string `json:"project,omitempty"`
+	// Routine limits the entry to runs of the routine with this ID.
+	Routine string `json:"routine,omitempty"`
+	// ExpiresAt, when set, is when the entry stops being used.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// Source is what wrote the entry: SourceCLI, or the tool the task agent
+	// used. Empty for entries written before it was recorded.
+	Source string `json:"source,omitempty"`
+	// RunID is the session-log run the entry was written in, if any.
+	RunID     string `json:"run_id,omitempty"`
 	Timestamp string `json:"timestamp"`
+	UpdatedAt string `json:"updated_at,omitempty"`
 }
 
-func (m *memory) Add(content string) error {
-	// Check if entry already exists
-	entries, err := m.List()
-	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to read existing entries: %w", err)
+// Scope reports which runs an entry applies to: ScopeGlobal, ScopeProject or
+// ScopeRoutine.
+func (e MemoryEntry) Scope() string {
+	switch {
+	case e.Routine != "":
+		return ScopeRoutine
+	case e.Project != "":
+		return ScopeProject
 	}
+	return ScopeGlobal
+}
 
-	for _, entry := range entries {
-		if entry.Content == content {
-			// Silently skip - already exists
-			return nil
-		}
+// ScopeLabel describes the scope for display, e.g. "project:/src/app".
+func (e MemoryEntry) ScopeLabel() string {
+	switch e.Scope() {
+	case ScopeRoutine:
+		return ScopeRoutine + ":" + e.Routine
+	case ScopeProject:
+		return ScopeProject + ":" + e.Project
 	}
+	return ScopeGlobal
+}
+
+// Expired reports whether the entry's expiry has passed at now.
+func (e MemoryEntry) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
 
-	// Add new entry
-	entry := MemoryEntry{
-		Content:   content,
-		Timestamp: time.Now().Format(time.RFC3339),
+// Provenance describes where the entry came from for display, e.g.
+// "remember (run 1a2b3c4d)".
+func (e MemoryEntry) Provenance() string {
+	source := e.Source
+	if source == "" {
+		source = "-"
+	}
+	if e.RunID == "" {
+		return source
+	}
+	runID := strings.ReplaceAll(e.RunID, "-", "")
+	if len(runID) > 8 {
+		runID = runID[:8]
 	}
+	return fmt.Sprintf("%s (run %s)", source, runID)
+}
+
+func (e MemoryEntry) sameScope(other MemoryEntry) bool {
+	return e.Project == other.Project && e.Routine == other.Routine
+}
+
+func (m *memory) Add(content string) error {
+	_, err := m.AddEntry(MemoryEntry{Content: content})
+	return err
+}
 
-	if err := utils.WriteToJSONLFile(m.path, entry); err != nil {
-		return fmt.Errorf("failed to write memory entry: %w", err)
+func (m *memory) AddEntry(entry MemoryEntry) (MemoryEntry, error) {
+	entry.Content = strings.TrimSpace(entry.Content)
+	if entry.Content == "" {
+		return MemoryEntry{}, fmt.Errorf("memory entry is empty")
 	}
+	entry.Tags = normalizeTags(entry.Tags)
 
-	return nil
+	err := m.withLock(func() error {
+		entries, err := m.List()
+		if err != nil {
+			return fmt.Errorf("failed to read existing entries: %w", err)
+		}
+
+		for _, existing := range entries {
+			if existing.Content == entry.Content && existing.sameScope(entry) {
+				// Silently skip - already exists
+				entry = existing
+				return nil
+			}
+		}
+
+		entry.ID = nextID(entries)
+		entry.Timestamp = time.Now().Format(time.RFC3339)
+		entry.UpdatedAt = ""
+		if err := utils.WriteToJSONLFile(m.path, entry); err != nil {
+			return fmt.Errorf("failed to write memory entry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return MemoryEntry{}, err
+	}
+	return entry, nil
 }
 
 func (m *memory) List() ([]MemoryEntry, error) {
@@ -74,7 +202,11 @@ func (m *memory) List() ([]MemoryEntry, error) {
 
 	var entries []MemoryEntry
 	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
 		var entry MemoryEntry
 		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal entry: %w", err)
@@ -86,11 +218,151 @@ func (m *memory) List() ([]MemoryEntry, error) {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	numberLegacyEntries(entries)
 	return entries, nil
 }
 
-func (m *memory) FormatAsPrompt() (string, error) {
+// numberLegacyEntries gives entries stored without an ID the lowest free IDs,
+// in file order, so they can be edited and removed like the others.
+func numberLegacyEntries(entries []MemoryEntry) {
+	used := map[int]bool{}
+	for _, entry := range entries {
+		used[entry.ID] = true
+	}
+	next := 1
+	for i := range entries {
+		if entries[i].ID != 0 {
+			continue
+		}
+		for used[next] {
+			next++
+		}
+		entries[i].ID = next
+		used[next] = true
+	}
+}
+
+func nextID(entries []MemoryEntry) int {
+	highest := 0
+	for _, entry := range entries {
+		highest = max(highest, entry.ID)
+	}
+	return highest + 1
+}
+
+func (m *memory) Get(id int) (MemoryEntry, error) {
 	entries, err := m.List()
+	if err != nil {
+		return MemoryEntry{}, err
+	}
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return MemoryEntry{}, fmt.Errorf("%w: %d", ErrNotFound, id)
+}
+
+func (m *memory) Update(id int, change func(*MemoryEntry)) (MemoryEntry, error) {
+	var updated MemoryEntry
+	err := m.withLock(func() error {
+		entries, err := m.List()
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(entries, func(entry MemoryEntry) bool { return entry.ID == id })
+		if i < 0 {
+			return fmt.Errorf("%w: %d", ErrNotFound, id)
+		}
+
+		updated = entries[i]
+		change(&updated)
+		updated.ID = id
+		updated.Timestamp = entries[i].Timestamp
+		updated.Content = strings.TrimSpace(updated.Content)
+		if updated.Content == "" {
+			return fmt.Errorf("memory entry is empty")
+		}
+		updated.Tags = normalizeTags(updated.Tags)
+		updated.UpdatedAt = time.Now().Format(time.RFC3339)
+		entries[i] = updated
+		return m.rewrite(entries)
+	})
+	if err != nil {
+		return MemoryEntry{}, err
+	}
+	return updated, nil
+}
+
+func (m *memory) Remove(ids ...int) error {
+	return m.withLock(func() error {
+		entries, err := m.List()
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if !slices.ContainsFunc(entries, func(entry MemoryEntry) bool { return entry.ID == id }) {
+				return fmt.Errorf("%w: %d", ErrNotFound, id)
+			}
+		}
+		entries = slices.DeleteFunc(entries, func(entry MemoryEntry) bool { return slices.Contains(ids, entry.ID) })
+		return m.rewrite(entries)
+	})
+}
+
+// withLock runs fn holding an exclusive lock on the memory file's lock file,
+// so that concurrent runs adding, editing and removing entries do not lose
+// each other's changes: an append to the old file while it is being rewritten
+// would be lost with it. Callers of rewrite must hold the lock.
+func (m *memory) withLock(fn func() error) error {
+	lockPath := m.path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("lock %s: %w", lockPath, err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	return fn()
+}
+
+// rewrite replaces the memory file with entries. The new file is written next
+// to the old one and renamed over it, so a failed write loses nothing.
+func (m *memory) rewrite(entries []MemoryEntry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write memory: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := json.NewEncoder(tmp)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write memory: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write memory: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write memory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		return fmt.Errorf("failed to write memory: %w", err)
+	}
+	return nil
+}
+
+func (m *memory) FormatAsPrompt(q Query) (string, error) {
+	entries, err := m.Relevant(q, MaxPromptEntries)
 	if err != nil {
 		return "", fmt.Errorf("failed to list memory entries: %w", err)
 	}
@@ -106,3 +378,15 @@ func (m *memory) FormatAsPrompt() (string, error) {
 
 	return "<memory>\n" + strings.Join(lines, "\n") + "\n</memory>", nil
 }
+
+// normalizeTags lowercases tags and drops blanks and duplicates.
+func normalizeTags(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
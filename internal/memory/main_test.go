@@ -1,8 +1,10 @@
 package memory
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -104,7 +106,7 @@ func TestMemoryFormatAsPrompt(t *testing.T) {
 
 		m := NewMemory(memoryPath)
 
-		prompt, err := m.FormatAsPrompt()
+		prompt, err := m.FormatAsPrompt(Query{})
 		assert.NoError(t, err)
 		assert.Empty(t, prompt, "Empty memory should return empty prompt")
 	})
@@ -116,7 +118,7 @@ func TestMemoryFormatAsPrompt(t *testing.T) {
 		m := NewMemory(memoryPath)
 		m.Add("viewing images is with catimg")
 
-		prompt, err := m.FormatAsPrompt()
+		prompt, err := m.FormatAsPrompt(Query{})
 		assert.NoError(t, err)
 		assert.Equal(t, "<memory>\nviewing images is with catimg\n</memory>", prompt)
 	})
@@ -130,7 +132,7 @@ func TestMemoryFormatAsPrompt(t *testing.T) {
 		m.Add("second thing to remember")
 		m.Add("third thing to remember")
 
-		prompt, err := m.FormatAsPrompt()
+		prompt, err := m.FormatAsPrompt(Query{})
 		assert.NoError(t, err)
 		expected := "<memory>\nfirst thing to remember\nsecond thing to remember\nthird thing to remember\n</memory>"
 		assert.Equal(t, expected, prompt)
@@ -142,7 +144,7 @@ func TestMemoryFormatAsPrompt(t *testing.T) {
 
 		m := NewMemory(nonExistentPath)
 
-		prompt, err := m.FormatAsPrompt()
+		prompt, err := m.FormatAsPrompt(Query{})
 		assert.NoError(t, err)
 		assert.Empty(t, prompt, "Non-existent memory file should return empty prompt")
 	})
@@ -183,3 +185,142 @@ func TestMemoryPersistence(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestMemoryEntries(t *testing.T) {
+	t.Run("AddEntryWithScopeAndTags", func(t *testing.T) {
+		memoryPath, cleanup := setupTempMemory(t)
+		defer cleanup()
+
+		m := NewMemory(memoryPath)
+		global, err := m.AddEntry(MemoryEntry{Content: "use ripgrep", Tags: []string{"Search", "search", " "}})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, global.ID)
+		assert.Equal(t, []string{"search"}, global.Tags)
+		assert.Equal(t, ScopeGlobal, global.Scope())
+
+		// The same content in another scope is a separate entry
+		project, err := m.AddEntry(MemoryEntry{Content: "use ripgrep", Project: "/src/app"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, project.ID)
+		assert.Equal(t, "project:/src/app", project.ScopeLabel())
+
+		again, err := m.AddEntry(MemoryEntry{Content: "use ripgrep", Project: "/src/app"})
+		assert.NoError(t, err)
+		assert.Equal(t, project.ID, again.ID)
+
+		_, err = m.AddEntry(MemoryEntry{Content: "  "})
+		assert.Error(t, err)
+	})
+
+	t.Run("ConcurrentWritesKeepEveryEntry", func(t *testing.T) {
+		memoryPath, cleanup := setupTempMemory(t)
+		defer cleanup()
+
+		m := NewMemory(memoryPath)
+		first, err := m.AddEntry(MemoryEntry{Content: "entry 0"})
+		assert.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 1; i <= 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// Separate stores, as separate processes would have.
+				store := NewMemory(memoryPath)
+				if i%2 == 0 {
+					_, err := store.Update(first.ID, func(entry *MemoryEntry) { entry.Content = fmt.Sprintf("entry 0, edit %d", i) })
+					assert.NoError(t, err)
+					return
+				}
+				_, err := store.AddEntry(MemoryEntry{Content: fmt.Sprintf("entry %d", i)})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		entries, err := m.List()
+		assert.NoError(t, err)
+		assert.Len(t, entries, 11)
+		ids := map[int]bool{}
+		for _, entry := range entries {
+			ids[entry.ID] = true
+		}
+		assert.Len(t, ids, 11, "every entry gets its own id")
+	})
+
+	t.Run("UpdateAndRemove", func(t *testing.T) {
+		memoryPath, cleanup := setupTempMemory(t)
+		defer cleanup()
+
+		m := NewMemory(memoryPath)
+		m.Add("first entry")
+		m.Add("second entry")
+		m.Add("third entry")
+
+		updated, err := m.Update(2, func(entry *MemoryEntry) {
+			entry.Content = "second entry, edited"
+			entry.Routine = "nightly"
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, ScopeRoutine, updated.Scope())
+		assert.NotEmpty(t, updated.UpdatedAt)
+
+		assert.NoError(t, m.Remove(1, 3))
+		entries, err := m.List()
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, 2, entries[0].ID)
+		assert.Equal(t, "second entry, edited", entries[0].Content)
+
+		// IDs are not reused
+		added, err := m.AddEntry(MemoryEntry{Content: "fourth entry"})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, added.ID)
+
+		assert.ErrorIs(t, m.Remove(42), ErrNotFound)
+		_, err = m.Update(42, func(*MemoryEntry) {})
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = m.Get(42)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("NumbersLegacyEntries", func(t *testing.T) {
+		memoryPath, cleanup := setupTempMemory(t)
+		defer cleanup()
+
+		legacy := `{"content":"old one","timestamp":"2025-01-01T00:00:00Z"}
+{"content":"old two","timestamp":"2025-01-02T00:00:00Z"}
+`
+		assert.NoError(t, os.WriteFile(memoryPath, []byte(legacy), 0644))
+
+		m := NewMemory(memoryPath)
+		added, err := m.AddEntry(MemoryEntry{Content: "new"})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, added.ID)
+
+		assert.NoError(t, m.Remove(1))
+		entries, err := m.List()
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, 2, entries[0].ID)
+		assert.Equal(t, "old two", entries[0].Content)
+	})
+
+	t.Run("KeepsProvenance", func(t *testing.T) {
+		memoryPath, cleanup := setupTempMemory(t)
+		defer cleanup()
+
+		m := NewMemory(memoryPath)
+		added, err := m.AddEntry(MemoryEntry{Content: "uses task test", Source: "remember", RunID: "0f3a9c2e-41d7-4b8a-9e21-5c6d7e8f9a0b"})
+		assert.NoError(t, err)
+		assert.Equal(t, "remember (run 0f3a9c2e)", added.Provenance())
+
+		updated, err := m.Update(added.ID, func(entry *MemoryEntry) { entry.Content = "uses task test, not go test" })
+		assert.NoError(t, err)
+		assert.Equal(t, "remember", updated.Source)
+		assert.Equal(t, added.RunID, updated.RunID)
+
+		assert.Equal(t, SourceCLI, MemoryEntry{Source: SourceCLI}.Provenance())
+		assert.Equal(t, "-", MemoryEntry{}.Provenance())
+	})
+}
@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxPromptEntries bounds how many entries FormatAsPrompt injects, so a large
+// memory does not bloat every prompt.
+const MaxPromptEntries = 20
+
+// Query describes the run memory is selected for.
+type Query struct {
+	// Dir is the run's working directory; project entries apply in it and
+	// below it.
+	Dir string
+	// Routine is the ID of the routine being run, if any.
+	Routine string
+	// Text is the request, matched against entries to rank them.
+	Text string
+}
+
+// InScope reports whether the entry applies to runs described by q.
+func (e MemoryEntry) InScope(q Query) bool {
+	switch e.Scope() {
+	case ScopeRoutine:
+		return e.Routine == q.Routine
+	case ScopeProject:
+		if q.Dir == "" {
+			return false
+		}
+		rel, err := filepath.Rel(e.Project, q.Dir)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	return true
+}
+
+// alwaysRelevant reports whether the entry is used in every run it is in scope
+// for, whatever the request: pinned entries, and routine entries, since a
+// routine sends the same request each run and its scope already says the
+// entry is about it.
+func alwaysRelevant(entry MemoryEntry) bool {
+	return entry.Scope() == ScopeRoutine || slices.Contains(entry.Tags, TagPinned)
+}
+
+// scopeWeight ranks narrower scopes first among equally relevant entries: an
+// entry written for this routine or project is likelier to matter than a
+// global one.
+func scopeWeight(entry MemoryEntry) int {
+	switch entry.Scope() {
+	case ScopeRoutine:
+		return 2
+	case ScopeProject:
+		return 1
+	}
+	return 0
+}
+
+// stopWords are too common to say anything about relevance.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"are": true, "was": true, "not": true, "but": true, "you": true, "how": true,
+	"what": true, "can": true, "use": true, "from": true, "into": true, "all": true,
+	"when": true, "where": true, "which": true, "should": true, "would": true, "does": true,
+}
+
+// terms splits text into the lowercase words used for matching.
+func terms(text string) []string {
+	var out []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) < 3 || stopWords[word] || slices.Contains(out, word) {
+			continue
+		}
+		out = append(out, word)
+	}
+	return out
+}
+
+// matchScore counts the query terms found in the entry; a term found in a tag
+// counts twice. Terms match words of the entry by prefix, so "deploy" finds
+// "deployment".
+func matchScore(entry MemoryEntry, query []string) int {
+	words := terms(entry.Content)
+	score := 0
+	for _, term := range query {
+		if slices.ContainsFunc(entry.Tags, func(tag string) bool { return strings.HasPrefix(tag, term) }) {
+			score += 2
+		} else if slices.ContainsFunc(words, func(word string) bool { return strings.HasPrefix(word, term) }) {
+			score++
+		}
+	}
+	return score
+}
+
+// rank orders entries by match score, then scope, then most recently written.
+func rank(entries []MemoryEntry, query []string) []MemoryEntry {
+	ranked := slices.Clone(entries)
+	scores := make(map[int]int, len(ranked))
+	for _, entry := range ranked {
+		scores[entry.ID] = matchScore(entry, query)
+	}
+	slices.SortStableFunc(ranked, func(a, b MemoryEntry) int {
+		if d := scores[b.ID] - scores[a.ID]; d != 0 {
+			return d
+		}
+		if d := scopeWeight(b) - scopeWeight(a); d != 0 {
+			return d
+		}
+		return strings.Compare(lastWritten(b), lastWritten(a))
+	})
+	return ranked
+}
+
+func lastWritten(entry MemoryEntry) string {
+	if entry.UpdatedAt != "" {
+		return entry.UpdatedAt
+	}
+	return entry.Timestamp
+}
+
+func (m *memory) Relevant(q Query, limit int) ([]MemoryEntry, error) {
+	entries, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	query := terms(q.Text)
+	entries = slices.DeleteFunc(entries, func(entry MemoryEntry) bool {
+		if entry.Expired(now) || !entry.InScope(q) {
+			return true
+		}
+		// With a request to match, entries it does not mention are left out
+		// unless they always apply; without one, every entry in scope is.
+		return len(query) > 0 && !alwaysRelevant(entry) && matchScore(entry, query) == 0
+	})
+	if limit <= 0 || len(entries) <= limit {
+		return entries, nil
+	}
+
+	// Keep the best entries, but in the order they were written, so the
+	// prompt reads the same from run to run.
+	keep := rank(entries, query)[:limit]
+	return slices.DeleteFunc(entries, func(entry MemoryEntry) bool {
+		return !slices.ContainsFunc(keep, func(k MemoryEntry) bool { return k.ID == entry.ID })
+	}), nil
+}
+
+func (m *memory) Search(text string) ([]MemoryEntry, error) {
+	entries, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	query := terms(text)
+	if len(query) == 0 {
+		// Short words only: fall back to a plain substring match.
+		needle := strings.ToLower(strings.TrimSpace(text))
+		return slices.DeleteFunc(entries, func(entry MemoryEntry) bool {
+			return needle == "" || !strings.Contains(strings.ToLower(entry.Content), needle)
+		}), nil
+	}
+	entries = slices.DeleteFunc(entries, func(entry MemoryEntry) bool { return matchScore(entry, query) == 0 })
+	return rank(entries, query), nil
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRelevant(t *testing.T) {
+	memoryPath, cleanup := setupTempMemory(t)
+	defer cleanup()
+
+	m := NewMemory(memoryPath)
+	for _, entry := range []MemoryEntry{
+		{Content: "global fact"},
+		{Content: "app uses task test, not go test", Project: "/src/app"},
+		{Content: "other project fact", Project: "/src/other"},
+		{Content: "nightly routine fact", Routine: "nightly"},
+		{Content: "expired fact", ExpiresAt: time.Now().Add(-time.Hour)},
+		{Content: "future fact", ExpiresAt: time.Now().Add(time.Hour)},
+	} {
+		_, err := m.AddEntry(entry)
+		require.NoError(t, err)
+	}
+
+	contents := func(entries []MemoryEntry) []string {
+		var out []string
+		for _, entry := range entries {
+			out = append(out, entry.Content)
+		}
+		return out
+	}
+
+	entries, err := m.Relevant(Query{Dir: "/src/app/internal"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"global fact", "app uses task test, not go test", "future fact"}, contents(entries))
+
+	entries, err = m.Relevant(Query{Dir: "/src/application", Routine: "nightly"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"global fact", "nightly routine fact", "future fact"}, contents(entries))
+
+	prompt, err := m.FormatAsPrompt(Query{})
+	require.NoError(t, err)
+	assert.Equal(t, "<memory>\nglobal fact\nfuture fact\n</memory>", prompt)
+}
+
+func TestMemoryRelevantRanksWhenOverLimit(t *testing.T) {
+	memoryPath, cleanup := setupTempMemory(t)
+	defer cleanup()
+
+	m := NewMemory(memoryPath)
+	for i := range 5 {
+		require.NoError(t, m.Add(fmt.Sprintf("unrelated fact %d", i)))
+	}
+	_, err := m.AddEntry(MemoryEntry{Content: "kubectl lives in ~/bin", Tags: []string{"kubernetes"}})
+	require.NoError(t, err)
+	_, err = m.AddEntry(MemoryEntry{Content: "deployments are in the staging namespace"})
+	require.NoError(t, err)
+	_, err = m.AddEntry(MemoryEntry{Content: "project note", Project: "/src/app"})
+	require.NoError(t, err)
+
+	_, err = m.AddEntry(MemoryEntry{Content: "pods restart slowly on kubernetes"})
+	require.NoError(t, err)
+
+	entries, err := m.Relevant(Query{Dir: "/src/app", Text: "restart the kubernetes deployment"}, 2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	// The best matches are kept, in the order they were written
+	assert.Equal(t, "kubectl lives in ~/bin", entries[0].Content)
+	assert.Equal(t, "pods restart slowly on kubernetes", entries[1].Content)
+}
+
+func TestMemoryRelevantDropsUnmatchedEntries(t *testing.T) {
+	memoryPath, cleanup := setupTempMemory(t)
+	defer cleanup()
+
+	m := NewMemory(memoryPath)
+	for _, entry := range []MemoryEntry{
+		{Content: "viewing images is with catimg"},
+		{Content: "deployments are in the staging namespace"},
+		{Content: "never push on Fridays", Tags: []string{TagPinned}},
+		{Content: "project note", Project: "/src/app"},
+		{Content: "the report goes to #ops", Routine: "nightly"},
+	} {
+		_, err := m.AddEntry(entry)
+		require.NoError(t, err)
+	}
+
+	entries, err := m.Relevant(Query{Dir: "/src/app", Routine: "nightly", Text: "summarise the deployment errors"}, MaxPromptEntries)
+	require.NoError(t, err)
+	var contents []string
+	for _, entry := range entries {
+		contents = append(contents, entry.Content)
+	}
+	assert.Equal(t, []string{"deployments are in the staging namespace", "never push on Fridays", "the report goes to #ops"}, contents)
+
+	// Without text to match, everything in scope applies.
+	entries, err = m.Relevant(Query{Dir: "/src/app", Routine: "nightly"}, MaxPromptEntries)
+	require.NoError(t, err)
+	assert.Len(t, entries, 5)
+}
+
+func TestMemorySearch(t *testing.T) {
+	memoryPath, cleanup := setupTempMemory(t)
+	defer cleanup()
+
+	m := NewMemory(memoryPath)
+	require.NoError(t, m.Add("viewing images is with catimg"))
+	_, err := m.AddEntry(MemoryEntry{Content: "prefer feh", Tags: []string{"images"}})
+	require.NoError(t, err)
+	require.NoError(t, m.Add("use kubectl for kubernetes"))
+
+	entries, err := m.Search("images")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	// A tag match ranks first
+	assert.Equal(t, "prefer feh", entries[0].Content)
+
+	entries, err = m.Search("k8")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	entries, err = m.Search("is")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, strings.HasPrefix(entries[0].Content, "viewing"))
+}
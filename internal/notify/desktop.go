@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// showDesktop shows a desktop notification. On Linux it calls the
+// org.freedesktop.Notifications D-Bus service through gdbus, falling back to
+// notify-send; on macOS it uses osascript.
+func showDesktop(ctx context.Context, title, body string) error {
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if _, err := exec.LookPath("gdbus"); err == nil {
+			return runNotifyCommand(ctx, "gdbus", "call", "--session",
+				"--dest", "org.freedesktop.Notifications",
+				"--object-path", "/org/freedesktop/Notifications",
+				"--method", "org.freedesktop.Notifications.Notify",
+				// app_name, replaces_id, app_icon, summary, body, actions, hints, expire_timeout
+				"Terminal Agent", "0", "", gvariantString(title), gvariantString(body), "[]", "{}", "-1")
+		}
+		if _, err := exec.LookPath("notify-send"); err == nil {
+			return runNotifyCommand(ctx, "notify-send", "--app-name=Terminal Agent", title, body)
+		}
+		return fmt.Errorf("no desktop notifier found (install gdbus or notify-send)")
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(body), strconv.Quote(title))
+		return runNotifyCommand(ctx, "osascript", "-e", script)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+}
+
+func runNotifyCommand(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, out)
+	}
+	return nil
+}
+
+// gvariantString renders s as a GVariant string literal, the syntax gdbus
+// parses its arguments with. That syntax has no \x escapes, which Go quoting
+// uses, so control characters are written as \uXXXX and everything else as
+// UTF-8.
+func gvariantString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range strings.ToValidUTF8(s, "\uFFFD") {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == 0:
+			// Dropped: GVariant strings end at NUL.
+		case unicode.IsControl(r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
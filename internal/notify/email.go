@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+)
+
+// sendEmail sends the summary as a plain-text email. net/smtp upgrades to TLS
+// when the server offers STARTTLS and only sends credentials over TLS or to
+// localhost.
+func (n *Notifier) sendEmail(ctx context.Context, notification routines.Notification, msg Message) error {
+	settings := n.smtp
+	if notification.SMTP != nil {
+		settings = *notification.SMTP
+	}
+	if strings.TrimSpace(settings.Addr) == "" {
+		return fmt.Errorf("no SMTP server configured (set routines.smtp.addr)")
+	}
+	host, _, err := net.SplitHostPort(settings.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %q: %w", settings.Addr, err)
+	}
+	from := settings.From
+	if from == "" {
+		from = "terminal-agent@" + host
+	}
+
+	var auth smtp.Auth
+	if settings.Username != "" {
+		password := ""
+		if settings.PasswordEnv != "" {
+			password = os.Getenv(settings.PasswordEnv)
+		}
+		auth = smtp.PlainAuth("", settings.Username, password, host)
+	}
+
+	body := emailMessage(from, notification.To, msg)
+	// net/smtp has no context support, so the deadline is enforced by giving up
+	// on the send; the connection is dropped when the goroutine returns.
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(settings.Addr, auth, from, notification.To, body) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func emailMessage(from string, to []string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title()))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	// SMTP requires CRLF line endings.
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Summary, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,126 @@
+// Package notify delivers routine run results to desktop notifications, email,
+// generic JSON webhooks and Slack-compatible incoming webhooks.
+//
+// Sinks are configured per routine and globally (routines.notifications); a
+// run fires every sink whose events match its outcome. Delivery failures are
+// returned to the caller, which logs them: a broken sink never fails the run.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+)
+
+// sendTimeout bounds delivery to one sink.
+const sendTimeout = 15 * time.Second
+
+// Message is the run result delivered to a sink. The caller redacts it before
+// it gets here.
+type Message struct {
+	RoutineID   string
+	RoutineName string
+	Outcome     string // routines.Outcome*
+	Trigger     string // routines.Trigger*
+	// Changed reports whether the output differs from the previous run's.
+	Changed bool
+	// Diff is the unified diff against the previous successful run's output.
+	Diff       string
+	StartedAt  time.Time
+	Duration   time.Duration
+	TokensUsed int
+	Error      string
+	Output     string
+	// Summary is the Markdown result summary written for the run.
+	Summary    string
+	ResultPath string
+}
+
+// Title is the one-line headline used by desktop notifications, email subjects
+// and Slack messages.
+func (m Message) Title() string {
+	name := m.RoutineName
+	if name == "" {
+		name = m.RoutineID
+	}
+	title := fmt.Sprintf("Routine %s: %s", name, m.Outcome)
+	if m.Changed {
+		title += " (output changed)"
+	}
+	return title
+}
+
+// Notifier sends messages. The zero value is not usable; use New.
+type Notifier struct {
+	client *http.Client
+	// smtp is the global mail server used when a notification sets none.
+	smtp routines.SMTPSettings
+	// desktop shows a desktop notification; replaced in tests.
+	desktop func(ctx context.Context, title, body string) error
+}
+
+// New returns a Notifier that sends email through the given default SMTP
+// settings.
+func New(smtp routines.SMTPSettings) *Notifier {
+	return &Notifier{
+		client:  &http.Client{Timeout: sendTimeout},
+		smtp:    smtp,
+		desktop: showDesktop,
+	}
+}
+
+// Dispatch sends msg to every notification whose events match the run and
+// returns the joined delivery errors.
+func (n *Notifier) Dispatch(ctx context.Context, notifications []routines.Notification, msg Message) error {
+	var errs []error
+	for _, notification := range notifications {
+		if !notification.Matches(msg.Outcome, msg.Changed) {
+			continue
+		}
+		if err := n.Send(ctx, notification, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s notification: %w", notification.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Send delivers msg to one sink regardless of its events.
+func (n *Notifier) Send(ctx context.Context, notification routines.Notification, msg Message) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	switch notification.Type {
+	case routines.NotifyDesktop:
+		return n.desktop(ctx, msg.Title(), desktopBody(msg))
+	case routines.NotifyEmail:
+		return n.sendEmail(ctx, notification, msg)
+	case routines.NotifyWebhook:
+		return n.postJSON(ctx, notification, webhookPayload(msg))
+	case routines.NotifySlack:
+		return n.postJSON(ctx, notification, slackPayload(msg))
+	default:
+		return fmt.Errorf("unknown notification type %q", notification.Type)
+	}
+}
+
+// desktopBody is the short text shown under the desktop notification title.
+func desktopBody(msg Message) string {
+	text := msg.Error
+	if text == "" {
+		text = msg.Output
+	}
+	return truncate(strings.Join(strings.Fields(text), " "), 200)
+}
+
+// truncate shortens s to at most n runes, marking the cut.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage() Message {
+	return Message{
+		RoutineID:  "nightly",
+		Outcome:    routines.OutcomeFailed,
+		Trigger:    routines.TriggerScheduled,
+		StartedAt:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration:   1500 * time.Millisecond,
+		TokensUsed: 42,
+		Error:      "boom",
+		Summary:    "# Routine: nightly\n\n- Status: failed\n",
+		ResultPath: "/tmp/nightly.md",
+	}
+}
+
+func TestWebhookPostsJSONSummary(t *testing.T) {
+	var got map[string]any
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Token")
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer server.Close()
+
+	n := New(routines.SMTPSettings{})
+	err := n.Send(context.Background(), routines.Notification{Type: routines.NotifyWebhook, URL: server.URL, Headers: map[string]string{"X-Token": "t"}}, testMessage())
+	require.NoError(t, err)
+
+	assert.Equal(t, "t", header)
+	assert.Equal(t, "nightly", got["routine_id"])
+	assert.Equal(t, "failed", got["outcome"])
+	assert.Equal(t, "scheduled", got["trigger"])
+	assert.Equal(t, float64(1500), got["duration_ms"])
+	assert.Equal(t, "boom", got["error"])
+	assert.Equal(t, "2026-01-02T03:04:05Z", got["started_at"])
+	assert.Contains(t, got["summary"], "# Routine: nightly")
+}
+
+func TestSlackPostsTextAndReportsHTTPErrors(t *testing.T) {
+	var text string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		text = body["text"]
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("invalid_token"))
+	}))
+	defer server.Close()
+
+	n := New(routines.SMTPSettings{})
+	slack := routines.Notification{Type: routines.NotifySlack, URL: server.URL}
+	require.NoError(t, n.Send(context.Background(), slack, testMessage()))
+	assert.True(t, strings.HasPrefix(text, "*Routine nightly: failed*\n# Routine: nightly"), text)
+
+	status = http.StatusForbidden
+	err := n.Send(context.Background(), slack, testMessage())
+	assert.ErrorContains(t, err, "403 Forbidden: invalid_token")
+}
+
+func TestEmailSendsThroughSMTP(t *testing.T) {
+	server := startFakeSMTP(t)
+
+	n := New(routines.SMTPSettings{Addr: server.addr, From: "agent@example.com"})
+	err := n.Send(context.Background(), routines.Notification{Type: routines.NotifyEmail, To: []string{"ops@example.com"}}, testMessage())
+	require.NoError(t, err)
+
+	mail := <-server.mail
+	assert.Equal(t, "<agent@example.com>", mail.from)
+	assert.Equal(t, []string{"<ops@example.com>"}, mail.to)
+	assert.Contains(t, mail.data, "Subject: Routine nightly: failed\r\n")
+	assert.Contains(t, mail.data, "\r\n# Routine: nightly\r\n")
+}
+
+func TestEmailNeedsAServer(t *testing.T) {
+	n := New(routines.SMTPSettings{})
+	err := n.Send(context.Background(), routines.Notification{Type: routines.NotifyEmail, To: []string{"ops@example.com"}}, testMessage())
+	assert.ErrorContains(t, err, "no SMTP server configured")
+}
+
+func TestDispatchSendsOnlyMatchingNotifications(t *testing.T) {
+	var shown []string
+	n := New(routines.SMTPSettings{})
+	n.desktop = func(ctx context.Context, title, body string) error {
+		shown = append(shown, title+" | "+body)
+		return nil
+	}
+	notifications := []routines.Notification{
+		{Type: routines.NotifyDesktop},
+		{Type: routines.NotifyDesktop, On: []string{routines.EventChanged}},
+		{Type: routines.NotifyWebhook, URL: "http://127.0.0.1:1/unreachable", On: []string{routines.OutcomeSuccess}},
+	}
+
+	require.NoError(t, n.Dispatch(context.Background(), notifications, testMessage()))
+	assert.Equal(t, []string{"Routine nightly: failed | boom"}, shown)
+
+	shown = nil
+	msg := testMessage()
+	msg.Outcome, msg.Error, msg.Output, msg.Changed = routines.OutcomeSuccess, "", "disk at 91%", true
+	err := n.Dispatch(context.Background(), notifications, msg)
+	assert.Equal(t, []string{"Routine nightly: success (output changed) | disk at 91%"}, shown)
+	assert.ErrorContains(t, err, "webhook notification:", "delivery errors are reported per sink")
+}
+
+type fakeMail struct {
+	from string
+	to   []string
+	data string
+}
+
+type fakeSMTP struct {
+	addr string
+	mail chan fakeMail
+}
+
+// startFakeSMTP serves just enough SMTP for net/smtp.SendMail to deliver one
+// message without STARTTLS or auth.
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	server := &fakeSMTP{addr: listener.Addr().String(), mail: make(chan fakeMail, 1)}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+		var mail fakeMail
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case verb == "EHLO" || verb == "HELO":
+				reply("250 fake")
+			case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+				mail.from = line[len("MAIL FROM:"):]
+				reply("250 ok")
+			case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+				mail.to = append(mail.to, line[len("RCPT TO:"):])
+				reply("250 ok")
+			case verb == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					dataLine, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				mail.data = data.String()
+				reply("250 queued")
+				server.mail <- mail
+			case verb == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return server
+}
+
+func TestGVariantString(t *testing.T) {
+	assert.Equal(t, `"Routine \"nightly\" failed"`, gvariantString(`Routine "nightly" failed`))
+	assert.Equal(t, `"line\nnext\tcol \\ end"`, gvariantString("line\nnext\tcol \\ end"))
+	// Go's \x1b is not GVariant syntax; control characters use \u.
+	assert.Equal(t, `"red \u001b[31mX\u001b[0m"`, gvariantString("red \x1b[31mX\x1b[0m"))
+	assert.Equal(t, `"café 😀"`, gvariantString("café \U0001F600"))
+	assert.Equal(t, `"ab�"`, gvariantString("a\x00b\xff"))
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/routines"
+)
+
+// slackTextLimit keeps Slack messages well under the 40k character cap while
+// leaving room for a readable summary.
+const slackTextLimit = 3500
+
+// webhookBody is the JSON document posted to generic webhooks.
+type webhookBody struct {
+	RoutineID   string `json:"routine_id"`
+	RoutineName string `json:"routine_name,omitempty"`
+	Outcome     string `json:"outcome"`
+	Trigger     string `json:"trigger,omitempty"`
+	Changed     bool   `json:"changed"`
+	Diff        string `json:"diff,omitempty"`
+	StartedAt   string `json:"started_at,omitempty"`
+	DurationMS  int64  `json:"duration_ms"`
+	TokensUsed  int    `json:"tokens_used"`
+	Error       string `json:"error,omitempty"`
+	Output      string `json:"output,omitempty"`
+	Summary     string `json:"summary"`
+	ResultPath  string `json:"result_path,omitempty"`
+}
+
+func webhookPayload(msg Message) any {
+	body := webhookBody{
+		RoutineID:   msg.RoutineID,
+		RoutineName: msg.RoutineName,
+		Outcome:     msg.Outcome,
+		Trigger:     msg.Trigger,
+		Changed:     msg.Changed,
+		Diff:        msg.Diff,
+		DurationMS:  msg.Duration.Milliseconds(),
+		TokensUsed:  msg.TokensUsed,
+		Error:       msg.Error,
+		Output:      msg.Output,
+		Summary:     msg.Summary,
+		ResultPath:  msg.ResultPath,
+	}
+	if !msg.StartedAt.IsZero() {
+		body.StartedAt = msg.StartedAt.Format(time.RFC3339)
+	}
+	return body
+}
+
+// slackPayload is the incoming-webhook shape understood by Slack and the chat
+// tools that mimic it (Mattermost, Rocket.Chat, Discord's /slack endpoint).
+func slackPayload(msg Message) any {
+	return map[string]string{"text": fmt.Sprintf("*%s*\n%s", msg.Title(), truncate(msg.Summary, slackTextLimit))}
+}
+
+func (n *Notifier) postJSON(ctx context.Context, notification routines.Notification, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range notification.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Redacted(), resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
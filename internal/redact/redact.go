@@ -0,0 +1,225 @@
+// Package redact masks secrets — API keys, cloud credentials, tokens and
+// private keys — before text is sent to a model or written to disk.
+//
+// A Redactor is created per run and counts what it replaced so the run summary
+// can report it. Replacements look like [REDACTED:<detector>], which no
+// detector matches again, so text can safely pass through several redaction
+// points (agent, session log, history) without being counted twice.
+package redact
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/laszukdawid/terminal-agent/internal/config"
+)
+
+const (
+	markerPrefix = "[REDACTED:"
+	secretGroup  = "secret"
+)
+
+type detector struct {
+	name string
+	re   *regexp.Regexp
+	// group is the submatch replaced; 0 replaces the whole match.
+	group int
+	// valid optionally rejects matches that are unlikely to be secrets.
+	valid func(string) bool
+}
+
+type builtinDetector struct {
+	name    string
+	pattern string
+	valid   func(string) bool
+}
+
+// builtinDetectors are ordered so specific detectors run before the generic
+// assignment detector, which would otherwise claim their matches.
+var builtinDetectors = []builtinDetector{
+	{name: "private_key", pattern: `(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----.*?-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`},
+	{name: "aws_access_key_id", pattern: `\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`},
+	{name: "aws_secret_access_key", pattern: `(?i)\baws_?secret_?(?:access_?)?key\b["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})(?:[^A-Za-z0-9/+=]|\z)`},
+	{name: "jwt", pattern: `\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}`},
+	{name: "anthropic_api_key", pattern: `\bsk-ant-[A-Za-z0-9_-]{20,}`},
+	{name: "openai_api_key", pattern: `\bsk-(?:proj-|svcacct-|admin-)?[A-Za-z0-9_-]{20,}`},
+	{name: "github_token", pattern: `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`},
+	{name: "slack_token", pattern: `\bxox[abprs]-[A-Za-z0-9-]{10,}`},
+	{name: "google_api_key", pattern: `\bAIza[0-9A-Za-z_-]{35}\b`},
+	{name: "stripe_key", pattern: `\b[rs]k_(?:live|test)_[0-9A-Za-z]{16,}\b`},
+	{name: "bearer_token", pattern: `(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]{20,}=*)`},
+	// Generic KEY=value assignments as printed by printenv or found in .env and
+	// YAML files. The value must end the token and mix letters and digits so
+	// code such as token = fetch_token() is left intact.
+	{
+		name:    "secret_assignment",
+		pattern: `(?i)\b[A-Z0-9_.-]*(?:api_?key|secret|token|passw(?:or)?d)[A-Z0-9_.-]*["']?[ \t]*[:=][ \t]*["']?(?P<secret>[^\s"',;()]{8,})(?:[\s"',;]|\z)`,
+		valid:   hasLetterAndDigit,
+	},
+}
+
+func hasLetterAndDigit(value string) bool {
+	return strings.ContainsAny(value, "0123456789") && strings.IndexFunc(value, func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	}) >= 0
+}
+
+// Redactor replaces secrets in text and counts replacements per detector. A
+// nil Redactor leaves text unchanged, so callers need no enabled checks. It is
+// safe for concurrent use.
+type Redactor struct {
+	detectors []detector
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// New builds a Redactor from the built-in detectors plus custom patterns.
+func New(custom ...config.RedactionPattern) (*Redactor, error) {
+	r := &Redactor{counts: map[string]int{}}
+	for _, builtin := range builtinDetectors {
+		re := regexp.MustCompile(builtin.pattern)
+		r.detectors = append(r.detectors, detector{name: builtin.name, re: re, group: max(re.SubexpIndex(secretGroup), 0), valid: builtin.valid})
+	}
+	for i, pattern := range custom {
+		name := strings.TrimSpace(pattern.Name)
+		if name == "" {
+			name = fmt.Sprintf("custom_%d", i+1)
+		}
+		if pattern.Pattern == "" {
+			return nil, fmt.Errorf("redaction pattern %q is empty", name)
+		}
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", name, err)
+		}
+		r.detectors = append(r.detectors, detector{name: name, re: re, group: max(re.SubexpIndex(secretGroup), 0)})
+	}
+	return r, nil
+}
+
+// Load builds the Redactor configured in the global config. It returns nil
+// when redaction is disabled.
+func Load() (*Redactor, error) {
+	cfg, err := config.LoadRedactionConfig()
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.IsEnabled() {
+		return nil, nil
+	}
+	return New(cfg.Patterns...)
+}
+
+// String returns text with every detected secret replaced.
+func (r *Redactor) String(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	for _, d := range r.detectors {
+		text = r.apply(d, text)
+	}
+	return text
+}
+
+func (r *Redactor) apply(d detector, text string) string {
+	matches := d.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last, replaced := 0, 0
+	for _, match := range matches {
+		start, end := match[2*d.group], match[2*d.group+1]
+		if start < 0 || start == end || strings.HasPrefix(text[start:end], markerPrefix) || (d.valid != nil && !d.valid(text[start:end])) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(markerPrefix + d.name + "]")
+		last = end
+		replaced++
+	}
+	if replaced == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+
+	r.mu.Lock()
+	r.counts[d.name] += replaced
+	r.mu.Unlock()
+	return b.String()
+}
+
+// Value redacts strings inside tool inputs and other decoded JSON values,
+// returning a copy; non-string leaves are returned unchanged.
+func (r *Redactor) Value(value any) any {
+	if r == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case string:
+		return r.String(v)
+	case map[string]any:
+		return r.Map(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = r.Value(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// Map redacts the string values of a map, returning a copy.
+func (r *Redactor) Map(values map[string]any) map[string]any {
+	if r == nil || values == nil {
+		return values
+	}
+	out := make(map[string]any, len(values))
+	for key, value := range values {
+		out[key] = r.Value(value)
+	}
+	return out
+}
+
+// Counts returns the number of replacements per detector so far.
+func (r *Redactor) Counts() map[string]int {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.counts) == 0 {
+		return nil
+	}
+	return maps.Clone(r.counts)
+}
+
+// Total returns the number of replacements in counts.
+func Total(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
+
+// Summary renders counts for run summaries, e.g.
+// "3 (aws_access_key_id: 2, jwt: 1)". It is empty when nothing was redacted.
+func Summary(counts map[string]int) string {
+	total := Total(counts)
+	if total == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(counts))
+	for _, name := range slices.Sorted(maps.Keys(counts)) {
+		parts = append(parts, fmt.Sprintf("%s: %d", name, counts[name]))
+	}
+	return fmt.Sprintf("%d (%s)", total, strings.Join(parts, ", "))
+}
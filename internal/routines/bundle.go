@@ -0,0 +1,183 @@
+package routines
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// BundleVersion is the version of the bundle format written by NewBundle.
+const BundleVersion = 1
+
+// Conflict policies decide what Import does with a bundled routine whose id is
+// already taken.
+const (
+	// ConflictSkip keeps the existing routine and drops the bundled one (the
+	// default).
+	ConflictSkip = "skip"
+	// ConflictRename imports the bundled routine under a free id.
+	ConflictRename = "rename"
+	// ConflictOverwrite replaces the existing routine.
+	ConflictOverwrite = "overwrite"
+)
+
+// Import actions, as reported in ImportResult.Action.
+const (
+	ImportAdded       = "added"
+	ImportRenamed     = "renamed"
+	ImportOverwritten = "overwritten"
+	ImportSkipped     = "skipped"
+)
+
+// Bundle is a portable set of routine definitions, for sharing routines between
+// machines. It has the shape of the definitions file, so that file can be
+// imported as a bundle too.
+type Bundle struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at,omitzero"`
+	Routines   []Routine `json:"routines"`
+}
+
+// NewBundle packs routines into a bundle, dropping the timestamps that only
+// mean something on this machine.
+func NewBundle(list []Routine, now time.Time) Bundle {
+	bundle := Bundle{Version: BundleVersion, ExportedAt: now.UTC(), Routines: make([]Routine, 0, len(list))}
+	for _, r := range list {
+		r.CreatedAt, r.UpdatedAt = time.Time{}, time.Time{}
+		bundle.Routines = append(bundle.Routines, r)
+	}
+	return bundle
+}
+
+// ParseBundle decodes a bundle, rejecting versions newer than this build
+// understands. A definitions file (no version) is read as version 1.
+func ParseBundle(data []byte) (Bundle, error) {
+	var bundle Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return Bundle{}, fmt.Errorf("parse routine bundle: %w", err)
+	}
+	if bundle.Version > BundleVersion {
+		return Bundle{}, fmt.Errorf("routine bundle version %d is newer than supported version %d", bundle.Version, BundleVersion)
+	}
+	if len(bundle.Routines) == 0 {
+		return Bundle{}, fmt.Errorf("routine bundle has no routines")
+	}
+	return bundle, nil
+}
+
+// ImportResult reports what Import did with one bundled routine.
+type ImportResult struct {
+	ID string // id the routine was stored under
+	// BundleID is the routine's id in the bundle; it differs from ID when the
+	// routine was renamed.
+	BundleID string
+	Action   string // Import*
+}
+
+// Import stores bundled routines, resolving id conflicts with onConflict (a
+// Conflict* policy; empty = skip). Renamed routines keep working together:
+// triggers and steps in the bundle that name a renamed routine are pointed at
+// its new id. Every routine is validated before anything is written, so an
+// invalid bundle imports nothing.
+func (s *Store) Import(bundled []Routine, onConflict string) ([]ImportResult, error) {
+	switch onConflict {
+	case "", ConflictSkip, ConflictRename, ConflictOverwrite:
+	default:
+		return nil, fmt.Errorf("unknown conflict policy %q (want skip, rename or overwrite)", onConflict)
+	}
+	seen := map[string]bool{}
+	for _, r := range bundled {
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("routine %q: %w", r.ID, err)
+		}
+		if seen[r.ID] {
+			return nil, fmt.Errorf("routine bundle lists %q more than once", r.ID)
+		}
+		seen[r.ID] = true
+	}
+
+	var results []ImportResult
+	now := time.Now().UTC()
+	err := withLock(s.lockPath(), func() error {
+		file, err := s.load()
+		if err != nil {
+			return err
+		}
+		results = make([]ImportResult, 0, len(bundled))
+		renamed := map[string]string{}
+		taken := func(id string) bool { return indexByID(file.Routines, id) >= 0 || seen[id] }
+		for _, r := range bundled {
+			result := ImportResult{ID: r.ID, BundleID: r.ID, Action: ImportAdded}
+			if indexByID(file.Routines, r.ID) >= 0 {
+				switch onConflict {
+				case ConflictRename:
+					result.ID, result.Action = freeID(r.ID, taken), ImportRenamed
+					renamed[r.ID] = result.ID
+					seen[result.ID] = true
+				case ConflictOverwrite:
+					result.Action = ImportOverwritten
+				default:
+					result.Action = ImportSkipped
+				}
+			}
+			results = append(results, result)
+		}
+
+		for i, r := range bundled {
+			result := results[i]
+			if result.Action == ImportSkipped {
+				continue
+			}
+			r.ID = result.ID
+			r.Triggers = retargetTriggers(r.Triggers, renamed)
+			r.Steps = retargetSteps(r.Steps, renamed)
+			r.CreatedAt, r.UpdatedAt = now, now
+			if idx := indexByID(file.Routines, r.ID); idx >= 0 {
+				r.CreatedAt = file.Routines[idx].CreatedAt
+				file.Routines[idx] = r
+				continue
+			}
+			file.Routines = append(file.Routines, r)
+		}
+		return writeJSONAtomic(s.path, file)
+	})
+	return results, err
+}
+
+// freeID returns id with the lowest numeric suffix that is not taken.
+func freeID(id string, taken func(string) bool) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", id, i)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+func retargetTriggers(triggers []Trigger, renamed map[string]string) []Trigger {
+	if len(renamed) == 0 || len(triggers) == 0 {
+		return triggers
+	}
+	out := make([]Trigger, len(triggers))
+	for i, t := range triggers {
+		if id, ok := renamed[t.Routine]; ok && t.Type == TriggerRoutine {
+			t.Routine = id
+		}
+		out[i] = t
+	}
+	return out
+}
+
+func retargetSteps(steps []Step, renamed map[string]string) []Step {
+	if len(renamed) == 0 || len(steps) == 0 {
+		return steps
+	}
+	out := make([]Step, len(steps))
+	for i, step := range steps {
+		if id, ok := renamed[step.Routine]; ok {
+			step.Routine = id
+		}
+		out[i] = step
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package routines
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Notification sink types.
+const (
+	NotifyDesktop = "desktop"
+	NotifyEmail   = "email"
+	NotifyWebhook = "webhook"
+	NotifySlack   = "slack"
+)
+
+// EventChanged is a notification event that fires when a successful run's
+// output differs from the previous successful run's output.
+const EventChanged = "changed"
+
+// defaultNotifyEvents apply when a Notification leaves On empty: only runs that
+// did not succeed are worth interrupting someone for.
+var defaultNotifyEvents = []string{OutcomeFailed, OutcomeTimeout, OutcomeTokenExceeded}
+
+// Notification routes a run's result summary to a sink. Routines carry their own
+// notifications; the global config can add more that apply to every routine.
+type Notification struct {
+	Type string `json:"type"` // desktop | email | webhook | slack
+	// On lists the outcomes (success, failed, timeout, token_exceeded) and/or
+	// "changed" that trigger the notification; empty = failed, timeout and
+	// token_exceeded.
+	On []string `json:"on,omitempty"`
+	// URL is the endpoint for webhook and slack notifications.
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// To lists email recipients. SMTP overrides the global routines.smtp
+	// settings for this notification.
+	To   []string      `json:"to,omitempty"`
+	SMTP *SMTPSettings `json:"smtp,omitempty"`
+}
+
+// SMTPSettings configure the mail server email notifications are sent through.
+// The password is read from the environment variable named by PasswordEnv so it
+// never lands in a config file.
+type SMTPSettings struct {
+	Addr        string `json:"addr,omitempty"` // host:port
+	From        string `json:"from,omitempty"`
+	Username    string `json:"username,omitempty"`
+	PasswordEnv string `json:"password_env,omitempty"`
+}
+
+// Events returns the events the notification fires on, applying the default.
+func (n Notification) Events() []string {
+	if len(n.On) == 0 {
+		return defaultNotifyEvents
+	}
+	return n.On
+}
+
+// Describe renders the notification for show displays and import notes.
+func (n Notification) Describe() string {
+	target := ""
+	switch n.Type {
+	case NotifyWebhook, NotifySlack:
+		// Webhook paths often embed the credential, so only the host is shown.
+		if u, err := url.Parse(n.URL); err == nil {
+			target = " " + u.Host
+		}
+	case NotifyEmail:
+		target = " " + strings.Join(n.To, ", ")
+	}
+	return fmt.Sprintf("%s%s on %s", n.Type, target, strings.Join(n.Events(), ", "))
+}
+
+// Matches reports whether a run with the given outcome fires the notification.
+// changed reports whether the output differs from the previous run's.
+func (n Notification) Matches(outcome string, changed bool) bool {
+	events := n.Events()
+	return slices.Contains(events, outcome) || (changed && slices.Contains(events, EventChanged))
+}
+
+// Validate checks that the notification names a known sink, known events and
+// the fields its sink needs.
+func (n Notification) Validate() error {
+	for _, event := range n.On {
+		if unknownEvent(event) {
+			return fmt.Errorf("unknown notification event %q (want success, failed, timeout, token_exceeded or changed)", event)
+		}
+	}
+	switch n.Type {
+	case NotifyDesktop:
+		return nil
+	case NotifyWebhook, NotifySlack:
+		u, err := url.Parse(n.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s notification needs an http(s) url, got %q", n.Type, n.URL)
+		}
+		return nil
+	case NotifyEmail:
+		if len(n.To) == 0 {
+			return fmt.Errorf("email notification needs at least one recipient")
+		}
+		for _, to := range n.To {
+			if _, err := mail.ParseAddress(to); err != nil {
+				return fmt.Errorf("email notification recipient %q is not an address", to)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown notification type %q (want desktop, email, webhook or slack)", n.Type)
+	}
+}
+
+func unknownEvent(event string) bool {
+	switch event {
+	case OutcomeSuccess, OutcomeFailed, OutcomeTimeout, OutcomeTokenExceeded, EventChanged:
+		return false
+	}
+	return true
+}
+
+// ParseNotification parses the compact CLI form of a notification:
+// "<type>[:<target>][@<event>,<event>...]". The target is the URL for webhook
+// and slack, and a comma-separated recipient list for email, e.g.
+// "slack:https://hooks.slack.com/services/X@failed,changed" or
+// "email:ops@example.com@failed". Email notifications are sent through the
+// global routines.smtp settings.
+//
+// Targets may hold "@" themselves, e.g. "email:ops@localhost", so the text
+// after the last "@" is taken as the events only when it is a list of known
+// events, or when there is no target for it to belong to.
+func ParseNotification(spec string) (Notification, error) {
+	spec = strings.TrimSpace(spec)
+	var n Notification
+	if at := strings.LastIndex(spec, "@"); at >= 0 {
+		var events []string
+		for _, event := range strings.Split(spec[at+1:], ",") {
+			if event = strings.TrimSpace(event); event != "" {
+				events = append(events, event)
+			}
+		}
+		if !strings.Contains(spec[:at], ":") || (len(events) > 0 && !slices.ContainsFunc(events, unknownEvent)) {
+			n.On = events
+			spec = spec[:at]
+		}
+	}
+	kind, target, _ := strings.Cut(spec, ":")
+	n.Type = strings.TrimSpace(kind)
+	target = strings.TrimSpace(target)
+	switch n.Type {
+	case NotifyWebhook, NotifySlack:
+		n.URL = target
+	case NotifyEmail:
+		for _, to := range strings.Split(target, ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				n.To = append(n.To, to)
+			}
+		}
+	}
+	if err := n.Validate(); err != nil {
+		return Notification{}, err
+	}
+	return n, nil
+}
@@ -0,0 +1,64 @@
+package routines
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// paramPattern keeps parameter names usable as {{.Params.<name>}}.
+var paramPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Param is a declared input of a routine prompt, read as {{.Params.<name>}}.
+// Manual runs can set it; scheduled and event-triggered runs use the default.
+type Param struct {
+	Name        string `json:"name"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func (r Routine) validateParams() error {
+	seen := map[string]bool{}
+	for _, p := range r.Params {
+		if !paramPattern.MatchString(p.Name) {
+			return fmt.Errorf("parameter name %q must match %s", p.Name, paramPattern.String())
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("duplicate parameter %q", p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return nil
+}
+
+// ResolveParams returns the value of every declared parameter: the given value
+// when set, else the default. Values for undeclared parameters are an error.
+func (r Routine) ResolveParams(given map[string]string) (map[string]string, error) {
+	values := make(map[string]string, len(r.Params))
+	names := make([]string, 0, len(r.Params))
+	for _, p := range r.Params {
+		values[p.Name] = p.Default
+		names = append(names, p.Name)
+	}
+	for name, value := range given {
+		if !slices.Contains(names, name) {
+			if len(names) == 0 {
+				return nil, fmt.Errorf("routine %q declares no parameters, got %q", r.ID, name)
+			}
+			return nil, fmt.Errorf("unknown parameter %q for routine %q (declared: %s)", name, r.ID, strings.Join(names, ", "))
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// ParseParam splits a "name=value" argument.
+func ParseParam(spec string) (string, string, error) {
+	name, value, ok := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !ok || !paramPattern.MatchString(name) {
+		return "", "", fmt.Errorf("parameter %q must be name=value", spec)
+	}
+	return name, value, nil
+}
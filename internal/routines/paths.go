@@ -43,3 +43,20 @@ func StatePath() string {
 func LogDir(id string) string {
 	return filepath.Join(DataDir(), id, "logs")
 }
+
+// ReadResultOutput returns the output section of a run's result summary, or ""
+// when the summary cannot be read.
+func ReadResultOutput(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	_, output, found := strings.Cut(string(data), "\n## Output\n\n")
+	if !found {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
@@ -0,0 +1,187 @@
+package routines
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Catch-up policies decide what the daemon does about scheduled runs it missed
+// while the machine was asleep or the daemon was stopped.
+const (
+	// CatchUpSkip drops missed runs (the default).
+	CatchUpSkip = "skip"
+	// CatchUpOnce runs the routine once for any number of missed runs.
+	CatchUpOnce = "once"
+	// CatchUpAll runs the routine once per missed run, up to MaxCatchUpRuns.
+	CatchUpAll = "all"
+)
+
+// MaxCatchUpRuns bounds how many missed runs CatchUpAll queues for a routine.
+const MaxCatchUpRuns = 24
+
+// QueuedRun is a routine run waiting in the daemon's queue, or running after
+// the daemon took it off the queue.
+type QueuedRun struct {
+	ID        string        `json:"id"`
+	RoutineID string        `json:"routine_id"`
+	Trigger   string        `json:"trigger"`
+	Event     *TriggerEvent `json:"event,omitempty"`
+	Priority  int           `json:"priority,omitempty"`
+	// Provider is the routine's resolved provider, for per-provider caps.
+	Provider   string    `json:"provider,omitempty"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	// MissedAt is the scheduled time a catch-up run stands in for.
+	MissedAt time.Time `json:"missed_at,omitempty"`
+	// StartedAt is set once the daemon starts the run.
+	StartedAt time.Time `json:"started_at,omitempty"`
+}
+
+// Running reports whether the daemon has started the run.
+func (q QueuedRun) Running() bool {
+	return !q.StartedAt.IsZero()
+}
+
+type queueFile struct {
+	Runs []QueuedRun `json:"runs,omitempty"`
+}
+
+// QueueStore persists the daemon's run queue to a JSON file (data dir), so
+// queued runs survive a daemon restart.
+type QueueStore struct {
+	path string
+}
+
+// NewQueueStore returns a QueueStore backed by the given file path.
+func NewQueueStore(path string) *QueueStore {
+	return &QueueStore{path: path}
+}
+
+// DefaultQueueStore returns a QueueStore backed by the standard queue path.
+func DefaultQueueStore() *QueueStore {
+	return NewQueueStore(QueuePath())
+}
+
+func (s *QueueStore) lockPath() string {
+	return s.path + ".lock"
+}
+
+func (s *QueueStore) load() (queueFile, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(bytes.TrimSpace(data)) == 0) {
+		return queueFile{}, nil
+	}
+	if err != nil {
+		return queueFile{}, err
+	}
+	var file queueFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return queueFile{}, err
+	}
+	return file, nil
+}
+
+func (s *QueueStore) update(fn func(file *queueFile) error) error {
+	return withLock(s.lockPath(), func() error {
+		file, err := s.load()
+		if err != nil {
+			return err
+		}
+		if err := fn(&file); err != nil {
+			return err
+		}
+		return writeJSONAtomic(s.path, file)
+	})
+}
+
+// List returns the queue in dispatch order: running runs first, then waiting
+// runs by descending priority and, within a priority, first in first out.
+func (s *QueueStore) List() ([]QueuedRun, error) {
+	file, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(file.Runs, func(a, b QueuedRun) int {
+		switch {
+		case a.Running() != b.Running():
+			if a.Running() {
+				return -1
+			}
+			return 1
+		case a.Priority != b.Priority:
+			return b.Priority - a.Priority
+		default:
+			return a.EnqueuedAt.Compare(b.EnqueuedAt)
+		}
+	})
+	return file.Runs, nil
+}
+
+// Push adds a run to the queue, assigning its ID, and returns it and whether it
+// was added. With coalesce set, a run is not added when the routine already
+// waits in the queue with the same trigger and no event payload, so a schedule
+// that fires faster than the routine runs does not pile up copies.
+func (s *QueueStore) Push(run QueuedRun, coalesce bool) (QueuedRun, bool, error) {
+	added := false
+	err := s.update(func(file *queueFile) error {
+		if coalesce && run.Event == nil && slices.ContainsFunc(file.Runs, func(q QueuedRun) bool {
+			return !q.Running() && q.RoutineID == run.RoutineID && q.Trigger == run.Trigger && q.Event == nil
+		}) {
+			return nil
+		}
+		if run.ID == "" {
+			run.ID = uuid.NewString()
+		}
+		file.Runs = append(file.Runs, run)
+		added = true
+		return nil
+	})
+	return run, added, err
+}
+
+// Start marks a queued run as running.
+func (s *QueueStore) Start(id string, at time.Time) error {
+	return s.update(func(file *queueFile) error {
+		for i := range file.Runs {
+			if file.Runs[i].ID == id {
+				file.Runs[i].StartedAt = at
+			}
+		}
+		return nil
+	})
+}
+
+// Remove drops a run from the queue.
+func (s *QueueStore) Remove(id string) error {
+	return s.update(func(file *queueFile) error {
+		file.Runs = slices.DeleteFunc(file.Runs, func(q QueuedRun) bool { return q.ID == id })
+		return nil
+	})
+}
+
+// DropRunning removes runs marked running, left behind by a daemon that
+// stopped before they finished, and returns them.
+func (s *QueueStore) DropRunning() ([]QueuedRun, error) {
+	var dropped []QueuedRun
+	err := s.update(func(file *queueFile) error {
+		file.Runs = slices.DeleteFunc(file.Runs, func(q QueuedRun) bool {
+			if q.Running() {
+				dropped = append(dropped, q)
+			}
+			return q.Running()
+		})
+		return nil
+	})
+	return dropped, err
+}
+
+// QueuePath is the daemon's run queue file in the data dir.
+func QueuePath() string {
+	return filepath.Join(DataDir(), "queue.json")
+}
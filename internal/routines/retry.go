@@ -0,0 +1,156 @@
+package routines
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Retry defaults, applied to fields a RetryPolicy leaves unset.
+const (
+	DefaultRetryBackoff    = 30 * time.Second
+	DefaultRetryMaxBackoff = 10 * time.Minute
+)
+
+// RetryPolicy re-runs an unattended routine whose run ended with a retryable
+// outcome. Attempts wait Backoff, doubling after each attempt up to MaxBackoff.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first; 0 or 1
+	// means no retries.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Backoff is the Go duration to wait before the first retry; empty = 30s.
+	Backoff string `json:"backoff,omitempty"`
+	// MaxBackoff caps the doubling backoff; empty = 10m.
+	MaxBackoff string `json:"max_backoff,omitempty"`
+	// On lists the outcomes that are retried; empty = failed and timeout.
+	On []string `json:"on,omitempty"`
+}
+
+// Attempts returns the total number of attempts the policy allows (at least 1).
+func (p *RetryPolicy) Attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// Retryable reports whether a run that ended with outcome should be retried.
+func (p *RetryPolicy) Retryable(outcome string) bool {
+	if p == nil || outcome == OutcomeSuccess {
+		return false
+	}
+	if len(p.On) == 0 {
+		return outcome == OutcomeFailed || outcome == OutcomeTimeout
+	}
+	return slices.Contains(p.On, outcome)
+}
+
+// Delay returns how long to wait after the given (1-based) attempt before the
+// next one.
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	if p == nil {
+		return 0
+	}
+	delay := parseDurationOr(p.Backoff, DefaultRetryBackoff)
+	limit := parseDurationOr(p.MaxBackoff, DefaultRetryMaxBackoff)
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// Validate checks the attempt count, durations and outcomes.
+func (p *RetryPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("retry max_attempts cannot be negative")
+	}
+	for _, value := range []string{p.Backoff, p.MaxBackoff} {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return fmt.Errorf("invalid retry backoff %q", value)
+		}
+	}
+	for _, outcome := range p.On {
+		switch outcome {
+		case OutcomeFailed, OutcomeTimeout, OutcomeTokenExceeded:
+		default:
+			return fmt.Errorf("unknown retry outcome %q (want failed, timeout or token_exceeded)", outcome)
+		}
+	}
+	return nil
+}
+
+func parseDurationOr(value string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(value)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+// Attempt is one try of a routine run. A run that is retried records an
+// attempt for each try.
+type Attempt struct {
+	Number     int       `json:"number"`
+	StartedAt  time.Time `json:"started_at"`
+	Outcome    string    `json:"outcome"`
+	Duration   string    `json:"duration,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	SessionLog string    `json:"session_log,omitempty"`
+	// NextRetryAt is when the next attempt starts; zero for the last one.
+	NextRetryAt time.Time `json:"next_retry_at,omitempty"`
+}
+
+// QuietHours is a daily window, in local time, during which the daemon does not
+// start scheduled runs. The window may wrap past midnight.
+type QuietHours struct {
+	start, end time.Duration // offsets from midnight
+	set        bool
+}
+
+// ParseQuietHours parses "HH:MM-HH:MM", e.g. "22:00-07:00". An empty string
+// yields no quiet hours.
+func ParseQuietHours(spec string) (QuietHours, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return QuietHours{}, nil
+	}
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		return QuietHours{}, fmt.Errorf("quiet hours %q must be HH:MM-HH:MM", spec)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return QuietHours{}, fmt.Errorf("quiet hours %q: %w", spec, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return QuietHours{}, fmt.Errorf("quiet hours %q: %w", spec, err)
+	}
+	return QuietHours{start: start, end: end, set: start != end}, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", strings.TrimSpace(value))
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the quiet window.
+func (q QuietHours) Contains(t time.Time) bool {
+	if !q.set {
+		return false
+	}
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if q.start < q.end {
+		return offset >= q.start && offset < q.end
+	}
+	return offset >= q.start || offset < q.end
+}
@@ -17,22 +17,46 @@ import (
 // built-in product defaults. Durations are Go duration strings; pointer fields
 // distinguish "unset" from a deliberate zero.
 type Routine struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name,omitempty"`
-	Prompt       string    `json:"prompt"`
-	Schedule     string    `json:"schedule,omitempty"` // cron expression; empty = manual-only
-	Provider     string    `json:"provider,omitempty"`
-	Model        string    `json:"model,omitempty"`
-	Timeout      string    `json:"timeout,omitempty"` // Go duration; "0" = unlimited
-	TokenBudget  *int      `json:"token_budget,omitempty"`
-	MaxTurns     *int      `json:"max_turns,omitempty"`
-	MaxToolCalls *int      `json:"max_tool_calls,omitempty"`
-	WorkingDir   string    `json:"working_dir,omitempty"`
-	Tools        []string  `json:"tools,omitempty"` // enabled tool names; nil = default policy (external off)
-	Deny         []string  `json:"deny,omitempty"`  // routine-scoped deny rules, highest priority
-	Enabled      bool      `json:"enabled"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Prompt   string `json:"prompt"`
+	Schedule string `json:"schedule,omitempty"` // cron expression; empty = manual-only
+	// Triggers fire the routine on events (file changes, webhooks, other
+	// routines, system events) in addition to its Schedule.
+	Triggers []Trigger `json:"triggers,omitempty"`
+	// Steps turn the routine into a workflow: a DAG of inline prompts and other
+	// routines run instead of Prompt.
+	Steps []Step `json:"steps,omitempty"`
+	// Params declares the prompt's inputs, with their defaults.
+	Params       []Param  `json:"params,omitempty"`
+	Provider     string   `json:"provider,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Timeout      string   `json:"timeout,omitempty"` // Go duration; "0" = unlimited
+	TokenBudget  *int     `json:"token_budget,omitempty"`
+	MaxTurns     *int     `json:"max_turns,omitempty"`
+	MaxToolCalls *int     `json:"max_tool_calls,omitempty"`
+	WorkingDir   string   `json:"working_dir,omitempty"`
+	Tools        []string `json:"tools,omitzero"` // enabled tool names; nil = default policy (external and remember off), empty = none
+	Deny         []string `json:"deny,omitempty"` // routine-scoped deny rules, highest priority
+	// Notify lists where run results are sent, in addition to the global
+	// routines.notifications.
+	Notify []Notification `json:"notify,omitempty"`
+	// OnlyNotifyOnChange suppresses notifications for successful runs whose
+	// output matches the previous successful run's.
+	OnlyNotifyOnChange bool `json:"only_notify_on_change,omitempty"`
+	// Retry re-runs scheduled and event-triggered runs that fail.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// DisableAfter disables the routine after this many consecutive failed
+	// runs; 0 = never.
+	DisableAfter int `json:"disable_after,omitempty"`
+	// Priority orders the daemon's run queue; higher runs first.
+	Priority int `json:"priority,omitempty"`
+	// CatchUp is what the daemon does about scheduled runs missed while the
+	// machine slept or the daemon was stopped: skip (default), once or all.
+	CatchUp   string    `json:"catch_up,omitempty"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
 }
 
 // idPattern constrains routine IDs to filesystem- and unit-name-safe slugs so
@@ -41,7 +65,7 @@ var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
 
 // Validate checks the invariants a routine must satisfy to be stored.
 func (r Routine) Validate() error {
-	if strings.TrimSpace(r.Prompt) == "" {
+	if strings.TrimSpace(r.Prompt) == "" && !r.IsWorkflow() {
 		return fmt.Errorf("routine prompt cannot be empty")
 	}
 	if !idPattern.MatchString(r.ID) {
@@ -50,13 +74,47 @@ func (r Routine) Validate() error {
 	if err := ValidateSchedule(r.Schedule); err != nil {
 		return err
 	}
+	if err := r.validateSteps(); err != nil {
+		return err
+	}
+	if err := r.validateParams(); err != nil {
+		return err
+	}
+	if err := r.Retry.Validate(); err != nil {
+		return err
+	}
+	if r.DisableAfter < 0 {
+		return fmt.Errorf("disable_after cannot be negative")
+	}
+	switch r.CatchUp {
+	case "", CatchUpSkip, CatchUpOnce, CatchUpAll:
+	default:
+		return fmt.Errorf("unknown catch_up policy %q (want skip, once or all)", r.CatchUp)
+	}
+	for _, t := range r.Triggers {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+		if t.Type == TriggerRoutine && t.Routine == r.ID {
+			return fmt.Errorf("routine %q cannot trigger itself", r.ID)
+		}
+	}
+	for _, n := range r.Notify {
+		if err := n.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // PromptPreview returns the first n characters of the prompt (rune-safe),
-// collapsed to a single line, for list displays.
+// collapsed to a single line, for list displays. Workflows show their step
+// count instead.
 func (r Routine) PromptPreview(n int) string {
 	preview := strings.Join(strings.Fields(r.Prompt), " ")
+	if r.IsWorkflow() {
+		preview = fmt.Sprintf("workflow: %d steps", len(r.Steps))
+	}
 	runes := []rune(preview)
 	if len(runes) <= n {
 		return preview
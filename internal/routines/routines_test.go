@@ -268,3 +268,401 @@ func TestRoutineValidateRejectsBadCron(t *testing.T) {
 	assert.Error(t, Routine{ID: "r", Prompt: "p", Schedule: "nope"}.Validate())
 	assert.NoError(t, Routine{ID: "r", Prompt: "p", Schedule: "@daily"}.Validate())
 }
+
+func TestParseNotification(t *testing.T) {
+	n, err := ParseNotification("slack:https://hooks.slack.com/services/T0/B0/x@failed,changed")
+	require.NoError(t, err)
+	assert.Equal(t, Notification{Type: NotifySlack, URL: "https://hooks.slack.com/services/T0/B0/x", On: []string{OutcomeFailed, EventChanged}}, n)
+
+	n, err = ParseNotification("email:ops@example.com,dev@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ops@example.com", "dev@example.com"}, n.To)
+	assert.Nil(t, n.On)
+
+	n, err = ParseNotification("email:ops@localhost")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ops@localhost"}, n.To)
+	assert.Nil(t, n.On)
+
+	n, err = ParseNotification("email:ops@localhost@failed,timeout")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ops@localhost"}, n.To)
+	assert.Equal(t, []string{OutcomeFailed, OutcomeTimeout}, n.On)
+
+	n, err = ParseNotification("desktop@success")
+	require.NoError(t, err)
+	assert.Equal(t, Notification{Type: NotifyDesktop, On: []string{OutcomeSuccess}}, n)
+
+	_, err = ParseNotification("pager:x")
+	assert.ErrorContains(t, err, `unknown notification type "pager"`)
+	_, err = ParseNotification("webhook:ftp://example.com")
+	assert.ErrorContains(t, err, "needs an http(s) url")
+	_, err = ParseNotification("desktop@sometimes")
+	assert.ErrorContains(t, err, `unknown notification event "sometimes"`)
+	_, err = ParseNotification("email:ops@localhost@sometimes")
+	assert.ErrorContains(t, err, `recipient "ops@localhost@sometimes" is not an address`)
+}
+
+func TestNotificationMatches(t *testing.T) {
+	defaults := Notification{Type: NotifyDesktop}
+	assert.True(t, defaults.Matches(OutcomeTimeout, false))
+	assert.False(t, defaults.Matches(OutcomeSuccess, true), "changed is opt-in")
+
+	changed := Notification{Type: NotifyDesktop, On: []string{EventChanged}}
+	assert.True(t, changed.Matches(OutcomeSuccess, true))
+	assert.False(t, changed.Matches(OutcomeSuccess, false))
+}
+
+func TestStateRecordKeepsOutputHash(t *testing.T) {
+	s := NewStateStore(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, s.Record("r", RunRecord{LastStatus: OutcomeSuccess, OutputHash: "abc"}))
+	require.NoError(t, s.Record("r", RunRecord{LastStatus: OutcomeFailed}))
+	rec, _, err := s.Get("r")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", rec.OutputHash)
+}
+
+func TestParseTrigger(t *testing.T) {
+	tr, err := ParseTrigger("file:./docs@*.md,*.txt")
+	require.NoError(t, err)
+	assert.Equal(t, Trigger{Type: TriggerFile, Paths: []string{"./docs"}, Include: []string{"*.md", "*.txt"}}, tr)
+
+	tr, err = ParseTrigger("routine:build@success,failed")
+	require.NoError(t, err)
+	assert.Equal(t, Trigger{Type: TriggerRoutine, Routine: "build", On: []string{OutcomeSuccess, OutcomeFailed}}, tr)
+
+	tr, err = ParseTrigger("system:network-up")
+	require.NoError(t, err)
+	assert.Equal(t, "on network-up", tr.Describe())
+
+	tr, err = ParseTrigger("webhook")
+	require.NoError(t, err)
+	assert.Len(t, tr.Token, 26, "a webhook trigger without a token gets a random one")
+	other, err := ParseTrigger("webhook")
+	require.NoError(t, err)
+	assert.NotEqual(t, tr.Token, other.Token)
+
+	tr, err = ParseTrigger("webhook:s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, Trigger{Type: TriggerWebhook, Token: "s3cret"}, tr)
+
+	for spec, want := range map[string]string{
+		"file":              "needs at least one path",
+		"file:x@[":          "invalid file trigger glob",
+		"routine:Bad ID":    "needs an upstream routine id",
+		"system:reboot":     `unknown system event "reboot"`,
+		"cron:* * * * *":    `unknown trigger type "cron"`,
+		"routine:a@skipped": `unknown routine trigger outcome "skipped"`,
+	} {
+		_, err := ParseTrigger(spec)
+		assert.ErrorContains(t, err, want, spec)
+	}
+}
+
+func TestRoutineValidateRequiresWebhookToken(t *testing.T) {
+	r := Routine{ID: "hook", Prompt: "p", Triggers: []Trigger{{Type: TriggerWebhook}}}
+	assert.ErrorContains(t, r.Validate(), "webhook trigger needs a token")
+
+	r.Triggers[0].Token = "s3cret"
+	assert.NoError(t, r.Validate())
+}
+
+func TestRoutineValidateRejectsSelfTrigger(t *testing.T) {
+	r := Routine{ID: "loop", Prompt: "p", Triggers: []Trigger{{Type: TriggerRoutine, Routine: "loop"}}}
+	assert.ErrorContains(t, r.Validate(), "cannot trigger itself")
+}
+
+func TestTriggerMatchesPath(t *testing.T) {
+	tr := Trigger{Type: TriggerFile, Include: []string{"*.go", "docs/*"}, Exclude: []string{"*_test.go"}}
+	assert.True(t, tr.MatchesPath("main.go"))
+	assert.True(t, tr.MatchesPath("pkg/main.go"), "globs match the base name")
+	assert.True(t, tr.MatchesPath("docs/index.md"), "globs match the relative path")
+	assert.False(t, tr.MatchesPath("main_test.go"))
+	assert.False(t, tr.MatchesPath("README.md"))
+	assert.True(t, Trigger{}.MatchesPath("anything"), "no include filter matches every file")
+	assert.Equal(t, DefaultTriggerDebounce, tr.DebounceDuration())
+}
+
+func TestRenderPrompt(t *testing.T) {
+	event := &PromptData{Trigger: TriggerEvent{Type: TriggerFile, Paths: []string{"a.go", "b.go"}}}
+	got, err := RenderPrompt(`Review {{join .Trigger.Paths ", "}} ({{.Trigger.Type}})`, event)
+	require.NoError(t, err)
+	assert.Equal(t, "Review a.go, b.go (file)", got)
+
+	got, err = RenderPrompt("Body: {{.Trigger.Body}}", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Body: {{.Trigger.Body}}", got, "without data the prompt is left as is")
+
+	got, err = RenderPrompt(`Changes in {{.Params.repo}} on {{.GitBranch}} since {{.LastRunAt | default "1 week ago"}}`, &PromptData{
+		GitBranch: "main",
+		Params:    map[string]string{"repo": "api"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Changes in api on main since 1 week ago", got)
+
+	got, err = RenderPrompt(`{{.Steps.build.Outcome}}: {{(index .Steps "unit-tests").Output}}`, &PromptData{Steps: map[string]StepOutput{
+		"build":      {Outcome: OutcomeSuccess},
+		"unit-tests": {Outcome: OutcomeFailed, Output: "2 failures"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "success: 2 failures", got)
+
+	_, err = RenderPrompt("{{.Trigger.Nope}}", event)
+	assert.ErrorContains(t, err, "rendering routine prompt")
+}
+
+func TestRoutineValidateSteps(t *testing.T) {
+	workflow := func(steps ...Step) Routine { return Routine{ID: "wf", Steps: steps} }
+	tests := []struct {
+		name    string
+		r       Routine
+		wantErr string
+	}{
+		{name: "fan-out fan-in", r: workflow(
+			Step{ID: "a", Prompt: "a"},
+			Step{ID: "b", Routine: "other", Needs: []string{"a"}},
+			Step{ID: "c", Prompt: "c", Needs: []string{"a"}},
+			Step{ID: "d", Prompt: "d", Needs: []string{"b", "c"}, If: IfAlways},
+		)},
+		{name: "no prompt or routine", r: workflow(Step{ID: "a"}), wantErr: "needs a routine or a prompt"},
+		{name: "both", r: workflow(Step{ID: "a", Prompt: "p", Routine: "x"}), wantErr: "both"},
+		{name: "itself", r: workflow(Step{ID: "a", Routine: "wf"}), wantErr: "its own workflow"},
+		{name: "duplicate", r: workflow(Step{ID: "a", Prompt: "p"}, Step{ID: "a", Prompt: "p"}), wantErr: "duplicate step id"},
+		{name: "unknown need", r: workflow(Step{ID: "a", Prompt: "p", Needs: []string{"z"}}), wantErr: "unknown step"},
+		{name: "failed without needs", r: workflow(Step{ID: "a", Prompt: "p", If: IfFailed}), wantErr: "needs at least one step"},
+		{name: "bad condition", r: workflow(Step{ID: "a", Prompt: "p", If: "sometimes"}), wantErr: "unknown condition"},
+		{name: "cycle", r: workflow(
+			Step{ID: "a", Prompt: "p", Needs: []string{"b"}},
+			Step{ID: "b", Prompt: "p", Needs: []string{"a"}},
+		), wantErr: "cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.r.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestStepOrderAndConditions(t *testing.T) {
+	r := Routine{ID: "wf", Steps: []Step{
+		{ID: "report", Prompt: "p", Needs: []string{"build"}},
+		{ID: "build", Prompt: "p"},
+		{ID: "lint", Prompt: "p"},
+	}}
+	order, err := r.StepOrder()
+	require.NoError(t, err)
+	ids := make([]string, 0, len(order))
+	for _, step := range order {
+		ids = append(ids, step.ID)
+	}
+	assert.Equal(t, []string{"build", "lint", "report"}, ids)
+
+	outcomes := []string{OutcomeSuccess, OutcomeTimeout}
+	assert.False(t, Step{}.ShouldRun(outcomes))
+	assert.True(t, Step{}.ShouldRun([]string{OutcomeSuccess}))
+	assert.True(t, Step{If: IfFailed}.ShouldRun(outcomes))
+	assert.False(t, Step{If: IfFailed}.ShouldRun([]string{OutcomeSuccess, OutcomeSkipped}), "a skipped step is not a failure")
+	assert.True(t, Step{If: IfTimeout}.ShouldRun(outcomes))
+	assert.True(t, Step{If: IfAlways}.ShouldRun([]string{OutcomeSkipped}))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var none *RetryPolicy
+	assert.Equal(t, 1, none.Attempts())
+	assert.False(t, none.Retryable(OutcomeFailed))
+
+	p := &RetryPolicy{MaxAttempts: 4, Backoff: "10s", MaxBackoff: "25s"}
+	assert.Equal(t, 4, p.Attempts())
+	assert.True(t, p.Retryable(OutcomeFailed))
+	assert.True(t, p.Retryable(OutcomeTimeout))
+	assert.False(t, p.Retryable(OutcomeTokenExceeded), "by default only failures and timeouts are retried")
+	assert.False(t, p.Retryable(OutcomeSuccess))
+	assert.Equal(t, []time.Duration{10 * time.Second, 20 * time.Second, 25 * time.Second},
+		[]time.Duration{p.Delay(1), p.Delay(2), p.Delay(3)})
+	assert.Equal(t, DefaultRetryBackoff, (&RetryPolicy{}).Delay(1))
+
+	assert.NoError(t, p.Validate())
+	assert.Error(t, (&RetryPolicy{Backoff: "soon"}).Validate())
+	assert.Error(t, (&RetryPolicy{On: []string{OutcomeSuccess}}).Validate())
+	assert.Error(t, Routine{ID: "r", Prompt: "p", DisableAfter: -1}.Validate())
+}
+
+func TestQuietHoursContains(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2026, 1, 1, hour, minute, 0, 0, time.Local) }
+
+	overnight, err := ParseQuietHours("22:00-07:00")
+	require.NoError(t, err)
+	assert.True(t, overnight.Contains(at(23, 30)))
+	assert.True(t, overnight.Contains(at(6, 59)))
+	assert.False(t, overnight.Contains(at(7, 0)))
+	assert.False(t, overnight.Contains(at(12, 0)))
+
+	lunch, err := ParseQuietHours("12:00-13:00")
+	require.NoError(t, err)
+	assert.True(t, lunch.Contains(at(12, 15)))
+	assert.False(t, lunch.Contains(at(13, 15)))
+
+	none, err := ParseQuietHours("")
+	require.NoError(t, err)
+	assert.False(t, none.Contains(at(3, 0)))
+
+	_, err = ParseQuietHours("22:00")
+	assert.Error(t, err)
+	_, err = ParseQuietHours("25:00-07:00")
+	assert.Error(t, err)
+}
+
+func TestStateRecordAttempt(t *testing.T) {
+	s := NewStateStore(t.TempDir() + "/state.json")
+	require.NoError(t, s.RecordAttempt("r", Attempt{Number: 1, Outcome: OutcomeFailed}))
+	require.NoError(t, s.RecordAttempt("r", Attempt{Number: 2, Outcome: OutcomeTimeout}))
+	rec, _, err := s.Get("r")
+	require.NoError(t, err)
+	require.Len(t, rec.Attempts, 2)
+	assert.Equal(t, 0, rec.ConsecutiveFailures, "attempts do not count as failed runs")
+
+	require.NoError(t, s.RecordAttempt("r", Attempt{Number: 1, Outcome: OutcomeSuccess}))
+	rec, _, err = s.Get("r")
+	require.NoError(t, err)
+	assert.Len(t, rec.Attempts, 1, "a new run starts a new attempt list")
+}
+
+func TestResolveParams(t *testing.T) {
+	r := Routine{ID: "changes", Params: []Param{{Name: "repo", Default: "."}, {Name: "since"}}}
+	values, err := r.ResolveParams(map[string]string{"since": "1 week ago"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"repo": ".", "since": "1 week ago"}, values)
+
+	_, err = r.ResolveParams(map[string]string{"branch": "main"})
+	assert.ErrorContains(t, err, "declared: repo, since")
+
+	assert.Error(t, Routine{ID: "r", Prompt: "p", Params: []Param{{Name: "has-dash"}}}.Validate())
+	assert.Error(t, Routine{ID: "r", Prompt: "p", Params: []Param{{Name: "a"}, {Name: "a"}}}.Validate())
+
+	name, value, err := ParseParam("repo=~/src/app=1")
+	require.NoError(t, err)
+	assert.Equal(t, "repo", name)
+	assert.Equal(t, "~/src/app=1", value)
+	_, _, err = ParseParam("repo")
+	assert.Error(t, err)
+}
+
+func TestNormalizeOutput(t *testing.T) {
+	assert.Equal(t, "  a\n\nb", NormalizeOutput("\r\n  a  \r\n\r\n\n\nb\t\n\n"))
+	assert.Equal(t, "", NormalizeOutput(" \n\n"))
+}
+
+func TestDiff(t *testing.T) {
+	assert.Empty(t, Diff("a\nb", "a\nb", "x", "y"))
+	assert.Equal(t, "--- x\n+++ y\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c", Diff("a\nb\nc", "a\nB\nc", "x", "y"))
+	assert.Equal(t, "--- x\n+++ y\n@@ -0,0 +1,1 @@\n+new", Diff("", "new", "x", "y"))
+
+	// Changes far apart get separate hunks with three lines of context.
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12"
+	to := "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve"
+	assert.Equal(t, "--- x\n+++ y\n"+
+		"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n"+
+		"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve", Diff(from, to, "x", "y"))
+}
+
+func TestQueueStoreOrdersAndCoalesces(t *testing.T) {
+	queue := NewQueueStore(filepath.Join(t.TempDir(), "queue.json"))
+	at := time.Date(2026, 6, 21, 0, 0, 0, 0, time.UTC)
+	push := func(run QueuedRun, coalesce bool) bool {
+		_, added, err := queue.Push(run, coalesce)
+		require.NoError(t, err)
+		return added
+	}
+
+	assert.True(t, push(QueuedRun{RoutineID: "low", Trigger: TriggerScheduled, EnqueuedAt: at}, true))
+	assert.True(t, push(QueuedRun{RoutineID: "high", Trigger: TriggerScheduled, Priority: 5, EnqueuedAt: at.Add(time.Minute)}, true))
+	assert.True(t, push(QueuedRun{RoutineID: "later", Trigger: TriggerScheduled, EnqueuedAt: at.Add(2 * time.Minute)}, true))
+	assert.False(t, push(QueuedRun{RoutineID: "low", Trigger: TriggerScheduled, EnqueuedAt: at.Add(3 * time.Minute)}, true), "a waiting scheduled run absorbs the fire")
+	assert.True(t, push(QueuedRun{RoutineID: "low", Trigger: TriggerWebhook, Event: &TriggerEvent{Type: TriggerWebhook}, EnqueuedAt: at.Add(4 * time.Minute)}, false))
+
+	list, err := queue.List()
+	require.NoError(t, err)
+	ids := func(runs []QueuedRun) []string {
+		var out []string
+		for _, run := range runs {
+			out = append(out, run.RoutineID+"/"+run.Trigger)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"high/scheduled", "low/scheduled", "later/scheduled", "low/webhook"}, ids(list))
+
+	require.NoError(t, queue.Start(list[2].ID, at))
+	list, err = queue.List()
+	require.NoError(t, err)
+	assert.Equal(t, "later", list[0].RoutineID, "running runs sort first")
+	assert.True(t, push(QueuedRun{RoutineID: "later", Trigger: TriggerScheduled, EnqueuedAt: at}, true), "a running run does not absorb fires")
+
+	dropped, err := queue.DropRunning()
+	require.NoError(t, err)
+	require.Len(t, dropped, 1)
+	require.NoError(t, queue.Remove(list[1].ID))
+	list, err = queue.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"low/scheduled", "later/scheduled", "low/webhook"}, ids(list))
+}
+
+func TestRoutineValidateCatchUp(t *testing.T) {
+	r := Routine{ID: "r", Prompt: "p", CatchUp: CatchUpAll}
+	assert.NoError(t, r.Validate())
+	r.CatchUp = "sometimes"
+	assert.ErrorContains(t, r.Validate(), "catch_up")
+}
+
+func TestStoreImportResolvesConflictsAndRetargets(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "routines.json"))
+	require.NoError(t, store.Add(Routine{ID: "fetch", Prompt: "mine", Enabled: true}))
+	bundle := NewBundle([]Routine{
+		{ID: "fetch", Prompt: "theirs", Enabled: true, CreatedAt: time.Now()},
+		{ID: "report", Prompt: "summarise", Triggers: []Trigger{{Type: TriggerRoutine, Routine: "fetch"}}},
+	}, time.Now())
+	assert.True(t, bundle.Routines[0].CreatedAt.IsZero(), "export drops machine-local timestamps")
+
+	results, err := store.Import(bundle.Routines, "")
+	require.NoError(t, err)
+	assert.Equal(t, ImportSkipped, results[0].Action)
+	assert.Equal(t, ImportAdded, results[1].Action)
+	got, err := store.Get("fetch")
+	require.NoError(t, err)
+	assert.Equal(t, "mine", got.Prompt)
+
+	_, err = store.Delete("report")
+	require.NoError(t, err)
+	results, err = store.Import(bundle.Routines, ConflictRename)
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{ID: "fetch-2", BundleID: "fetch", Action: ImportRenamed}, results[0])
+	report, err := store.Get("report")
+	require.NoError(t, err)
+	assert.Equal(t, "fetch-2", report.Triggers[0].Routine, "triggers follow the renamed routine")
+
+	results, err = store.Import(bundle.Routines[:1], ConflictOverwrite)
+	require.NoError(t, err)
+	assert.Equal(t, ImportOverwritten, results[0].Action)
+	got, err = store.Get("fetch")
+	require.NoError(t, err)
+	assert.Equal(t, "theirs", got.Prompt)
+
+	_, err = store.Import([]Routine{{ID: "ok", Prompt: "p"}, {ID: "Bad ID", Prompt: "p"}}, "")
+	assert.Error(t, err)
+	assert.False(t, store.IDTaken("ok"), "an invalid bundle imports nothing")
+}
+
+func TestParseBundle(t *testing.T) {
+	bundle, err := ParseBundle([]byte(`{"routines":[{"id":"a","prompt":"p"}]}`))
+	require.NoError(t, err, "a definitions file is a bundle")
+	assert.Equal(t, "a", bundle.Routines[0].ID)
+
+	_, err = ParseBundle([]byte(`{"version":99,"routines":[{"id":"a","prompt":"p"}]}`))
+	assert.ErrorContains(t, err, "newer")
+	_, err = ParseBundle([]byte(`{"version":1}`))
+	assert.Error(t, err)
+}
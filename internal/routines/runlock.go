@@ -39,3 +39,14 @@ func AcquireRunLock(id string) (release func(), err error) {
 		f.Close()
 	}, nil
 }
+
+// RunInProgress reports whether another process holds the routine's execution
+// lock, without keeping it.
+func RunInProgress(id string) bool {
+	release, err := AcquireRunLock(id)
+	if err != nil {
+		return errors.Is(err, ErrRunInProgress)
+	}
+	release()
+	return false
+}
@@ -32,16 +32,24 @@ const (
 // RunRecord is the persisted status of a routine's most recent run plus the
 // daemon-maintained next run time.
 type RunRecord struct {
-	LastRunAt           time.Time `json:"last_run_at,omitempty"`
-	LastStatus          string    `json:"last_status,omitempty"`
-	LastDuration        string    `json:"last_duration,omitempty"`
-	LastTrigger         string    `json:"last_trigger,omitempty"`
-	LastError           string    `json:"last_error,omitempty"`
-	LastSessionLog      string    `json:"last_session_log,omitempty"`
-	LastResultPath      string    `json:"last_result_path,omitempty"`
-	TokensUsed          int       `json:"tokens_used,omitempty"`
+	LastRunAt      time.Time `json:"last_run_at,omitempty"`
+	LastStatus     string    `json:"last_status,omitempty"`
+	LastDuration   string    `json:"last_duration,omitempty"`
+	LastTrigger    string    `json:"last_trigger,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastSessionLog string    `json:"last_session_log,omitempty"`
+	LastResultPath string    `json:"last_result_path,omitempty"`
+	TokensUsed     int       `json:"tokens_used,omitempty"`
+	// OutputHash fingerprints the last successful run's output so the next run
+	// can tell whether it changed.
+	OutputHash string `json:"output_hash,omitempty"`
+	// OutputPath is the stored, normalised output of the last successful run,
+	// which the next run is diffed against.
+	OutputPath          string    `json:"output_path,omitempty"`
 	ConsecutiveFailures int       `json:"consecutive_failures,omitempty"`
 	NextRunAt           time.Time `json:"next_run_at,omitempty"`
+	// Attempts lists the tries of the most recent run when it was retried.
+	Attempts []Attempt `json:"attempts,omitempty"`
 }
 
 // Succeeded reports whether the recorded outcome was a success.
@@ -114,8 +122,8 @@ func (s *StateStore) All() (map[string]RunRecord, error) {
 }
 
 // Record stores the outcome of a run. It derives ConsecutiveFailures from the
-// prior record and preserves the daemon-maintained NextRunAt when the incoming
-// record leaves it zero.
+// prior record and preserves the daemon-maintained NextRunAt and the last
+// OutputHash and OutputPath when the incoming record leaves them unset.
 func (s *StateStore) Record(id string, rec RunRecord) error {
 	return withLock(s.lockPath(), func() error {
 		file, err := s.load()
@@ -131,6 +139,12 @@ func (s *StateStore) Record(id string, rec RunRecord) error {
 		if rec.NextRunAt.IsZero() {
 			rec.NextRunAt = prior.NextRunAt
 		}
+		if rec.OutputHash == "" {
+			rec.OutputHash = prior.OutputHash
+		}
+		if rec.OutputPath == "" {
+			rec.OutputPath = prior.OutputPath
+		}
 		file.Runs[id] = rec
 		return writeJSONAtomic(s.path, file)
 	})
@@ -151,6 +165,25 @@ func (s *StateStore) SetNextRun(id string, next time.Time) error {
 	})
 }
 
+// RecordAttempt stores one attempt of an in-progress run, so retries show up
+// while the run backs off. The first attempt starts a new list; Record then
+// replaces it with the finished run's attempts.
+func (s *StateStore) RecordAttempt(id string, attempt Attempt) error {
+	return withLock(s.lockPath(), func() error {
+		file, err := s.load()
+		if err != nil {
+			return err
+		}
+		rec := file.Runs[id]
+		if attempt.Number <= 1 {
+			rec.Attempts = nil
+		}
+		rec.Attempts = append(rec.Attempts, attempt)
+		file.Runs[id] = rec
+		return writeJSONAtomic(s.path, file)
+	})
+}
+
 // Delete removes a routine's run record.
 func (s *StateStore) Delete(id string) error {
 	return withLock(s.lockPath(), func() error {
@@ -0,0 +1,289 @@
+package routines
+
+import (
+	"crypto/rand"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Event-driven run triggers, alongside TriggerManual and TriggerScheduled. They
+// name both a Trigger's Type and the trigger recorded for the run it fires.
+const (
+	TriggerFile    = "file"
+	TriggerWebhook = "webhook"
+	TriggerRoutine = "routine"
+	TriggerSystem  = "system"
+)
+
+// System events a TriggerSystem trigger can fire on.
+const (
+	// SystemLogin fires when the daemon starts, which its service unit does at
+	// login.
+	SystemLogin = "login"
+	// SystemNetworkUp fires when a non-loopback network interface comes up.
+	SystemNetworkUp = "network-up"
+	// SystemWake fires after the machine resumes from sleep.
+	SystemWake = "wake"
+)
+
+// DefaultTriggerDebounce is how long a file trigger waits for changes to settle
+// before firing.
+const DefaultTriggerDebounce = 2 * time.Second
+
+// Trigger fires a routine on an event rather than a cron schedule. A routine can
+// have any number of triggers in addition to (or instead of) its Schedule.
+type Trigger struct {
+	Type string `json:"type"` // file | webhook | routine | system
+
+	// Paths are the files or directories a file trigger watches. Directories
+	// are watched recursively when Recursive is set.
+	Paths     []string `json:"paths,omitempty"`
+	Recursive bool     `json:"recursive,omitempty"`
+	// Include and Exclude are glob filters matched against a changed file's base
+	// name and its path relative to the watched directory. An empty Include
+	// matches every file.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// Debounce is a Go duration to wait for changes to settle; empty = 2s.
+	Debounce string `json:"debounce,omitempty"`
+
+	// Routine is the upstream routine whose completion fires a routine trigger,
+	// and On the upstream outcomes that count; empty = success.
+	Routine string   `json:"routine,omitempty"`
+	On      []string `json:"on,omitempty"`
+
+	// Event is the system event that fires a system trigger.
+	Event string `json:"event,omitempty"`
+
+	// Token must be presented by webhook callers as a bearer token or in the
+	// X-Agent-Token header. A webhook trigger needs one: the endpoint is plain
+	// HTTP on loopback, so without it any web page the user visits could fire
+	// the routine.
+	Token string `json:"token,omitempty"`
+}
+
+// DebounceDuration returns the trigger's debounce, applying the default.
+func (t Trigger) DebounceDuration() time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(t.Debounce)); err == nil && d > 0 {
+		return d
+	}
+	return DefaultTriggerDebounce
+}
+
+// UpstreamOutcomes returns the upstream outcomes that fire a routine trigger.
+func (t Trigger) UpstreamOutcomes() []string {
+	if len(t.On) == 0 {
+		return []string{OutcomeSuccess}
+	}
+	return t.On
+}
+
+// MatchesPath reports whether a changed file passes the include and exclude
+// filters. rel is the path relative to the watched root.
+func (t Trigger) MatchesPath(rel string) bool {
+	base := filepath.Base(rel)
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(pattern, base); ok {
+				return true
+			}
+			if ok, _ := filepath.Match(pattern, filepath.ToSlash(rel)); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if matches(t.Exclude) {
+		return false
+	}
+	return len(t.Include) == 0 || matches(t.Include)
+}
+
+// Describe renders the trigger for list and show displays.
+func (t Trigger) Describe() string {
+	switch t.Type {
+	case TriggerFile:
+		desc := "file " + strings.Join(t.Paths, ", ")
+		if len(t.Include) > 0 {
+			desc += " (" + strings.Join(t.Include, ", ") + ")"
+		}
+		return desc
+	case TriggerRoutine:
+		return fmt.Sprintf("after %s %s", t.Routine, strings.Join(t.UpstreamOutcomes(), "/"))
+	case TriggerSystem:
+		return "on " + t.Event
+	default:
+		return t.Type
+	}
+}
+
+// Validate checks that the trigger has a known type and the fields it needs.
+func (t Trigger) Validate() error {
+	switch t.Type {
+	case TriggerFile:
+		if len(t.Paths) == 0 {
+			return fmt.Errorf("file trigger needs at least one path")
+		}
+		for _, pattern := range slices.Concat(t.Include, t.Exclude) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid file trigger glob %q: %w", pattern, err)
+			}
+		}
+		if debounce := strings.TrimSpace(t.Debounce); debounce != "" {
+			if d, err := time.ParseDuration(debounce); err != nil || d < 0 {
+				return fmt.Errorf("invalid file trigger debounce %q", t.Debounce)
+			}
+		}
+	case TriggerWebhook:
+		if strings.TrimSpace(t.Token) == "" {
+			return fmt.Errorf("webhook trigger needs a token for callers to present")
+		}
+	case TriggerRoutine:
+		if !idPattern.MatchString(t.Routine) {
+			return fmt.Errorf("routine trigger needs an upstream routine id, got %q", t.Routine)
+		}
+		for _, outcome := range t.On {
+			switch outcome {
+			case OutcomeSuccess, OutcomeFailed, OutcomeTimeout, OutcomeTokenExceeded:
+			default:
+				return fmt.Errorf("unknown routine trigger outcome %q", outcome)
+			}
+		}
+	case TriggerSystem:
+		switch t.Event {
+		case SystemLogin, SystemNetworkUp, SystemWake:
+		default:
+			return fmt.Errorf("unknown system event %q (want login, network-up or wake)", t.Event)
+		}
+	default:
+		return fmt.Errorf("unknown trigger type %q (want file, webhook, routine or system)", t.Type)
+	}
+	return nil
+}
+
+// ParseTrigger parses the compact CLI form of a trigger:
+//
+//	file:<path>[@<glob>,<glob>...]
+//	webhook[:<token>]
+//	routine:<id>[@<outcome>,<outcome>...]
+//	system:<login|network-up|wake>
+//
+// A webhook trigger given without a token gets a random one.
+func ParseTrigger(spec string) (Trigger, error) {
+	kind, target, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	t := Trigger{Type: strings.TrimSpace(kind)}
+	target, filters, _ := strings.Cut(target, "@")
+	var list []string
+	for _, item := range strings.Split(filters, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	target = strings.TrimSpace(target)
+	switch t.Type {
+	case TriggerFile:
+		if target != "" {
+			t.Paths = []string{target}
+		}
+		t.Include = list
+	case TriggerRoutine:
+		t.Routine = target
+		t.On = list
+	case TriggerSystem:
+		t.Event = target
+	case TriggerWebhook:
+		t.Token = target
+		if t.Token == "" {
+			t.Token = NewWebhookToken()
+		}
+	}
+	if err := t.Validate(); err != nil {
+		return Trigger{}, err
+	}
+	return t, nil
+}
+
+// NewWebhookToken returns a random token for a webhook trigger.
+func NewWebhookToken() string {
+	return rand.Text()
+}
+
+// TriggerEvent is the payload of a fired trigger. It is passed to the run and
+// exposed to the routine prompt as {{.Trigger}}.
+type TriggerEvent struct {
+	Type    string    `json:"type"`
+	FiredAt time.Time `json:"fired_at"`
+	// Paths are the changed files (file triggers).
+	Paths []string `json:"paths,omitempty"`
+	// Body, Headers and Query describe the request (webhook triggers).
+	Body    string            `json:"body,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	// Routine, Outcome and Output describe the upstream run (routine triggers).
+	Routine string `json:"routine,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
+	Output  string `json:"output,omitempty"`
+	// Event names the system event (system triggers).
+	Event string `json:"event,omitempty"`
+	// Params sets declared prompt parameters (manual runs requested through
+	// the daemon).
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// PromptData is the data a routine prompt template is executed with.
+type PromptData struct {
+	// RoutineID is the id of the routine being run.
+	RoutineID string
+	// Date is the run's local date (2006-01-02) and Now its start time
+	// (RFC 3339).
+	Date string
+	Now  string
+	// LastRunAt is the start of the previous run (RFC 3339; empty before the
+	// first run) and LastOutput that run's output.
+	LastRunAt  string
+	LastOutput string
+	// WorkingDir is the directory the run works in and GitBranch its checked-out
+	// branch, if it is a git repository with a branch checked out.
+	WorkingDir string
+	GitBranch  string
+	// Params holds the value of each declared parameter.
+	Params map[string]string
+	// Trigger is the payload of the trigger that fired the run.
+	Trigger TriggerEvent
+	// Steps holds the results of finished workflow steps, keyed by step id.
+	Steps map[string]StepOutput
+}
+
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+	// default returns value, or fallback when value is empty:
+	// {{.LastRunAt | default "1 week ago"}}.
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// RenderPrompt executes the routine prompt as a Go text/template, e.g.
+// "Review {{join .Trigger.Paths \", \"}}". With nil data the prompt is returned
+// unchanged.
+func RenderPrompt(prompt string, data *PromptData) (string, error) {
+	if data == nil || !strings.Contains(prompt, "{{") {
+		return prompt, nil
+	}
+	tmpl, err := template.New("prompt").Funcs(promptFuncs).Option("missingkey=zero").Parse(prompt)
+	if err != nil {
+		return "", fmt.Errorf("invalid routine prompt template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering routine prompt: %w", err)
+	}
+	return b.String(), nil
+}
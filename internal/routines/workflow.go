@@ -0,0 +1,161 @@
+package routines
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// OutcomeSkipped is the outcome of a workflow step whose condition was not met.
+const OutcomeSkipped = "skipped"
+
+// Step conditions. A step runs when its condition holds over the outcomes of the
+// steps it needs.
+const (
+	// IfSuccess runs the step when every needed step succeeded (the default).
+	IfSuccess = "success"
+	// IfFailed runs the step when any needed step did not succeed.
+	IfFailed = "failed"
+	// IfTimeout runs the step when any needed step timed out.
+	IfTimeout = "timeout"
+	// IfAlways runs the step once its needed steps finished, whatever happened.
+	IfAlways = "always"
+)
+
+// Step is one node of a workflow routine: an inline prompt, or another routine
+// run with its own prompt and settings. Steps without a dependency between them
+// run in parallel (fan-out); a step that needs several steps waits for all of
+// them (fan-in).
+type Step struct {
+	ID string `json:"id"`
+	// Exactly one of Routine and Prompt is set. Inline prompts run with the
+	// workflow routine's settings. Both are Go templates that can read the
+	// results of finished steps as {{.Steps.<id>.Output}} and
+	// {{.Steps.<id>.Outcome}}.
+	Routine string `json:"routine,omitempty"`
+	Prompt  string `json:"prompt,omitempty"`
+	// Needs lists the steps that must finish first.
+	Needs []string `json:"needs,omitempty"`
+	// If is the condition on the needed steps' outcomes; empty = success.
+	If string `json:"if,omitempty"`
+}
+
+// Condition returns the step's condition, applying the default.
+func (s Step) Condition() string {
+	if s.If == "" {
+		return IfSuccess
+	}
+	return s.If
+}
+
+// ShouldRun reports whether the step's condition holds for the outcomes of the
+// steps it needs.
+func (s Step) ShouldRun(needed []string) bool {
+	switch s.Condition() {
+	case IfAlways:
+		return true
+	case IfFailed:
+		return slices.ContainsFunc(needed, func(outcome string) bool {
+			return outcome != OutcomeSuccess && outcome != OutcomeSkipped
+		})
+	case IfTimeout:
+		return slices.Contains(needed, OutcomeTimeout)
+	default:
+		return !slices.ContainsFunc(needed, func(outcome string) bool { return outcome != OutcomeSuccess })
+	}
+}
+
+// Describe renders the step for show displays and workflow logs.
+func (s Step) Describe() string {
+	desc := s.ID + ": prompt"
+	if s.Routine != "" {
+		desc = s.ID + ": routine " + s.Routine
+	}
+	if len(s.Needs) > 0 {
+		desc += fmt.Sprintf(" (needs %s, if %s)", strings.Join(s.Needs, ", "), s.Condition())
+	}
+	return desc
+}
+
+// StepOutput is a finished step's result as seen by downstream prompts.
+type StepOutput struct {
+	Outcome string
+	Output  string
+}
+
+// IsWorkflow reports whether the routine runs steps instead of a single prompt.
+func (r Routine) IsWorkflow() bool {
+	return len(r.Steps) > 0
+}
+
+// validateSteps checks step ids, references and conditions, and that the
+// dependencies form a DAG.
+func (r Routine) validateSteps() error {
+	ids := map[string]bool{}
+	for _, step := range r.Steps {
+		if !idPattern.MatchString(step.ID) {
+			return fmt.Errorf("step id %q must match %s", step.ID, idPattern.String())
+		}
+		if ids[step.ID] {
+			return fmt.Errorf("duplicate step id %q", step.ID)
+		}
+		ids[step.ID] = true
+	}
+	for _, step := range r.Steps {
+		hasPrompt := strings.TrimSpace(step.Prompt) != ""
+		switch {
+		case step.Routine == "" && !hasPrompt:
+			return fmt.Errorf("step %q needs a routine or a prompt", step.ID)
+		case step.Routine != "" && hasPrompt:
+			return fmt.Errorf("step %q cannot have both a routine and a prompt", step.ID)
+		case step.Routine == r.ID:
+			return fmt.Errorf("step %q cannot run its own workflow %q", step.ID, r.ID)
+		}
+		for _, need := range step.Needs {
+			if !ids[need] {
+				return fmt.Errorf("step %q needs unknown step %q", step.ID, need)
+			}
+		}
+		switch step.Condition() {
+		case IfSuccess, IfAlways:
+		case IfFailed, IfTimeout:
+			if len(step.Needs) == 0 {
+				return fmt.Errorf("step %q: if %q needs at least one step in needs", step.ID, step.If)
+			}
+		default:
+			return fmt.Errorf("step %q: unknown condition %q (want success, failed, timeout or always)", step.ID, step.If)
+		}
+	}
+	if _, err := r.StepOrder(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// StepOrder returns the steps in an order where every step follows the steps
+// it needs, keeping the definition order otherwise. It fails on a cycle.
+func (r Routine) StepOrder() ([]Step, error) {
+	done := map[string]bool{}
+	order := make([]Step, 0, len(r.Steps))
+	for len(order) < len(r.Steps) {
+		progressed := false
+		for _, step := range r.Steps {
+			if done[step.ID] || slices.ContainsFunc(step.Needs, func(need string) bool { return !done[need] }) {
+				continue
+			}
+			done[step.ID] = true
+			order = append(order, step)
+			progressed = true
+		}
+		if !progressed {
+			var stuck []string
+			for _, step := range r.Steps {
+				if !done[step.ID] {
+					stuck = append(stuck, step.ID)
+				}
+			}
+			return nil, fmt.Errorf("workflow steps form a cycle: %s", strings.Join(stuck, ", "))
+		}
+	}
+	return order, nil
+}
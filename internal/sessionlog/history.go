@@ -24,6 +24,8 @@ type Summary struct {
 	Model       string
 	Cwd         string
 	Command     string
+	RoutineID   string
+	StepID      string
 	Request     string
 	Response    string
 	Error       string
@@ -103,6 +105,47 @@ func readSummary(path string) (Summary, bool, error) {
 	return summary, true, nil
 }
 
+// Load reads a whole session log of any kind: its summary and every record in
+// order. Malformed lines, such as the partial last line of a run still being
+// written, are skipped.
+func Load(path string) (Summary, []Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Summary{}, nil, fmt.Errorf("open session log %s: %w", path, err)
+	}
+	defer f.Close()
+
+	summary := Summary{Path: path}
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), scannerMaxTokenSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			logSkippedSession(path, err)
+			continue
+		}
+		applyRecord(&summary, summaryRecord{
+			RunID:     rec.RunID,
+			Kind:      rec.Kind,
+			Type:      rec.Type,
+			Timestamp: rec.Timestamp,
+			Meta:      rec.Meta,
+			Text:      rec.Text,
+			Error:     rec.Error,
+		})
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return Summary{}, nil, fmt.Errorf("read session log %s: %w", path, err)
+	}
+	return summary, records, nil
+}
+
 type summaryRecordHeader struct {
 	Type RecordType `json:"type"`
 }
@@ -158,6 +201,8 @@ func applyRecord(summary *Summary, rec summaryRecord) {
 		summary.Model = rec.Meta.Model
 		summary.Cwd = rec.Meta.Cwd
 		summary.Command = rec.Meta.Command
+		summary.RoutineID = rec.Meta.RoutineID
+		summary.StepID = rec.Meta.StepID
 		summary.CreatedAt = rec.Meta.CreatedAt
 	}
 	if summary.RunID == "" {
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/laszukdawid/terminal-agent/internal/redact"
 	"github.com/laszukdawid/terminal-agent/internal/utils"
 	"go.uber.org/zap"
 )
@@ -35,6 +36,9 @@ const (
 	RecordProgress     RecordType = "progress"
 	RecordConfirmation RecordType = "confirmation"
 	RecordDeclined     RecordType = "declined"
+	RecordSimulated    RecordType = "simulated"
+	RecordEgress       RecordType = "egress"
+	RecordStep         RecordType = "step"
 	RecordCompleted    RecordType = "completed"
 	RecordFailed       RecordType = "failed"
 )
@@ -53,7 +57,12 @@ type Meta struct {
 	TaskTimeout string `json:"task_timeout,omitempty"`
 	// RoutineID records which routine produced the run for routine runs; empty
 	// for ad-hoc ask/chat/task runs.
-	RoutineID string    `json:"routine_id,omitempty"`
+	RoutineID string `json:"routine_id,omitempty"`
+	// StepID records which workflow step of the routine produced the run.
+	StepID string `json:"step_id,omitempty"`
+	// DryRun marks a routine dry run, whose mutating tool calls were recorded
+	// as simulated instead of run.
+	DryRun    bool      `json:"dry_run,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -74,13 +83,19 @@ type Record struct {
 	Confirmation string         `json:"confirmation,omitempty"`
 	Allowed      *bool          `json:"allowed,omitempty"`
 	Error        string         `json:"error,omitempty"`
+	// StepID names the workflow step a step record reports on.
+	StepID string `json:"step_id,omitempty"`
+	// Redactions counts masked secrets per detector; set on the final
+	// completed or failed record of a run.
+	Redactions map[string]int `json:"redactions,omitempty"`
 }
 
 // Recorder appends Records to one run's JSONL file. It is safe for concurrent use.
 type Recorder struct {
-	runID string
-	kind  string
-	path  string
+	runID    string
+	kind     string
+	path     string
+	redactor *redact.Redactor
 
 	mu  sync.Mutex
 	seq int
@@ -93,6 +108,12 @@ type Recorder struct {
 // New never returns an error: logging must never block a run. If the meta header cannot
 // be written it is logged at warn level and a usable Recorder is still returned.
 func New(dir string, meta Meta) *Recorder {
+	return NewWithRedactor(dir, meta, nil)
+}
+
+// NewWithRedactor is New with secrets masked in every written record, including
+// the meta header's command line. A nil redactor writes records unchanged.
+func NewWithRedactor(dir string, meta Meta, redactor *redact.Redactor) *Recorder {
 	runID := strings.TrimSpace(meta.RunID)
 	if runID == "" {
 		runID = uuid.NewString()
@@ -102,10 +123,17 @@ func New(dir string, meta Meta) *Recorder {
 		meta.CreatedAt = time.Now()
 	}
 
+	// Workflow step logs carry the step id in their name so a run's steps are
+	// easy to tell apart in the routine's log directory.
+	label := meta.Kind
+	if meta.StepID != "" {
+		label += "-" + meta.StepID
+	}
 	r := &Recorder{
-		runID: runID,
-		kind:  meta.Kind,
-		path:  filePath(dir, meta.CreatedAt, meta.Kind, runID),
+		runID:    runID,
+		kind:     meta.Kind,
+		path:     filePath(dir, meta.CreatedAt, label, runID),
+		redactor: redactor,
 	}
 
 	r.Write(Record{Type: RecordMeta, Timestamp: meta.CreatedAt, Meta: &meta})
@@ -129,6 +157,7 @@ func (r *Recorder) Write(rec Record) {
 	if r == nil {
 		return
 	}
+	r.redact(&rec)
 
 	r.mu.Lock()
 	r.seq++
@@ -154,6 +183,22 @@ func (r *Recorder) Write(rec Record) {
 	}
 }
 
+func (r *Recorder) redact(rec *Record) {
+	if r.redactor == nil {
+		return
+	}
+	if rec.Meta != nil {
+		meta := *rec.Meta
+		meta.Command = r.redactor.String(meta.Command)
+		rec.Meta = &meta
+	}
+	rec.Text = r.redactor.String(rec.Text)
+	rec.ToolInput = r.redactor.Map(rec.ToolInput)
+	rec.ToolResult = r.redactor.String(rec.ToolResult)
+	rec.Confirmation = r.redactor.String(rec.Confirmation)
+	rec.Error = r.redactor.String(rec.Error)
+}
+
 // filePath builds {dir}/{timestamp}_{kind}_{shortid}.jsonl.
 func filePath(dir string, createdAt time.Time, kind, runID string) string {
 	shortID := strings.ReplaceAll(runID, "-", "")
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
@@ -16,6 +17,8 @@ import (
 type BashExecutor struct {
 	workDir string
 	output  io.Writer
+	// env holds extra KEY=value variables added to the inherited environment.
+	env []string
 }
 
 type ProcessOptions struct {
@@ -54,6 +57,9 @@ func (b *BashExecutor) ExecContextWithOptions(ctx context.Context, code string,
 	if b.workDir != "" {
 		cmd.Dir = b.workDir
 	}
+	if len(b.env) > 0 {
+		cmd.Env = append(os.Environ(), b.env...)
+	}
 
 	result, err := runProcess(ctx, processCtx, cmd, b.output, opts)
 	if err != nil {
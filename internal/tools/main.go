@@ -4,6 +4,7 @@ import (
 	"maps"
 
 	"github.com/laszukdawid/terminal-agent/internal/config"
+	"github.com/laszukdawid/terminal-agent/internal/memory"
 )
 
 type toolProvider struct {
@@ -30,6 +31,12 @@ func NewToolProvider(config config.Config) ToolProvider {
 	mcpTools := GetMCPTools(mcpFileSchema)
 	maps.Copy(allTools, mcpTools)
 
+	for _, name := range managedDisabledTools() {
+		delete(builtinTools, name)
+		delete(mcpTools, name)
+		delete(allTools, name)
+	}
+
 	return &toolProvider{
 		builtinTools: builtinTools,
 		mcpTools:     mcpTools,
@@ -38,6 +45,17 @@ func NewToolProvider(config config.Config) ToolProvider {
 	}
 }
 
+// managedDisabledTools returns the tools removed by the organisation policy. A
+// policy that fails to load is reported when the task starts, so it is ignored
+// here like an unreadable MCP file.
+func managedDisabledTools() []string {
+	policy, err := config.LoadManagedPolicy()
+	if err != nil {
+		return nil
+	}
+	return policy.DisabledTools
+}
+
 func (tp *toolProvider) GetAllTools() map[string]Tool {
 	return tp.allTools
 }
@@ -61,6 +79,9 @@ func GetAllBuiltinTools(config config.Config) map[string]Tool {
 	fileSearchTool := NewFileSearchTool(workDir)
 	pythonTool := NewPythonTool(workDir)
 	readTool := NewReadTool(workDir)
+	memoryStore := memory.NewMemory(memory.DefaultPath())
+	rememberTool := NewRememberTool(memoryStore, workDir)
+	recallTool := NewRecallTool(memoryStore, workDir)
 
 	tools := map[string]Tool{
 		unixTool.Name():       unixTool,
@@ -68,6 +89,8 @@ func GetAllBuiltinTools(config config.Config) map[string]Tool {
 		fileSearchTool.Name(): fileSearchTool,
 		pythonTool.Name():     pythonTool,
 		readTool.Name():       readTool,
+		rememberTool.Name():   rememberTool,
+		recallTool.Name():     recallTool,
 	}
 
 	websearchTool := NewWebsearchTool()
@@ -9,7 +9,7 @@ import (
 func TestBuiltinToolsIncludeNativeTools(t *testing.T) {
 	tools := GetAllBuiltinTools(config.NewDefaultConfig())
 
-	for _, name := range []string{ToolNameFileEdit, ToolNameFileSearch, ToolNamePython, ToolNameRead, ToolNameWebsearch} {
+	for _, name := range []string{ToolNameFileEdit, ToolNameFileSearch, ToolNamePython, ToolNameRead, ToolNameWebsearch, ToolNameRemember, ToolNameRecall} {
 		if tools[name] == nil {
 			t.Fatalf("expected builtin tool %q to be registered", name)
 		}
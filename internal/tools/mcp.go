@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"maps"
+	"net/url"
 	"os"
 	"time"
 
+	"github.com/laszukdawid/terminal-agent/internal/egress"
 	"github.com/laszukdawid/terminal-agent/internal/utils"
 	mcpClient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	mcpMain "github.com/mark3labs/mcp-go/mcp"
 	"go.uber.org/zap"
 )
@@ -27,12 +30,15 @@ type MCPInput struct {
 	Password    bool   `json:"password,omitempty"`
 }
 
-// MCPServer represents a server configuration
+// MCPServer represents a server configuration. A server is started locally
+// from Command, or reached over streamable HTTP when URL is set.
 type MCPServer struct {
 	Name    string            `json:"name"`
 	Command string            `json:"command"`
 	Args    []string          `json:"args"`
 	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 // MCPTool is a wrapper that adapts an MCP server to the Tool interface
@@ -43,6 +49,9 @@ type MCPTool struct {
 	inputSchema mcpMain.ToolInputSchema
 	client      *mcpClient.Client
 	logger      zap.Logger
+	// host is the remote server's host for HTTP servers; empty for local
+	// stdio servers.
+	host string
 }
 
 func NewMCPTool(name string, description string, inputSchema mcpMain.ToolInputSchema, client *mcpClient.Client) *MCPTool {
@@ -69,6 +78,14 @@ func (t *MCPTool) ExternalFacing() bool {
 	return true
 }
 
+// EgressHosts reports the remote host of an HTTP MCP server.
+func (t *MCPTool) EgressHosts(map[string]any) []string {
+	if t.host == "" {
+		return nil
+	}
+	return []string{t.host}
+}
+
 // Description returns the description of the MCP tool
 func (t *MCPTool) Description() string {
 	return t.description
@@ -93,6 +110,9 @@ func (t *MCPTool) HelpText() string {
 func (t *MCPTool) RunSchema(input map[string]any) (string, error) {
 	logger := *utils.GetLogger()
 	logger.Sugar().Debugf("RunSchema tool '%s' input: %v", t.name, input)
+	if err := egress.Check(t.EgressHosts(input)...); err != nil {
+		return "", err
+	}
 
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -179,24 +199,15 @@ func getServerAllTools(server MCPServer) (map[string]Tool, error) {
 	logger.Debug("getServerAllTools", zap.String("server", server.Name))
 	tools := make(map[string]Tool)
 
-	flatEnv := make([]string, 0, len(server.Env))
-	for key, value := range server.Env {
-		flatEnv = append(flatEnv, fmt.Sprintf("%s=%s", key, value))
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
 	// Pool server for all tools
-	c, err := mcpClient.NewStdioMCPClient(
-		server.Command,
-		flatEnv,
-		server.Args...,
-	)
+	c, host, err := newMCPClient(ctx, server)
 	if err != nil {
-		return nil, fmt.Errorf("error creating MCP client: %w", err)
+		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	initRequest := mcpMain.InitializeRequest{}
 	initRequest.Params.ProtocolVersion = mcpMain.LATEST_PROTOCOL_VERSION
 	initRequest.Params.ClientInfo = mcpMain.Implementation{Name: server.Command, Version: "0.1"}
@@ -225,8 +236,41 @@ func getServerAllTools(server MCPServer) (map[string]Tool, error) {
 			description: tool.Description,
 			inputSchema: tool.InputSchema,
 			client:      c,
+			host:        host,
 		}
 	}
 
 	return tools, nil
 }
+
+// newMCPClient connects to a server and returns the remote host for HTTP
+// servers. HTTP servers are checked against the egress policy first.
+func newMCPClient(ctx context.Context, server MCPServer) (*mcpClient.Client, string, error) {
+	if server.URL == "" {
+		flatEnv := make([]string, 0, len(server.Env))
+		for key, value := range server.Env {
+			flatEnv = append(flatEnv, fmt.Sprintf("%s=%s", key, value))
+		}
+		c, err := mcpClient.NewStdioMCPClient(server.Command, flatEnv, server.Args...)
+		if err != nil {
+			return nil, "", fmt.Errorf("error creating MCP client: %w", err)
+		}
+		return c, "", nil
+	}
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil || serverURL.Host == "" {
+		return nil, "", fmt.Errorf("invalid MCP server URL %q", server.URL)
+	}
+	if err := egress.Check(serverURL.Host); err != nil {
+		return nil, "", err
+	}
+	c, err := mcpClient.NewStreamableHttpClient(server.URL, transport.WithHTTPHeaders(server.Headers))
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating MCP client: %w", err)
+	}
+	if err := c.Start(ctx); err != nil {
+		return nil, "", fmt.Errorf("error starting MCP client: %w", err)
+	}
+	return c, serverURL.Host, nil
+}
@@ -0,0 +1,291 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/memory"
+)
+
+const (
+	rememberToolDescription = "Save a durable fact learned during this run to long-term memory, so later runs can recall it " +
+		"(e.g. \"this repo runs tests with `task test`, not `go test`\"). Only save facts that will still hold later; " +
+		"not progress notes, guesses or secrets. The user confirms every entry."
+	recallToolDescription = "Look up long-term memory: facts saved by the user or by earlier runs that apply to this directory " +
+		"or routine. Without a query, returns the entries that apply; with one, the best matches first."
+
+	// defaultRecallLimit bounds how many entries recall returns when the
+	// model does not ask for a number.
+	defaultRecallLimit = 10
+)
+
+// RememberTool lets the task agent add entries to long-term memory. Entries
+// record the run and tool that wrote them.
+type RememberTool struct {
+	name        string
+	description string
+	inputSchema map[string]any
+	helpText    string
+	store       memory.Memory
+	workDir     string
+}
+
+func NewRememberTool(store memory.Memory, workDir string) *RememberTool {
+	return &RememberTool{
+		name:        ToolNameRemember,
+		description: rememberToolDescription,
+		inputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"content": map[string]string{
+					"type":        "string",
+					"description": "The fact to remember, as a short self-contained sentence",
+				},
+				"tags": map[string]any{
+					"type":        "array",
+					"items":       map[string]string{"type": "string"},
+					"description": "Optional keywords to find the entry by",
+				},
+				"scope": map[string]any{
+					"type":        "string",
+					"enum":        []string{memory.ScopeProject, memory.ScopeGlobal, memory.ScopeRoutine},
+					"description": "Where the fact applies: project (this working directory and below, default), global (everywhere) or routine (runs of the current routine; always used in routine runs)",
+				},
+			},
+			"required": []string{"content"},
+		},
+		helpText: "Save a fact to long-term memory, scoped to the working directory by default.",
+		store:    store,
+		workDir:  workDir,
+	}
+}
+
+func (t *RememberTool) Name() string {
+	return t.name
+}
+
+// PermissionCategory is write: an entry outlives the run and is added to the
+// prompts of later ones. It names no path inside the workspace, so it is
+// confirmed unless a rule allows it.
+func (t *RememberTool) PermissionCategory() PermissionCategory {
+	return PermissionWrite
+}
+
+func (t *RememberTool) Description() string {
+	return t.description
+}
+
+func (t *RememberTool) InputSchema() map[string]any {
+	return t.inputSchema
+}
+
+func (t *RememberTool) HelpText() string {
+	return t.helpText
+}
+
+func (t *RememberTool) ToolStatus(input map[string]any) string {
+	content := trimmedStringInput(input, "content")
+	if content == "" {
+		return ""
+	}
+	return fmt.Sprintf("Remember(%s)", content)
+}
+
+func (t *RememberTool) Run(input *string) (string, error) {
+	if input == nil {
+		return "", fmt.Errorf("content is required")
+	}
+	return t.RunSchema(map[string]any{"content": *input})
+}
+
+func (t *RememberTool) RunSchema(input map[string]any) (string, error) {
+	dir := memoryWorkDir(t.workDir)
+	return t.RunSchemaWithContext(input, ToolExecutionContext{RootDir: dir, CurrentDir: dir})
+}
+
+func (t *RememberTool) RunSchemaWithContext(input map[string]any, ctx ToolExecutionContext) (string, error) {
+	content := trimmedStringInput(input, "content")
+	if content == "" {
+		return "", fmt.Errorf("content is required")
+	}
+	entry := memory.MemoryEntry{
+		Content: content,
+		Tags:    stringListInput(input, "tags"),
+		Source:  t.name,
+		RunID:   ctx.RunID,
+	}
+
+	scope := trimmedStringInput(input, "scope")
+	if ctx.RoutineID != "" {
+		// A routine run is unattended: what it learns stays with the routine
+		// rather than reaching interactive runs in the project or everywhere.
+		scope = memory.ScopeRoutine
+	}
+	switch scope {
+	case "", memory.ScopeProject:
+		if ctx.RootDir == "" {
+			return "", fmt.Errorf("this run has no working directory; use scope %q", memory.ScopeGlobal)
+		}
+		entry.Project = ctx.RootDir
+	case memory.ScopeRoutine:
+		if ctx.RoutineID == "" {
+			return "", fmt.Errorf("this run is not part of a routine; use scope %q or %q", memory.ScopeProject, memory.ScopeGlobal)
+		}
+		entry.Routine = ctx.RoutineID
+	case memory.ScopeGlobal:
+	default:
+		return "", fmt.Errorf("unknown scope %q; use %s, %s or %s", scope, memory.ScopeProject, memory.ScopeGlobal, memory.ScopeRoutine)
+	}
+
+	saved, err := t.store.AddEntry(entry)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Remembered as #%d (%s).", saved.ID, saved.ScopeLabel()), nil
+}
+
+// RecallTool lets the task agent read the long-term memory that applies to
+// its run.
+type RecallTool struct {
+	name        string
+	description string
+	inputSchema map[string]any
+	helpText    string
+	store       memory.Memory
+	workDir     string
+}
+
+func NewRecallTool(store memory.Memory, workDir string) *RecallTool {
+	return &RecallTool{
+		name:        ToolNameRecall,
+		description: recallToolDescription,
+		inputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]string{
+					"type":        "string",
+					"description": "Words to look for in the entries and their tags; omit to list the entries that apply",
+				},
+				"limit": map[string]string{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum number of entries to return (default %d)", defaultRecallLimit),
+				},
+			},
+		},
+		helpText: "Look up the long-term memory entries that apply to the working directory.",
+		store:    store,
+		workDir:  workDir,
+	}
+}
+
+func (t *RecallTool) Name() string {
+	return t.name
+}
+
+func (t *RecallTool) PermissionCategory() PermissionCategory {
+	return PermissionRead
+}
+
+func (t *RecallTool) Description() string {
+	return t.description
+}
+
+func (t *RecallTool) InputSchema() map[string]any {
+	return t.inputSchema
+}
+
+func (t *RecallTool) HelpText() string {
+	return t.helpText
+}
+
+func (t *RecallTool) ToolStatus(input map[string]any) string {
+	return fmt.Sprintf("Recall(%s)", trimmedStringInput(input, "query"))
+}
+
+func (t *RecallTool) Run(input *string) (string, error) {
+	query := ""
+	if input != nil {
+		query = *input
+	}
+	return t.RunSchema(map[string]any{"query": query})
+}
+
+func (t *RecallTool) RunSchema(input map[string]any) (string, error) {
+	dir := memoryWorkDir(t.workDir)
+	return t.RunSchemaWithContext(input, ToolExecutionContext{RootDir: dir, CurrentDir: dir})
+}
+
+func (t *RecallTool) RunSchemaWithContext(input map[string]any, ctx ToolExecutionContext) (string, error) {
+	limit := defaultRecallLimit
+	if value, ok := integerInput(input, "limit"); ok && value > 0 {
+		limit = value
+	}
+	scope := memory.Query{Dir: ctx.RootDir, Routine: ctx.RoutineID}
+
+	var entries []memory.MemoryEntry
+	var err error
+	if text := trimmedStringInput(input, "query"); text == "" {
+		entries, err = t.store.Relevant(scope, limit)
+	} else {
+		if entries, err = t.store.Search(text); err != nil {
+			return "", err
+		}
+		now := time.Now()
+		entries = slices.DeleteFunc(entries, func(entry memory.MemoryEntry) bool {
+			return entry.Expired(now) || !entry.InScope(scope)
+		})
+		entries = entries[:min(limit, len(entries))]
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "No memory entries found.", nil
+	}
+
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		line := fmt.Sprintf("#%d [%s] %s", entry.ID, entry.ScopeLabel(), entry.Content)
+		if len(entry.Tags) > 0 {
+			line += " (tags: " + strings.Join(entry.Tags, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// memoryWorkDir is the project directory for calls made outside a task run,
+// such as `agent tool exec remember`: the configured working directory, or
+// else the current one.
+func memoryWorkDir(workDir string) string {
+	if workDir != "" {
+		return workDir
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// stringListInput reads a list of strings, as decoded from JSON ([]any) or
+// passed directly ([]string).
+func stringListInput(input map[string]any, key string) []string {
+	switch value := input[key].(type) {
+	case []string:
+		return value
+	case []any:
+		out := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case string:
+		if strings.TrimSpace(value) != "" {
+			return []string{value}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laszukdawid/terminal-agent/internal/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRememberToolScopes(t *testing.T) {
+	store := memory.NewMemory(filepath.Join(t.TempDir(), "memory.jsonl"))
+	tool := NewRememberTool(store, "/work")
+	ctx := ToolExecutionContext{RootDir: "/src/app", CurrentDir: "/src/app/cmd", RunID: "run-1"}
+	routineCtx := ctx
+	routineCtx.RoutineID = "nightly"
+
+	out, err := tool.RunSchemaWithContext(map[string]any{"content": "uses task test"}, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Remembered as #1 (project:/src/app).", out)
+
+	_, err = tool.RunSchemaWithContext(map[string]any{"content": "reports go to ops", "scope": "routine"}, routineCtx)
+	require.NoError(t, err)
+	_, err = tool.RunSchemaWithContext(map[string]any{"content": "prefer rg", "scope": "global", "tags": []any{"Search"}}, ctx)
+	require.NoError(t, err)
+	// A routine run cannot write to the project or global memory.
+	out, err = tool.RunSchemaWithContext(map[string]any{"content": "deploys are safe", "scope": "global"}, routineCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "Remembered as #4 (routine:nightly).", out)
+
+	entries, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, "/src/app", entries[0].Project)
+	assert.Equal(t, "nightly", entries[1].Routine)
+	assert.Equal(t, memory.ScopeGlobal, entries[2].Scope())
+	assert.Equal(t, []string{"search"}, entries[2].Tags)
+	assert.Equal(t, memory.ScopeRoutine, entries[3].Scope())
+	for _, entry := range entries {
+		assert.Equal(t, ToolNameRemember, entry.Source)
+		assert.Equal(t, "run-1", entry.RunID)
+		assert.NotEmpty(t, entry.Timestamp)
+	}
+}
+
+func TestRememberToolRejectsBadInput(t *testing.T) {
+	store := memory.NewMemory(filepath.Join(t.TempDir(), "memory.jsonl"))
+	tool := NewRememberTool(store, "/work")
+
+	_, err := tool.RunSchemaWithContext(map[string]any{"content": "  "}, ToolExecutionContext{RootDir: "/work"})
+	assert.ErrorContains(t, err, "content is required")
+
+	_, err = tool.RunSchemaWithContext(map[string]any{"content": "x", "scope": "routine"}, ToolExecutionContext{RootDir: "/work"})
+	assert.ErrorContains(t, err, "not part of a routine")
+
+	_, err = tool.RunSchemaWithContext(map[string]any{"content": "x", "scope": "team"}, ToolExecutionContext{RootDir: "/work"})
+	assert.ErrorContains(t, err, "unknown scope")
+
+	entries, _ := store.List()
+	assert.Empty(t, entries)
+}
+
+func TestRecallToolReturnsEntriesInScope(t *testing.T) {
+	store := memory.NewMemory(filepath.Join(t.TempDir(), "memory.jsonl"))
+	for _, entry := range []memory.MemoryEntry{
+		{Content: "this repo runs tests with task test", Project: "/src/app", Tags: []string{"testing"}},
+		{Content: "other repo runs tests with make check", Project: "/src/other"},
+		{Content: "prefer rg over grep"},
+		{Content: "old test server is down", ExpiresAt: time.Now().Add(-time.Hour)},
+	} {
+		_, err := store.AddEntry(entry)
+		require.NoError(t, err)
+	}
+	tool := NewRecallTool(store, "/work")
+	ctx := ToolExecutionContext{RootDir: "/src/app", CurrentDir: "/src/app"}
+
+	out, err := tool.RunSchemaWithContext(map[string]any{}, ctx)
+	require.NoError(t, err)
+	lines := strings.Split(out, "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "#1 [project:/src/app] this repo runs tests with task test (tags: testing)", lines[0])
+	assert.Equal(t, "#3 [global] prefer rg over grep", lines[1])
+
+	out, err = tool.RunSchemaWithContext(map[string]any{"query": "tests"}, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "#1 [project:/src/app] this repo runs tests with task test (tags: testing)", out)
+
+	out, err = tool.RunSchemaWithContext(map[string]any{"limit": float64(1)}, ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, out, "\n")
+
+	out, err = tool.RunSchemaWithContext(map[string]any{"query": "kubernetes"}, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "No memory entries found.", out)
+}
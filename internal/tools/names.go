@@ -1,10 +1,13 @@
 package tools
 
 const (
-	ToolNameUnix       = "unix"
-	ToolNameFileEdit   = "file_edit"
-	ToolNameFileSearch = "file_search"
-	ToolNamePython     = "python"
-	ToolNameRead       = "read"
-	ToolNameWebsearch  = "websearch"
+	ToolNameUnix           = "unix"
+	ToolNameFileEdit       = "file_edit"
+	ToolNameFileSearch     = "file_search"
+	ToolNamePython         = "python"
+	ToolNameRead           = "read"
+	ToolNameWebsearch      = "websearch"
+	ToolNameRemember       = "remember"
+	ToolNameRecall         = "recall"
+	ToolNameSemanticSearch = "semantic_search"
 )
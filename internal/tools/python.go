@@ -172,6 +172,9 @@ func (t *PythonTool) RunSchemaContext(ctx context.Context, input map[string]any,
 	cmd := exec.CommandContext(processCtx, commandName, commandArgs...)
 	configureCommandCancellation(cmd)
 	cmd.Dir = normalizedCtx.CurrentDir
+	if len(execCtx.Env) > 0 {
+		cmd.Env = append(os.Environ(), execCtx.Env...)
+	}
 	result, err := runProcess(ctx, processCtx, cmd, execCtx.Output, opts)
 	if err != nil {
 		return result.Output, fmt.Errorf("python execution failed: %w", err)
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/laszukdawid/terminal-agent/internal/index"
+)
+
+const (
+	semanticSearchToolDescription = "Search the project's semantic index for code and docs related to a question, " +
+		"e.g. \"where are session tokens issued\". Returns the best-matching snippets with their file and lines. " +
+		"Use it to find where something lives before reading files; use file_search for exact names."
+
+	// defaultSemanticSearchTopK is how many snippets a search returns when the
+	// model does not ask for a number.
+	defaultSemanticSearchTopK = 5
+	maxSemanticSearchTopK     = 20
+)
+
+// Searcher finds the indexed chunks closest to a query.
+type Searcher interface {
+	Search(ctx context.Context, query string, k int) ([]index.Result, error)
+}
+
+// SemanticSearchTool searches a project index built with `agent index build`.
+// It is only offered to runs in a directory that has one.
+type SemanticSearchTool struct {
+	name        string
+	description string
+	inputSchema map[string]any
+	helpText    string
+	searcher    Searcher
+}
+
+func NewSemanticSearchTool(searcher Searcher) *SemanticSearchTool {
+	return &SemanticSearchTool{
+		name:        ToolNameSemanticSearch,
+		description: semanticSearchToolDescription,
+		inputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]string{
+					"type":        "string",
+					"description": "What to look for, in natural language",
+				},
+				"top_k": map[string]string{
+					"type":        "integer",
+					"description": fmt.Sprintf("Number of snippets to return (default %d, at most %d)", defaultSemanticSearchTopK, maxSemanticSearchTopK),
+				},
+			},
+			"required": []string{"query"},
+		},
+		helpText: "Search the project's semantic index for related code and docs.",
+		searcher: searcher,
+	}
+}
+
+func (t *SemanticSearchTool) Name() string {
+	return t.name
+}
+
+func (t *SemanticSearchTool) PermissionCategory() PermissionCategory {
+	return PermissionRead
+}
+
+func (t *SemanticSearchTool) Description() string {
+	return t.description
+}
+
+func (t *SemanticSearchTool) InputSchema() map[string]any {
+	return t.inputSchema
+}
+
+func (t *SemanticSearchTool) HelpText() string {
+	return t.helpText
+}
+
+func (t *SemanticSearchTool) ToolStatus(input map[string]any) string {
+	return fmt.Sprintf("SemanticSearch(%s)", trimmedStringInput(input, "query"))
+}
+
+func (t *SemanticSearchTool) Run(input *string) (string, error) {
+	if input == nil {
+		return "", fmt.Errorf("query is required")
+	}
+	return t.RunSchema(map[string]any{"query": *input})
+}
+
+func (t *SemanticSearchTool) RunSchema(input map[string]any) (string, error) {
+	return t.RunSchemaContext(context.Background(), input, ToolExecutionContext{})
+}
+
+func (t *SemanticSearchTool) RunSchemaContext(ctx context.Context, input map[string]any, _ ToolExecutionContext) (string, error) {
+	query := trimmedStringInput(input, "query")
+	if query == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	k := defaultSemanticSearchTopK
+	if value, ok := integerInput(input, "top_k"); ok && value > 0 {
+		k = min(value, maxSemanticSearchTopK)
+	}
+
+	results, err := t.searcher.Search(ctx, query, k)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "The index is empty; no results.", nil
+	}
+	return index.FormatResults(results), nil
+}
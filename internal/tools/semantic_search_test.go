@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/laszukdawid/terminal-agent/internal/index"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSearcher struct {
+	query   string
+	k       int
+	results []index.Result
+}
+
+func (s *fakeSearcher) Search(_ context.Context, query string, k int) ([]index.Result, error) {
+	s.query, s.k = query, k
+	return s.results[:min(k, len(s.results))], nil
+}
+
+func TestSemanticSearchTool(t *testing.T) {
+	searcher := &fakeSearcher{results: []index.Result{
+		{Chunk: index.Chunk{Path: "auth/login.go", StartLine: 1, EndLine: 4, Content: "func Login() {}"}, Score: 0.8},
+	}}
+	tool := NewSemanticSearchTool(searcher)
+	assert.Equal(t, PermissionRead, tool.PermissionCategory())
+
+	out, err := tool.RunSchemaContext(context.Background(), map[string]any{"query": " login "}, ToolExecutionContext{})
+	require.NoError(t, err)
+	assert.Equal(t, "login", searcher.query)
+	assert.Equal(t, defaultSemanticSearchTopK, searcher.k)
+	assert.Equal(t, "<context source=\"auth/login.go:1-4\">\nfunc Login() {}\n</context>", out)
+
+	_, err = tool.RunSchemaContext(context.Background(), map[string]any{"query": "login", "top_k": float64(100)}, ToolExecutionContext{})
+	require.NoError(t, err)
+	assert.Equal(t, maxSemanticSearchTopK, searcher.k)
+
+	_, err = tool.RunSchemaContext(context.Background(), map[string]any{}, ToolExecutionContext{})
+	assert.ErrorContains(t, err, "query is required")
+}
+
+func TestSemanticSearchToolEmptyIndex(t *testing.T) {
+	out, err := NewSemanticSearchTool(&fakeSearcher{}).RunSchema(map[string]any{"query": "login"})
+	require.NoError(t, err)
+	assert.Equal(t, "The index is empty; no results.", out)
+}
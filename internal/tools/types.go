@@ -56,6 +56,21 @@ func IsExternalFacing(tool Tool) bool {
 	return ok && external.ExternalFacing()
 }
 
+// EgressTool lets an external-facing tool declare the hosts a call will
+// contact, so the egress policy can be checked and reported before it runs.
+type EgressTool interface {
+	Tool
+	EgressHosts(input map[string]any) []string
+}
+
+// EgressHosts returns the hosts a tool call declares it will contact.
+func EgressHosts(tool Tool, input map[string]any) []string {
+	if egressTool, ok := tool.(EgressTool); ok {
+		return egressTool.EgressHosts(input)
+	}
+	return nil
+}
+
 // PermissionCategory describes a tool's blast radius for confirmation policy.
 type PermissionCategory string
 
@@ -92,6 +107,13 @@ type ToolExecutionContext struct {
 	// Progress is an optional semantic progress sink. It is separate from Output:
 	// progress is for user-facing status updates, not captured command output.
 	Progress func(string)
+	// Env holds extra KEY=value variables for processes the tool starts, e.g.
+	// the egress proxy settings.
+	Env []string
+	// RunID is the session-log run the call belongs to, and RoutineID the
+	// routine being run, if any. Tools that persist state record them.
+	RunID     string
+	RoutineID string
 }
 
 type ContextualTool interface {
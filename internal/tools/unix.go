@@ -209,14 +209,14 @@ func (u *UnixTool) RunSchemaContext(ctx context.Context, input map[string]any, e
 	}
 
 	executor := u.executor
-	if execCtx.CurrentDir != "" || execCtx.Output != nil {
+	if execCtx.CurrentDir != "" || execCtx.Output != nil || len(execCtx.Env) > 0 {
 		workDir := execCtx.CurrentDir
 		if workDir == "" {
 			if bashExecutor, ok := u.executor.(*BashExecutor); ok {
 				workDir = bashExecutor.workDir
 			}
 		}
-		executor = &BashExecutor{workDir: workDir, output: execCtx.Output}
+		executor = &BashExecutor{workDir: workDir, output: execCtx.Output, env: execCtx.Env}
 	}
 	return u.execCodeWithExecutorOptions(ctx, cmd, executor, opts)
 }
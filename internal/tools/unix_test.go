@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"io"
 	"os"
 	"testing"
@@ -180,6 +181,17 @@ func TestUnixToolZeroTimeoutAndMaxBytesMeanUnlimited(t *testing.T) {
 	assert.Equal(t, "abcdef", out)
 }
 
+func TestUnixToolAddsExecutionContextEnv(t *testing.T) {
+	tool := NewUnixTool(nil)
+
+	out, err := tool.RunSchemaContext(context.Background(), map[string]any{
+		"command": `printf "%s" "$HTTPS_PROXY"`,
+	}, ToolExecutionContext{Env: []string{"HTTPS_PROXY=http://127.0.0.1:3128"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:3128", out)
+}
+
 func TestUnixToolRejectsInvalidProcessOptions(t *testing.T) {
 	tool := NewUnixTool(nil)
 
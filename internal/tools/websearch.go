@@ -7,6 +7,7 @@ import (
 
 	tavilygo "github.com/diverged/tavily-go"
 	"github.com/diverged/tavily-go/models"
+	"github.com/laszukdawid/terminal-agent/internal/egress"
 )
 
 const (
@@ -17,6 +18,9 @@ const (
 
 	websearchMissingKeyMessage = "websearch requires TAVILY_KEY environment variable to be set"
 
+	// tavilyHost is the API host the Tavily client contacts.
+	tavilyHost = "api.tavily.com"
+
 	websearchSystemPrompt = `You use the websearch tool to find relevant information based on the user's query.
     The input is provided in English and you provide the search query.
     Your output is a markdown list of the first few results.
@@ -112,6 +116,11 @@ func (w *WebsearchTool) ExternalFacing() bool {
 	return true
 }
 
+// EgressHosts reports the search backend contacted by every search.
+func (w *WebsearchTool) EgressHosts(map[string]any) []string {
+	return []string{tavilyHost}
+}
+
 func (w *WebsearchTool) Description() string {
 	return w.description
 }
@@ -145,6 +154,9 @@ func (w *WebsearchTool) searchTavily(query string) (string, error) {
 	if tavilyKey == "" {
 		return "", fmt.Errorf(websearchMissingKeyMessage)
 	}
+	if err := egress.Check(tavilyHost); err != nil {
+		return "", err
+	}
 
 	searchReq := models.SearchRequest{
 		Query:       query,